@@ -33,6 +33,21 @@ var DefaultCollapseSidebar = false // default value of collapse sidebar
 var SessionSecret = uuid.New().String()
 var CryptoSecret = uuid.New().String()
 
+// CryptoSecretConfigured reports whether CRYPTO_SECRET was set explicitly, only then can data encrypted with it survive a restart
+var CryptoSecretConfigured = false
+
+// TwoFAVerifiedSessionKey marks sessions whose login passed the second factor
+const TwoFAVerifiedSessionKey = "two_fa_verified"
+
+// CryptoSecretPrevious is the master key being rotated out, it is only used to decrypt existing secrets
+var CryptoSecretPrevious = ""
+
+// ChannelSecretEncryptionEnabled controls whether channel keys are envelope encrypted at rest
+var ChannelSecretEncryptionEnabled = false
+
+// TokenKeyPepper is mixed into the stored hash of every API token key, it must stay stable across restarts
+var TokenKeyPepper = ""
+
 var OptionMap map[string]string
 var OptionMapRWMutex sync.RWMutex
 
@@ -117,6 +132,16 @@ var SyncFrequency int // unit is second
 var BatchUpdateEnabled = false
 var BatchUpdateInterval int
 
+// 异步日志写入：日志先进入内存队列，再由后台协程批量写入
+var LogAsyncEnabled = false
+var LogQueueSize int
+var LogBatchSize int
+var LogFlushInterval int // unit is millisecond
+var LogQueueFullPolicy string
+
+// 用量汇总：消费日志与错误请求按分钟与小时汇总，供用量分析接口查询
+var UsageRollupEnabled = true
+
 var RelayTimeout int // unit is second
 
 var GeminiSafetySetting string
@@ -187,6 +212,17 @@ const (
 	RedemptionCodeStatusUsed     = 3 // also don't use 0
 )
 
+const (
+	SubscriptionPlanStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	SubscriptionPlanStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	SubscriptionStatusActive    = 1 // don't use 0, 0 is the default value!
+	SubscriptionStatusExpired   = 2 // also don't use 0
+	SubscriptionStatusCancelled = 3
+)
+
 const (
 	ChannelStatusUnknown          = 0
 	ChannelStatusEnabled          = 1 // don't use 0, 0 is the default value!
@@ -19,6 +19,11 @@ func GenerateHMAC(data string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// HashTokenKey returns the value stored in the database for an API token key
+func HashTokenKey(key string) string {
+	return GenerateHMACWithKey([]byte(TokenKeyPepper), key)
+}
+
 func Password2Hash(password string) (string, error) {
 	passwordBytes := []byte(password)
 	hashedPassword, err := bcrypt.GenerateFromPassword(passwordBytes, bcrypt.DefaultCost)
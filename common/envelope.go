@@ -0,0 +1,175 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 信封加密：每个密文使用独立的随机数据密钥(DEK)加密，DEK 再由 CRYPTO_SECRET 派生的主密钥(KEK)加密。
+// 轮换主密钥时只需重新加密 DEK，格式为 enc:v1:<kek id>:<encrypted dek>:<ciphertext>
+
+const envelopePrefix = "enc:v1:"
+
+var ErrUnknownMasterKey = errors.New("secret was encrypted with an unknown master key")
+
+type masterKey struct {
+	id  string
+	key []byte
+}
+
+func deriveMasterKey(secret string) masterKey {
+	sum := sha256.Sum256([]byte(secret))
+	idSum := sha256.Sum256(sum[:])
+	return masterKey{id: hex.EncodeToString(idSum[:4]), key: sum[:]}
+}
+
+func currentMasterKey() masterKey {
+	return deriveMasterKey(CryptoSecret)
+}
+
+func findMasterKey(id string) (masterKey, bool) {
+	current := currentMasterKey()
+	if current.id == id {
+		return current, true
+	}
+	if CryptoSecretPrevious != "" {
+		previous := deriveMasterKey(CryptoSecretPrevious)
+		if previous.id == id {
+			return previous, true
+		}
+	}
+	return masterKey{}, false
+}
+
+func aesGCMSeal(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func aesGCMOpen(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// IsEncryptedSecret reports whether the value was produced by EncryptSecret
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// EncryptSecret envelope-encrypts the value with the current master key
+func EncryptSecret(plaintext string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	ciphertext, err := aesGCMSeal(dek, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	kek := currentMasterKey()
+	wrappedDek, err := aesGCMSeal(kek.key, dek)
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + kek.id + ":" + base64.RawStdEncoding.EncodeToString(wrappedDek) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+func parseEnvelope(value string) (kekId string, wrappedDek []byte, ciphertext []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("malformed encrypted secret")
+	}
+	wrappedDek, err = base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, err
+	}
+	ciphertext, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return parts[0], wrappedDek, ciphertext, nil
+}
+
+func unwrapDek(kekId string, wrappedDek []byte) ([]byte, error) {
+	kek, ok := findMasterKey(kekId)
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	return aesGCMOpen(kek.key, wrappedDek)
+}
+
+// DecryptSecret returns the plaintext of an encrypted value, values that are not encrypted are returned unchanged
+func DecryptSecret(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+	kekId, wrappedDek, ciphertext, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dek, err := unwrapDek(kekId, wrappedDek)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := aesGCMOpen(dek, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// RewrapSecret re-encrypts the data key of an encrypted value with the current master key,
+// plaintext values are encrypted. The second return value reports whether the value changed.
+func RewrapSecret(value string) (string, bool, error) {
+	if !IsEncryptedSecret(value) {
+		encrypted, err := EncryptSecret(value)
+		return encrypted, err == nil, err
+	}
+	kekId, wrappedDek, ciphertext, err := parseEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	kek := currentMasterKey()
+	if kekId == kek.id {
+		return value, false, nil
+	}
+	dek, err := unwrapDek(kekId, wrappedDek)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	wrappedDek, err = aesGCMSeal(kek.key, dek)
+	if err != nil {
+		return "", false, err
+	}
+	return envelopePrefix + kek.id + ":" + base64.RawStdEncoding.EncodeToString(wrappedDek) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), true, nil
+}
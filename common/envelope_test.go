@@ -0,0 +1,87 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func withCryptoSecrets(t *testing.T, current string, previous string) {
+	t.Helper()
+	oldCurrent, oldPrevious := CryptoSecret, CryptoSecretPrevious
+	CryptoSecret, CryptoSecretPrevious = current, previous
+	t.Cleanup(func() {
+		CryptoSecret, CryptoSecretPrevious = oldCurrent, oldPrevious
+	})
+}
+
+func TestEncryptSecretRoundTrip(t *testing.T) {
+	withCryptoSecrets(t, "current-secret", "")
+	first, err := EncryptSecret("sk-upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := EncryptSecret("sk-upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedSecret(first) || strings.Contains(first, "sk-upstream") {
+		t.Fatalf("expected an envelope without the plaintext, got %s", first)
+	}
+	if first == second {
+		t.Fatal("expected every encryption to use a fresh data key")
+	}
+	plaintext, err := DecryptSecret(first)
+	if err != nil || plaintext != "sk-upstream" {
+		t.Fatalf("expected the plaintext back, got %q, %v", plaintext, err)
+	}
+
+	// 未加密的值原样返回
+	if plaintext, err := DecryptSecret("sk-plain"); err != nil || plaintext != "sk-plain" {
+		t.Fatalf("expected plaintext to pass through, got %q, %v", plaintext, err)
+	}
+
+	tampered := first[:len(first)-2] + "AA"
+	if tampered == first {
+		tampered = first[:len(first)-2] + "BB"
+	}
+	if _, err := DecryptSecret(tampered); err == nil {
+		t.Fatal("expected tampered ciphertext to fail")
+	}
+}
+
+func TestRewrapSecretRotatesMasterKey(t *testing.T) {
+	withCryptoSecrets(t, "old-secret", "")
+	encrypted, err := EncryptSecret("sk-upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后旧主密钥作为 CRYPTO_SECRET_PREVIOUS 仍可解密
+	CryptoSecret, CryptoSecretPrevious = "new-secret", "old-secret"
+	if plaintext, err := DecryptSecret(encrypted); err != nil || plaintext != "sk-upstream" {
+		t.Fatalf("expected the previous master key to decrypt, got %q, %v", plaintext, err)
+	}
+	rewrapped, changed, err := RewrapSecret(encrypted)
+	if err != nil || !changed {
+		t.Fatalf("expected the secret to be re-wrapped, got %v, %v", changed, err)
+	}
+	if _, changed, _ := RewrapSecret(rewrapped); changed {
+		t.Fatal("expected a secret under the current master key to stay unchanged")
+	}
+
+	// 移除旧主密钥后，只有重新加密过的值可以解密
+	CryptoSecretPrevious = ""
+	if plaintext, err := DecryptSecret(rewrapped); err != nil || plaintext != "sk-upstream" {
+		t.Fatalf("expected the re-wrapped secret to decrypt, got %q, %v", plaintext, err)
+	}
+	if _, err := DecryptSecret(encrypted); !errors.Is(err, ErrUnknownMasterKey) {
+		t.Fatalf("expected unknown master key error, got %v", err)
+	}
+
+	// 明文在重新加密时被加密
+	encryptedPlain, changed, err := RewrapSecret("sk-plain")
+	if err != nil || !changed || !IsEncryptedSecret(encryptedPlain) {
+		t.Fatalf("expected plaintext to be encrypted, got %q, %v, %v", encryptedPlain, changed, err)
+	}
+}
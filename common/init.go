@@ -16,13 +16,15 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+
+	ReencryptSecrets = flag.Bool("reencrypt-secrets", false, "re-encrypt channel secrets with the current CRYPTO_SECRET and exit")
 )
 
 func printHelp() {
 	fmt.Println("New API " + Version + " - All in one API service for OpenAI API.")
 	fmt.Println("Copyright (C) 2023 JustSong. All rights reserved.")
 	fmt.Println("GitHub: https://github.com/songquanpeng/one-api")
-	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--reencrypt-secrets] [--version] [--help]")
 }
 
 func InitEnv() {
@@ -50,9 +52,21 @@ func InitEnv() {
 	}
 	if os.Getenv("CRYPTO_SECRET") != "" {
 		CryptoSecret = os.Getenv("CRYPTO_SECRET")
+		CryptoSecretConfigured = true
 	} else {
 		CryptoSecret = SessionSecret
 	}
+	CryptoSecretPrevious = os.Getenv("CRYPTO_SECRET_PREVIOUS")
+	ChannelSecretEncryptionEnabled = GetEnvOrDefaultBool("CHANNEL_SECRET_ENCRYPTION_ENABLED", false)
+	if ChannelSecretEncryptionEnabled && !CryptoSecretConfigured {
+		// 随机生成的密钥在重启后会丢失，加密后的渠道密钥将无法解密
+		log.Fatal("CHANNEL_SECRET_ENCRYPTION_ENABLED requires CRYPTO_SECRET to be set.")
+	}
+	// 令牌哈希使用的 pepper 只能显式配置，不能取自会轮换的 CRYPTO_SECRET 或 SESSION_SECRET，否则轮换后所有令牌失效
+	TokenKeyPepper = os.Getenv("TOKEN_KEY_PEPPER")
+	if TokenKeyPepper == "" {
+		log.Println("WARNING: TOKEN_KEY_PEPPER is not set, token keys are stored as unpeppered hashes. Set it once and never change it.")
+	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
@@ -84,6 +98,13 @@ func InitEnv() {
 	BatchUpdateInterval = GetEnvOrDefault("BATCH_UPDATE_INTERVAL", 5)
 	RelayTimeout = GetEnvOrDefault("RELAY_TIMEOUT", 0)
 
+	LogAsyncEnabled = GetEnvOrDefaultBool("LOG_ASYNC_ENABLED", false)
+	LogQueueSize = GetEnvOrDefault("LOG_QUEUE_SIZE", 10000)
+	LogBatchSize = GetEnvOrDefault("LOG_BATCH_SIZE", 200)
+	LogFlushInterval = GetEnvOrDefault("LOG_FLUSH_INTERVAL", 1000)
+	LogQueueFullPolicy = GetEnvOrDefaultString("LOG_QUEUE_FULL_POLICY", "block")
+	UsageRollupEnabled = GetEnvOrDefaultBool("USAGE_ROLLUP_ENABLED", true)
+
 	// Initialize string variables with GetEnvOrDefaultString
 	GeminiSafetySetting = GetEnvOrDefaultString("GEMINI_SAFETY_SETTING", "BLOCK_NONE")
 	CohereSafetySetting = GetEnvOrDefaultString("COHERE_SAFETY_SETTING", "NONE")
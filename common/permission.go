@@ -0,0 +1,84 @@
+package common
+
+import "strings"
+
+// 管理权限，管理员可通过自定义角色被授予其中的一部分，超级管理员拥有全部权限
+const (
+	PermissionManageChannels   = "channel.manage"
+	PermissionViewChannelKeys  = "channel.key.view"
+	PermissionManageUsers      = "user.manage"
+	PermissionIssueRedemptions = "redemption.manage"
+	PermissionViewAllLogs      = "log.view_all"
+	PermissionManageLogs       = "log.manage"
+	PermissionViewLogPayloads  = "log.payload.view"
+	PermissionManageOptions    = "option.manage"
+	PermissionApprovePayments  = "payment.approve"
+)
+
+var AllPermissions = []string{
+	PermissionManageChannels,
+	PermissionViewChannelKeys,
+	PermissionManageUsers,
+	PermissionIssueRedemptions,
+	PermissionViewAllLogs,
+	PermissionManageLogs,
+	PermissionViewLogPayloads,
+	PermissionManageOptions,
+	PermissionApprovePayments,
+}
+
+// DefaultAdminPermissions 是未分配自定义角色的管理员所拥有的权限，与引入权限模型之前管理员的能力一致
+var DefaultAdminPermissions = []string{
+	PermissionManageChannels,
+	PermissionManageUsers,
+	PermissionIssueRedemptions,
+	PermissionViewAllLogs,
+	PermissionManageLogs,
+}
+
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// rootOnlyOptionKeys 支付与回调地址相关的配置项，修改后会改变收款与回调的去向
+var rootOnlyOptionKeys = map[string]bool{
+	"ServerAddress":         true,
+	"PayAddress":            true,
+	"CustomCallbackAddress": true,
+	"EpayId":                true,
+	"EpayKey":               true,
+	"PayMethods":            true,
+	"Price":                 true,
+	"MinTopUp":              true,
+	"TopupGroupRatio":       true,
+	"StripeApiAddress":      true,
+	"StripeSecretKey":       true,
+	"StripeWebhookSecret":   true,
+	"StripeCurrency":        true,
+	"StripeUnitPrice":       true,
+	"WorkerUrl":             true,
+	"WorkerValidKey":        true,
+}
+
+// rootOnlyOptionPrefixes 登录、两步验证与请求内容加密相关的配置
+var rootOnlyOptionPrefixes = []string{"oidc.", "passkey.", "two_fa.", "payload_capture."}
+
+// IsRootOnlyOption 判断配置项是否只有超级管理员可以修改，拥有 option.manage 权限的管理员不能修改
+// 支付配置、各类密钥与令牌，以及登录与加密相关的配置
+func IsRootOnlyOption(key string) bool {
+	if rootOnlyOptionKeys[key] {
+		return true
+	}
+	for _, prefix := range rootOnlyOptionPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	lower := strings.ToLower(key)
+	return strings.HasSuffix(lower, "secret") || strings.HasSuffix(lower, "key") || strings.HasSuffix(lower, "token")
+}
@@ -0,0 +1,22 @@
+package common
+
+import "testing"
+
+func TestIsRootOnlyOption(t *testing.T) {
+	rootOnly := []string{
+		"PayAddress", "EpayKey", "StripeApiAddress", "StripeWebhookSecret", "ServerAddress",
+		"SMTPToken", "GitHubClientSecret", "TurnstileSecretKey", "log_retention.s3_secret_key",
+		"oidc.client_secret", "oidc.authorization_endpoint", "payload_capture.enabled", "two_fa.require_for_admin",
+	}
+	for _, key := range rootOnly {
+		if !IsRootOnlyOption(key) {
+			t.Errorf("expected %s to be root only", key)
+		}
+	}
+	shared := []string{"SystemName", "Notice", "ModelRatio", "GroupRatio", "RetryTimes", "invoice.company_name", "spend_report.enabled"}
+	for _, key := range shared {
+		if IsRootOnlyOption(key) {
+			t.Errorf("expected %s to be editable with option.manage", key)
+		}
+	}
+}
@@ -325,3 +325,28 @@ func RedisHSetField(key, field string, value interface{}) error {
 	}
 	return nil
 }
+
+// RedisSetNX 仅在 key 不存在时写入，返回是否写入成功，用于分布式锁
+func RedisSetNX(key string, value string, expiration time.Duration) (bool, error) {
+	if DebugEnabled {
+		SysLog(fmt.Sprintf("Redis SETNX: key=%s, value=%s, expiration=%v", key, value, expiration))
+	}
+	ctx := context.Background()
+	return RDB.SetNX(ctx, key, value, expiration).Result()
+}
+
+var redisDelIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisDelIfEqual 仅在 key 的值等于 value 时删除，避免释放已过期后被其他节点重新获取的锁
+func RedisDelIfEqual(key string, value string) error {
+	if DebugEnabled {
+		SysLog(fmt.Sprintf("Redis DEL if equal: key=%s, value=%s", key, value))
+	}
+	ctx := context.Background()
+	return redisDelIfEqualScript.Run(ctx, RDB, []string{key}, value).Err()
+}
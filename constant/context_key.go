@@ -32,4 +32,16 @@ const (
 	ContextKeyUserGroup   ContextKey = "user_group"
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
+
+	ContextKeyUserCreditLimit ContextKey = "user_credit_limit"
+	// ContextKeyUserSubscriptionUntil 用户生效中订阅的最晚到期时间
+	ContextKeyUserSubscriptionUntil ContextKey = "user_subscription_until"
+
+	/* log related keys */
+	ContextKeyPayloadCapture ContextKey = "payload_capture"
+	ContextKeyRequestTags    ContextKey = "request_tags"
+	ContextKeyPriceSchedule  ContextKey = "price_schedule"
+	ContextKeyPriceOverride  ContextKey = "price_override"
+	// ContextKeySubscriptionQuota 本次请求从订阅套餐中扣除的额度
+	ContextKeySubscriptionQuota ContextKey = "subscription_quota"
 )
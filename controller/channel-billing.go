@@ -342,6 +342,12 @@ func updateChannelMoonshotBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelBalance(channel *model.Channel) (float64, error) {
+	// the balance queries below authenticate with the plaintext key, channel is never saved back as a whole
+	key, err := channel.GetKey()
+	if err != nil {
+		return 0, err
+	}
+	channel.Key = key
 	baseURL := constant.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() == "" {
 		channel.BaseURL = &baseURL
@@ -406,6 +412,17 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 	return balance, nil
 }
 
+// refreshChannelBalance 查询并保存渠道余额，同时检查余额变化是否与计算的上游成本相符
+func refreshChannelBalance(channel *model.Channel) (float64, error) {
+	prevBalance, prevUpdatedTime := channel.Balance, channel.BalanceUpdatedTime
+	balance, err := updateChannelBalance(channel)
+	if err != nil {
+		return 0, err
+	}
+	service.CheckChannelBalanceDrift(channel, prevBalance, prevUpdatedTime, balance)
+	return balance, nil
+}
+
 func UpdateChannelBalance(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -423,7 +440,7 @@ func UpdateChannelBalance(c *gin.Context) {
 		})
 		return
 	}
-	balance, err := updateChannelBalance(channel)
+	balance, err := refreshChannelBalance(channel)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -452,7 +469,7 @@ func updateAllChannelsBalance() error {
 		//if channel.Type != common.ChannelTypeOpenAI && channel.Type != common.ChannelTypeCustom {
 		//	continue
 		//}
-		balance, err := updateChannelBalance(channel)
+		balance, err := refreshChannelBalance(channel)
 		if err != nil {
 			continue
 		} else {
@@ -52,6 +52,14 @@ func parseStatusFilter(statusParam string) int {
 	}
 }
 
+// clearChannelSecrets 列表接口不返回渠道密钥与可能加密存储的 Other 字段，编辑渠道时通过 GetChannel 获取明文
+func clearChannelSecrets(channels []*model.Channel) {
+	for _, channel := range channels {
+		channel.Key = ""
+		channel.Other = ""
+	}
+}
+
 func GetAllChannels(c *gin.Context) {
 	p, _ := strconv.Atoi(c.Query("p"))
 	pageSize, _ := strconv.Atoi(c.Query("page_size"))
@@ -149,6 +157,7 @@ func GetAllChannels(c *gin.Context) {
 		typeCounts[r.Type] = r.Count
 	}
 
+	clearChannelSecrets(channelData)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -193,7 +202,15 @@ func FetchUpstreamModels(c *gin.Context) {
 	case constant.ChannelTypeAli:
 		url = fmt.Sprintf("%s/compatible-mode/v1/models", baseURL)
 	}
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	key, err := channel.GetKey()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -342,6 +359,7 @@ func SearchChannels(c *gin.Context) {
 	}
 
 	pagedData := channelData[startIdx:endIdx]
+	clearChannelSecrets(pagedData)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -355,6 +373,42 @@ func SearchChannels(c *gin.Context) {
 	return
 }
 
+// GetChannelKey 返回渠道的明文密钥，需要查看渠道密钥的权限，每次查看都会记录日志
+func GetChannelKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	key, err := channel.GetKey()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("查看了渠道 #%d 的密钥", channel.Id))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"key": key,
+		},
+	})
+}
+
 func GetChannel(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -372,6 +426,14 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.Other, err = channel.GetOther()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -663,6 +725,7 @@ func UpdateChannel(c *gin.Context) {
 		return
 	}
 	channel.Key = ""
+	channel.Other, _ = channel.GetOther()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -671,6 +734,24 @@ func UpdateChannel(c *gin.Context) {
 	return
 }
 
+// ReencryptChannelSecrets re-encrypts all channel secrets with the current master key after CRYPTO_SECRET was rotated
+func ReencryptChannelSecrets(c *gin.Context) {
+	count, err := model.ReencryptChannelSecrets()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.InitChannelCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
 func FetchModels(c *gin.Context) {
 	var req struct {
 		BaseURL string `json:"base_url"`
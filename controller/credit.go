@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreditApplyRequest struct {
+	RequestedLimit int    `json:"requested_limit"`
+	Reason         string `json:"reason"`
+}
+
+type CreditReviewRequest struct {
+	CreditLimit int    `json:"credit_limit"`
+	TermsDays   int    `json:"terms_days"`
+	Remark      string `json:"remark"`
+}
+
+// GetSelfCredit 返回当前用户的账期申请与未付欠款
+func GetSelfCredit(c *gin.Context) {
+	userId := c.GetInt("id")
+	account, err := model.GetCreditAccountByUserId(userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	unpaid, err := model.GetUnpaidCreditQuota(userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled":      system_setting.GetCreditSettings().Enabled,
+			"account":      account,
+			"unpaid_quota": unpaid,
+		},
+	})
+}
+
+func ApplySelfCredit(c *gin.Context) {
+	if !system_setting.GetCreditSettings().Enabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开放账期申请",
+		})
+		return
+	}
+	var req CreditApplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Reason) > 255 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	account, err := model.ApplyCreditAccount(user, req.RequestedLimit, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    account,
+	})
+}
+
+func getCreditStatements(c *gin.Context, userId int) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	status, _ := strconv.Atoi(c.Query("status"))
+	statements, total, err := model.GetCreditStatements(userId, status, pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
+
+func GetSelfCreditStatements(c *gin.Context) {
+	getCreditStatements(c, c.GetInt("id"))
+}
+
+func GetAllCreditStatements(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getCreditStatements(c, userId)
+}
+
+func GetCreditAccounts(c *gin.Context) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	status, _ := strconv.Atoi(c.Query("status"))
+	accounts, total, err := model.GetCreditAccounts(status, pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(accounts)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
+
+// ReviewCreditAccount 审批账期申请，action 为 approve（通过或调整额度）、reject 或 close
+func ReviewCreditAccount(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var req CreditReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Remark) > 255 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	account, err := model.GetCreditAccountById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "账期申请不存在",
+		})
+		return
+	}
+	reviewerId := c.GetInt("id")
+	var content string
+	switch c.Param("action") {
+	case "approve":
+		if req.TermsDays == 0 {
+			req.TermsDays = system_setting.GetCreditSettings().TermsDays
+		}
+		err = model.ApproveCreditAccount(account, req.CreditLimit, req.TermsDays, reviewerId, req.Remark)
+		content = fmt.Sprintf("管理员 %s 开通账期额度 %s，账期 %d 天", c.GetString("username"), common.LogQuota(req.CreditLimit), req.TermsDays)
+	case "reject":
+		err = model.RejectCreditAccount(account, reviewerId, req.Remark)
+		content = fmt.Sprintf("管理员 %s 拒绝了账期申请", c.GetString("username"))
+	case "close":
+		err = model.CloseCreditAccount(account, reviewerId, req.Remark)
+		content = fmt.Sprintf("管理员 %s 关闭了账期额度", c.GetString("username"))
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的操作",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.Remark != "" {
+		content += "，备注：" + req.Remark
+	}
+	model.RecordLog(account.UserId, model.LogTypeManage, content)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// SettleCreditStatement 确认结算单已线下付款，为用户补足欠款
+func SettleCreditStatement(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	statement, err := model.GetCreditStatementById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "结算单不存在",
+		})
+		return
+	}
+	quota, err := model.SettleCreditStatement(statement)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(statement.UserId, model.LogTypeTopup, fmt.Sprintf("管理员 %s 确认账期结算单 #%d 已线下付款，补足额度 %s", c.GetString("username"), statement.Id, common.LogQuota(quota)))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GenerateInvoiceRequest struct {
+	UserId int `json:"user_id"`
+	// Month 账单月份，格式为 2006-01
+	Month string `json:"month"`
+}
+
+func getInvoices(c *gin.Context, userId int) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	invoices, total, err := model.GetInvoices(userId, pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(invoices)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
+
+// downloadInvoice 下载账单，format 为 pdf 时返回 PDF 文件，否则返回 JSON 格式的账单快照
+func downloadInvoice(c *gin.Context, userId int) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	invoice, err := model.GetInvoiceById(id, userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "账单不存在",
+		})
+		return
+	}
+	statement, err := service.GetInvoiceStatement(invoice)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	switch c.DefaultQuery("format", "json") {
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", invoice.Number))
+		c.Data(http.StatusOK, "application/pdf", service.RenderInvoicePDF(statement))
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    statement,
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不支持的格式，仅支持 pdf 与 json",
+		})
+	}
+}
+
+func GetSelfInvoices(c *gin.Context) {
+	getInvoices(c, c.GetInt("id"))
+}
+
+func GetSelfInvoice(c *gin.Context) {
+	downloadInvoice(c, c.GetInt("id"))
+}
+
+func GetAllInvoices(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getInvoices(c, userId)
+}
+
+func GetInvoice(c *gin.Context) {
+	downloadInvoice(c, 0)
+}
+
+// GenerateInvoice 手动为用户生成指定月份的账单，用于补开账单
+func GenerateInvoice(c *gin.Context) {
+	var req GenerateInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserId == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	start, end, err := service.InvoiceMonthRange(req.Month)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的账单月份",
+		})
+		return
+	}
+	if end.Unix() > common.GetTimestamp() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "账单月份尚未结束",
+		})
+		return
+	}
+	invoice, err := service.GenerateInvoice(req.UserId, start, end)
+	if err != nil {
+		message := err.Error()
+		if !errors.Is(err, model.ErrInvoiceExists) {
+			common.SysError(fmt.Sprintf("failed to generate invoice for user %d: %s", req.UserId, message))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	invoice.Content = ""
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoice,
+	})
+}
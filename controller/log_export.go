@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每写出多少行刷新一次响应，避免客户端长时间收不到数据
+const logExportFlushRows = 500
+
+type logExportColumn struct {
+	name      string
+	adminOnly bool
+	value     func(log *model.Log, other map[string]interface{}, channelNames map[int]string) interface{}
+}
+
+func otherField(key string) func(*model.Log, map[string]interface{}, map[int]string) interface{} {
+	return func(_ *model.Log, other map[string]interface{}, _ map[int]string) interface{} {
+		return other[key]
+	}
+}
+
+// logExportColumns 导出的列，Other 中的常用字段被展开为独立列，admin_info 等内部信息不导出
+var logExportColumns = []logExportColumn{
+	{"id", true, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Id }},
+	{"created_at", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.CreatedAt }},
+	{"time", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} {
+		return time.Unix(l.CreatedAt, 0).Format(time.RFC3339)
+	}},
+	{"type", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Type }},
+	{"user_id", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.UserId }},
+	{"username", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Username }},
+	{"token_id", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.TokenId }},
+	{"token_name", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.TokenName }},
+	{"model_name", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.ModelName }},
+	{"group", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Group }},
+	{"channel_id", true, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.ChannelId }},
+	{"channel_name", true, func(l *model.Log, _ map[string]interface{}, names map[int]string) interface{} {
+		return names[l.ChannelId]
+	}},
+	{"quota", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Quota }},
+	{"upstream_cost", true, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.UpstreamCost }},
+	{"prompt_tokens", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.PromptTokens }},
+	{"completion_tokens", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.CompletionTokens }},
+	{"cache_tokens", false, otherField("cache_tokens")},
+	{"cache_creation_tokens", false, otherField("cache_creation_tokens")},
+	{"use_time", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.UseTime }},
+	{"frt", false, otherField("frt")},
+	{"is_stream", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.IsStream }},
+	{"model_ratio", false, otherField("model_ratio")},
+	{"completion_ratio", false, otherField("completion_ratio")},
+	{"cache_ratio", false, otherField("cache_ratio")},
+	{"cache_creation_ratio", false, otherField("cache_creation_ratio")},
+	{"model_price", false, otherField("model_price")},
+	{"group_ratio", false, otherField("group_ratio")},
+	{"user_group_ratio", false, otherField("user_group_ratio")},
+	{"upstream_model_name", true, otherField("upstream_model_name")},
+	{"tags", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Tags }},
+	{"ip", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Ip }},
+	{"content", false, func(l *model.Log, _ map[string]interface{}, _ map[int]string) interface{} { return l.Content }},
+}
+
+func formatLogExportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseLogExportFilter(c *gin.Context) *model.LogExportFilter {
+	filter := &model.LogExportFilter{
+		Username:  c.Query("username"),
+		ModelName: c.Query("model_name"),
+		TokenName: c.Query("token_name"),
+		Group:     c.Query("group"),
+		Tag:       c.Query("tag"),
+	}
+	filter.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	filter.LogType, _ = strconv.Atoi(c.Query("type"))
+	filter.StartTimestamp, _ = strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	filter.EndTimestamp, _ = strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	filter.TokenId, _ = strconv.Atoi(c.Query("token_id"))
+	filter.ChannelId, _ = strconv.Atoi(c.Query("channel"))
+	return filter
+}
+
+// ExportAllLogs 管理员按条件导出日志，format 为 csv 或 jsonl
+func ExportAllLogs(c *gin.Context) {
+	exportLogs(c, parseLogExportFilter(c), true)
+}
+
+// ExportUserLogs 用户导出自己的日志，不包含渠道等管理信息
+func ExportUserLogs(c *gin.Context) {
+	filter := parseLogExportFilter(c)
+	filter.UserId = c.GetInt("id")
+	filter.Username = ""
+	filter.ChannelId = 0
+	exportLogs(c, filter, false)
+}
+
+func exportLogs(c *gin.Context, filter *model.LogExportFilter, isAdmin bool) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不支持的导出格式，仅支持 csv 与 jsonl",
+		})
+		return
+	}
+	var channelNames map[int]string
+	if isAdmin {
+		var err error
+		if channelNames, err = model.GetChannelNames(); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+	columns := make([]logExportColumn, 0, len(logExportColumns))
+	for _, column := range logExportColumns {
+		if isAdmin || !column.adminOnly {
+			columns = append(columns, column)
+		}
+	}
+
+	fileName := fmt.Sprintf("usage-%s.%s", time.Now().Format("20060102150405"), format)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	}
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	csvWriter := csv.NewWriter(c.Writer)
+	encoder := json.NewEncoder(c.Writer)
+	record := make([]string, len(columns))
+	if format == "csv" {
+		for i, column := range columns {
+			record[i] = column.name
+		}
+		_ = csvWriter.Write(record)
+	}
+	rows := 0
+	err := model.IterateLogs(c.Request.Context(), filter, func(log *model.Log) error {
+		other := common.StrToMap(log.Other)
+		if format == "csv" {
+			for i, column := range columns {
+				record[i] = formatLogExportValue(column.value(log, other, channelNames))
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return err
+			}
+		} else {
+			item := make(map[string]interface{}, len(columns))
+			for _, column := range columns {
+				item[column.name] = column.value(log, other, channelNames)
+			}
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		rows++
+		if rows%logExportFlushRows == 0 {
+			csvWriter.Flush()
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	csvWriter.Flush()
+	c.Writer.Flush()
+	if err != nil {
+		// 响应头已经发出，只能中断输出并记录错误
+		common.SysError("failed to export logs: " + err.Error())
+	}
+}
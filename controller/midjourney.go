@@ -74,6 +74,11 @@ func UpdateMidjourneyTaskBulk() {
 				}
 				continue
 			}
+			channelKey, err := midjourneyChannel.GetKey()
+			if err != nil {
+				common.LogError(ctx, err.Error())
+				continue
+			}
 			requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
 
 			body, _ := json.Marshal(map[string]any{
@@ -90,7 +95,7 @@ func UpdateMidjourneyTaskBulk() {
 			// 使用带有超时的 context 创建新的请求
 			req = req.WithContext(ctx)
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("mj-api-secret", midjourneyChannel.Key)
+			req.Header.Set("mj-api-secret", channelKey)
 			resp, err := service.GetHttpClient().Do(req)
 			if err != nil {
 				common.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
@@ -158,7 +163,7 @@ func UpdateMidjourneyTaskBulk() {
 					common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else {
 					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+						err = model.RefundTaskQuota(task.UserId, task.Quota, task.SubscriptionQuota)
 						if err != nil {
 							common.LogError(ctx, "fail to increase user quota: "+err.Error())
 						}
@@ -71,6 +71,9 @@ func GetStatus(c *gin.Context) {
 		"data_export_default_time": common.DataExportDefaultTime,
 		"default_collapse_sidebar": common.DefaultCollapseSidebar,
 		"enable_online_topup":      setting.PayAddress != "" && setting.EpayId != "" && setting.EpayKey != "",
+		"enable_stripe_topup":      setting.StripeSecretKey != "" && setting.StripeWebhookSecret != "",
+		"stripe_unit_price":        setting.StripeUnitPrice,
+		"stripe_currency":          setting.StripeCurrency,
 		"mj_notify_enabled":        setting.MjNotifyEnabled,
 		"chats":                    setting.Chats,
 		"demo_site_enabled":        operation_setting.DemoSiteEnabled,
@@ -87,6 +90,7 @@ func GetStatus(c *gin.Context) {
 		"oidc_enabled":                system_setting.GetOIDCSettings().Enabled,
 		"oidc_client_id":              system_setting.GetOIDCSettings().ClientId,
 		"oidc_authorization_endpoint": system_setting.GetOIDCSettings().AuthorizationEndpoint,
+		"passkey_login":               system_setting.GetPasskeySettings().Enabled,
 		"setup":                       constant.Setup,
 	}
 
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"one-api/common"
 	"one-api/model"
@@ -9,7 +10,9 @@ import (
 	"one-api/setting/console_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/setting/system_setting"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,7 +21,7 @@ func GetOptions(c *gin.Context) {
 	var options []*model.Option
 	common.OptionMapRWMutex.Lock()
 	for k, v := range common.OptionMap {
-		if strings.HasSuffix(k, "Token") || strings.HasSuffix(k, "Secret") || strings.HasSuffix(k, "Key") {
+		if strings.HasSuffix(k, "Token") || strings.HasSuffix(k, "Secret") || strings.HasSuffix(k, "Key") || strings.HasSuffix(k, "secret_key") {
 			continue
 		}
 		options = append(options, &model.Option{
@@ -45,6 +48,13 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
+	if c.GetInt("role") < common.RoleRootUser && common.IsRootOnlyOption(option.Key) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "支付、密钥与登录安全相关的配置只有超级管理员可以修改",
+		})
+		return
+	}
 	switch option.Key {
 	case "GitHubOAuthEnabled":
 		if option.Value == "true" && common.GitHubClientId == "" {
@@ -103,6 +113,30 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "payload_capture.enabled":
+		if option.Value == "true" && !common.CryptoSecretConfigured {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用请求内容采集，请先设置 CRYPTO_SECRET 环境变量，采集的内容只会加密保存",
+			})
+			return
+		}
+	case "payload_capture.max_body_bytes":
+		if maxBytes, _ := strconv.Atoi(option.Value); maxBytes > model.MaxLogPayloadBodyBytes {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("最大保存字节数不能超过 %d", model.MaxLogPayloadBodyBytes),
+			})
+			return
+		}
+	case "invoice.timezone":
+		if _, err := time.LoadLocation(option.Value); err != nil || option.Value == "" {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的账单时区，请填写 IANA 时区名称，例如 UTC、Asia/Shanghai",
+			})
+			return
+		}
 	case "GroupRatio":
 		err = ratio_setting.CheckGroupRatio(option.Value)
 		if err != nil {
@@ -121,6 +155,33 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "price_schedule.timezone":
+		err = ratio_setting.CheckPriceScheduleTimezone(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "price_schedule.off_peak":
+		err = ratio_setting.CheckPriceScheduleOffPeak(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "price_schedule.promotions":
+		err = ratio_setting.CheckPricePromotions(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "console_setting.api_info":
 		err = console_setting.ValidateConsoleSettings(option.Value, "ApiInfo")
 		if err != nil {
@@ -0,0 +1,380 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	passkeyRegistrationSessionKey = "passkey_registration"
+	passkeyLoginSessionKey        = "passkey_login"
+)
+
+func passkeyLoginEnabled() bool {
+	return system_setting.GetPasskeySettings().Enabled
+}
+
+func newWebAuthn() (*webauthn.WebAuthn, error) {
+	settings := system_setting.GetPasskeySettings()
+	rpId := settings.RPID
+	if rpId == "" {
+		serverUrl, err := url.Parse(setting.ServerAddress)
+		if err != nil {
+			return nil, err
+		}
+		rpId = serverUrl.Hostname()
+	}
+	displayName := settings.RPDisplayName
+	if displayName == "" {
+		displayName = common.SystemName
+	}
+	origins := make([]string, 0)
+	for _, origin := range strings.Split(settings.Origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimRight(origin, "/"))
+		}
+	}
+	if len(origins) == 0 {
+		origins = append(origins, strings.TrimRight(setting.ServerAddress, "/"))
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpId,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+	})
+}
+
+func savePasskeySession(c *gin.Context, key string, data *webauthn.SessionData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	session := sessions.Default(c)
+	session.Set(key, string(raw))
+	return session.Save()
+}
+
+// loadPasskeySession 取出并删除会话中的挑战，每个挑战只能使用一次
+func loadPasskeySession(c *gin.Context, key string) (*webauthn.SessionData, error) {
+	session := sessions.Default(c)
+	raw, ok := session.Get(key).(string)
+	if !ok || raw == "" {
+		return nil, errors.New("请求已过期，请重试")
+	}
+	session.Delete(key)
+	if err := session.Save(); err != nil {
+		return nil, err
+	}
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func passkeyError(c *gin.Context, err error) {
+	message := err.Error()
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+		message = protocolErr.Details + ": " + protocolErr.DevInfo
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": false,
+		"message": message,
+	})
+}
+
+func GetSelfPasskeys(c *gin.Context) {
+	credentials, err := model.GetUserPasskeys(c.GetInt("id"))
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    credentials,
+	})
+}
+
+func BeginPasskeyRegistration(c *gin.Context) {
+	if !passkeyLoginEnabled() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通行密钥登录",
+		})
+		return
+	}
+	web, err := newWebAuthn()
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	user, err := model.GetPasskeyUser(c.GetInt("id"))
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	exclusions := make([]protocol.CredentialDescriptor, 0)
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	// 要求可发现凭据，才能在不输入用户名的情况下直接登录
+	creation, sessionData, err := web.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	if err = savePasskeySession(c, passkeyRegistrationSessionKey, sessionData); err != nil {
+		passkeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    creation,
+	})
+}
+
+// FinishPasskeyRegistration 请求体为浏览器返回的凭据，名称通过 name 参数传入
+func FinishPasskeyRegistration(c *gin.Context) {
+	web, err := newWebAuthn()
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	sessionData, err := loadPasskeySession(c, passkeyRegistrationSessionKey)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	userId := c.GetInt("id")
+	user, err := model.GetPasskeyUser(userId)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	credential, err := web.FinishRegistration(user, *sessionData, c.Request)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		name = "Passkey " + strconv.Itoa(len(user.Credentials)+1)
+	}
+	if utf8.RuneCountInString(name) > 64 {
+		name = string([]rune(name)[:64])
+	}
+	if err = model.InsertPasskey(userId, name, credential); err != nil {
+		passkeyError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeSystem, "添加了通行密钥 "+name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteSelfPasskey(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId := c.GetInt("id")
+	if err := model.DeleteUserPasskey(userId, id); err != nil {
+		passkeyError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeSystem, "删除了通行密钥")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// BeginPasskeyLogin 开始无用户名的通行密钥登录
+func BeginPasskeyLogin(c *gin.Context) {
+	if !passkeyLoginEnabled() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通行密钥登录",
+		})
+		return
+	}
+	web, err := newWebAuthn()
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	assertion, sessionData, err := web.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	if err = savePasskeySession(c, passkeyLoginSessionKey, sessionData); err != nil {
+		passkeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    assertion,
+	})
+}
+
+// FinishPasskeyLogin 通行密钥本身即包含持有与用户验证两个因素，登录后视为已完成两步验证
+func FinishPasskeyLogin(c *gin.Context) {
+	if !passkeyLoginEnabled() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通行密钥登录",
+		})
+		return
+	}
+	web, err := newWebAuthn()
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	sessionData, err := loadPasskeySession(c, passkeyLoginSessionKey)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	var passkeyUser *model.PasskeyUser
+	credential, err := web.FinishDiscoverableLogin(func(rawId, userHandle []byte) (webauthn.User, error) {
+		passkeyUser, err = model.GetPasskeyUserByHandle(rawId, userHandle)
+		return passkeyUser, err
+	}, *sessionData, c.Request)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	finishPasskeyAssertion(passkeyUser, credential, c)
+}
+
+// BeginPasskeyTwoFA 使用通行密钥完成密码或第三方登录后的第二步验证，关闭通行密钥后不能再作为第二步验证使用
+func BeginPasskeyTwoFA(c *gin.Context) {
+	if !passkeyLoginEnabled() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通行密钥登录",
+		})
+		return
+	}
+	user, message := getPendingTwoFAUser(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	web, err := newWebAuthn()
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	passkeyUser, err := model.GetPasskeyUser(user.Id)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	if len(passkeyUser.Credentials) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "未添加通行密钥",
+		})
+		return
+	}
+	assertion, sessionData, err := web.BeginLogin(passkeyUser)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	if err = savePasskeySession(c, passkeyLoginSessionKey, sessionData); err != nil {
+		passkeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    assertion,
+	})
+}
+
+func FinishPasskeyTwoFA(c *gin.Context) {
+	if !passkeyLoginEnabled() {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通行密钥登录",
+		})
+		return
+	}
+	user, message := getPendingTwoFAUser(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	web, err := newWebAuthn()
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	sessionData, err := loadPasskeySession(c, passkeyLoginSessionKey)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	passkeyUser, err := model.GetPasskeyUser(user.Id)
+	if err != nil {
+		passkeyError(c, err)
+		return
+	}
+	credential, err := web.FinishLogin(passkeyUser, *sessionData, c.Request)
+	if err != nil {
+		recordFailedTwoFAAttempt(user.Id)
+		passkeyError(c, err)
+		return
+	}
+	finishPasskeyAssertion(passkeyUser, credential, c)
+}
+
+func finishPasskeyAssertion(passkeyUser *model.PasskeyUser, credential *webauthn.Credential, c *gin.Context) {
+	user := passkeyUser.User
+	if user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "用户已被封禁",
+		})
+		return
+	}
+	if credential.Authenticator.CloneWarning {
+		common.SysError("passkey sign counter went backwards for user " + strconv.Itoa(user.Id) + ", the authenticator may be cloned")
+	}
+	if err := model.UpdatePasskeyAfterLogin(user.Id, credential); err != nil {
+		common.SysError("failed to update passkey: " + err.Error())
+	}
+	completeLogin(user, true, c)
+}
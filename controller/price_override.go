@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetPriceOverrides(c *gin.Context) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	overrides, total, err := model.GetPriceOverrides(userId, pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(overrides)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
+
+// GetSelfPriceOverrides 用户查看自己的协议价，包括令牌级的覆盖
+func GetSelfPriceOverrides(c *gin.Context) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	overrides, total, err := model.GetPriceOverrides(c.GetInt("id"), pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	for _, override := range overrides {
+		override.Remark = ""
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(overrides)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
+
+func AddPriceOverride(c *gin.Context) {
+	var override model.PriceOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	override.Id = 0
+	if err := override.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    override,
+	})
+}
+
+func UpdatePriceOverride(c *gin.Context) {
+	var req model.PriceOverride
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	override, err := model.GetPriceOverrideById(req.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// 覆盖所属的用户不允许修改
+	override.TokenId = req.TokenId
+	override.ModelName = req.ModelName
+	override.RatioMultiplier = req.RatioMultiplier
+	override.ModelPrice = req.ModelPrice
+	override.CompletionRatio = req.CompletionRatio
+	override.ExpiredTime = req.ExpiredTime
+	override.Remark = req.Remark
+	if err = override.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    override,
+	})
+}
+
+func DeletePriceOverride(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeletePriceOverrideById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
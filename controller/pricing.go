@@ -4,6 +4,7 @@ import (
 	"one-api/model"
 	"one-api/setting"
 	"one-api/setting/ratio_setting"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -43,6 +44,8 @@ func GetPricing(c *gin.Context) {
 		"data":         pricing,
 		"group_ratio":  groupRatio,
 		"usable_group": usableGroup,
+		// 当前与即将生效的时段价格，倍率需要与分组倍率相乘
+		"price_schedules": ratio_setting.GetPriceScheduleStatus(time.Now()),
 	})
 }
 
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaAdjustmentRequest struct {
+	UserId int    `json:"user_id"`
+	Type   string `json:"type"`
+	Quota  int    `json:"quota"`
+	// LogId 退款时被退款的消费日志
+	LogId  int    `json:"log_id"`
+	Reason string `json:"reason"`
+}
+
+// AdjustUserQuota 管理员增加、扣除或退还用户额度，必须填写原因
+func AdjustUserQuota(c *gin.Context) {
+	var req QuotaAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > 255 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请填写调整原因，且不超过 255 个字符",
+		})
+		return
+	}
+	user, err := model.GetUserById(req.UserId, false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "用户不存在",
+		})
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+	adjustment := &model.QuotaAdjustment{
+		UserId:        user.Id,
+		Type:          req.Type,
+		Quota:         req.Quota,
+		LogId:         req.LogId,
+		Reason:        req.Reason,
+		AdminId:       c.GetInt("id"),
+		AdminUsername: c.GetString("username"),
+	}
+	if err := model.AdjustUserQuota(adjustment, system_setting.GetQuotaAdjustmentSettings().DailyCapPerAdmin); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    adjustment,
+	})
+}
+
+// GetQuotaAdjustments 返回额度调整记录，可按 user_id 过滤
+func GetQuotaAdjustments(c *gin.Context) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	adjustments, total, err := model.GetQuotaAdjustments(userId, pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(adjustments)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetQuotaLedgerEntries 返回额度账本分录，可按 user_id 与 type 过滤
+func GetQuotaLedgerEntries(c *gin.Context) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	entries, total, err := model.GetQuotaLedgerEntries(userId, c.Query("type"), pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(entries)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
+
+// GetQuotaLedgerReport 返回本节点最近一次的余额核对结果
+func GetQuotaLedgerReport(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    service.GetLastQuotaLedgerReport(),
+	})
+}
+
+// ReconcileQuotaLedger 立即核对所有用户的余额与额度账本
+func ReconcileQuotaLedger(c *gin.Context) {
+	report, err := service.ReconcileQuotaLedger()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    report,
+	})
+}
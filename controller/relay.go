@@ -46,6 +46,20 @@ func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode
 		err = relay.TextHelper(c)
 	}
 
+	if err != nil {
+		model.RecordUsageRollup(model.UsageRollupRecord{
+			UserId:     c.GetInt("id"),
+			Username:   c.GetString("username"),
+			TokenId:    c.GetInt("token_id"),
+			TokenName:  c.GetString("token_name"),
+			ModelName:  c.GetString("original_model"),
+			ChannelId:  c.GetInt("channel_id"),
+			Group:      c.GetString("group"),
+			StatusCode: err.StatusCode,
+			Tags:       common.GetContextKeyString(c, constant.ContextKeyRequestTags),
+		})
+	}
+
 	if constant2.ErrorLogEnabled && err != nil {
 		// 保存错误日志到mysql中
 		userId := c.GetInt("id")
@@ -263,7 +277,10 @@ func getChannel(c *gin.Context, group, originalModel string, retryCount int) (*m
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("获取重试渠道失败: %s", err.Error()))
 	}
-	middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+	err = middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+	if err != nil {
+		return nil, err
+	}
 	return channel, nil
 }
 
@@ -398,7 +415,11 @@ func RelayTask(c *gin.Context) {
 		useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
 		c.Set("use_channel", useChannel)
 		common.LogInfo(c, fmt.Sprintf("using channel #%d to retry (remain times %d)", channel.Id, i))
-		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		err = middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		if err != nil {
+			common.LogError(c, err.Error())
+			break
+		}
 
 		requestBody, err := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
@@ -113,7 +113,11 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 	if adaptor == nil {
 		return errors.New("adaptor not found")
 	}
-	resp, err := adaptor.FetchTask(*channel.BaseURL, channel.Key, map[string]any{
+	channelKey, err := channel.GetKey()
+	if err != nil {
+		return err
+	}
+	resp, err := adaptor.FetchTask(*channel.BaseURL, channelKey, map[string]any{
 		"ids": taskIds,
 	})
 	if err != nil {
@@ -161,7 +165,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 			} else {
 				quota := task.Quota
 				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
+					err = model.RefundTaskQuota(task.UserId, quota, task.SubscriptionQuota)
 					if err != nil {
 						common.LogError(ctx, "fail to increase user quota: "+err.Error())
 					}
@@ -143,7 +143,6 @@ func AddToken(c *gin.Context) {
 	cleanToken := model.Token{
 		UserId:             c.GetInt("id"),
 		Name:               token.Name,
-		Key:                key,
 		CreatedTime:        common.GetTimestamp(),
 		AccessedTime:       common.GetTimestamp(),
 		ExpiredTime:        token.ExpiredTime,
@@ -154,6 +153,7 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 	}
+	cleanToken.SetKey(key)
 	err = cleanToken.Insert()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -162,13 +162,72 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	// 明文令牌只在创建时返回一次，数据库中仅保存其哈希
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
+		"data": gin.H{
+			"id":  cleanToken.Id,
+			"key": "sk-" + key,
+		},
 	})
 	return
 }
 
+// RegenerateToken 重新生成令牌密钥，原密钥立即失效，新的明文密钥只在此次响应中返回
+func RegenerateToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	token, err := model.GetTokenByIds(id, c.GetInt("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	regenerateTokenKey(c, token)
+}
+
+// GetChatTokenKey 返回聊天链接使用的专用令牌，每次调用都会重新生成其密钥
+func GetChatTokenKey(c *gin.Context) {
+	token, err := model.GetOrCreateChatToken(c.GetInt("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	regenerateTokenKey(c, token)
+}
+
+func regenerateTokenKey(c *gin.Context, token *model.Token) {
+	key, err := common.GenerateKey()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "生成令牌失败",
+		})
+		common.SysError("failed to generate token key: " + err.Error())
+		return
+	}
+	if err := token.RegenerateKey(key); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"id":  token.Id,
+			"key": "sk-" + key,
+		},
+	})
+}
+
 func DeleteToken(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	userId := c.GetInt("id")
@@ -3,18 +3,14 @@ package controller
 import (
 	"fmt"
 	"log"
-	"net/url"
 	"one-api/common"
 	"one-api/model"
 	"one-api/service"
 	"one-api/setting"
 	"strconv"
-	"sync"
 	"time"
 
-	"github.com/Calcium-Ion/go-epay/epay"
 	"github.com/gin-gonic/gin"
-	"github.com/samber/lo"
 	"github.com/shopspring/decimal"
 )
 
@@ -25,25 +21,17 @@ type EpayRequest struct {
 }
 
 type AmountRequest struct {
-	Amount    int64  `json:"amount"`
-	TopUpCode string `json:"top_up_code"`
+	Amount        int64  `json:"amount"`
+	TopUpCode     string `json:"top_up_code"`
+	PaymentMethod string `json:"payment_method"`
 }
 
-func GetEpayClient() *epay.Client {
-	if setting.PayAddress == "" || setting.EpayId == "" || setting.EpayKey == "" {
-		return nil
-	}
-	withUrl, err := epay.NewClient(&epay.Config{
-		PartnerID: setting.EpayId,
-		Key:       setting.EpayKey,
-	}, setting.PayAddress)
-	if err != nil {
-		return nil
-	}
-	return withUrl
+func getPayMoney(amount int64, group string) float64 {
+	return getPayMoneyWithPrice(amount, group, setting.Price)
 }
 
-func getPayMoney(amount int64, group string) float64 {
+// getPayMoneyWithPrice 按指定的单价计算支付金额，price 为每美金额度的价格
+func getPayMoneyWithPrice(amount int64, group string, price float64) float64 {
 	dAmount := decimal.NewFromInt(amount)
 
 	if !common.DisplayInCurrencyEnabled {
@@ -57,7 +45,7 @@ func getPayMoney(amount int64, group string) float64 {
 	}
 
 	dTopupGroupRatio := decimal.NewFromFloat(topupGroupRatio)
-	dPrice := decimal.NewFromFloat(setting.Price)
+	dPrice := decimal.NewFromFloat(price)
 
 	payMoney := dAmount.Mul(dPrice).Mul(dTopupGroupRatio)
 
@@ -104,23 +92,20 @@ func RequestEpay(c *gin.Context) {
 	}
 
 	callBackAddress := service.GetCallbackAddress()
-	returnUrl, _ := url.Parse(setting.ServerAddress + "/console/log")
-	notifyUrl, _ := url.Parse(callBackAddress + "/api/user/epay/notify")
 	tradeNo := fmt.Sprintf("%s%d", common.GetRandomString(6), time.Now().Unix())
 	tradeNo = fmt.Sprintf("USR%dNO%s", id, tradeNo)
-	client := GetEpayClient()
-	if client == nil {
+	provider := service.GetPaymentProvider(req.PaymentMethod)
+	if !provider.Enabled() {
 		c.JSON(200, gin.H{"message": "error", "data": "当前管理员未配置支付信息"})
 		return
 	}
-	uri, params, err := client.Purchase(&epay.PurchaseArgs{
-		Type:           req.PaymentMethod,
-		ServiceTradeNo: tradeNo,
-		Name:           fmt.Sprintf("TUC%d", req.Amount),
-		Money:          strconv.FormatFloat(payMoney, 'f', 2, 64),
-		Device:         epay.PC,
-		NotifyUrl:      notifyUrl,
-		ReturnUrl:      returnUrl,
+	result, err := provider.CreateOrder(&service.PaymentOrder{
+		TradeNo:       tradeNo,
+		Name:          fmt.Sprintf("TUC%d", req.Amount),
+		Money:         payMoney,
+		PaymentMethod: req.PaymentMethod,
+		NotifyUrl:     callBackAddress + "/api/user/epay/notify",
+		ReturnUrl:     setting.ServerAddress + "/console/log",
 	})
 	if err != nil {
 		c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
@@ -133,108 +118,50 @@ func RequestEpay(c *gin.Context) {
 		amount = dAmount.Div(dQuotaPerUnit).IntPart()
 	}
 	topUp := &model.TopUp{
-		UserId:     id,
-		Amount:     amount,
-		Money:      payMoney,
-		TradeNo:    tradeNo,
-		CreateTime: time.Now().Unix(),
-		Status:     "pending",
+		UserId:        id,
+		Amount:        amount,
+		Money:         payMoney,
+		TradeNo:       tradeNo,
+		CreateTime:    time.Now().Unix(),
+		Status:        "pending",
+		PaymentMethod: req.PaymentMethod,
 	}
 	err = topUp.Insert()
 	if err != nil {
 		c.JSON(200, gin.H{"message": "error", "data": "创建订单失败"})
 		return
 	}
-	c.JSON(200, gin.H{"message": "success", "data": params, "url": uri})
-}
-
-// tradeNo lock
-var orderLocks sync.Map
-var createLock sync.Mutex
-
-// LockOrder 尝试对给定订单号加锁
-func LockOrder(tradeNo string) {
-	lock, ok := orderLocks.Load(tradeNo)
-	if !ok {
-		createLock.Lock()
-		defer createLock.Unlock()
-		lock, ok = orderLocks.Load(tradeNo)
-		if !ok {
-			lock = new(sync.Mutex)
-			orderLocks.Store(tradeNo, lock)
-		}
-	}
-	lock.(*sync.Mutex).Lock()
-}
-
-// UnlockOrder 释放给定订单号的锁
-func UnlockOrder(tradeNo string) {
-	lock, ok := orderLocks.Load(tradeNo)
-	if ok {
-		lock.(*sync.Mutex).Unlock()
-	}
+	c.JSON(200, gin.H{"message": "success", "data": result.Params, "url": result.Url})
 }
 
 func EpayNotify(c *gin.Context) {
-	params := lo.Reduce(lo.Keys(c.Request.URL.Query()), func(r map[string]string, t string, i int) map[string]string {
-		r[t] = c.Request.URL.Query().Get(t)
-		return r
-	}, map[string]string{})
-	client := GetEpayClient()
-	if client == nil {
-		log.Println("易支付回调失败 未找到配置信息")
-		_, err := c.Writer.Write([]byte("fail"))
-		if err != nil {
-			log.Println("易支付回调写入失败")
-			return
-		}
-	}
-	verifyInfo, err := client.Verify(params)
-	if err == nil && verifyInfo.VerifyStatus {
-		_, err := c.Writer.Write([]byte("success"))
-		if err != nil {
-			log.Println("易支付回调写入失败")
-		}
-	} else {
+	provider := service.GetPaymentProvider(service.PaymentProviderEpay)
+	event, err := provider.VerifyCallback(c)
+	if err != nil {
+		log.Println("易支付回调验证失败: " + err.Error())
 		_, err := c.Writer.Write([]byte("fail"))
 		if err != nil {
 			log.Println("易支付回调写入失败")
 		}
-		log.Println("易支付回调签名验证失败")
 		return
 	}
+	_, err = c.Writer.Write([]byte("success"))
+	if err != nil {
+		log.Println("易支付回调写入失败")
+	}
 
-	if verifyInfo.TradeStatus == epay.StatusTradeSuccess {
-		log.Println(verifyInfo)
-		LockOrder(verifyInfo.ServiceTradeNo)
-		defer UnlockOrder(verifyInfo.ServiceTradeNo)
-		topUp := model.GetTopUpByTradeNo(verifyInfo.ServiceTradeNo)
-		if topUp == nil {
-			log.Printf("易支付回调未找到订单: %v", verifyInfo)
+	if event.Type == service.PaymentEventPaid {
+		log.Println(event)
+		quotaToAdd, err := service.CompletePaymentOrder(provider, event)
+		if err != nil {
+			log.Printf("易支付回调更新订单失败: %v, %v", event, err)
 			return
 		}
-		if topUp.Status == "pending" {
-			topUp.Status = "success"
-			err := topUp.Update()
-			if err != nil {
-				log.Printf("易支付回调更新订单失败: %v", topUp)
-				return
-			}
-			//user, _ := model.GetUserById(topUp.UserId, false)
-			//user.Quota += topUp.Amount * 500000
-			dAmount := decimal.NewFromInt(int64(topUp.Amount))
-			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
-			quotaToAdd := int(dAmount.Mul(dQuotaPerUnit).IntPart())
-			err = model.IncreaseUserQuota(topUp.UserId, quotaToAdd, true)
-			if err != nil {
-				log.Printf("易支付回调更新用户失败: %v", topUp)
-				return
-			}
-			log.Printf("易支付回调更新用户成功 %v", topUp)
-			model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", common.LogQuota(quotaToAdd), topUp.Money))
+		if quotaToAdd > 0 {
+			log.Printf("易支付回调更新用户成功 %v", event)
 		}
 	} else {
-		log.Printf("易支付异常回调: %v", verifyInfo)
+		log.Printf("易支付异常回调: %v", event)
 	}
 }
 
@@ -257,9 +184,108 @@ func RequestAmount(c *gin.Context) {
 		return
 	}
 	payMoney := getPayMoney(req.Amount, group)
+	if req.PaymentMethod == PaymentMethodStripe {
+		payMoney = getPayMoneyWithPrice(req.Amount, group, setting.StripeUnitPrice)
+	}
 	if payMoney <= 0.01 {
 		c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
 	c.JSON(200, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
 }
+
+func GetAllTopUps(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 1 {
+		p = 1
+	}
+	if pageSize < 1 {
+		pageSize = common.ItemsPerPage
+	}
+	topUps, total, err := model.GetAllTopUps(c.Query("status"), (p-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"items":     topUps,
+			"total":     total,
+			"page":      p,
+			"page_size": pageSize,
+		},
+	})
+}
+
+type CompleteTopUpRequest struct {
+	TradeNo string `json:"trade_no"`
+}
+
+// AdminCompleteTopUp 人工确认已到账但未收到回调的订单
+func AdminCompleteTopUp(c *gin.Context) {
+	var req CompleteTopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TradeNo == "" {
+		c.JSON(200, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if err := service.LockOrder(req.TradeNo); err != nil {
+		c.JSON(200, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	defer service.UnlockOrder(req.TradeNo)
+	topUp, quota, err := model.CompleteTopUp(req.TradeNo)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("管理员 %s 确认充值订单 %s，充值金额: %v，支付金额：%f", c.GetString("username"), topUp.TradeNo, common.LogQuota(quota), topUp.Money))
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type RefundTopUpRequest struct {
+	TradeNo string  `json:"trade_no"`
+	Money   float64 `json:"money"`
+}
+
+// AdminRefundTopUp 通过支付渠道为充值订单退款，Stripe 等通过回调通知退款结果的渠道在收到回调后扣回额度
+func AdminRefundTopUp(c *gin.Context) {
+	var req RefundTopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TradeNo == "" || req.Money <= 0 {
+		c.JSON(200, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	topUp, err := service.RefundPaymentOrder(req.TradeNo, req.Money)
+	if err != nil {
+		c.JSON(200, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	model.RecordLog(topUp.UserId, model.LogTypeManage, fmt.Sprintf("管理员 %s 为充值订单 %s 发起退款 %.2f", c.GetString("username"), topUp.TradeNo, req.Money))
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
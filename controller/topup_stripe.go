@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+const PaymentMethodStripe = service.PaymentProviderStripe
+
+type StripeCheckoutRequest struct {
+	Amount    int64  `json:"amount"`
+	TopUpCode string `json:"top_up_code"`
+}
+
+func RequestStripeCheckout(c *gin.Context) {
+	var req StripeCheckoutRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "参数错误"})
+		return
+	}
+	provider := service.GetPaymentProvider(PaymentMethodStripe)
+	if !provider.Enabled() {
+		c.JSON(200, gin.H{"message": "error", "data": "当前管理员未配置 Stripe 支付信息"})
+		return
+	}
+	if req.Amount < getMinTopup() {
+		c.JSON(200, gin.H{"message": "error", "data": fmt.Sprintf("充值数量不能小于 %d", getMinTopup())})
+		return
+	}
+
+	id := c.GetInt("id")
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "获取用户信息失败"})
+		return
+	}
+	payMoney := getPayMoneyWithPrice(req.Amount, user.Group, setting.StripeUnitPrice)
+	if service.MoneyToStripeAmount(payMoney, setting.StripeCurrency) < 1 {
+		c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
+		return
+	}
+
+	tradeNo := fmt.Sprintf("%s%d", common.GetRandomString(6), time.Now().Unix())
+	tradeNo = fmt.Sprintf("USR%dNO%s", id, tradeNo)
+	result, err := provider.CreateOrder(&service.PaymentOrder{
+		TradeNo:   tradeNo,
+		Name:      fmt.Sprintf("TUC%d", req.Amount),
+		Money:     payMoney,
+		Email:     user.Email,
+		ReturnUrl: setting.ServerAddress + "/console/log",
+		CancelUrl: setting.ServerAddress + "/console/topup",
+	})
+	if err != nil {
+		common.SysError("failed to create stripe checkout session: " + err.Error())
+		c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
+		return
+	}
+	amount := req.Amount
+	if !common.DisplayInCurrencyEnabled {
+		dAmount := decimal.NewFromInt(amount)
+		dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+		amount = dAmount.Div(dQuotaPerUnit).IntPart()
+	}
+	topUp := &model.TopUp{
+		UserId:        id,
+		Amount:        amount,
+		Money:         payMoney,
+		TradeNo:       tradeNo,
+		CreateTime:    time.Now().Unix(),
+		Status:        "pending",
+		PaymentMethod: PaymentMethodStripe,
+		// 保存 checkout session id，对账时用于查询未收到回调的订单
+		PaymentIntent: result.ExternalId,
+	}
+	err = topUp.Insert()
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "创建订单失败"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "success", "data": gin.H{"url": result.Url}})
+}
+
+// StripeWebhook 处理 Stripe 的 webhook 回调。返回非 2xx 时 Stripe 会重试，
+// 因此只有处理失败时返回错误，重复或无关的事件均返回 200
+func StripeWebhook(c *gin.Context) {
+	provider := service.GetPaymentProvider(PaymentMethodStripe)
+	event, err := provider.VerifyCallback(c)
+	if err != nil {
+		common.SysLog("stripe webhook verification failed: " + err.Error())
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	switch event.Type {
+	case service.PaymentEventPaid:
+		_, err = service.CompletePaymentOrder(provider, event)
+	case service.PaymentEventRefunded:
+		err = service.ApplyPaymentRefund(provider, event)
+	case service.PaymentEventReceipt:
+		if event.ExternalId != "" && event.ReceiptUrl != "" {
+			err = model.UpdatePaymentReceiptUrl(event.ExternalId, event.ReceiptUrl)
+		}
+	}
+	// 不属于本系统的订单（如同一 Stripe 账户的其他业务）直接忽略
+	if errors.Is(err, service.ErrPaymentOrderNotFound) {
+		common.SysLog(fmt.Sprintf("stripe webhook has no matching order: %v", event))
+		err = nil
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to handle stripe webhook %v: %s", event, err.Error()))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+func GetSelfPaymentReceipts(c *gin.Context) {
+	pageInfo, err := common.GetPageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "parse page query failed",
+		})
+		return
+	}
+	receipts, total, err := model.GetUserPaymentReceipts(c.GetInt("id"), pageInfo)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(receipts)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    pageInfo,
+	})
+}
@@ -0,0 +1,410 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	pendingTwoFASessionKey     = "pending_2fa_id"
+	pendingTwoFATimeSessionKey = "pending_2fa_time"
+
+	// 第一步登录成功后完成第二步验证的时限
+	pendingTwoFATimeout = 5 * time.Minute
+	// 每个用户在 twoFAFailureWindow 内最多验证失败 twoFAMaxFailures 次，超过后需等待窗口结束
+	twoFAMaxFailures   = 5
+	twoFAFailureWindow = 15 * time.Minute
+)
+
+// 验证失败次数按用户记录在服务端（启用 Redis 时在 Redis 中，否则在本机内存中），
+// 重新登录或重放旧的会话 Cookie 都不会重置
+var twoFAFailures = struct {
+	sync.Mutex
+	counts map[int]*twoFAFailureCount
+}{counts: make(map[int]*twoFAFailureCount)}
+
+type twoFAFailureCount struct {
+	count     int
+	expiresAt time.Time
+}
+
+func twoFAFailureRedisKey(userId int) string {
+	return fmt.Sprintf("2fa_failures:%d", userId)
+}
+
+func getTwoFAFailures(userId int) int {
+	if common.RedisEnabled {
+		count, err := common.RDB.Get(context.Background(), twoFAFailureRedisKey(userId)).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			common.SysError("failed to get 2fa failures: " + err.Error())
+		}
+		return count
+	}
+	twoFAFailures.Lock()
+	defer twoFAFailures.Unlock()
+	failure, ok := twoFAFailures.counts[userId]
+	if !ok || time.Now().After(failure.expiresAt) {
+		delete(twoFAFailures.counts, userId)
+		return 0
+	}
+	return failure.count
+}
+
+func recordFailedTwoFAAttempt(userId int) {
+	if common.RedisEnabled {
+		ctx := context.Background()
+		key := twoFAFailureRedisKey(userId)
+		count, err := common.RDB.Incr(ctx, key).Result()
+		if err == nil && count == 1 {
+			err = common.RDB.Expire(ctx, key, twoFAFailureWindow).Err()
+		}
+		if err != nil {
+			common.SysError("failed to record 2fa failure: " + err.Error())
+		}
+		return
+	}
+	twoFAFailures.Lock()
+	defer twoFAFailures.Unlock()
+	failure, ok := twoFAFailures.counts[userId]
+	if !ok || time.Now().After(failure.expiresAt) {
+		failure = &twoFAFailureCount{expiresAt: time.Now().Add(twoFAFailureWindow)}
+		twoFAFailures.counts[userId] = failure
+	}
+	failure.count++
+}
+
+func clearTwoFAFailures(userId int) {
+	if common.RedisEnabled {
+		_ = common.RedisDel(twoFAFailureRedisKey(userId))
+		return
+	}
+	twoFAFailures.Lock()
+	delete(twoFAFailures.counts, userId)
+	twoFAFailures.Unlock()
+}
+
+type TwoFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+func twoFARequiredForUser(user *model.User) bool {
+	return system_setting.GetTwoFASettings().RequireForAdmin && user.Role >= common.RoleAdminUser
+}
+
+func twoFAIssuer() string {
+	if issuer := system_setting.GetTwoFASettings().Issuer; issuer != "" {
+		return issuer
+	}
+	return common.SystemName
+}
+
+// startPendingTwoFALogin 记录已通过第一步的用户，但不建立登录会话
+func startPendingTwoFALogin(user *model.User, setupRequired bool, totpEnabled bool, passkeyEnabled bool, c *gin.Context) {
+	session := sessions.Default(c)
+	session.Clear()
+	session.Set(pendingTwoFASessionKey, user.Id)
+	session.Set(pendingTwoFATimeSessionKey, time.Now().Unix())
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无法保存会话信息，请重试",
+			"success": false,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+		"data": gin.H{
+			"require_2fa":       true,
+			"require_2fa_setup": setupRequired,
+			"totp":              totpEnabled,
+			"passkey":           passkeyEnabled,
+		},
+	})
+}
+
+// getPendingTwoFAUser 返回处于第二步验证中的用户，超时或失败次数过多时清除状态
+func getPendingTwoFAUser(c *gin.Context) (*model.User, string) {
+	session := sessions.Default(c)
+	id, ok := session.Get(pendingTwoFASessionKey).(int)
+	if !ok || id == 0 {
+		return nil, "登录状态已失效，请重新登录"
+	}
+	startTime, _ := session.Get(pendingTwoFATimeSessionKey).(int64)
+	if time.Since(time.Unix(startTime, 0)) > pendingTwoFATimeout {
+		session.Clear()
+		_ = session.Save()
+		return nil, "登录状态已失效，请重新登录"
+	}
+	if getTwoFAFailures(id) >= twoFAMaxFailures {
+		session.Clear()
+		_ = session.Save()
+		return nil, "验证失败次数过多，请稍后再试"
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil || user.Status != common.UserStatusEnabled {
+		session.Clear()
+		_ = session.Save()
+		return nil, "用户已被封禁或不存在"
+	}
+	return user, ""
+}
+
+// LoginTwoFA 使用验证码或恢复码完成登录的第二步；
+// 被策略要求启用但尚未绑定的管理员，在此提交验证码即完成绑定
+func LoginTwoFA(c *gin.Context) {
+	var req TwoFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+	user, message := getPendingTwoFAUser(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": message,
+			"success": false,
+		})
+		return
+	}
+	if !model.IsTwoFAEnabled(user.Id) {
+		if passkeyLoginEnabled() && model.CountUserPasskeys(user.Id) > 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "请使用通行密钥完成验证",
+				"success": false,
+			})
+			return
+		}
+		if !twoFARequiredForUser(user) {
+			completeLogin(user, false, c)
+			return
+		}
+		backupCodes, err := model.ConfirmTwoFAEnrollment(user.Id, req.Code)
+		if err != nil {
+			recordFailedTwoFAAttempt(user.Id)
+			c.JSON(http.StatusOK, gin.H{
+				"message": err.Error(),
+				"success": false,
+			})
+			return
+		}
+		model.RecordLog(user.Id, model.LogTypeSystem, "启用了两步验证")
+		cleanUser, err := saveLoginSession(user, true, c)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "无法保存会话信息，请重试",
+				"success": false,
+			})
+			return
+		}
+		// 恢复码只在此时返回一次
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "",
+			"success":      true,
+			"data":         cleanUser,
+			"backup_codes": backupCodes,
+		})
+		return
+	}
+	if err := model.VerifyTwoFA(user.Id, req.Code); err != nil {
+		recordFailedTwoFAAttempt(user.Id)
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	completeLogin(user, true, c)
+}
+
+// LoginTwoFASetup 为被要求启用两步验证但尚未绑定的用户生成密钥
+func LoginTwoFASetup(c *gin.Context) {
+	user, message := getPendingTwoFAUser(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": message,
+			"success": false,
+		})
+		return
+	}
+	if !twoFARequiredForUser(user) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无需绑定两步验证",
+			"success": false,
+		})
+		return
+	}
+	respondTwoFASecret(user, c)
+}
+
+func respondTwoFASecret(user *model.User, c *gin.Context) {
+	secret, err := model.StartTwoFAEnrollment(user.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+		"data": gin.H{
+			"secret":      secret,
+			"otpauth_url": common.TOTPProvisioningURI(twoFAIssuer(), user.Username, secret),
+		},
+	})
+}
+
+func GetSelfTwoFA(c *gin.Context) {
+	userId := c.GetInt("id")
+	enabled := model.IsTwoFAEnabled(userId)
+	var remaining int64
+	if enabled {
+		remaining = model.CountUnusedTwoFABackupCodes(userId)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+		"data": gin.H{
+			"enabled":                enabled,
+			"backup_codes_remaining": remaining,
+			"required":               system_setting.GetTwoFASettings().RequireForAdmin && c.GetInt("role") >= common.RoleAdminUser,
+		},
+	})
+}
+
+func SetupSelfTwoFA(c *gin.Context) {
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	respondTwoFASecret(user, c)
+}
+
+func EnableSelfTwoFA(c *gin.Context) {
+	var req TwoFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+	userId := c.GetInt("id")
+	backupCodes, err := model.ConfirmTwoFAEnrollment(userId, req.Code)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	markSessionTwoFAVerified(c)
+	model.RecordLog(userId, model.LogTypeSystem, "启用了两步验证")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+		"data": gin.H{
+			"backup_codes": backupCodes,
+		},
+	})
+}
+
+func DisableSelfTwoFA(c *gin.Context) {
+	var req TwoFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+	userId := c.GetInt("id")
+	if system_setting.GetTwoFASettings().RequireForAdmin && c.GetInt("role") >= common.RoleAdminUser {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "管理员必须启用两步验证，无法关闭",
+			"success": false,
+		})
+		return
+	}
+	if err := model.VerifyTwoFA(userId, req.Code); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	if err := model.DisableTwoFA(userId); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	model.RecordLog(userId, model.LogTypeSystem, "关闭了两步验证")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+	})
+}
+
+func RegenerateSelfTwoFABackupCodes(c *gin.Context) {
+	var req TwoFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+	userId := c.GetInt("id")
+	if err := model.VerifyTwoFA(userId, req.Code); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	backupCodes, err := model.RegenerateTwoFABackupCodes(userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+		"data": gin.H{
+			"backup_codes": backupCodes,
+		},
+	})
+}
+
+func markSessionTwoFAVerified(c *gin.Context) {
+	session := sessions.Default(c)
+	if session.Get("id") == nil {
+		return
+	}
+	session.Set(common.TwoFAVerifiedSessionKey, true)
+	_ = session.Save()
+}
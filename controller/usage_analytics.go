@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func parseUsageAnalyticsQuery(c *gin.Context) *model.UsageAnalyticsQuery {
+	query := &model.UsageAnalyticsQuery{
+		Bucket:    c.Query("bucket"),
+		ModelName: c.Query("model_name"),
+		Group:     c.Query("group"),
+		Tag:       c.Query("tag"),
+	}
+	if groupBy := c.Query("group_by"); groupBy != "" {
+		query.GroupBy = strings.Split(groupBy, ",")
+	}
+	query.StartTimestamp, _ = strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	query.EndTimestamp, _ = strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	query.UserId, _ = strconv.Atoi(c.Query("user_id"))
+	query.TokenId, _ = strconv.Atoi(c.Query("token_id"))
+	query.ChannelId, _ = strconv.Atoi(c.Query("channel"))
+	if query.EndTimestamp == 0 {
+		query.EndTimestamp = common.GetTimestamp()
+	}
+	if query.StartTimestamp == 0 {
+		query.StartTimestamp = query.EndTimestamp - 86400
+	}
+	return query
+}
+
+// GetUsageAnalytics 管理员按任意维度聚合用量
+func GetUsageAnalytics(c *gin.Context) {
+	respondUsageAnalytics(c, parseUsageAnalyticsQuery(c), true)
+}
+
+// GetUserUsageAnalytics 用户聚合自己的用量，不能按渠道查询
+func GetUserUsageAnalytics(c *gin.Context) {
+	query := parseUsageAnalyticsQuery(c)
+	query.UserId = c.GetInt("id")
+	query.ChannelId = 0
+	for _, dimension := range query.GroupBy {
+		if strings.TrimSpace(dimension) == "channel" {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权按渠道分组",
+			})
+			return
+		}
+	}
+	respondUsageAnalytics(c, query, false)
+}
+
+// GetMarginReport 管理员查看营收额度与上游成本的毛利报告，默认按渠道分组，可选 channel、model、group 维度
+func GetMarginReport(c *gin.Context) {
+	query := parseUsageAnalyticsQuery(c)
+	if len(query.GroupBy) == 0 {
+		query.GroupBy = []string{"channel"}
+	}
+	for _, dimension := range query.GroupBy {
+		dimension = strings.TrimSpace(dimension)
+		if dimension != "channel" && dimension != "model" && dimension != "group" {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "毛利报告仅支持按渠道、模型、分组统计",
+			})
+			return
+		}
+	}
+	respondUsageAnalytics(c, query, true)
+}
+
+func respondUsageAnalytics(c *gin.Context, query *model.UsageAnalyticsQuery, isAdmin bool) {
+	// 分钟级汇总只保留 7 天，且数据量较大，限制查询跨度
+	if query.Bucket == "minute" && query.EndTimestamp-query.StartTimestamp > 86400 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "按分钟统计时时间跨度不能超过 1 天",
+		})
+		return
+	}
+	items, err := model.QueryUsageAnalytics(query)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !isAdmin {
+		// 上游成本与毛利属于内部信息
+		for _, item := range items {
+			item.UpstreamCost = 0
+			item.Margin = 0
+			item.MarginRate = 0
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    items,
+	})
+}
+
+// GetUserSpendReport 预览当前用户某个周期的用量报告
+func GetUserSpendReport(c *gin.Context) {
+	period := c.DefaultQuery("period", service.SpendReportDaily)
+	if period != service.SpendReportDaily && period != service.SpendReportWeekly && period != service.SpendReportMonthly {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的报告周期",
+		})
+		return
+	}
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	report, err := service.BuildSpendReport(user, period, time.Now())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    report,
+	})
+}
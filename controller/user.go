@@ -9,6 +9,7 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	"one-api/setting"
+	"one-api/setting/system_setting"
 	"strconv"
 	"strings"
 	"sync"
@@ -67,13 +68,19 @@ func Login(c *gin.Context) {
 
 // setup session & cookies and then return user info
 func setupLogin(user *model.User, c *gin.Context) {
-	session := sessions.Default(c)
-	session.Set("id", user.Id)
-	session.Set("username", user.Username)
-	session.Set("role", user.Role)
-	session.Set("status", user.Status)
-	session.Set("group", user.Group)
-	err := session.Save()
+	// 启用了两步验证、添加了通行密钥（或被策略要求启用）的用户需要先完成第二步验证
+	totpEnabled := model.IsTwoFAEnabled(user.Id)
+	passkeyEnabled := passkeyLoginEnabled() && model.CountUserPasskeys(user.Id) > 0
+	setupRequired := twoFARequiredForUser(user) && !totpEnabled && !passkeyEnabled
+	if setupRequired || totpEnabled || passkeyEnabled {
+		startPendingTwoFALogin(user, setupRequired, totpEnabled, passkeyEnabled, c)
+		return
+	}
+	completeLogin(user, false, c)
+}
+
+func completeLogin(user *model.User, twoFAVerified bool, c *gin.Context) {
+	cleanUser, err := saveLoginSession(user, twoFAVerified, c)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "无法保存会话信息，请重试",
@@ -81,19 +88,38 @@ func setupLogin(user *model.User, c *gin.Context) {
 		})
 		return
 	}
-	cleanUser := model.User{
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+		"data":    cleanUser,
+	})
+}
+
+func saveLoginSession(user *model.User, twoFAVerified bool, c *gin.Context) (*model.User, error) {
+	session := sessions.Default(c)
+	session.Delete(pendingTwoFASessionKey)
+	session.Delete(pendingTwoFATimeSessionKey)
+	session.Set("id", user.Id)
+	session.Set("username", user.Username)
+	session.Set("role", user.Role)
+	session.Set("status", user.Status)
+	session.Set("group", user.Group)
+	session.Set(common.TwoFAVerifiedSessionKey, twoFAVerified)
+	if err := session.Save(); err != nil {
+		return nil, err
+	}
+	if twoFAVerified {
+		clearTwoFAFailures(user.Id)
+	}
+	return &model.User{
 		Id:          user.Id,
 		Username:    user.Username,
 		DisplayName: user.DisplayName,
 		Role:        user.Role,
 		Status:      user.Status,
 		Group:       user.Group,
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"message": "",
-		"success": true,
-		"data":    cleanUser,
-	})
+		Permissions: model.GetUserPermissions(user.Id, user.Role),
+	}, nil
 }
 
 func Logout(c *gin.Context) {
@@ -219,7 +245,6 @@ func Register(c *gin.Context) {
 		token := model.Token{
 			UserId:             insertedUser.Id, // 使用插入后的用户ID
 			Name:               cleanUser.Username + "的初始令牌",
-			Key:                key,
 			CreatedTime:        common.GetTimestamp(),
 			AccessedTime:       common.GetTimestamp(),
 			ExpiredTime:        -1,     // 永不过期
@@ -227,6 +252,7 @@ func Register(c *gin.Context) {
 			UnlimitedQuota:     true,
 			ModelLimitsEnabled: false,
 		}
+		token.SetKey(key)
 		if setting.DefaultUseAutoGroup {
 			token.Group = "auto"
 		}
@@ -463,6 +489,7 @@ func GetSelf(c *gin.Context) {
 	}
 	// Hide admin remarks: set to empty to trigger omitempty tag, ensuring the remark field is not included in JSON returned to regular users
 	user.Remark = ""
+	user.Permissions = model.GetUserPermissions(user.Id, user.Role)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -502,9 +529,16 @@ func GetUserModels(c *gin.Context) {
 	return
 }
 
+// UpdateUserRequest 管理员编辑用户的请求，修改额度时必须填写 QuotaReason
+type UpdateUserRequest struct {
+	model.User
+	QuotaReason string `json:"quota_reason"`
+}
+
 func UpdateUser(c *gin.Context) {
-	var updatedUser model.User
-	err := json.NewDecoder(c.Request.Body).Decode(&updatedUser)
+	var req UpdateUserRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	updatedUser := req.User
 	if err != nil || updatedUser.Id == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -548,6 +582,30 @@ func UpdateUser(c *gin.Context) {
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
+	// 修改额度按差额走额度调整，受每日调整上限约束并记录原因；先校验，其余字段保存成功后再调整额度
+	var adjustment *model.QuotaAdjustment
+	if updatedUser.Quota != originUser.Quota {
+		req.QuotaReason = strings.TrimSpace(req.QuotaReason)
+		if req.QuotaReason == "" || len(req.QuotaReason) > 255 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "修改额度时请填写调整原因，且不超过 255 个字符",
+			})
+			return
+		}
+		adjustment = &model.QuotaAdjustment{
+			UserId:        originUser.Id,
+			Type:          model.QuotaAdjustmentTypeCredit,
+			Quota:         updatedUser.Quota - originUser.Quota,
+			Reason:        req.QuotaReason,
+			AdminId:       c.GetInt("id"),
+			AdminUsername: c.GetString("username"),
+		}
+		if adjustment.Quota < 0 {
+			adjustment.Type = model.QuotaAdjustmentTypeDebit
+			adjustment.Quota = -adjustment.Quota
+		}
+	}
 	updatePassword := updatedUser.Password != ""
 	if err := updatedUser.Edit(updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -556,8 +614,14 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
-	if originUser.Quota != updatedUser.Quota {
-		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
+	if adjustment != nil {
+		if err := model.AdjustUserQuota(adjustment, system_setting.GetQuotaAdjustmentSettings().DailyCapPerAdmin); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "用户信息已保存，但额度调整失败：" + err.Error(),
+			})
+			return
+		}
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -813,6 +877,22 @@ func ManageUser(c *gin.Context) {
 			})
 			return
 		}
+	case "disable_2fa":
+		if err := model.DisableTwoFA(user.Id); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		if err := model.DeleteAllUserPasskeys(user.Id); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		model.RecordLog(user.Id, model.LogTypeManage, fmt.Sprintf("管理员 %s 重置了两步验证与通行密钥", c.GetString("username")))
 	case "promote":
 		if myRole != common.RoleRootUser {
 			c.JSON(http.StatusOK, gin.H{
@@ -949,6 +1029,9 @@ type UpdateUserSettingRequest struct {
 	NotificationEmail          string  `json:"notification_email,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	SpendReportDaily           bool    `json:"spend_report_daily"`
+	SpendReportWeekly          bool    `json:"spend_report_weekly"`
+	SpendReportMonthly         bool    `json:"spend_report_monthly"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -1026,6 +1109,9 @@ func UpdateUserSetting(c *gin.Context) {
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
+		SpendReportDaily:      req.SpendReportDaily,
+		SpendReportWeekly:     req.SpendReportWeekly,
+		SpendReportMonthly:    req.SpendReportMonthly,
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
@@ -4,4 +4,10 @@ type ChannelSettings struct {
 	ForceFormat       bool   `json:"force_format,omitempty"`
 	ThinkingToContent bool   `json:"thinking_to_content,omitempty"`
 	Proxy             string `json:"proxy"`
+	// UpstreamCostRatio 上游实际成本相对于本站原价（不含分组倍率）的比例，0 表示未配置
+	UpstreamCostRatio float64 `json:"upstream_cost_ratio,omitempty"`
+	// UpstreamModelCostRatio 按模型覆盖 UpstreamCostRatio
+	UpstreamModelCostRatio map[string]float64 `json:"upstream_model_cost_ratio,omitempty"`
+	// BalanceUSDRate 渠道余额 1 个单位折合的美元数，用于余额偏差告警，0 视为 1
+	BalanceUSDRate float64 `json:"balance_usd_rate,omitempty"`
 }
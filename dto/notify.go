@@ -5,6 +5,7 @@ type Notify struct {
 	Title   string        `json:"title"`
 	Content string        `json:"content"`
 	Values  []interface{} `json:"values"`
+	Data    interface{}   `json:"data,omitempty"` // 结构化数据，随 webhook 一起发送
 }
 
 const ContentValueParam = "{{value}}"
@@ -13,6 +14,10 @@ const (
 	NotifyTypeQuotaExceed   = "quota_exceed"
 	NotifyTypeChannelUpdate = "channel_update"
 	NotifyTypeChannelTest   = "channel_test"
+	NotifyTypeSpendReport   = "spend_report"
+	NotifyTypeSpendAnomaly  = "spend_anomaly"
+	NotifyTypeSubscription  = "subscription"
+	NotifyTypeCredit        = "credit"
 )
 
 func NewNotify(t string, title string, content string, values []interface{}) Notify {
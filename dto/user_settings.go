@@ -8,6 +8,9 @@ type UserSetting struct {
 	NotificationEmail     string  `json:"notification_email,omitempty"`             // NotificationEmail 通知邮箱地址
 	AcceptUnsetRatioModel bool    `json:"accept_unset_model_ratio_model,omitempty"` // AcceptUnsetRatioModel 是否接受未设置价格的模型
 	RecordIpLog           bool    `json:"record_ip_log,omitempty"`                  // 是否记录请求和错误日志IP
+	SpendReportDaily      bool    `json:"spend_report_daily,omitempty"`             // 是否接收每日用量报告
+	SpendReportWeekly     bool    `json:"spend_report_weekly,omitempty"`            // 是否接收每周用量报告
+	SpendReportMonthly    bool    `json:"spend_report_monthly,omitempty"`           // 是否接收每月用量报告
 }
 
 var (
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,8 +15,12 @@ import (
 	"one-api/router"
 	"one-api/service"
 	"one-api/setting/ratio_setting"
+	"one-api/setting/system_setting"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-contrib/sessions"
@@ -39,6 +45,15 @@ func main() {
 		return
 	}
 
+	if *common.ReencryptSecrets {
+		count, err := model.ReencryptChannelSecrets()
+		if err != nil {
+			common.FatalLog("failed to re-encrypt channel secrets: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("re-encrypted secrets of %d channels", count))
+		return
+	}
+
 	common.SysLog("New API " + common.Version + " started")
 	if os.Getenv("GIN_MODE") != "debug" {
 		gin.SetMode(gin.ReleaseMode)
@@ -78,6 +93,9 @@ func main() {
 		}()
 
 		go model.SyncChannelCache(common.SyncFrequency)
+
+		model.InitPriceOverrideCache()
+		go model.SyncPriceOverrideCache(common.SyncFrequency)
 	}
 
 	// 热更新配置
@@ -85,6 +103,31 @@ func main() {
 
 	// 数据看板
 	go model.UpdateQuotaData()
+	go model.UpdateUsageRollups()
+
+	if common.IsMasterNode {
+		// 为启用额度账本前已有余额的用户记录期初余额，全部完成后不再执行
+		if count, err := model.InitQuotaLedgerOpenings(); err != nil {
+			common.SysError("failed to init quota ledger openings: " + err.Error())
+		} else if count > 0 {
+			common.SysLog(fmt.Sprintf("recorded quota ledger openings for %d users", count))
+		}
+		if system_setting.GetPayloadCaptureSettings().Enabled && !common.CryptoSecretConfigured {
+			common.SysError("WARNING: payload capture is enabled but CRYPTO_SECRET is not set, no request or response will be captured")
+		}
+		// 清理超过保存期限的请求内容与日志
+		go model.RunLogPayloadCleanup(func() int {
+			return system_setting.GetPayloadCaptureSettings().RetentionDays
+		})
+		go service.StartLogRetentionJob()
+		go service.StartSpendReportJob()
+		go service.StartSpendAnomalyJob()
+		go service.StartSubscriptionRenewalJob()
+		go service.StartInvoiceJob()
+		go service.StartCreditJob()
+		go service.StartPaymentReconcileJob()
+		go service.StartQuotaLedgerReconcileJob()
+	}
 
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
@@ -113,6 +156,7 @@ func main() {
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
 		model.InitBatchUpdater()
 	}
+	model.InitLogWriter()
 
 	if os.Getenv("ENABLE_PPROF") == "true" {
 		gopool.Go(func() {
@@ -153,10 +197,30 @@ func main() {
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
 	}
-	err = server.Run(":" + port)
-	if err != nil {
-		common.FatalLog("failed to start HTTP server: " + err.Error())
+	httpServer := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
 	}
+	go func() {
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			common.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	}()
+
+	// 收到退出信号后先停止接收请求，再写完队列中的日志
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	common.SysLog("shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		common.SysError("failed to shutdown HTTP server: " + err.Error())
+	}
+	model.StopLogWriter(10 * time.Second)
+	model.SaveUsageRollupCache()
+	model.CloseLogSinks()
 }
 
 func InitResources() error {
@@ -200,6 +264,10 @@ func InitResources() error {
 	if err != nil {
 		return err
 	}
+	err = model.InitLogSinks()
+	if err != nil {
+		return err
+	}
 
 	// Initialize Redis
 	err = common.InitRedisClient()
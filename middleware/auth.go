@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/setting/system_setting"
 	"strconv"
 	"strings"
 
@@ -22,7 +23,12 @@ func validUserInfo(username string, role int) bool {
 	return true
 }
 
-func authHelper(c *gin.Context, minRole int) {
+func authHelper(c *gin.Context, minRole int, permissions ...string) {
+	authWithPermissions(c, minRole, false, permissions)
+}
+
+// authWithPermissions 校验登录状态与角色，anyOf 为 true 时只需拥有 permissions 中的任意一个，否则需全部拥有
+func authWithPermissions(c *gin.Context, minRole int, anyOf bool, permissions []string) {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
@@ -117,6 +123,45 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	// 开启管理员强制两步验证后，未完成第二步验证的会话（如策略开启前登录的会话）不能访问管理接口
+	if !useAccessToken && minRole >= common.RoleAdminUser && system_setting.GetTwoFASettings().RequireForAdmin &&
+		session.Get(common.TwoFAVerifiedSessionKey) != true {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员需要完成两步验证，请重新登录",
+		})
+		c.Abort()
+		return
+	}
+	if anyOf && len(permissions) > 0 {
+		granted := model.GetUserPermissions(id.(int), role.(int))
+		allowed := false
+		for _, permission := range permissions {
+			if common.StringsContains(granted, permission) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，缺少权限 " + strings.Join(permissions, " 或 "),
+			})
+			c.Abort()
+			return
+		}
+	} else {
+		for _, permission := range permissions {
+			if !model.UserHasPermission(id.(int), role.(int), permission) {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "无权进行此操作，缺少权限 " + permission,
+				})
+				c.Abort()
+				return
+			}
+		}
+	}
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
@@ -148,6 +193,20 @@ func AdminAuth() func(c *gin.Context) {
 	}
 }
 
+// PermissionAuth 要求管理员拥有指定的权限，超级管理员拥有全部权限
+func PermissionAuth(permission string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, common.RoleAdminUser, permission)
+	}
+}
+
+// AnyPermissionAuth 要求管理员拥有指定权限中的任意一个，用于多个管理页面共用的接口
+func AnyPermissionAuth(permissions ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authWithPermissions(c, common.RoleAdminUser, true, permissions)
+	}
+}
+
 func RootAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		authHelper(c, common.RoleRootUser)
@@ -230,6 +289,9 @@ func TokenAuth() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusForbidden, "用户已被封禁")
 			return
 		}
+		if !checkUserCreditStatus(c, userCache) {
+			return
+		}
 
 		userCache.WriteContext(c)
 
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.DB = db
+	model.LOG_DB = db
+	common.UsingSQLite = true
+	common.RedisEnabled = false
+	if err := db.AutoMigrate(&model.User{}, &model.AdminRole{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func createTestAdmin(t *testing.T, username string, role int, permissions []string) *model.User {
+	t.Helper()
+	user := &model.User{Username: username, Password: "12345678", AffCode: username, Role: role, Status: common.UserStatusEnabled, Group: "default"}
+	if permissions != nil {
+		adminRole := &model.AdminRole{Name: username}
+		if err := adminRole.SetPermissions(permissions); err != nil {
+			t.Fatal(err)
+		}
+		if err := model.DB.Create(adminRole).Error; err != nil {
+			t.Fatal(err)
+		}
+		user.AdminRoleId = adminRole.Id
+	}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// requestAs 以 user 的登录会话请求经过 auth 保护的接口，返回接口是否放行
+func requestAs(t *testing.T, user *model.User, apiUserId int, auth gin.HandlerFunc) bool {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("test"))))
+	router.GET("/protected", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set("id", user.Id)
+		session.Set("username", user.Username)
+		session.Set("role", user.Role)
+		session.Set("status", user.Status)
+	}, auth, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("New-Api-User", strconv.Itoa(apiUserId))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var resp struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Success
+}
+
+func TestPermissionAuth(t *testing.T) {
+	setupTestDB(t)
+	root := createTestAdmin(t, "root", common.RoleRootUser, nil)
+	admin := createTestAdmin(t, "admin", common.RoleAdminUser, nil)
+	optionAdmin := createTestAdmin(t, "options", common.RoleAdminUser, []string{common.PermissionManageOptions})
+	user := createTestAdmin(t, "user", common.RoleCommonUser, nil)
+
+	cases := []struct {
+		name       string
+		user       *model.User
+		permission string
+		allowed    bool
+	}{
+		{"root has every permission", root, common.PermissionManageOptions, true},
+		{"default admin manages channels", admin, common.PermissionManageChannels, true},
+		{"default admin cannot manage options", admin, common.PermissionManageOptions, false},
+		{"custom role grants options", optionAdmin, common.PermissionManageOptions, true},
+		{"custom role replaces the defaults", optionAdmin, common.PermissionManageChannels, false},
+		{"common user is rejected", user, common.PermissionManageChannels, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if allowed := requestAs(t, c.user, c.user.Id, PermissionAuth(c.permission)); allowed != c.allowed {
+				t.Fatalf("expected allowed=%v, got %v", c.allowed, allowed)
+			}
+		})
+	}
+
+	if requestAs(t, root, admin.Id, PermissionAuth(common.PermissionManageChannels)) {
+		t.Fatal("expected a mismatched New-Api-User to be rejected")
+	}
+}
+
+func TestAnyPermissionAuth(t *testing.T) {
+	setupTestDB(t)
+	optionAdmin := createTestAdmin(t, "options", common.RoleAdminUser, []string{common.PermissionManageOptions})
+	if !requestAs(t, optionAdmin, optionAdmin.Id, AnyPermissionAuth(common.PermissionManageChannels, common.PermissionManageOptions)) {
+		t.Fatal("expected any one of the permissions to be enough")
+	}
+	if requestAs(t, optionAdmin, optionAdmin.Id, AnyPermissionAuth(common.PermissionManageChannels, common.PermissionManageUsers)) {
+		t.Fatal("expected a role without any of the permissions to be rejected")
+	}
+}
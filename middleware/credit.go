@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/limiter"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const CreditThrottleMark = "CRTL"
+
+// allowCreditThrottledRequest 逾期限流，每个用户每分钟最多 maxCount 次请求
+func allowCreditThrottledRequest(userId int, maxCount int) (bool, error) {
+	key := CreditThrottleMark + strconv.Itoa(userId)
+	if common.RedisEnabled {
+		ctx := context.Background()
+		tb := limiter.New(ctx, common.RDB)
+		return tb.Allow(
+			ctx,
+			"rateLimit:"+key,
+			limiter.WithCapacity(int64(maxCount)*60),
+			limiter.WithRate(int64(maxCount)),
+			limiter.WithRequested(60),
+		)
+	}
+	inMemoryRateLimiter.Init(common.RateLimitKeyExpirationDuration)
+	return inMemoryRateLimiter.Request(key, maxCount, 60), nil
+}
+
+// checkUserCreditStatus 根据账期逾期状态限制或拒绝请求，返回 false 时请求已被中止
+func checkUserCreditStatus(c *gin.Context, userCache *model.UserBase) bool {
+	switch userCache.CreditStatus {
+	case model.UserCreditStatusSuspended:
+		abortWithOpenAiMessage(c, http.StatusForbidden, "账期结算单逾期未付，账户已暂停调用，请付清欠款后重试")
+		return false
+	case model.UserCreditStatusThrottled:
+		maxCount := system_setting.GetCreditSettings().ThrottleRequestsPerMinute
+		if maxCount <= 0 {
+			return true
+		}
+		allowed, err := allowCreditThrottledRequest(userCache.Id, maxCount)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to check credit throttle for user %d: %s", userCache.Id, err.Error()))
+			return true
+		}
+		if !allowed {
+			abortWithOpenAiMessage(c, http.StatusTooManyRequests, fmt.Sprintf("账期结算单逾期未付，每分钟最多请求 %d 次，付清欠款后恢复", maxCount))
+			return false
+		}
+	}
+	return true
+}
@@ -122,7 +122,12 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
-		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		err = SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if err != nil {
+			common.SysError(err.Error())
+			abortWithOpenAiMessage(c, http.StatusInternalServerError, "渠道密钥解密失败，请联系管理员")
+			return
+		}
 		c.Next()
 	}
 }
@@ -240,10 +245,20 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	return &modelRequest, shouldSelectChannel, nil
 }
 
-func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) {
+// SetupContextForSelectedChannel writes the channel into the request context, this is the only place
+// where the upstream credentials of a relayed request get decrypted
+func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) error {
 	c.Set("original_model", modelName) // for retry
 	if channel == nil {
-		return
+		return nil
+	}
+	key, err := channel.GetKey()
+	if err != nil {
+		return err
+	}
+	other, err := channel.GetOther()
+	if err != nil {
+		return err
 	}
 	c.Set("channel_id", channel.Id)
 	c.Set("channel_name", channel.Name)
@@ -257,27 +272,28 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	c.Set("auto_ban", channel.GetAutoBan())
 	c.Set("model_mapping", channel.GetModelMapping())
 	c.Set("status_code_mapping", channel.GetStatusCodeMapping())
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	common.SetContextKey(c, constant.ContextKeyBaseUrl, channel.GetBaseURL())
 	// TODO: api_version统一
 	switch channel.Type {
 	case constant.ChannelTypeAzure:
-		c.Set("api_version", channel.Other)
+		c.Set("api_version", other)
 	case constant.ChannelTypeVertexAi:
-		c.Set("region", channel.Other)
+		c.Set("region", other)
 	case constant.ChannelTypeXunfei:
-		c.Set("api_version", channel.Other)
+		c.Set("api_version", other)
 	case constant.ChannelTypeGemini:
-		c.Set("api_version", channel.Other)
+		c.Set("api_version", other)
 	case constant.ChannelTypeAli:
-		c.Set("plugin", channel.Other)
+		c.Set("plugin", other)
 	case constant.ChannelCloudflare:
-		c.Set("api_version", channel.Other)
+		c.Set("api_version", other)
 	case constant.ChannelTypeMokaAI:
-		c.Set("api_version", channel.Other)
+		c.Set("api_version", other)
 	case constant.ChannelTypeCoze:
-		c.Set("bot_id", channel.Other)
+		c.Set("bot_id", other)
 	}
+	return nil
 }
 
 // extractModelNameFromGeminiPath 从 Gemini API URL 路径中提取模型名
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/system_setting"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 流式响应按原始 SSE 采集，拼接后的文本远小于原始数据，因此原始数据允许更大的上限
+const payloadStreamRawMultiplier = 8
+
+// payloadCaptureWriter 在写给客户端的同时保留响应内容，超出上限的部分只写出不保留
+type payloadCaptureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *payloadCaptureWriter) capture(data []byte) {
+	remaining := w.limit - w.body.Len()
+	if remaining <= 0 {
+		if len(data) > 0 {
+			w.truncated = true
+		}
+		return
+	}
+	if len(data) > remaining {
+		data = data[:remaining]
+		w.truncated = true
+	}
+	w.body.Write(data)
+}
+
+func (w *payloadCaptureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *payloadCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// PayloadCapture 按采集设置保存完整的请求与响应，需放在 TokenAuth 之后
+func PayloadCapture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			c.Next()
+			return
+		}
+		group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
+		if group == "" {
+			group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+		}
+		if !service.ShouldCapturePayload(c.GetInt("id"), c.GetInt("token_id"), group) {
+			c.Next()
+			return
+		}
+		common.SetContextKey(c, constant.ContextKeyPayloadCapture, true)
+		maxBytes := system_setting.GetPayloadCaptureSettings().MaxBodyBytes
+		if maxBytes <= 0 {
+			maxBytes = 32 * 1024
+		}
+		maxBytes = min(maxBytes, model.MaxLogPayloadBodyBytes)
+		writer := &payloadCaptureWriter{
+			ResponseWriter: c.Writer,
+			limit:          maxBytes * payloadStreamRawMultiplier,
+		}
+		c.Writer = writer
+		c.Next()
+
+		requestBody := "[non-JSON request body omitted]"
+		if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+			if body, err := common.GetRequestBody(c); err == nil {
+				requestBody = string(body)
+			}
+		}
+		isStream := strings.HasPrefix(writer.Header().Get("Content-Type"), "text/event-stream")
+		raw := writer.body.Bytes()
+		payload := &model.LogPayload{
+			RequestId: c.GetString(common.RequestIdKey),
+			UserId:    c.GetInt("id"),
+			TokenId:   c.GetInt("token_id"),
+			Path:      c.Request.URL.Path,
+			Status:    writer.Status(),
+			IsStream:  isStream,
+			CreatedAt: common.GetTimestamp(),
+		}
+		gopool.Go(func() {
+			responseBody := string(raw)
+			if isStream {
+				responseBody = service.AssembleStreamResponse(raw)
+			}
+			// 先脱敏再截断，避免截断后的 JSON 无法按路径脱敏
+			var requestTruncated, responseTruncated bool
+			payload.Request, requestTruncated = truncatePayload(service.RedactPayload(requestBody), maxBytes)
+			payload.Response, responseTruncated = truncatePayload(service.RedactPayload(responseBody), maxBytes)
+			payload.Truncated = requestTruncated || responseTruncated || writer.truncated
+			if err := model.InsertLogPayload(payload); err != nil {
+				common.SysError("failed to save log payload: " + err.Error())
+			}
+		})
+	}
+}
+
+func truncatePayload(body string, maxBytes int) (string, bool) {
+	if len(body) <= maxBytes {
+		return body, false
+	}
+	return strings.ToValidUTF8(body[:maxBytes], ""), true
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/service"
+	"one-api/setting/system_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTags 解析请求携带的成本归属标签并写入上下文，随消费日志一起保存
+func RequestTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(system_setting.GetRequestTagSettings().AllowedKeys) == 0 {
+			c.Next()
+			return
+		}
+		var metadata map[string]any
+		if c.Request.Method == http.MethodPost && strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+			if body, err := common.GetRequestBody(c); err == nil {
+				var request struct {
+					Metadata map[string]any `json:"metadata"`
+				}
+				if json.Unmarshal(body, &request) == nil {
+					metadata = request.Metadata
+				}
+				c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+			}
+		}
+		tags, err := service.ParseRequestTags(c.GetHeader("X-NewAPI-Tags"), metadata)
+		if err != nil {
+			abortWithOpenAiMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(tags) > 0 {
+			common.SetContextKey(c, constant.ContextKeyRequestTags, service.FormatRequestTags(tags))
+		}
+		c.Next()
+	}
+}
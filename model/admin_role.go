@@ -0,0 +1,189 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+	"time"
+)
+
+// AdminRole is a custom role that grants a set of permissions to the admins assigned to it
+type AdminRole struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+	Permissions string `json:"-" gorm:"type:text"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (role *AdminRole) GetPermissions() []string {
+	permissions := make([]string, 0)
+	if role.Permissions == "" {
+		return permissions
+	}
+	if err := json.Unmarshal([]byte(role.Permissions), &permissions); err != nil {
+		common.SysError("failed to decode permissions of admin role: " + err.Error())
+	}
+	return permissions
+}
+
+func (role *AdminRole) SetPermissions(permissions []string) error {
+	unique := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if !common.IsValidPermission(permission) {
+			return errors.New("未知的权限：" + permission)
+		}
+		if !common.StringsContains(unique, permission) {
+			unique = append(unique, permission)
+		}
+	}
+	data, err := json.Marshal(unique)
+	if err != nil {
+		return err
+	}
+	role.Permissions = string(data)
+	return nil
+}
+
+// MarshalJSON exposes the permissions as a list instead of the stored JSON text
+func (role AdminRole) MarshalJSON() ([]byte, error) {
+	type alias AdminRole
+	return json.Marshal(struct {
+		alias
+		Permissions []string `json:"permissions"`
+	}{alias(role), role.GetPermissions()})
+}
+
+func GetAllAdminRoles() ([]*AdminRole, error) {
+	var roles []*AdminRole
+	err := DB.Order("id asc").Find(&roles).Error
+	return roles, err
+}
+
+func GetAdminRoleById(id int) (*AdminRole, error) {
+	var role AdminRole
+	err := DB.First(&role, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (role *AdminRole) Insert() error {
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" {
+		return errors.New("角色名称不能为空")
+	}
+	role.CreatedTime = common.GetTimestamp()
+	return DB.Create(role).Error
+}
+
+func (role *AdminRole) Update() error {
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" {
+		return errors.New("角色名称不能为空")
+	}
+	err := DB.Model(role).Select("name", "description", "permissions").Updates(role).Error
+	if err != nil {
+		return err
+	}
+	return invalidateAdminRoleCache(role.Id)
+}
+
+func DeleteAdminRoleById(id int) error {
+	var count int64
+	if err := DB.Model(&User{}).Where("admin_role_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该角色仍被用户使用，无法删除")
+	}
+	if err := DB.Delete(&AdminRole{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	return invalidateAdminRoleCache(id)
+}
+
+// SetUserAdminRole assigns a custom role to the user, roleId 0 restores the default admin permissions
+func SetUserAdminRole(userId int, roleId int) error {
+	if roleId != 0 {
+		if _, err := GetAdminRoleById(roleId); err != nil {
+			return errors.New("角色不存在")
+		}
+	}
+	err := DB.Model(&User{}).Where("id = ?", userId).Update("admin_role_id", roleId).Error
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+func getAdminRoleCacheKey(roleId int) string {
+	return fmt.Sprintf("admin_role:%d", roleId)
+}
+
+// invalidateAdminRoleCache clears the cached permissions of a role, the users' admin_role_id is cached with the user
+func invalidateAdminRoleCache(roleId int) error {
+	if !common.RedisEnabled {
+		return nil
+	}
+	return common.RedisDelKey(getAdminRoleCacheKey(roleId))
+}
+
+// getAdminRolePermissions returns the permissions of a role, from redis when enabled
+func getAdminRolePermissions(roleId int) ([]string, error) {
+	if common.RedisEnabled {
+		cached, err := common.RedisGet(getAdminRoleCacheKey(roleId))
+		if err == nil {
+			permissions := make([]string, 0)
+			if err := json.Unmarshal([]byte(cached), &permissions); err == nil {
+				return permissions, nil
+			}
+		}
+	}
+	adminRole, err := GetAdminRoleById(roleId)
+	if err != nil {
+		return nil, err
+	}
+	permissions := adminRole.GetPermissions()
+	if common.RedisEnabled {
+		data, _ := json.Marshal(permissions)
+		err = common.RedisSet(getAdminRoleCacheKey(roleId), string(data), time.Duration(common.RedisKeyCacheSeconds())*time.Second)
+		if err != nil {
+			common.SysError("failed to update admin role cache: " + err.Error())
+		}
+	}
+	return permissions, nil
+}
+
+// GetUserPermissions returns the effective permissions of a user with the given role,
+// the admin role id is read from the user cache and the role permissions from the role cache,
+// both are invalidated when they change
+func GetUserPermissions(userId int, role int) []string {
+	switch {
+	case role >= common.RoleRootUser:
+		return common.AllPermissions
+	case role >= common.RoleAdminUser:
+		userCache, err := GetUserCache(userId)
+		if err != nil {
+			common.SysError("failed to get admin role of user: " + err.Error())
+			return []string{}
+		}
+		if userCache.AdminRoleId == 0 {
+			return common.DefaultAdminPermissions
+		}
+		permissions, err := getAdminRolePermissions(userCache.AdminRoleId)
+		if err != nil {
+			return []string{}
+		}
+		return permissions
+	default:
+		return []string{}
+	}
+}
+
+func UserHasPermission(userId int, role int, permission string) bool {
+	return common.StringsContains(GetUserPermissions(userId, role), permission)
+}
@@ -1,7 +1,11 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"one-api/common"
 	"one-api/dto"
 	"strings"
@@ -14,6 +18,7 @@ type Channel struct {
 	Id                 int     `json:"id"`
 	Type               int     `json:"type" gorm:"default:0"`
 	Key                string  `json:"key" gorm:"not null"`
+	KeyFingerprint     string  `json:"-" gorm:"type:varchar(64);index"` // hash of the plaintext key, lets the key be searched while it is stored encrypted
 	OpenAIOrganization *string `json:"openai_organization"`
 	TestModel          *string `json:"test_model"`
 	Status             int     `json:"status" gorm:"default:1"`
@@ -96,6 +101,61 @@ func (channel *Channel) GetAutoBan() bool {
 	return *channel.AutoBan == 1
 }
 
+// ErrChannelSecretEncryptionDisabled is returned when channel secrets are re-encrypted without a configured CRYPTO_SECRET
+var ErrChannelSecretEncryptionDisabled = errors.New("channel secret encryption requires CRYPTO_SECRET and CHANNEL_SECRET_ENCRYPTION_ENABLED")
+
+// BeforeSave encrypts the upstream credentials before they reach the database
+func (channel *Channel) BeforeSave(tx *gorm.DB) error {
+	if channel.Key != "" && !common.IsEncryptedSecret(channel.Key) {
+		// an encrypted key was loaded from the database, its fingerprint is already stored
+		channel.KeyFingerprint = ChannelKeyFingerprint(channel.Key)
+	}
+	if !common.ChannelSecretEncryptionEnabled {
+		return nil
+	}
+	return channel.encryptSecrets()
+}
+
+// ChannelKeyFingerprint returns the value stored in key_fingerprint for a plaintext channel key
+func ChannelKeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (channel *Channel) encryptSecrets() (err error) {
+	if channel.Key != "" && !common.IsEncryptedSecret(channel.Key) {
+		channel.Key, err = common.EncryptSecret(channel.Key)
+		if err != nil {
+			return err
+		}
+	}
+	if channel.Other != "" && !common.IsEncryptedSecret(channel.Other) {
+		channel.Other, err = common.EncryptSecret(channel.Other)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetKey returns the plaintext upstream key, decrypting it if it is stored encrypted
+func (channel *Channel) GetKey() (string, error) {
+	key, err := common.DecryptSecret(channel.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key of channel #%d: %w", channel.Id, err)
+	}
+	return key, nil
+}
+
+// GetOther returns the plaintext of the Other field, decrypting it if it is stored encrypted
+func (channel *Channel) GetOther() (string, error) {
+	other, err := common.DecryptSecret(channel.Other)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt other of channel #%d: %w", channel.Id, err)
+	}
+	return other, nil
+}
+
 func (channel *Channel) Save() error {
 	return DB.Save(channel).Error
 }
@@ -159,11 +219,11 @@ func SearchChannels(keyword string, group string, model string, idSort bool) ([]
 			// sqlite, PostgreSQL
 			groupCondition = `(',' || ` + commonGroupCol + ` || ',') LIKE ?`
 		}
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
+		whereClause = "(id = ? OR name LIKE ? OR key_fingerprint = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", ChannelKeyFingerprint(keyword), "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
 	} else {
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%")
+		whereClause = "(id = ? OR name LIKE ? OR key_fingerprint = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", ChannelKeyFingerprint(keyword), "%"+keyword+"%", "%"+model+"%")
 	}
 
 	// 执行查询
@@ -492,11 +552,11 @@ func SearchTags(keyword string, group string, model string, idSort bool) ([]*str
 			// sqlite, PostgreSQL
 			groupCondition = `(',' || ` + commonGroupCol + ` || ',') LIKE ?`
 		}
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
+		whereClause = "(id = ? OR name LIKE ? OR key_fingerprint = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", ChannelKeyFingerprint(keyword), "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
 	} else {
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%")
+		whereClause = "(id = ? OR name LIKE ? OR key_fingerprint = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", ChannelKeyFingerprint(keyword), "%"+keyword+"%", "%"+model+"%")
 	}
 
 	subQuery := baseQuery.Where(whereClause, args...).
@@ -645,3 +705,66 @@ func CountChannelsGroupByType() (map[int64]int64, error) {
 	}
 	return counts, nil
 }
+
+// MigrateChannelKeyFingerprints fills key_fingerprint for channels saved before the column existed
+func MigrateChannelKeyFingerprints() error {
+	var channels []*Channel
+	err := DB.Select("id, "+commonKeyCol).Where("key_fingerprint = ? OR key_fingerprint IS NULL", "").Find(&channels).Error
+	if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		key, err := channel.GetKey()
+		if err != nil {
+			common.SysError(err.Error())
+			continue
+		}
+		err = DB.Model(&Channel{}).Where("id = ?", channel.Id).UpdateColumn("key_fingerprint", ChannelKeyFingerprint(key)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReencryptChannelSecrets encrypts every channel secret with the current master key.
+// Plaintext secrets are encrypted and secrets wrapped by CRYPTO_SECRET_PREVIOUS are re-wrapped,
+// it returns the number of channels that were rewritten.
+func ReencryptChannelSecrets() (int, error) {
+	if !common.CryptoSecretConfigured || !common.ChannelSecretEncryptionEnabled {
+		// without a configured secret the master key is derived from SESSION_SECRET and may change on restart
+		return 0, ErrChannelSecretEncryptionDisabled
+	}
+	var channels []*Channel
+	err := DB.Find(&channels).Error
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, channel := range channels {
+		key, keyChanged, err := common.RewrapSecret(channel.Key)
+		if err != nil {
+			return updated, fmt.Errorf("channel #%d: %w", channel.Id, err)
+		}
+		otherChanged := false
+		other := channel.Other
+		if other != "" {
+			other, otherChanged, err = common.RewrapSecret(channel.Other)
+			if err != nil {
+				return updated, fmt.Errorf("channel #%d: %w", channel.Id, err)
+			}
+		}
+		if !keyChanged && !otherChanged {
+			continue
+		}
+		err = DB.Model(&Channel{}).Where("id = ?", channel.Id).Updates(map[string]interface{}{
+			"key":   key,
+			"other": other,
+		}).Error
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
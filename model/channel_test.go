@@ -0,0 +1,93 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func enableTestChannelEncryption(t *testing.T, secret string) {
+	t.Helper()
+	oldEnabled, oldConfigured := common.ChannelSecretEncryptionEnabled, common.CryptoSecretConfigured
+	oldSecret, oldPrevious := common.CryptoSecret, common.CryptoSecretPrevious
+	common.ChannelSecretEncryptionEnabled = true
+	common.CryptoSecretConfigured = true
+	common.CryptoSecret = secret
+	common.CryptoSecretPrevious = ""
+	t.Cleanup(func() {
+		common.ChannelSecretEncryptionEnabled = oldEnabled
+		common.CryptoSecretConfigured = oldConfigured
+		common.CryptoSecret = oldSecret
+		common.CryptoSecretPrevious = oldPrevious
+	})
+}
+
+func getTestStoredChannel(t *testing.T, id int) *Channel {
+	t.Helper()
+	var channel Channel
+	if err := DB.First(&channel, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &channel
+}
+
+func TestChannelSecretsEncryptedAtRest(t *testing.T) {
+	setupTestDB(t)
+	enableTestChannelEncryption(t, "channel-secret")
+	channel := &Channel{Name: "openai", Key: "sk-upstream", Other: "2024-02-01", Status: common.ChannelStatusEnabled}
+	if err := DB.Create(channel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stored := getTestStoredChannel(t, channel.Id)
+	if !common.IsEncryptedSecret(stored.Key) || !common.IsEncryptedSecret(stored.Other) {
+		t.Fatalf("expected encrypted key and other, got %q, %q", stored.Key, stored.Other)
+	}
+	key, err := stored.GetKey()
+	if err != nil || key != "sk-upstream" {
+		t.Fatalf("expected the plaintext key, got %q, %v", key, err)
+	}
+	other, err := stored.GetOther()
+	if err != nil || other != "2024-02-01" {
+		t.Fatalf("expected the plaintext other, got %q, %v", other, err)
+	}
+
+	// 保存已加密的渠道不会重复加密
+	if err := stored.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := getTestStoredChannel(t, channel.Id).GetKey(); err != nil || key != "sk-upstream" {
+		t.Fatalf("expected the key to survive a second save, got %q, %v", key, err)
+	}
+}
+
+func TestReencryptChannelSecrets(t *testing.T) {
+	setupTestDB(t)
+	// 启用加密前保存的明文渠道
+	plain := &Channel{Name: "plain", Key: "sk-plain", Status: common.ChannelStatusEnabled}
+	if err := DB.Create(plain).Error; err != nil {
+		t.Fatal(err)
+	}
+	enableTestChannelEncryption(t, "old-secret")
+	encrypted := &Channel{Name: "encrypted", Key: "sk-encrypted", Status: common.ChannelStatusEnabled}
+	if err := DB.Create(encrypted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	common.CryptoSecret, common.CryptoSecretPrevious = "new-secret", "old-secret"
+	updated, err := ReencryptChannelSecrets()
+	if err != nil || updated != 2 {
+		t.Fatalf("expected 2 channels to be rewritten, got %d, %v", updated, err)
+	}
+	if updated, err = ReencryptChannelSecrets(); err != nil || updated != 0 {
+		t.Fatalf("expected nothing left to rewrite, got %d, %v", updated, err)
+	}
+
+	common.CryptoSecretPrevious = ""
+	for id, expected := range map[int]string{plain.Id: "sk-plain", encrypted.Id: "sk-encrypted"} {
+		stored := getTestStoredChannel(t, id)
+		key, err := stored.GetKey()
+		if !common.IsEncryptedSecret(stored.Key) || err != nil || key != expected {
+			t.Fatalf("channel #%d: expected %q under the new master key, got %q, %v", id, expected, key, err)
+		}
+	}
+}
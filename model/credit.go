@@ -0,0 +1,371 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CreditAccountStatusPending  = 1 // 待审批
+	CreditAccountStatusActive   = 2 // 已开通
+	CreditAccountStatusRejected = 3 // 已拒绝
+	CreditAccountStatusClosed   = 4 // 已关闭
+)
+
+// 用户的账期状态，结算单逾期后由定时任务更新，保存在 User.CreditStatus 中随用户缓存读取
+const (
+	UserCreditStatusNormal    = 0
+	UserCreditStatusThrottled = 1 // 限制请求频率
+	UserCreditStatusSuspended = 2 // 暂停 API 调用
+)
+
+const (
+	CreditStatementStatusUnpaid = 1
+	CreditStatementStatusPaid   = 2
+)
+
+var ErrCreditStatementExists = errors.New("该账单期的结算单已存在")
+
+// CreditAccount 用户的账期（后付费）额度。审批通过后用户余额可以透支到 -CreditLimit，
+// 每月生成结算单，结算单在账期内未付清时限制或暂停账户
+type CreditAccount struct {
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex"`
+	Username       string `json:"username" gorm:"type:varchar(64)"`
+	RequestedLimit int    `json:"requested_limit"`
+	CreditLimit    int    `json:"credit_limit"`
+	TermsDays      int    `json:"terms_days"`
+	Status         int    `json:"status" gorm:"default:1;index"`
+	// Reason 用户的申请说明，Remark 为管理员的审批备注
+	Reason       string `json:"reason" gorm:"type:varchar(255)"`
+	Remark       string `json:"remark" gorm:"type:varchar(255)"`
+	ReviewerId   int    `json:"reviewer_id"`
+	ReviewedTime int64  `json:"reviewed_time" gorm:"bigint"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+}
+
+// CreditStatement 账期用户的月度结算单。AmountDue 为本期新增的欠款，
+// 之后的充值与兑换按结算单的先后顺序计入 PaidQuota，付清后结算单状态改为已付款
+type CreditStatement struct {
+	Id          int   `json:"id"`
+	UserId      int   `json:"user_id" gorm:"uniqueIndex:idx_credit_statements_user_period,priority:1"`
+	PeriodStart int64 `json:"period_start" gorm:"bigint;uniqueIndex:idx_credit_statements_user_period,priority:2"`
+	PeriodEnd   int64 `json:"period_end" gorm:"bigint"`
+	// InvoiceId 同一账单期的月度账单
+	InvoiceId      int   `json:"invoice_id"`
+	UsageQuota     int   `json:"usage_quota"`
+	ClosingBalance int   `json:"closing_balance"`
+	AmountDue      int   `json:"amount_due"`
+	PaidQuota      int   `json:"paid_quota"`
+	DueTime        int64 `json:"due_time" gorm:"bigint;index"`
+	Status         int   `json:"status" gorm:"default:1;index"`
+	PaidTime       int64 `json:"paid_time" gorm:"bigint"`
+	CreatedTime    int64 `json:"created_time" gorm:"bigint"`
+}
+
+// setUserCredit 更新用户的账期额度与账期状态，并清除用户缓存
+func setUserCredit(tx *gorm.DB, userId int, updates map[string]interface{}) error {
+	if err := tx.Model(&User{}).Where("id = ?", userId).Updates(updates).Error; err != nil {
+		return err
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	return nil
+}
+
+func GetCreditAccountByUserId(userId int) (*CreditAccount, error) {
+	var account CreditAccount
+	err := DB.Where("user_id = ?", userId).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func GetCreditAccountById(id int) (*CreditAccount, error) {
+	var account CreditAccount
+	if err := DB.First(&account, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetCreditAccounts 分页返回账期申请与账户，status 为 0 时不按状态过滤
+func GetCreditAccounts(status int, pageInfo *common.PageInfo) (accounts []*CreditAccount, total int64, err error) {
+	query := DB.Model(&CreditAccount{})
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&accounts).Error
+	return accounts, total, err
+}
+
+func GetActiveCreditAccounts() (accounts []*CreditAccount, err error) {
+	err = DB.Where("status = ?", CreditAccountStatusActive).Find(&accounts).Error
+	return accounts, err
+}
+
+// GetCreditAccountsWithoutStatement 返回在 periodEnd 之前开通、但还没有 periodStart 账单期结算单的账期账户
+func GetCreditAccountsWithoutStatement(periodStart int64, periodEnd int64) (accounts []*CreditAccount, err error) {
+	err = DB.Where("status = ? AND reviewed_time < ?", CreditAccountStatusActive, periodEnd).
+		Where("user_id NOT IN (?)", DB.Model(&CreditStatement{}).Select("user_id").Where("period_start = ?", periodStart)).
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// ApplyCreditAccount 提交或更新用户的账期申请，已开通的账户不能重复申请
+func ApplyCreditAccount(user *User, requestedLimit int, reason string) (*CreditAccount, error) {
+	if requestedLimit <= 0 {
+		return nil, errors.New("申请额度必须大于 0")
+	}
+	account, err := GetCreditAccountByUserId(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		account = &CreditAccount{UserId: user.Id, CreatedTime: common.GetTimestamp()}
+	} else if account.Status == CreditAccountStatusActive {
+		return nil, errors.New("已开通账期额度，如需调整请联系管理员")
+	}
+	account.Username = user.Username
+	account.RequestedLimit = requestedLimit
+	account.Reason = reason
+	account.Status = CreditAccountStatusPending
+	if err := DB.Save(account).Error; err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// ApproveCreditAccount 审批通过账期申请，或调整已开通账户的额度与账期
+func ApproveCreditAccount(account *CreditAccount, creditLimit int, termsDays int, reviewerId int, remark string) error {
+	if creditLimit <= 0 || termsDays <= 0 {
+		return errors.New("账期额度与账期天数必须大于 0")
+	}
+	if account.Status == CreditAccountStatusClosed || account.Status == CreditAccountStatusRejected {
+		return errors.New("账期申请已关闭或已拒绝")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(account).Updates(map[string]interface{}{
+			"credit_limit":  creditLimit,
+			"terms_days":    termsDays,
+			"status":        CreditAccountStatusActive,
+			"remark":        remark,
+			"reviewer_id":   reviewerId,
+			"reviewed_time": common.GetTimestamp(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return setUserCredit(tx, account.UserId, map[string]interface{}{"credit_limit": creditLimit})
+	})
+}
+
+// RejectCreditAccount 拒绝待审批的账期申请
+func RejectCreditAccount(account *CreditAccount, reviewerId int, remark string) error {
+	if account.Status != CreditAccountStatusPending {
+		return errors.New("只能拒绝待审批的申请")
+	}
+	return DB.Model(account).Updates(map[string]interface{}{
+		"status":        CreditAccountStatusRejected,
+		"remark":        remark,
+		"reviewer_id":   reviewerId,
+		"reviewed_time": common.GetTimestamp(),
+	}).Error
+}
+
+// CloseCreditAccount 关闭账期账户，用户恢复为预付费，已有的欠款仍需通过充值补足
+func CloseCreditAccount(account *CreditAccount, reviewerId int, remark string) error {
+	if account.Status != CreditAccountStatusActive {
+		return errors.New("账期账户未开通")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(account).Updates(map[string]interface{}{
+			"status":        CreditAccountStatusClosed,
+			"remark":        remark,
+			"reviewer_id":   reviewerId,
+			"reviewed_time": common.GetTimestamp(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return setUserCredit(tx, account.UserId, map[string]interface{}{
+			"credit_limit":  0,
+			"credit_status": UserCreditStatusNormal,
+		})
+	})
+}
+
+// UpdateUserCreditStatus 更新用户的逾期状态，状态未变化时不写入
+func UpdateUserCreditStatus(userId int, status int) (bool, error) {
+	result := DB.Model(&User{}).Where("id = ? AND credit_status <> ?", userId, status).Update("credit_status", status)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	return true, nil
+}
+
+// GetUnpaidCreditQuota 返回用户未付清的结算单欠款合计
+func GetUnpaidCreditQuota(userId int) (int, error) {
+	var quota int
+	err := DB.Model(&CreditStatement{}).Select("COALESCE(SUM(amount_due - paid_quota), 0)").
+		Where("user_id = ? AND status = ?", userId, CreditStatementStatusUnpaid).Scan(&quota).Error
+	return quota, err
+}
+
+// GetOldestOverdueCreditStatement 返回用户最早到期且在 now 时已逾期的未付结算单，不存在时返回 nil
+func GetOldestOverdueCreditStatement(userId int, now int64) (*CreditStatement, error) {
+	var statement CreditStatement
+	err := DB.Where("user_id = ? AND status = ? AND due_time < ?", userId, CreditStatementStatusUnpaid, now).
+		Order("due_time").First(&statement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// GetCreditOverdueUserIds 返回有逾期未付结算单或当前处于逾期状态的用户
+func GetCreditOverdueUserIds(now int64) ([]int, error) {
+	var overdueUserIds []int
+	err := DB.Model(&CreditStatement{}).Distinct("user_id").
+		Where("status = ? AND due_time < ?", CreditStatementStatusUnpaid, now).Pluck("user_id", &overdueUserIds).Error
+	if err != nil {
+		return nil, err
+	}
+	var statusUserIds []int
+	err = DB.Model(&User{}).Where("credit_status <> ?", UserCreditStatusNormal).Pluck("id", &statusUserIds).Error
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int]bool, len(overdueUserIds)+len(statusUserIds))
+	userIds := make([]int, 0, len(overdueUserIds)+len(statusUserIds))
+	for _, id := range append(overdueUserIds, statusUserIds...) {
+		if !seen[id] {
+			seen[id] = true
+			userIds = append(userIds, id)
+		}
+	}
+	return userIds, nil
+}
+
+// InsertCreditStatement 保存结算单，欠款已付清的结算单直接标记为已付款
+func InsertCreditStatement(statement *CreditStatement) error {
+	var count int64
+	err := DB.Model(&CreditStatement{}).Where("user_id = ? AND period_start = ?", statement.UserId, statement.PeriodStart).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCreditStatementExists
+	}
+	statement.CreatedTime = common.GetTimestamp()
+	statement.Status = CreditStatementStatusUnpaid
+	if statement.PaidQuota >= statement.AmountDue {
+		statement.Status = CreditStatementStatusPaid
+		statement.PaidTime = statement.CreatedTime
+	}
+	return DB.Create(statement).Error
+}
+
+func GetCreditStatementById(id int) (*CreditStatement, error) {
+	var statement CreditStatement
+	if err := DB.First(&statement, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// GetCreditStatements 分页返回结算单，userId 为 0 时返回所有用户的结算单
+func GetCreditStatements(userId int, status int, pageInfo *common.PageInfo) (statements []*CreditStatement, total int64, err error) {
+	query := DB.Model(&CreditStatement{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("period_start desc, id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&statements).Error
+	return statements, total, err
+}
+
+// applyCreditPayment 将充值或兑换的额度按到期先后计入用户未付清的结算单
+func applyCreditPayment(tx *gorm.DB, userId int, quota int) error {
+	if quota <= 0 {
+		return nil
+	}
+	var statements []*CreditStatement
+	err := tx.Where("user_id = ? AND status = ?", userId, CreditStatementStatusUnpaid).Order("due_time, id").Find(&statements).Error
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if quota <= 0 {
+			break
+		}
+		paid := min(quota, statement.AmountDue-statement.PaidQuota)
+		quota -= paid
+		updates := map[string]interface{}{"paid_quota": statement.PaidQuota + paid}
+		if statement.PaidQuota+paid >= statement.AmountDue {
+			updates["status"] = CreditStatementStatusPaid
+			updates["paid_time"] = common.GetTimestamp()
+		}
+		if err := tx.Model(statement).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SettleCreditStatement 管理员确认结算单已线下付款：为用户补足结算单的未付欠款并标记为已付款，返回补足的额度
+func SettleCreditStatement(statement *CreditStatement) (int, error) {
+	if statement.Status != CreditStatementStatusUnpaid {
+		return 0, errors.New("结算单已付款")
+	}
+	quota := statement.AmountDue - statement.PaidQuota
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(statement).Where("status = ?", CreditStatementStatusUnpaid).Updates(map[string]interface{}{
+			"paid_quota": statement.AmountDue,
+			"status":     CreditStatementStatusPaid,
+			"paid_time":  common.GetTimestamp(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("结算单已付款")
+		}
+		if err := RecordQuotaLedger(tx, statement.UserId, QuotaLedgerTypeCreditSettle, quota, strconv.Itoa(statement.Id)); err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", statement.UserId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := cacheIncrUserQuota(statement.UserId, int64(quota)); err != nil {
+		common.SysError("failed to increase user quota cache: " + err.Error())
+	}
+	return quota, nil
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvoiceExists = errors.New("该账单期的账单已存在")
+
+// Invoice 用户的月度账单。金额字段为额度单位，Content 为生成时的账单快照（JSON），
+// 下载时从快照渲染，保证已生成的账单内容不随之后的设置或数据变化
+type Invoice struct {
+	Id       int    `json:"id"`
+	Number   string `json:"number" gorm:"type:varchar(64);uniqueIndex"`
+	Sequence int    `json:"sequence" gorm:"uniqueIndex"`
+	UserId   int    `json:"user_id" gorm:"uniqueIndex:idx_invoices_user_period,priority:1"`
+	Username string `json:"username" gorm:"type:varchar(64)"`
+	// PeriodStart 与 PeriodEnd 为账单期的起止时间，PeriodEnd 不包含在内
+	PeriodStart     int64   `json:"period_start" gorm:"bigint;uniqueIndex:idx_invoices_user_period,priority:2"`
+	PeriodEnd       int64   `json:"period_end" gorm:"bigint"`
+	OpeningBalance  int     `json:"opening_balance"`
+	TopUpQuota      int     `json:"top_up_quota"`
+	RedemptionQuota int     `json:"redemption_quota"`
+	UsageQuota      int     `json:"usage_quota"`
+	AdjustmentQuota int     `json:"adjustment_quota"`
+	ClosingBalance  int     `json:"closing_balance"`
+	Subtotal        float64 `json:"subtotal"`
+	TaxRate         float64 `json:"tax_rate"`
+	Tax             float64 `json:"tax"`
+	Total           float64 `json:"total"`
+	Content         string  `json:"content,omitempty" gorm:"type:text"`
+	CreatedTime     int64   `json:"created_time" gorm:"bigint"`
+}
+
+// InvoiceUsage 账单期内单个模型的消费汇总
+type InvoiceUsage struct {
+	ModelName        string `json:"model_name"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Quota            int64  `json:"quota"`
+}
+
+// InsertInvoice 为账单分配下一个序号并保存，prepare 在分配序号后生成账单编号与快照。
+// 序号有唯一索引，并发生成时冲突的一方重新分配
+func InsertInvoice(invoice *Invoice, start int, prepare func(invoice *Invoice) error) error {
+	var err error
+	for i := 0; i < 5; i++ {
+		var exists int64
+		err = DB.Model(&Invoice{}).Where("user_id = ? AND period_start = ?", invoice.UserId, invoice.PeriodStart).Count(&exists).Error
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrInvoiceExists
+		}
+		var last int
+		err = DB.Model(&Invoice{}).Select("COALESCE(MAX(sequence), 0)").Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		invoice.Id = 0
+		invoice.Sequence = max(last+1, start)
+		invoice.CreatedTime = common.GetTimestamp()
+		if err = prepare(invoice); err != nil {
+			return err
+		}
+		if err = DB.Create(invoice).Error; err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// GetLastInvoice 返回用户在 periodStart 之前的最近一期账单，不存在时返回 nil
+func GetLastInvoice(userId int, periodStart int64) (*Invoice, error) {
+	var invoice Invoice
+	err := DB.Omit("content").Where("user_id = ? AND period_start < ?", userId, periodStart).
+		Order("period_start desc").First(&invoice).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetInvoiceIdByPeriod 返回用户指定账单期的账单 id，不存在时返回 0
+func GetInvoiceIdByPeriod(userId int, periodStart int64) (int, error) {
+	var ids []int
+	err := DB.Model(&Invoice{}).Where("user_id = ? AND period_start = ?", userId, periodStart).Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// GetInvoices 分页返回账单列表，不包含账单快照，userId 为 0 时返回所有用户的账单
+func GetInvoices(userId int, pageInfo *common.PageInfo) (invoices []*Invoice, total int64, err error) {
+	query := DB.Model(&Invoice{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Omit("content").Order("period_start desc, id desc").
+		Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&invoices).Error
+	return invoices, total, err
+}
+
+// GetInvoiceById 返回账单，userId 不为 0 时只返回该用户的账单
+func GetInvoiceById(id int, userId int) (*Invoice, error) {
+	var invoice Invoice
+	query := DB.Where("id = ?", id)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err := query.First(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetInvoiceUsage 按模型汇总用户在 [start, end) 内的消费日志
+func GetInvoiceUsage(userId int, start int64, end int64) (usage []*InvoiceUsage, err error) {
+	err = LOG_DB.Model(&Log{}).
+		Select("model_name, COUNT(*) AS requests, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens, COALESCE(SUM(quota), 0) AS quota").
+		Where("user_id = ? AND type = ? AND created_at >= ? AND created_at < ?", userId, LogTypeConsume, start, end).
+		Group("model_name").Order("quota desc").Scan(&usage).Error
+	return usage, err
+}
+
+// GetInvoiceSubscriptionQuota 按模型汇总用户在 [start, end) 内由订阅套餐抵扣的消费额度，
+// 套餐抵扣的额度记录在消费日志的 other.subscription_quota 中，不计入账单的应付消费
+func GetInvoiceSubscriptionQuota(userId int, start int64, end int64) (map[string]int, error) {
+	var rows []struct {
+		ModelName string
+		Other     string
+	}
+	err := LOG_DB.Model(&Log{}).Select("model_name, other").
+		Where("user_id = ? AND type = ? AND created_at >= ? AND created_at < ?", userId, LogTypeConsume, start, end).
+		Where("other LIKE ?", "%subscription_quota%").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	quota := make(map[string]int)
+	for _, row := range rows {
+		var other struct {
+			SubscriptionQuota int `json:"subscription_quota"`
+		}
+		if err := common.UnmarshalJsonStr(row.Other, &other); err != nil {
+			continue
+		}
+		if other.SubscriptionQuota > 0 {
+			quota[row.ModelName] += other.SubscriptionQuota
+		}
+	}
+	return quota, nil
+}
+
+// SumUserConsumedQuota 返回用户在 start 之后（含）的消费额度，end 为 0 时不限制结束时间
+func SumUserConsumedQuota(userId int, start int64, end int64) (int, error) {
+	var quota int
+	query := LOG_DB.Model(&Log{}).Select("COALESCE(SUM(quota), 0)").
+		Where("user_id = ? AND type = ? AND created_at >= ?", userId, LogTypeConsume, start)
+	if end != 0 {
+		query = query.Where("created_at < ?", end)
+	}
+	err := query.Scan(&quota).Error
+	return quota, err
+}
+
+// GetUserSuccessTopUps 返回用户在 start 之后（含）创建并已支付的充值订单（包括之后退款的订单），end 为 0 时不限制结束时间
+func GetUserSuccessTopUps(userId int, start int64, end int64) (topUps []*TopUp, err error) {
+	query := DB.Where("user_id = ? AND status IN ? AND create_time >= ?", userId, []string{"success", "refunded"}, start)
+	if end != 0 {
+		query = query.Where("create_time < ?", end)
+	}
+	err = query.Order("id").Find(&topUps).Error
+	return topUps, err
+}
+
+// SumUserRedeemedQuota 返回用户在 start 之后（含）兑换的额度，end 为 0 时不限制结束时间
+func SumUserRedeemedQuota(userId int, start int64, end int64) (int, error) {
+	var quota int
+	query := DB.Unscoped().Model(&Redemption{}).Select("COALESCE(SUM(quota), 0)").
+		Where("used_user_id = ? AND status = ? AND redeemed_time >= ?", userId, common.RedemptionCodeStatusUsed, start)
+	if end != 0 {
+		query = query.Where("redeemed_time < ?", end)
+	}
+	err := query.Scan(&quota).Error
+	return quota, err
+}
+
+// GetInvoiceUserIds 返回在 [start, end) 内有消费或充值的用户
+func GetInvoiceUserIds(start int64, end int64) ([]int, error) {
+	var logUserIds []int
+	err := LOG_DB.Model(&Log{}).Distinct("user_id").
+		Where("type = ? AND created_at >= ? AND created_at < ?", LogTypeConsume, start, end).Pluck("user_id", &logUserIds).Error
+	if err != nil {
+		return nil, err
+	}
+	var topUpUserIds []int
+	err = DB.Model(&TopUp{}).Distinct("user_id").
+		Where("status IN ? AND create_time >= ? AND create_time < ?", []string{"success", "refunded"}, start, end).Pluck("user_id", &topUpUserIds).Error
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int]bool, len(logUserIds)+len(topUpUserIds))
+	userIds := make([]int, 0, len(logUserIds)+len(topUpUserIds))
+	for _, id := range append(logUserIds, topUpUserIds...) {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			userIds = append(userIds, id)
+		}
+	}
+	return userIds, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
 	"os"
 	"strings"
 	"time"
@@ -33,6 +35,8 @@ type Log struct {
 	TokenId          int    `json:"token_id" gorm:"default:0;index"`
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
+	Tags             string `json:"tags" gorm:"size:255;index;default:''"`
+	UpstreamCost     int    `json:"upstream_cost,omitempty" gorm:"default:0"`
 	Other            string `json:"other"`
 }
 
@@ -43,11 +47,13 @@ const (
 	LogTypeManage
 	LogTypeSystem
 	LogTypeError
+	LogTypeAdjustment // 管理员调整额度，对应一条 QuotaAdjustment 记录
 )
 
 func formatUserLogs(logs []*Log) {
 	for i := range logs {
 		logs[i].ChannelName = ""
+		logs[i].UpstreamCost = 0
 		var otherMap map[string]interface{}
 		otherMap = common.StrToMap(logs[i].Other)
 		if otherMap != nil {
@@ -62,17 +68,38 @@ func formatUserLogs(logs []*Log) {
 func GetLogByKey(key string) (logs []*Log, err error) {
 	if os.Getenv("LOG_SQL_DSN") != "" {
 		var tk Token
-		if err = DB.Model(&Token{}).Where(logKeyCol+"=?", strings.TrimPrefix(key, "sk-")).First(&tk).Error; err != nil {
+		if err = DB.Model(&Token{}).Where(commonKeyCol+"=?", common.HashTokenKey(strings.TrimPrefix(key, "sk-"))).First(&tk).Error; err != nil {
 			return nil, err
 		}
 		err = LOG_DB.Model(&Log{}).Where("token_id=?", tk.Id).Find(&logs).Error
 	} else {
-		err = LOG_DB.Joins("left join tokens on tokens.id = logs.token_id").Where("tokens.key = ?", strings.TrimPrefix(key, "sk-")).Find(&logs).Error
+		err = LOG_DB.Joins("left join tokens on tokens.id = logs.token_id").Where("tokens."+logKeyCol+" = ?", common.HashTokenKey(strings.TrimPrefix(key, "sk-"))).Find(&logs).Error
 	}
 	formatUserLogs(logs)
 	return logs, err
 }
 
+// shouldRecordIp 判断是否需要记录 IP，优先使用鉴权时已写入上下文的用户设置，避免每条日志都查询一次
+func shouldRecordIp(c *gin.Context, userId int) bool {
+	if userSetting, ok := common.GetContextKeyType[dto.UserSetting](c, constant.ContextKeyUserSetting); ok {
+		return userSetting.RecordIpLog
+	}
+	settingMap, err := GetUserSetting(userId, false)
+	return err == nil && settingMap.RecordIpLog
+}
+
+// withPayloadRequestId 请求内容被采集时记录请求 ID，用于从日志查询对应的请求与响应
+func withPayloadRequestId(c *gin.Context, other map[string]interface{}) map[string]interface{} {
+	if !common.GetContextKeyBool(c, constant.ContextKeyPayloadCapture) {
+		return other
+	}
+	if other == nil {
+		other = make(map[string]interface{})
+	}
+	other["request_id"] = c.GetString(common.RequestIdKey)
+	return other
+}
+
 func RecordLog(userId int, logType int, content string) {
 	if logType == LogTypeConsume && !common.LogConsumeEnabled {
 		return
@@ -85,7 +112,7 @@ func RecordLog(userId int, logType int, content string) {
 		Type:      logType,
 		Content:   content,
 	}
-	err := LOG_DB.Create(log).Error
+	err := insertLog(log)
 	if err != nil {
 		common.SysError("failed to record log: " + err.Error())
 	}
@@ -95,14 +122,8 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 	isStream bool, group string, other map[string]interface{}) {
 	common.LogInfo(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
 	username := c.GetString("username")
-	otherStr := common.MapToJsonStr(other)
-	// 判断是否需要记录 IP
-	needRecordIp := false
-	if settingMap, err := GetUserSetting(userId, false); err == nil {
-		if settingMap.RecordIpLog {
-			needRecordIp = true
-		}
-	}
+	otherStr := common.MapToJsonStr(withPayloadRequestId(c, other))
+	needRecordIp := shouldRecordIp(c, userId)
 	log := &Log{
 		UserId:           userId,
 		Username:         username,
@@ -125,9 +146,10 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 			}
 			return ""
 		}(),
+		Tags:  common.GetContextKeyString(c, constant.ContextKeyRequestTags),
 		Other: otherStr,
 	}
-	err := LOG_DB.Create(log).Error
+	err := insertLog(log)
 	if err != nil {
 		common.LogError(c, "failed to record log: "+err.Error())
 	}
@@ -151,18 +173,34 @@ type RecordConsumeLogParams struct {
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
 	common.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, params=%s", userId, common.GetJsonString(params)))
+	ttft := 0
+	if params.IsStream {
+		ttft = otherInt(params.Other, "frt")
+	}
+	upstreamCost := computeUpstreamCost(c, params.ChannelId, params.ModelName, params.Quota, params.Other)
+	RecordUsageRollup(UsageRollupRecord{
+		UserId:           userId,
+		Username:         c.GetString("username"),
+		TokenId:          params.TokenId,
+		TokenName:        params.TokenName,
+		ModelName:        params.ModelName,
+		ChannelId:        params.ChannelId,
+		Group:            params.Group,
+		PromptTokens:     params.PromptTokens,
+		CompletionTokens: params.CompletionTokens,
+		CachedTokens:     otherInt(params.Other, "cache_tokens"),
+		Quota:            params.Quota,
+		UpstreamCost:     upstreamCost,
+		UseTimeSeconds:   params.UseTimeSeconds,
+		TtftMs:           ttft,
+		Tags:             common.GetContextKeyString(c, constant.ContextKeyRequestTags),
+	})
 	if !common.LogConsumeEnabled {
 		return
 	}
 	username := c.GetString("username")
-	otherStr := common.MapToJsonStr(params.Other)
-	// 判断是否需要记录 IP
-	needRecordIp := false
-	if settingMap, err := GetUserSetting(userId, false); err == nil {
-		if settingMap.RecordIpLog {
-			needRecordIp = true
-		}
-	}
+	otherStr := common.MapToJsonStr(withPayloadRequestId(c, params.Other))
+	needRecordIp := shouldRecordIp(c, userId)
 	log := &Log{
 		UserId:           userId,
 		Username:         username,
@@ -185,9 +223,11 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 			}
 			return ""
 		}(),
-		Other: otherStr,
+		Tags:         common.GetContextKeyString(c, constant.ContextKeyRequestTags),
+		UpstreamCost: upstreamCost,
+		Other:        otherStr,
 	}
-	err := LOG_DB.Create(log).Error
+	err := insertLog(log)
 	if err != nil {
 		common.LogError(c, "failed to record log: "+err.Error())
 	}
@@ -198,7 +238,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 }
 
-func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string) (logs []*Log, total int64, err error) {
+func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string, tag string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
 		tx = LOG_DB
@@ -227,6 +267,9 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	if group != "" {
 		tx = tx.Where("logs."+logGroupCol+" = ?", group)
 	}
+	if tag != "" {
+		tx = whereLogTag(tx, "logs.tags", tag)
+	}
 	err = tx.Model(&Log{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
@@ -267,7 +310,7 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 	return logs, total, err
 }
 
-func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int, group string) (logs []*Log, total int64, err error) {
+func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int, group string, tag string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
 		tx = LOG_DB.Where("logs.user_id = ?", userId)
@@ -290,6 +333,9 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	if group != "" {
 		tx = tx.Where("logs."+logGroupCol+" = ?", group)
 	}
+	if tag != "" {
+		tx = whereLogTag(tx, "logs.tags", tag)
+	}
 	err = tx.Model(&Log{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
@@ -303,6 +349,24 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	return logs, total, err
 }
 
+// parseLogTags 解析 key=value,key2=value2 格式的标签
+func parseLogTags(tags string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(tags, ",") {
+		if key, value, found := strings.Cut(pair, "="); found {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// whereLogTag 按 key=value 筛选标签，tags 列保存按键排序、逗号分隔的 key=value 列表
+func whereLogTag(tx *gorm.DB, column string, tag string) *gorm.DB {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(tag)
+	return tx.Where("("+column+" = ? OR "+column+" LIKE ? ESCAPE '!' OR "+column+" LIKE ? ESCAPE '!' OR "+column+" LIKE ? ESCAPE '!')",
+		tag, escaped+",%", "%,"+escaped, "%,"+escaped+",%")
+}
+
 func SearchAllLogs(keyword string) (logs []*Log, err error) {
 	err = LOG_DB.Where("type = ? or content LIKE ?", keyword, keyword+"%").Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	return logs, err
@@ -320,7 +384,7 @@ type Stat struct {
 	Tpm   int `json:"tpm"`
 }
 
-func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
+func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string, tag string) (stat Stat) {
 	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
 
 	// 为rpm和tpm创建单独的查询
@@ -352,6 +416,10 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 		tx = tx.Where(logGroupCol+" = ?", group)
 		rpmTpmQuery = rpmTpmQuery.Where(logGroupCol+" = ?", group)
 	}
+	if tag != "" {
+		tx = whereLogTag(tx, "tags", tag)
+		rpmTpmQuery = whereLogTag(rpmTpmQuery, "tags", tag)
+	}
 
 	tx = tx.Where("type = ?", LogTypeConsume)
 	rpmTpmQuery = rpmTpmQuery.Where("type = ?", LogTypeConsume)
@@ -409,3 +477,43 @@ func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64,
 
 	return total, nil
 }
+
+// GetLogsBefore returns logs of the given type created before the timestamp with id greater than afterId, ordered by id
+func GetLogsBefore(logType int, before int64, afterId int, limit int) (logs []*Log, err error) {
+	err = LOG_DB.Where("type = ? AND created_at < ? AND id > ?", logType, before, afterId).
+		Order("id asc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// DeleteLogsBefore deletes logs of the given type created before the timestamp, maxId > 0 limits the deletion
+// to logs that have already been archived
+func DeleteLogsBefore(ctx context.Context, logType int, before int64, maxId int, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		if nil != ctx.Err() {
+			return total, ctx.Err()
+		}
+		tx := LOG_DB.Where("type = ? AND created_at < ?", logType, before)
+		if maxId > 0 {
+			tx = tx.Where("id <= ?", maxId)
+		}
+		result := tx.Limit(limit).Delete(&Log{})
+		if nil != result.Error {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// GetTokenLogIps 返回令牌在时间范围内发起请求的 IP，结束时间不包含在内，只有开启 IP 记录的用户才有数据
+func GetTokenLogIps(tokenId int, startTimestamp int64, endTimestamp int64) ([]string, error) {
+	var ips []string
+	err := LOG_DB.Model(&Log{}).
+		Where("token_id = ? AND created_at >= ? AND created_at < ? AND ip <> ''", tokenId, startTimestamp, endTimestamp).
+		Distinct("ip").Pluck("ip", &ips).Error
+	return ips, err
+}
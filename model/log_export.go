@@ -0,0 +1,96 @@
+package model
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// LogExportFilter 导出日志的筛选条件，零值字段不参与筛选
+type LogExportFilter struct {
+	UserId         int
+	Username       string
+	LogType        int
+	StartTimestamp int64
+	EndTimestamp   int64
+	ModelName      string
+	TokenName      string
+	TokenId        int
+	ChannelId      int
+	Group          string
+	Tag            string // key=value
+}
+
+func (f *LogExportFilter) apply(tx *gorm.DB) *gorm.DB {
+	if f.UserId != 0 {
+		tx = tx.Where("logs.user_id = ?", f.UserId)
+	}
+	if f.Username != "" {
+		tx = tx.Where("logs.username = ?", f.Username)
+	}
+	if f.LogType != LogTypeUnknown {
+		tx = tx.Where("logs.type = ?", f.LogType)
+	}
+	if f.StartTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", f.StartTimestamp)
+	}
+	if f.EndTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", f.EndTimestamp)
+	}
+	if f.ModelName != "" {
+		tx = tx.Where("logs.model_name like ?", f.ModelName)
+	}
+	if f.TokenName != "" {
+		tx = tx.Where("logs.token_name = ?", f.TokenName)
+	}
+	if f.TokenId != 0 {
+		tx = tx.Where("logs.token_id = ?", f.TokenId)
+	}
+	if f.ChannelId != 0 {
+		tx = tx.Where("logs.channel_id = ?", f.ChannelId)
+	}
+	if f.Group != "" {
+		tx = tx.Where("logs."+logGroupCol+" = ?", f.Group)
+	}
+	if f.Tag != "" {
+		tx = whereLogTag(tx, "logs.tags", f.Tag)
+	}
+	return tx
+}
+
+// IterateLogs 按 id 升序逐行读取符合条件的日志并交给 fn 处理，数据直接来自数据库游标，不会整体加载到内存，
+// fn 返回错误或 ctx 取消时停止读取
+func IterateLogs(ctx context.Context, filter *LogExportFilter, fn func(log *Log) error) error {
+	tx := filter.apply(LOG_DB.WithContext(ctx).Model(&Log{}))
+	rows, err := tx.Order("logs.id asc").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var log Log
+		if err = LOG_DB.ScanRows(rows, &log); err != nil {
+			return err
+		}
+		if err = fn(&log); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetChannelNames 返回渠道 id 与名称的映射，供导出时补充渠道名称
+func GetChannelNames() (map[int]string, error) {
+	var channels []struct {
+		Id   int    `gorm:"column:id"`
+		Name string `gorm:"column:name"`
+	}
+	if err := DB.Table("channels").Select("id, name").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(channels))
+	for _, channel := range channels {
+		names[channel.Id] = channel.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+)
+
+var (
+	ErrLogPayloadNotCaptured    = errors.New("该日志未保存请求内容")
+	ErrLogPayloadRequiresSecret = errors.New("保存请求内容需要设置 CRYPTO_SECRET")
+	ErrLogPayloadBodyTooLarge   = errors.New("请求内容超过保存上限")
+)
+
+// MaxLogPayloadBodyBytes 请求与响应各自保存的字节数上限，加密并 base64 编码后仍不超过 MySQL TEXT 的 64KB
+const MaxLogPayloadBodyBytes = 47 * 1024
+
+// logPayloadColumnBytes MySQL TEXT 列的最大字节数
+const logPayloadColumnBytes = 65535
+
+// LogPayload is an opt-in capture of the full request and response of a relayed request,
+// it is linked to the consume or error log through the request id stored in Log.Other
+type LogPayload struct {
+	Id        int    `json:"id"`
+	RequestId string `json:"request_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenId   int    `json:"token_id" gorm:"default:0"`
+	Path      string `json:"path" gorm:"type:varchar(255)"`
+	Status    int    `json:"status"`
+	IsStream  bool   `json:"is_stream"`
+	Truncated bool   `json:"truncated"`
+	Request   string `json:"request" gorm:"type:text"`
+	Response  string `json:"response" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// InsertLogPayload encrypts the bodies at rest, payloads are never stored without a configured CRYPTO_SECRET
+func InsertLogPayload(payload *LogPayload) error {
+	if !common.CryptoSecretConfigured {
+		return ErrLogPayloadRequiresSecret
+	}
+	var err error
+	if payload.Request, err = common.EncryptSecret(payload.Request); err != nil {
+		return err
+	}
+	if payload.Response, err = common.EncryptSecret(payload.Response); err != nil {
+		return err
+	}
+	if len(payload.Request) > logPayloadColumnBytes || len(payload.Response) > logPayloadColumnBytes {
+		return ErrLogPayloadBodyTooLarge
+	}
+	return LOG_DB.Create(payload).Error
+}
+
+func GetLogPayloadByRequestId(requestId string) (*LogPayload, error) {
+	var payload LogPayload
+	if err := LOG_DB.Where("request_id = ?", requestId).First(&payload).Error; err != nil {
+		return nil, err
+	}
+	var err error
+	if payload.Request, err = common.DecryptSecret(payload.Request); err != nil {
+		return nil, err
+	}
+	if payload.Response, err = common.DecryptSecret(payload.Response); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// GetLogPayloadByLogId finds the payload through the request id recorded in the log
+func GetLogPayloadByLogId(logId int) (*LogPayload, error) {
+	var log Log
+	if err := LOG_DB.Select("id", "other").First(&log, "id = ?", logId).Error; err != nil {
+		return nil, err
+	}
+	requestId, _ := common.StrToMap(log.Other)["request_id"].(string)
+	if requestId == "" {
+		return nil, ErrLogPayloadNotCaptured
+	}
+	return GetLogPayloadByRequestId(requestId)
+}
+
+func DeleteLogPayloadsBefore(timestamp int64) (int64, error) {
+	result := LOG_DB.Where("created_at < ?", timestamp).Delete(&LogPayload{})
+	return result.RowsAffected, result.Error
+}
+
+// RunLogPayloadCleanup deletes captured payloads older than the retention returned by getRetentionDays
+func RunLogPayloadCleanup(getRetentionDays func() int) {
+	for {
+		if days := getRetentionDays(); days > 0 {
+			count, err := DeleteLogPayloadsBefore(time.Now().AddDate(0, 0, -days).Unix())
+			if err != nil {
+				common.SysError("failed to delete expired log payloads: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("deleted %d expired log payloads", count))
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,313 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogSink is a destination for usage and system logs, sinks are selected with LOG_SINKS
+type LogSink interface {
+	Name() string
+	Write(logs []*Log) error
+	Close() error
+}
+
+const (
+	LogSinkSQL        = "sql"
+	LogSinkClickHouse = "clickhouse"
+	LogSinkFile       = "file"
+	LogSinkStdout     = "stdout"
+)
+
+// logSinks is nil until InitLogSinks is called, in which case logs only go to LOG_DB
+var logSinks []LogSink
+
+// InitLogSinks creates the sinks listed in LOG_SINKS (comma separated, default "sql"), should be called after InitLogDB
+func InitLogSinks() error {
+	names := strings.Split(common.GetEnvOrDefaultString("LOG_SINKS", LogSinkSQL), ",")
+	sinks := make([]LogSink, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		var sink LogSink
+		var err error
+		switch name {
+		case LogSinkSQL:
+			sink = sqlLogSink{}
+		case LogSinkClickHouse:
+			sink, err = newClickHouseLogSink()
+		case LogSinkFile:
+			sink, err = newFileLogSink()
+		case LogSinkStdout:
+			sink = newStdoutLogSink()
+		default:
+			err = fmt.Errorf("unknown log sink: %s", name)
+		}
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		return fmt.Errorf("LOG_SINKS must contain at least one sink")
+	}
+	// 先写 SQL，使其余日志目标也能拿到自增 ID
+	sort.SliceStable(sinks, func(i, j int) bool {
+		return sinks[i].Name() == LogSinkSQL && sinks[j].Name() != LogSinkSQL
+	})
+	if !seen[LogSinkSQL] {
+		// 控制台统计、日志导出、用量汇总、账单与按日志退款都只读取 LOG_DB
+		common.SysError("LOG_SINKS does not contain sql, the dashboard, log export, usage rollups, invoices and consume log refunds will not see new logs")
+	}
+	logSinks = sinks
+	common.SysLog("log sinks: " + strings.Join(GetLogSinkNames(), ","))
+	return nil
+}
+
+func getLogSinks() []LogSink {
+	if logSinks == nil {
+		return []LogSink{sqlLogSink{}}
+	}
+	return logSinks
+}
+
+func GetLogSinkNames() []string {
+	sinks := getLogSinks()
+	names := make([]string, 0, len(sinks))
+	for _, sink := range sinks {
+		names = append(names, sink.Name())
+	}
+	return names
+}
+
+// writeLogs writes the logs to every sink and returns the first error
+func writeLogs(logs []*Log) error {
+	var firstErr error
+	for _, sink := range getLogSinks() {
+		if err := sink.Write(logs); err != nil {
+			err = fmt.Errorf("log sink %s: %w", sink.Name(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func CloseLogSinks() {
+	for _, sink := range logSinks {
+		if err := sink.Close(); err != nil {
+			common.SysError(fmt.Sprintf("failed to close log sink %s: %s", sink.Name(), err.Error()))
+		}
+	}
+}
+
+type sqlLogSink struct{}
+
+func (sqlLogSink) Name() string {
+	return LogSinkSQL
+}
+
+func (sqlLogSink) Write(logs []*Log) error {
+	err := LOG_DB.Create(&logs).Error
+	if err != nil {
+		// a failed batch may have assigned some ids already, clear them so the logs can be retried
+		for _, log := range logs {
+			log.Id = 0
+		}
+	}
+	return err
+}
+
+func (sqlLogSink) Close() error {
+	return nil
+}
+
+// stdoutLogSink prints one JSON object per line, suitable for container log collectors
+type stdoutLogSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newStdoutLogSink() *stdoutLogSink {
+	return &stdoutLogSink{encoder: json.NewEncoder(os.Stdout)}
+}
+
+func (s *stdoutLogSink) Name() string {
+	return LogSinkStdout
+}
+
+func (s *stdoutLogSink) Write(logs []*Log) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, log := range logs {
+		if err := s.encoder.Encode(log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *stdoutLogSink) Close() error {
+	return nil
+}
+
+// fileLogSink appends JSONL files named logs-YYYY-MM-DD[.N].jsonl, a new file is started
+// every day and whenever the current one exceeds LOG_FILE_MAX_SIZE_MB
+type fileLogSink struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	file   *os.File
+	writer *bufio.Writer
+	day    string
+	index  int
+	size   int64
+}
+
+func newFileLogSink() (*fileLogSink, error) {
+	dir := common.GetEnvOrDefaultString("LOG_FILE_DIR", "")
+	if dir == "" {
+		dir = "./logs"
+		if *common.LogDir != "" {
+			dir = *common.LogDir
+		}
+		dir = filepath.Join(dir, "records")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileLogSink{
+		dir:      dir,
+		maxSize:  int64(common.GetEnvOrDefault("LOG_FILE_MAX_SIZE_MB", 100)) * 1024 * 1024,
+		maxFiles: common.GetEnvOrDefault("LOG_FILE_MAX_FILES", 0),
+	}, nil
+}
+
+func (s *fileLogSink) Name() string {
+	return LogSinkFile
+}
+
+func (s *fileLogSink) Write(logs []*Log) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, log := range logs {
+		line, err := json.Marshal(log)
+		if err != nil {
+			return err
+		}
+		if err = s.rotate(int64(len(line) + 1)); err != nil {
+			return err
+		}
+		if _, err = s.writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		s.size += int64(len(line) + 1)
+	}
+	return s.writer.Flush()
+}
+
+// rotate opens a new file if the day changed or the next line would exceed the size limit
+func (s *fileLogSink) rotate(next int64) error {
+	day := time.Now().Format("2006-01-02")
+	if s.file != nil && day == s.day && (s.maxSize <= 0 || s.size+next <= s.maxSize || s.size == 0) {
+		return nil
+	}
+	if s.file != nil {
+		if err := s.closeFile(); err != nil {
+			return err
+		}
+		if day == s.day {
+			s.index++
+		}
+	}
+	if day != s.day {
+		s.day = day
+		s.index = 0
+	}
+	for {
+		name := filepath.Join(s.dir, s.fileName())
+		info, err := os.Stat(name)
+		if err == nil && s.maxSize > 0 && info.Size() >= s.maxSize {
+			// 重启后跳过当天已写满的文件
+			s.index++
+			continue
+		}
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		s.file = file
+		s.writer = bufio.NewWriter(file)
+		s.size = 0
+		if info != nil {
+			s.size = info.Size()
+		}
+		break
+	}
+	s.removeOldFiles()
+	return nil
+}
+
+func (s *fileLogSink) fileName() string {
+	if s.index == 0 {
+		return fmt.Sprintf("logs-%s.jsonl", s.day)
+	}
+	return fmt.Sprintf("logs-%s.%d.jsonl", s.day, s.index)
+}
+
+func (s *fileLogSink) removeOldFiles() {
+	if s.maxFiles <= 0 {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(s.dir, "logs-*.jsonl"))
+	if err != nil || len(files) <= s.maxFiles {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		infoI, errI := os.Stat(files[i])
+		infoJ, errJ := os.Stat(files[j])
+		if errI != nil || errJ != nil {
+			return files[i] < files[j]
+		}
+		return infoI.ModTime().Before(infoJ.ModTime())
+	})
+	for _, file := range files[:len(files)-s.maxFiles] {
+		if err = os.Remove(file); err != nil {
+			common.SysError("failed to remove old log file: " + err.Error())
+		}
+	}
+}
+
+func (s *fileLogSink) closeFile() error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	err := s.file.Close()
+	s.file = nil
+	s.writer = nil
+	return err
+}
+
+func (s *fileLogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.closeFile()
+}
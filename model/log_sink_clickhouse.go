@@ -0,0 +1,160 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"regexp"
+	"time"
+)
+
+// clickHouseLogSink bulk inserts logs through the ClickHouse HTTP interface using JSONEachRow
+type clickHouseLogSink struct {
+	endpoint string
+	table    string
+	client   *http.Client
+}
+
+// clickHouseLogRow uses the same column names as the SQL logs table
+type clickHouseLogRow struct {
+	Id               int    `json:"id"`
+	UserId           int    `json:"user_id"`
+	CreatedAt        int64  `json:"created_at"`
+	Type             int    `json:"type"`
+	Content          string `json:"content"`
+	Username         string `json:"username"`
+	TokenName        string `json:"token_name"`
+	ModelName        string `json:"model_name"`
+	Quota            int    `json:"quota"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	UseTime          int    `json:"use_time"`
+	IsStream         bool   `json:"is_stream"`
+	ChannelId        int    `json:"channel_id"`
+	TokenId          int    `json:"token_id"`
+	Group            string `json:"group"`
+	Ip               string `json:"ip"`
+	Tags             string `json:"tags"`
+	UpstreamCost     int    `json:"upstream_cost"`
+	Other            string `json:"other"`
+}
+
+var clickHouseTableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+func newClickHouseLogSink() (*clickHouseLogSink, error) {
+	endpoint := common.GetEnvOrDefaultString("LOG_CLICKHOUSE_URL", "")
+	if endpoint == "" {
+		return nil, fmt.Errorf("LOG_CLICKHOUSE_URL is required for the clickhouse log sink")
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid LOG_CLICKHOUSE_URL: %w", err)
+	}
+	table := common.GetEnvOrDefaultString("LOG_CLICKHOUSE_TABLE", "logs")
+	if !clickHouseTableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid LOG_CLICKHOUSE_TABLE: %s", table)
+	}
+	sink := &clickHouseLogSink{
+		endpoint: endpoint,
+		table:    table,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if common.IsMasterNode {
+		if err := sink.createTable(); err != nil {
+			return nil, err
+		}
+	}
+	return sink, nil
+}
+
+func (s *clickHouseLogSink) Name() string {
+	return LogSinkClickHouse
+}
+
+func (s *clickHouseLogSink) createTable() error {
+	query := "CREATE TABLE IF NOT EXISTS " + s.table + ` (
+	id Int64, user_id Int64, created_at Int64, type Int32, content String,
+	username String, token_name String, model_name String, quota Int64,
+	prompt_tokens Int64, completion_tokens Int64, use_time Int64, is_stream Bool,
+	channel_id Int64, token_id Int64, ` + "`group`" + ` String, ip String, tags String, upstream_cost Int64, other String
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(toDateTime(created_at))
+ORDER BY (created_at, user_id)`
+	if err := s.exec(query, nil); err != nil {
+		return err
+	}
+	// 兼容在增加 tags、upstream_cost 列之前创建的表
+	return s.exec("ALTER TABLE "+s.table+" ADD COLUMN IF NOT EXISTS tags String, ADD COLUMN IF NOT EXISTS upstream_cost Int64", nil)
+}
+
+func (s *clickHouseLogSink) Write(logs []*Log) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, log := range logs {
+		row := clickHouseLogRow{
+			Id:               log.Id,
+			UserId:           log.UserId,
+			CreatedAt:        log.CreatedAt,
+			Type:             log.Type,
+			Content:          log.Content,
+			Username:         log.Username,
+			TokenName:        log.TokenName,
+			ModelName:        log.ModelName,
+			Quota:            log.Quota,
+			PromptTokens:     log.PromptTokens,
+			CompletionTokens: log.CompletionTokens,
+			UseTime:          log.UseTime,
+			IsStream:         log.IsStream,
+			ChannelId:        log.ChannelId,
+			TokenId:          log.TokenId,
+			Group:            log.Group,
+			Ip:               log.Ip,
+			Tags:             log.Tags,
+			UpstreamCost:     log.UpstreamCost,
+			Other:            log.Other,
+		}
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+	return s.exec("INSERT INTO "+s.table+" FORMAT JSONEachRow", &body)
+}
+
+func (s *clickHouseLogSink) exec(query string, body io.Reader) error {
+	endpoint, err := url.Parse(s.endpoint)
+	if err != nil {
+		return err
+	}
+	params := endpoint.Query()
+	params.Set("query", query)
+	endpoint.RawQuery = params.Encode()
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint.String(), body)
+	if err != nil {
+		return err
+	}
+	if endpoint.User != nil {
+		password, _ := endpoint.User.Password()
+		req.SetBasicAuth(endpoint.User.Username(), password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+func (s *clickHouseLogSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,341 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LogQueueFullPolicyBlock      = "block"
+	LogQueueFullPolicyDropNew    = "drop_new"
+	LogQueueFullPolicyDropOldest = "drop_oldest"
+)
+
+// logWriter buffers logs in a bounded queue and writes them to the log sinks in batches
+type logWriter struct {
+	queue         chan *Log
+	batchSize     int
+	flushInterval time.Duration
+	policy        string
+
+	// closed is guarded by mu so that no log is sent after the queue is closed
+	mu      sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+
+	// secondary sinks are written by their own workers so that a slow sink cannot stall the SQL sink
+	workers []*logSinkWorker
+
+	enqueued atomic.Int64
+	written  atomic.Int64
+	dropped  atomic.Int64
+	failed   atomic.Int64
+}
+
+type LogWriterStats struct {
+	Sinks    []string `json:"sinks"`
+	Enabled  bool     `json:"enabled"`
+	Policy   string   `json:"policy"`
+	Capacity int      `json:"capacity"`
+	Pending  int      `json:"pending"`
+	Enqueued int64    `json:"enqueued"`
+	Written  int64    `json:"written"`
+	Dropped  int64    `json:"dropped"`
+	Failed   int64    `json:"failed"`
+}
+
+var asyncLogWriter *logWriter
+
+// InitLogWriter starts the background log writer when LOG_ASYNC_ENABLED is set or the ClickHouse sink is used,
+// should be called after InitLogSinks
+func InitLogWriter() {
+	if !common.LogAsyncEnabled {
+		if !common.StringsContains(GetLogSinkNames(), LogSinkClickHouse) {
+			return
+		}
+		// ClickHouse 适合批量写入，逐条同步写入会在每个请求上发起一次 HTTP 插入
+		common.SysLog("the clickhouse log sink requires batched writes, async log writer enabled regardless of LOG_ASYNC_ENABLED")
+	}
+	queueSize := common.LogQueueSize
+	if queueSize <= 0 {
+		queueSize = 10000
+	}
+	batchSize := common.LogBatchSize
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+	flushInterval := time.Duration(common.LogFlushInterval) * time.Millisecond
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	policy := common.LogQueueFullPolicy
+	switch policy {
+	case LogQueueFullPolicyBlock, LogQueueFullPolicyDropNew, LogQueueFullPolicyDropOldest:
+	default:
+		common.SysError("unknown LOG_QUEUE_FULL_POLICY " + policy + ", using block")
+		policy = LogQueueFullPolicyBlock
+	}
+	w := &logWriter{
+		queue:         make(chan *Log, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		policy:        policy,
+		stopped:       make(chan struct{}),
+	}
+	for _, sink := range getLogSinks() {
+		if sink.Name() != LogSinkSQL {
+			w.workers = append(w.workers, newLogSinkWorker(sink, w))
+		}
+	}
+	go w.run()
+	asyncLogWriter = w
+	common.SysLog(fmt.Sprintf("async log writer enabled, queue size %d, batch size %d, flush interval %s, policy %s",
+		queueSize, batchSize, flushInterval, policy))
+}
+
+// StopLogWriter flushes all queued logs, it should be called before the database is closed
+func StopLogWriter(timeout time.Duration) {
+	w := asyncLogWriter
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.stopped:
+		common.SysLog(fmt.Sprintf("async log writer stopped, written %d, dropped %d, failed %d",
+			w.written.Load(), w.dropped.Load(), w.failed.Load()))
+	case <-time.After(timeout):
+		common.SysError(fmt.Sprintf("async log writer did not finish within %s, %d logs may be lost", timeout, len(w.queue)))
+	}
+}
+
+func GetLogWriterStats() LogWriterStats {
+	w := asyncLogWriter
+	if w == nil {
+		return LogWriterStats{Sinks: GetLogSinkNames()}
+	}
+	return LogWriterStats{
+		Sinks:    GetLogSinkNames(),
+		Enabled:  true,
+		Policy:   w.policy,
+		Capacity: cap(w.queue),
+		Pending:  len(w.queue),
+		Enqueued: w.enqueued.Load(),
+		Written:  w.written.Load(),
+		Dropped:  w.dropped.Load(),
+		Failed:   w.failed.Load(),
+	}
+}
+
+// insertLog writes the log through the async writer if enabled, otherwise directly to the log sinks
+func insertLog(log *Log) error {
+	if w := asyncLogWriter; w != nil && w.enqueue(log) {
+		return nil
+	}
+	return writeLogs([]*Log{log})
+}
+
+// enqueue returns false once the writer is closed, the caller should then write synchronously
+func (w *logWriter) enqueue(log *Log) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+	switch w.policy {
+	case LogQueueFullPolicyDropNew:
+		select {
+		case w.queue <- log:
+		default:
+			w.dropped.Add(1)
+			return true
+		}
+	case LogQueueFullPolicyDropOldest:
+		for {
+			select {
+			case w.queue <- log:
+				w.enqueued.Add(1)
+				return true
+			default:
+			}
+			select {
+			case <-w.queue:
+				w.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		w.queue <- log
+	}
+	w.enqueued.Add(1)
+	return true
+}
+
+func (w *logWriter) run() {
+	defer close(w.stopped)
+	defer func() {
+		for _, worker := range w.workers {
+			worker.stop()
+		}
+	}()
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Log, 0, w.batchSize)
+	for {
+		select {
+		case log, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, log)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (w *logWriter) flush(batch []*Log) {
+	if len(batch) == 0 {
+		return
+	}
+	written := int64(len(batch))
+	for _, sink := range getLogSinks() {
+		if sink.Name() != LogSinkSQL {
+			continue
+		}
+		failed := writeSQLLogs(sink, batch)
+		w.failed.Add(failed)
+		written -= failed
+	}
+	w.written.Add(written)
+	for _, worker := range w.workers {
+		worker.enqueue(batch)
+	}
+}
+
+// writeSQLLogs writes the batch to the SQL sink and returns the number of logs that could not be written
+func writeSQLLogs(sink LogSink, batch []*Log) int64 {
+	err := sink.Write(batch)
+	if err == nil {
+		return 0
+	}
+	// 数据库不可用时逐条重试只会把同样的超时重复 len(batch) 次
+	if !isLogDBReachable() {
+		common.SysError(fmt.Sprintf("log sink %s failed to write %d logs, database unreachable: %s", sink.Name(), len(batch), err.Error()))
+		return int64(len(batch))
+	}
+	// 批量写入失败时逐条重试，避免一条异常数据导致整批丢失
+	common.SysError(fmt.Sprintf("log sink %s failed to write %d logs in batch, retrying one by one: %s", sink.Name(), len(batch), err.Error()))
+	var failed int64
+	for _, log := range batch {
+		if err = sink.Write([]*Log{log}); err != nil {
+			failed++
+			common.SysError(fmt.Sprintf("log sink %s failed to record log: %s", sink.Name(), err.Error()))
+		}
+	}
+	return failed
+}
+
+func isLogDBReachable() bool {
+	sqlDB, err := LOG_DB.DB()
+	if err != nil {
+		return false
+	}
+	return sqlDB.Ping() == nil
+}
+
+const (
+	logSinkWorkerQueueSize  = 64
+	logSinkMaxBackoff       = 5 * time.Minute
+	logSinkFailureThreshold = 3
+)
+
+// logSinkWorker writes batches to one secondary sink, after logSinkFailureThreshold consecutive failures
+// the sink is skipped for an exponentially growing backoff instead of being retried on every flush
+type logSinkWorker struct {
+	sink   LogSink
+	writer *logWriter
+	queue  chan []*Log
+	done   chan struct{}
+
+	failures  int
+	openUntil time.Time
+}
+
+func newLogSinkWorker(sink LogSink, writer *logWriter) *logSinkWorker {
+	worker := &logSinkWorker{
+		sink:   sink,
+		writer: writer,
+		queue:  make(chan []*Log, logSinkWorkerQueueSize),
+		done:   make(chan struct{}),
+	}
+	go worker.run()
+	return worker
+}
+
+// enqueue never blocks, batches are dropped when the sink falls too far behind
+func (s *logSinkWorker) enqueue(batch []*Log) {
+	logs := make([]*Log, len(batch))
+	copy(logs, batch)
+	select {
+	case s.queue <- logs:
+	default:
+		s.writer.failed.Add(int64(len(logs)))
+		common.SysError(fmt.Sprintf("log sink %s is falling behind, dropped %d logs", s.sink.Name(), len(logs)))
+	}
+}
+
+func (s *logSinkWorker) stop() {
+	close(s.queue)
+	<-s.done
+}
+
+func (s *logSinkWorker) run() {
+	defer close(s.done)
+	for logs := range s.queue {
+		s.write(logs, time.Now())
+	}
+}
+
+func (s *logSinkWorker) write(logs []*Log, now time.Time) {
+	if now.Before(s.openUntil) {
+		s.writer.failed.Add(int64(len(logs)))
+		return
+	}
+	err := s.sink.Write(logs)
+	if err == nil {
+		if s.failures >= logSinkFailureThreshold {
+			common.SysLog(fmt.Sprintf("log sink %s recovered", s.sink.Name()))
+		}
+		s.failures = 0
+		return
+	}
+	s.writer.failed.Add(int64(len(logs)))
+	s.failures++
+	if s.failures < logSinkFailureThreshold {
+		common.SysError(fmt.Sprintf("log sink %s failed to write %d logs: %s", s.sink.Name(), len(logs), err.Error()))
+		return
+	}
+	backoff := time.Second << min(s.failures-logSinkFailureThreshold, 10)
+	if backoff > logSinkMaxBackoff {
+		backoff = logSinkMaxBackoff
+	}
+	s.openUntil = now.Add(backoff)
+	common.SysError(fmt.Sprintf("log sink %s failed %d times in a row, skipping it for %s: %s", s.sink.Name(), s.failures, backoff, err.Error()))
+}
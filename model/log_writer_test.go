@@ -0,0 +1,82 @@
+package model
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testLogSink struct {
+	delay  time.Duration
+	err    error
+	writes atomic.Int64
+}
+
+func (s *testLogSink) Name() string {
+	return "test"
+}
+
+func (s *testLogSink) Write(logs []*Log) error {
+	s.writes.Add(1)
+	time.Sleep(s.delay)
+	return s.err
+}
+
+func (s *testLogSink) Close() error {
+	return nil
+}
+
+func TestLogWriterSlowSinkDoesNotStallSQL(t *testing.T) {
+	setupTestDB(t)
+	slow := &testLogSink{delay: 500 * time.Millisecond}
+	logSinks = []LogSink{sqlLogSink{}, slow}
+	t.Cleanup(func() { logSinks = nil })
+
+	w := &logWriter{}
+	w.workers = []*logSinkWorker{newLogSinkWorker(slow, w)}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		w.flush([]*Log{{UserId: 1, Type: LogTypeConsume, CreatedAt: time.Now().Unix()}})
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("flush waited %s for the secondary sink", elapsed)
+	}
+	var count int64
+	LOG_DB.Model(&Log{}).Count(&count)
+	if count != 3 || w.written.Load() != 3 {
+		t.Fatalf("expected 3 logs in the SQL sink, got %d (written %d)", count, w.written.Load())
+	}
+	w.workers[0].stop()
+	if slow.writes.Load() != 3 {
+		t.Fatalf("expected the secondary sink to receive 3 batches, got %d", slow.writes.Load())
+	}
+}
+
+func TestLogSinkWorkerBackoff(t *testing.T) {
+	failing := &testLogSink{err: errors.New("connection refused")}
+	w := &logWriter{}
+	worker := &logSinkWorker{sink: failing, writer: w}
+	now := time.Now()
+	logs := []*Log{{}, {}}
+	for i := 0; i < logSinkFailureThreshold; i++ {
+		worker.write(logs, now)
+	}
+	if failing.writes.Load() != logSinkFailureThreshold {
+		t.Fatalf("expected %d attempts, got %d", logSinkFailureThreshold, failing.writes.Load())
+	}
+	// 熔断期间不再请求该日志目标，也不逐条重试
+	worker.write(logs, now.Add(500*time.Millisecond))
+	if failing.writes.Load() != logSinkFailureThreshold {
+		t.Fatalf("expected the sink to be skipped during backoff, got %d attempts", failing.writes.Load())
+	}
+	if w.failed.Load() != int64(len(logs)*(logSinkFailureThreshold+1)) {
+		t.Fatalf("unexpected failed count %d", w.failed.Load())
+	}
+
+	failing.err = nil
+	worker.write(logs, now.Add(2*time.Second))
+	if worker.failures != 0 || failing.writes.Load() != logSinkFailureThreshold+1 {
+		t.Fatalf("expected the sink to recover after the backoff, failures %d", worker.failures)
+	}
+}
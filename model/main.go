@@ -196,7 +196,25 @@ func InitDB() (err error) {
 		}
 		common.SysLog("database migration started")
 		err = migrateDB()
-		return err
+		if err != nil {
+			return err
+		}
+		if common.ChannelSecretEncryptionEnabled {
+			// encrypt channel secrets that are still stored in plaintext
+			count, err := ReencryptChannelSecrets()
+			if err != nil {
+				common.SysError("failed to encrypt channel secrets: " + err.Error())
+			} else if count > 0 {
+				common.SysLog(fmt.Sprintf("encrypted secrets of %d channels", count))
+			}
+		}
+		if err := MigrateChannelKeyFingerprints(); err != nil {
+			common.SysError("failed to fill channel key fingerprints: " + err.Error())
+		}
+		if err := MigrateUserSubscriptionUntil(); err != nil {
+			common.SysError("failed to fill user subscription expiry: " + err.Error())
+		}
+		return MigrateTokenKeys()
 	} else {
 		common.FatalLog(err)
 	}
@@ -257,6 +275,22 @@ func migrateDB() error {
 		&QuotaData{},
 		&Task{},
 		&Setup{},
+		&TwoFA{},
+		&TwoFABackupCode{},
+		&PasskeyCredential{},
+		&AdminRole{},
+		&LogPayload{},
+		&UsageRollup{},
+		&PriceOverride{},
+		&SubscriptionPlan{},
+		&UserSubscription{},
+		&PaymentReceipt{},
+		&Invoice{},
+		&CreditAccount{},
+		&CreditStatement{},
+		&QuotaAdjustment{},
+		&QuotaLedgerEntry{},
+		&QuotaLedgerCheckpoint{},
 	)
 	if err != nil {
 		return err
@@ -266,7 +300,6 @@ func migrateDB() error {
 
 func migrateDBFast() error {
 	var wg sync.WaitGroup
-	errChan := make(chan error, 12) // Buffer size matches number of migrations
 
 	migrations := []struct {
 		model interface{}
@@ -284,7 +317,25 @@ func migrateDBFast() error {
 		{&QuotaData{}, "QuotaData"},
 		{&Task{}, "Task"},
 		{&Setup{}, "Setup"},
+		{&TwoFA{}, "TwoFA"},
+		{&TwoFABackupCode{}, "TwoFABackupCode"},
+		{&PasskeyCredential{}, "PasskeyCredential"},
+		{&AdminRole{}, "AdminRole"},
+		{&LogPayload{}, "LogPayload"},
+		{&UsageRollup{}, "UsageRollup"},
+		{&PriceOverride{}, "PriceOverride"},
+		{&SubscriptionPlan{}, "SubscriptionPlan"},
+		{&UserSubscription{}, "UserSubscription"},
+		{&PaymentReceipt{}, "PaymentReceipt"},
+		{&Invoice{}, "Invoice"},
+		{&CreditAccount{}, "CreditAccount"},
+		{&CreditStatement{}, "CreditStatement"},
+		{&QuotaAdjustment{}, "QuotaAdjustment"},
+		{&QuotaLedgerEntry{}, "QuotaLedgerEntry"},
+		{&QuotaLedgerCheckpoint{}, "QuotaLedgerCheckpoint"},
 	}
+	// Buffer size matches number of migrations
+	errChan := make(chan error, len(migrations))
 
 	for _, m := range migrations {
 		wg.Add(1)
@@ -312,7 +363,7 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &LogPayload{}); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 为每个测试创建独立的内存 SQLite 数据库并完成迁移
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	DB = db
+	LOG_DB = db
+	common.UsingSQLite = true
+	common.RedisEnabled = false
+	common.OptionMapRWMutex.Lock()
+	common.OptionMap = make(map[string]string)
+	common.OptionMapRWMutex.Unlock()
+	initCol()
+	if err := migrateDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func createTestUser(t *testing.T, username string, quota int) *User {
+	t.Helper()
+	user := &User{Username: username, Password: "12345678", AffCode: username, Group: "default", Quota: quota}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func getTestUserQuota(t *testing.T, userId int) int {
+	t.Helper()
+	var quota int
+	if err := DB.Model(&User{}).Where("id = ?", userId).Select("quota").Scan(&quota).Error; err != nil {
+		t.Fatal(err)
+	}
+	return quota
+}
+
+func getTestLedgerBalance(t *testing.T, userId int) int {
+	t.Helper()
+	balances, err := GetQuotaLedgerBalances([]int{userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balances[userId]
+}
@@ -23,6 +23,8 @@ type Midjourney struct {
 	Quota       int    `json:"quota"`
 	Buttons     string `json:"buttons"`
 	Properties  string `json:"properties"`
+	// SubscriptionQuota 费用中由订阅套餐抵扣的部分，任务失败时退回套餐
+	SubscriptionQuota int `json:"subscription_quota" gorm:"default:0"`
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
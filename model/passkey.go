@@ -0,0 +1,148 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"one-api/common"
+	"strconv"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// PasskeyCredential is a WebAuthn credential registered by a user,
+// the full credential record is kept as JSON so that new fields of the spec do not need migrations
+type PasskeyCredential struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64)"`
+	CredentialId string `json:"-" gorm:"type:varchar(255);uniqueIndex"`
+	Credential   string `json:"-" gorm:"type:text"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+}
+
+// PasskeyUser adapts User to webauthn.User
+type PasskeyUser struct {
+	User        *User
+	Credentials []PasskeyCredential
+}
+
+func (u *PasskeyUser) WebAuthnID() []byte {
+	return PasskeyUserHandle(u.User.Id)
+}
+
+func (u *PasskeyUser) WebAuthnName() string {
+	return u.User.Username
+}
+
+func (u *PasskeyUser) WebAuthnDisplayName() string {
+	if u.User.DisplayName != "" {
+		return u.User.DisplayName
+	}
+	return u.User.Username
+}
+
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Credentials))
+	for _, record := range u.Credentials {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(record.Credential), &credential); err != nil {
+			common.SysError("failed to decode passkey credential: " + err.Error())
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials
+}
+
+// PasskeyUserHandle is the WebAuthn user handle of a user, it is returned by authenticators on discoverable login
+func PasskeyUserHandle(userId int) []byte {
+	return []byte(strconv.Itoa(userId))
+}
+
+func encodeCredentialId(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+func GetPasskeyUser(userId int) (*PasskeyUser, error) {
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := GetUserPasskeys(userId)
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyUser{User: user, Credentials: credentials}, nil
+}
+
+// GetPasskeyUserByHandle resolves the user of a discoverable login and checks the credential belongs to them
+func GetPasskeyUserByHandle(rawId []byte, userHandle []byte) (*PasskeyUser, error) {
+	userId, err := strconv.Atoi(string(userHandle))
+	if err != nil {
+		return nil, errors.New("invalid user handle")
+	}
+	var record PasskeyCredential
+	err = DB.Where("credential_id = ? AND user_id = ?", encodeCredentialId(rawId), userId).First(&record).Error
+	if err != nil {
+		return nil, errors.New("passkey not found")
+	}
+	return GetPasskeyUser(userId)
+}
+
+func GetUserPasskeys(userId int) ([]PasskeyCredential, error) {
+	var credentials []PasskeyCredential
+	err := DB.Where("user_id = ?", userId).Order("id asc").Find(&credentials).Error
+	return credentials, err
+}
+
+func CountUserPasskeys(userId int) int64 {
+	var count int64
+	DB.Model(&PasskeyCredential{}).Where("user_id = ?", userId).Count(&count)
+	return count
+}
+
+func InsertPasskey(userId int, name string, credential *webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	record := PasskeyCredential{
+		UserId:       userId,
+		Name:         name,
+		CredentialId: encodeCredentialId(credential.ID),
+		Credential:   string(data),
+		CreatedTime:  common.GetTimestamp(),
+	}
+	return DB.Create(&record).Error
+}
+
+// UpdatePasskeyAfterLogin stores the new sign counter and flags reported by the authenticator
+func UpdatePasskeyAfterLogin(userId int, credential *webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&PasskeyCredential{}).
+		Where("credential_id = ? AND user_id = ?", encodeCredentialId(credential.ID), userId).
+		Updates(map[string]interface{}{
+			"credential":     string(data),
+			"last_used_time": common.GetTimestamp(),
+		}).Error
+}
+
+func DeleteUserPasskey(userId int, id int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&PasskeyCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("通行密钥不存在")
+	}
+	return nil
+}
+
+func DeleteAllUserPasskeys(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&PasskeyCredential{}).Error
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentReceiptTypePayment = "payment"
+	PaymentReceiptTypeRefund  = "refund"
+)
+
+// ErrPaymentReceiptDuplicated 相同 ExternalId 的凭证已存在，说明回调已经处理过
+var ErrPaymentReceiptDuplicated = errors.New("支付凭证已存在")
+
+// PaymentReceipt 第三方支付的收款与退款凭证，关联到充值订单。
+// ExternalId 为第三方的唯一单号（收款为 payment intent，退款为退款单号），用于保证回调幂等
+type PaymentReceipt struct {
+	Id         int     `json:"id"`
+	TopUpId    int     `json:"top_up_id" gorm:"index"`
+	UserId     int     `json:"user_id" gorm:"index"`
+	Provider   string  `json:"provider" gorm:"type:varchar(32)"`
+	Type       string  `json:"type" gorm:"type:varchar(16)"`
+	ExternalId string  `json:"external_id" gorm:"type:varchar(255);uniqueIndex"`
+	Money      float64 `json:"money"`
+	Currency   string  `json:"currency" gorm:"type:varchar(16)"`
+	// Quota 收款时为入账的额度，退款时为扣回的额度
+	Quota       int    `json:"quota"`
+	ReceiptUrl  string `json:"receipt_url" gorm:"type:varchar(512)"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (receipt *PaymentReceipt) insert(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&PaymentReceipt{}).Where("external_id = ?", receipt.ExternalId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPaymentReceiptDuplicated
+	}
+	receipt.CreatedTime = common.GetTimestamp()
+	return tx.Create(receipt).Error
+}
+
+func GetTopUpByPaymentIntent(paymentIntent string) *TopUp {
+	var topUp *TopUp
+	err := DB.Where("payment_intent = ?", paymentIntent).First(&topUp).Error
+	if err != nil {
+		return nil
+	}
+	return topUp
+}
+
+// RefundTopUp 处理充值订单的退款并从用户余额中扣回对应额度。refundedTotal 为该笔支付累计的退款金额，
+// 本次扣回的额度按新增退款金额占订单金额的比例计算，全额退款时订单状态改为 refunded。
+// 用户余额不足时允许扣为负数
+func RefundTopUp(topUp *TopUp, receipt *PaymentReceipt, refundedTotal float64) (*TopUp, int, error) {
+	if topUp.Status != "success" && topUp.Status != "refunded" {
+		return topUp, 0, errors.New("订单状态不是已支付")
+	}
+	totalQuota := topUp.TopUpQuota()
+	quota := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var refunded struct {
+			Money float64
+			Quota int
+		}
+		err := tx.Model(&PaymentReceipt{}).Select("COALESCE(SUM(money), 0) AS money, COALESCE(SUM(quota), 0) AS quota").
+			Where("top_up_id = ? AND type = ?", topUp.Id, PaymentReceiptTypeRefund).Scan(&refunded).Error
+		if err != nil {
+			return err
+		}
+		delta := refundedTotal - refunded.Money
+		if delta < 0.005 {
+			return ErrPaymentReceiptDuplicated
+		}
+		fullyRefunded := refundedTotal >= topUp.Money-0.005
+		if fullyRefunded || topUp.Money <= 0 {
+			quota = totalQuota - refunded.Quota
+		} else {
+			quota = int(math.Round(float64(totalQuota) * delta / topUp.Money))
+			quota = min(quota, totalQuota-refunded.Quota)
+		}
+		receipt.TopUpId = topUp.Id
+		receipt.UserId = topUp.UserId
+		receipt.Type = PaymentReceiptTypeRefund
+		receipt.Money = delta
+		receipt.Quota = quota
+		if err := receipt.insert(tx); err != nil {
+			return err
+		}
+		if fullyRefunded {
+			if err := tx.Model(&TopUp{}).Where("id = ?", topUp.Id).Update("status", "refunded").Error; err != nil {
+				return err
+			}
+		}
+		if err := RecordQuotaLedger(tx, topUp.UserId, QuotaLedgerTypeTopUpRefund, -quota, topUp.TradeNo); err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota - ?", quota)).Error
+	})
+	if err != nil {
+		return topUp, 0, err
+	}
+	if err := cacheDecrUserQuota(topUp.UserId, int64(quota)); err != nil {
+		common.SysError("failed to decrease user quota cache: " + err.Error())
+	}
+	return topUp, quota, nil
+}
+
+// GetTopUpRefundedMoney 返回订单的累计退款金额
+func GetTopUpRefundedMoney(topUpId int) (float64, error) {
+	var money float64
+	err := DB.Model(&PaymentReceipt{}).Select("COALESCE(SUM(money), 0)").
+		Where("top_up_id = ? AND type = ?", topUpId, PaymentReceiptTypeRefund).Scan(&money).Error
+	return money, err
+}
+
+// UpdatePaymentReceiptUrl 补充收款凭证的收据地址
+func UpdatePaymentReceiptUrl(externalId string, receiptUrl string) error {
+	return DB.Model(&PaymentReceipt{}).Where("external_id = ? AND type = ?", externalId, PaymentReceiptTypePayment).
+		Update("receipt_url", receiptUrl).Error
+}
+
+func GetUserPaymentReceipts(userId int, pageInfo *common.PageInfo) (receipts []*PaymentReceipt, total int64, err error) {
+	query := DB.Model(&PaymentReceipt{}).Where("user_id = ?", userId)
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&receipts).Error
+	return receipts, total, err
+}
@@ -0,0 +1,199 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceOverride 为指定用户或令牌单独设置的模型价格，用于企业客户的协议价等场景。
+// TokenId 为 0 时对用户的所有令牌生效；ModelName 支持精确名称、以 * 结尾的前缀匹配以及 * 匹配全部模型
+type PriceOverride struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenId   int    `json:"token_id" gorm:"index;default:0"`
+	ModelName string `json:"model_name" gorm:"type:varchar(255)"`
+	// RatioMultiplier 乘以模型倍率（按次计费的模型乘以价格），0 表示不调整
+	RatioMultiplier float64 `json:"ratio_multiplier" gorm:"default:0"`
+	// ModelPrice 大于 0 时改为按次计费，单位为美元
+	ModelPrice float64 `json:"model_price" gorm:"default:0"`
+	// CompletionRatio 大于 0 时替换模型的补全倍率
+	CompletionRatio float64 `json:"completion_ratio" gorm:"default:0"`
+	// ExpiredTime 为 -1 表示永不过期
+	ExpiredTime int64  `json:"expired_time" gorm:"bigint;default:-1"`
+	Remark      string `json:"remark" gorm:"type:varchar(255)"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (o *PriceOverride) Expired(now int64) bool {
+	return o.ExpiredTime != -1 && o.ExpiredTime <= now
+}
+
+// matchLevel 返回模型名称的匹配程度，数值越大越优先：精确匹配 > 更长的前缀匹配 > *，-1 表示不匹配
+func (o *PriceOverride) matchLevel(modelName string) int {
+	if o.ModelName == modelName {
+		return len(modelName) + 1
+	}
+	if prefix, ok := strings.CutSuffix(o.ModelName, "*"); ok && strings.HasPrefix(modelName, prefix) {
+		return len(prefix)
+	}
+	return -1
+}
+
+func (o *PriceOverride) validate() error {
+	o.ModelName = strings.TrimSpace(o.ModelName)
+	if o.UserId == 0 {
+		return errors.New("用户不能为空")
+	}
+	if o.ModelName == "" {
+		return errors.New("模型名称不能为空")
+	}
+	if o.RatioMultiplier < 0 || o.ModelPrice < 0 || o.CompletionRatio < 0 {
+		return errors.New("倍率与价格不能为负数")
+	}
+	if o.RatioMultiplier == 0 && o.ModelPrice == 0 && o.CompletionRatio == 0 {
+		return errors.New("倍率乘数、按次价格与补全倍率至少需要设置一项")
+	}
+	if o.ExpiredTime == 0 {
+		o.ExpiredTime = -1
+	}
+	if o.TokenId != 0 {
+		token, err := GetTokenById(o.TokenId)
+		if err != nil {
+			return errors.New("令牌不存在")
+		}
+		if token.UserId != o.UserId {
+			return errors.New("令牌不属于该用户")
+		}
+	}
+	return nil
+}
+
+func (o *PriceOverride) Insert() error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	o.CreatedTime = common.GetTimestamp()
+	if err := DB.Create(o).Error; err != nil {
+		return err
+	}
+	InitPriceOverrideCache()
+	return nil
+}
+
+func (o *PriceOverride) Update() error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	err := DB.Model(o).Select("token_id", "model_name", "ratio_multiplier", "model_price", "completion_ratio", "expired_time", "remark").Updates(o).Error
+	if err != nil {
+		return err
+	}
+	InitPriceOverrideCache()
+	return nil
+}
+
+func GetPriceOverrideById(id int) (*PriceOverride, error) {
+	var override PriceOverride
+	if err := DB.First(&override, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func DeletePriceOverrideById(id int) error {
+	if err := DB.Delete(&PriceOverride{}, "id = ?", id).Error; err != nil {
+		return err
+	}
+	InitPriceOverrideCache()
+	return nil
+}
+
+// GetPriceOverrides 列出价格覆盖，userId 为 0 时列出全部
+func GetPriceOverrides(userId int, pageInfo *common.PageInfo) (overrides []*PriceOverride, total int64, err error) {
+	query := DB.Model(&PriceOverride{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&overrides).Error
+	return overrides, total, err
+}
+
+var userPriceOverrides map[int][]*PriceOverride
+var priceOverrideSyncLock sync.RWMutex
+
+func InitPriceOverrideCache() {
+	if !common.MemoryCacheEnabled {
+		return
+	}
+	var overrides []*PriceOverride
+	if err := DB.Where("expired_time = -1 OR expired_time > ?", common.GetTimestamp()).Find(&overrides).Error; err != nil {
+		common.SysError("failed to load price overrides: " + err.Error())
+		return
+	}
+	newUserPriceOverrides := make(map[int][]*PriceOverride)
+	for _, override := range overrides {
+		newUserPriceOverrides[override.UserId] = append(newUserPriceOverrides[override.UserId], override)
+	}
+	priceOverrideSyncLock.Lock()
+	userPriceOverrides = newUserPriceOverrides
+	priceOverrideSyncLock.Unlock()
+}
+
+func SyncPriceOverrideCache(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		InitPriceOverrideCache()
+	}
+}
+
+func getUserPriceOverrides(userId int) ([]*PriceOverride, error) {
+	if common.MemoryCacheEnabled {
+		priceOverrideSyncLock.RLock()
+		defer priceOverrideSyncLock.RUnlock()
+		return userPriceOverrides[userId], nil
+	}
+	var overrides []*PriceOverride
+	err := DB.Where("user_id = ?", userId).Find(&overrides).Error
+	return overrides, err
+}
+
+// GetPriceOverride 返回请求适用的价格覆盖。优先级：令牌级覆盖 > 用户级覆盖，
+// 同一级别内精确模型名 > 更长的前缀匹配 > *，已过期的覆盖会被忽略
+func GetPriceOverride(userId int, tokenId int, modelName string) (*PriceOverride, bool) {
+	overrides, err := getUserPriceOverrides(userId)
+	if err != nil {
+		common.SysError("failed to get price overrides: " + err.Error())
+		return nil, false
+	}
+	now := common.GetTimestamp()
+	var best *PriceOverride
+	bestLevel := -1
+	for _, override := range overrides {
+		if override.Expired(now) || (override.TokenId != 0 && override.TokenId != tokenId) {
+			continue
+		}
+		level := override.matchLevel(modelName)
+		if level < 0 {
+			continue
+		}
+		// 令牌级覆盖总是优先于用户级覆盖
+		if override.TokenId != 0 {
+			level += 1 << 20
+		}
+		if level > bestLevel {
+			best = override
+			bestLevel = level
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	override := *best
+	return &override, true
+}
@@ -11,14 +11,15 @@ import (
 )
 
 type Pricing struct {
-	ModelName              string                  `json:"model_name"`
-	QuotaType              int                     `json:"quota_type"`
-	ModelRatio             float64                 `json:"model_ratio"`
-	ModelPrice             float64                 `json:"model_price"`
-	OwnerBy                string                  `json:"owner_by"`
-	CompletionRatio        float64                 `json:"completion_ratio"`
-	EnableGroup            []string                `json:"enable_groups"`
-	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
+	ModelName              string                         `json:"model_name"`
+	QuotaType              int                            `json:"quota_type"`
+	ModelRatio             float64                        `json:"model_ratio"`
+	ModelPrice             float64                        `json:"model_price"`
+	OwnerBy                string                         `json:"owner_by"`
+	CompletionRatio        float64                        `json:"completion_ratio"`
+	PriceTiers             []ratio_setting.ModelPriceTier `json:"price_tiers,omitempty"`
+	EnableGroup            []string                       `json:"enable_groups"`
+	SupportedEndpointTypes []constant.EndpointType        `json:"supported_endpoint_types"`
 }
 
 var (
@@ -119,6 +120,7 @@ func updatePricing() {
 			modelRatio, _ := ratio_setting.GetModelRatio(model)
 			pricing.ModelRatio = modelRatio
 			pricing.CompletionRatio = ratio_setting.GetCompletionRatio(model)
+			pricing.PriceTiers = ratio_setting.GetModelTierRatios(model)
 			pricing.QuotaType = 0
 		}
 		pricingMap = append(pricingMap, pricing)
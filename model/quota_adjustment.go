@@ -0,0 +1,239 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	QuotaAdjustmentTypeCredit = "credit" // 增加额度
+	QuotaAdjustmentTypeDebit  = "debit"  // 扣除额度
+	QuotaAdjustmentTypeRefund = "refund" // 退还一条消费日志的额度
+)
+
+var ErrQuotaAdjustmentCapExceeded = errors.New("超出今日调整额度上限")
+
+// QuotaAdjustment 管理员对用户额度的调整记录，Quota 为调整的额度（正数），方向由 Type 决定。
+// 退款记录的 LogId 为被退款的消费日志
+type QuotaAdjustment struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Type          string `json:"type" gorm:"type:varchar(16)"`
+	Quota         int    `json:"quota"`
+	LogId         int    `json:"log_id" gorm:"index"`
+	Reason        string `json:"reason" gorm:"type:varchar(255)"`
+	AdminId       int    `json:"admin_id" gorm:"index:idx_quota_adjustments_admin_time,priority:1"`
+	AdminUsername string `json:"admin_username" gorm:"type:varchar(64)"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint;index:idx_quota_adjustments_admin_time,priority:2"`
+}
+
+// SumAdminAdjustedQuota 返回管理员从 since 起调整的额度合计
+func SumAdminAdjustedQuota(adminId int, since int64) (int, error) {
+	return sumAdminAdjustedQuota(DB, adminId, since)
+}
+
+func sumAdminAdjustedQuota(tx *gorm.DB, adminId int, since int64) (int, error) {
+	var quota int
+	err := tx.Model(&QuotaAdjustment{}).Select("COALESCE(SUM(quota), 0)").
+		Where("admin_id = ? AND created_time >= ?", adminId, since).Scan(&quota).Error
+	return quota, err
+}
+
+// SumRefundedQuotaByLogId 返回一条消费日志已退款的额度合计
+func SumRefundedQuotaByLogId(logId int) (int, error) {
+	return sumRefundedQuotaByLogId(DB, logId)
+}
+
+func sumRefundedQuotaByLogId(tx *gorm.DB, logId int) (int, error) {
+	var quota int
+	err := tx.Model(&QuotaAdjustment{}).Select("COALESCE(SUM(quota), 0)").
+		Where("log_id = ? AND type = ?", logId, QuotaAdjustmentTypeRefund).Scan(&quota).Error
+	return quota, err
+}
+
+// consumeLogRefundableQuota 返回消费日志中由余额支付的额度，套餐抵扣的部分记录在 other.subscription_quota 中，不退回余额
+func consumeLogRefundableQuota(log *Log) int {
+	var other struct {
+		SubscriptionQuota int `json:"subscription_quota"`
+	}
+	if log.Other != "" {
+		if err := common.UnmarshalJsonStr(log.Other, &other); err != nil {
+			common.SysError(fmt.Sprintf("failed to parse other of log %d: %s", log.Id, err.Error()))
+		}
+	}
+	return max(log.Quota-other.SubscriptionQuota, 0)
+}
+
+// GetQuotaAdjustments 分页返回额度调整记录，userId 为 0 时返回所有用户的记录
+func GetQuotaAdjustments(userId int, pageInfo *common.PageInfo) (adjustments []*QuotaAdjustment, total int64, err error) {
+	query := DB.Model(&QuotaAdjustment{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&adjustments).Error
+	return adjustments, total, err
+}
+
+// AdjustUserQuota 按 adjustment 调整用户额度并保存调整记录，随后在用户日志中记录一条调整日志。
+// dailyCap 为管理员当日调整额度的上限，0 表示不限制；扣除额度时用户余额不能为负，
+// 退款的额度不能超过消费日志由余额支付且未退款的部分，退款同时退还令牌额度
+func AdjustUserQuota(adjustment *QuotaAdjustment, dailyCap int) error {
+	if adjustment.Quota <= 0 {
+		return errors.New("调整额度必须大于 0")
+	}
+	var consumeLog *Log
+	switch adjustment.Type {
+	case QuotaAdjustmentTypeCredit, QuotaAdjustmentTypeDebit:
+		adjustment.LogId = 0
+	case QuotaAdjustmentTypeRefund:
+		var log Log
+		err := LOG_DB.First(&log, "id = ? AND type = ?", adjustment.LogId, LogTypeConsume).Error
+		if err != nil {
+			return errors.New("消费日志不存在")
+		}
+		if log.UserId != adjustment.UserId {
+			return errors.New("消费日志不属于该用户")
+		}
+		consumeLog = &log
+	default:
+		return errors.New("无效的调整类型")
+	}
+
+	now := time.Now()
+	adjustment.CreatedTime = now.Unix()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 按 id 顺序锁定管理员与用户的记录，同一管理员的每日上限检查、同一用户消费日志的退款检查
+		// 在多节点部署下也会串行执行，不会被并发请求绕过
+		var lockedIds []int
+		err := tx.Unscoped().Model(&User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []int{adjustment.AdminId, adjustment.UserId}).Order("id").Pluck("id", &lockedIds).Error
+		if err != nil {
+			return err
+		}
+		if dailyCap > 0 {
+			year, month, day := now.Date()
+			adjusted, err := sumAdminAdjustedQuota(tx, adjustment.AdminId, time.Date(year, month, day, 0, 0, 0, 0, now.Location()).Unix())
+			if err != nil {
+				return err
+			}
+			if adjusted+adjustment.Quota > dailyCap {
+				return fmt.Errorf("%w，今日已调整 %s，上限 %s", ErrQuotaAdjustmentCapExceeded, common.LogQuota(adjusted), common.LogQuota(dailyCap))
+			}
+		}
+		if consumeLog != nil {
+			refunded, err := sumRefundedQuotaByLogId(tx, consumeLog.Id)
+			if err != nil {
+				return err
+			}
+			refundable := consumeLogRefundableQuota(consumeLog)
+			if refunded+adjustment.Quota > refundable {
+				return fmt.Errorf("退款额度超过消费日志的可退额度 %s", common.LogQuota(max(refundable-refunded, 0)))
+			}
+		}
+
+		query := tx.Model(&User{}).Where("id = ?", adjustment.UserId)
+		var updates map[string]interface{}
+		switch adjustment.Type {
+		case QuotaAdjustmentTypeCredit:
+			updates = map[string]interface{}{"quota": gorm.Expr("quota + ?", adjustment.Quota)}
+		case QuotaAdjustmentTypeDebit:
+			query = query.Where("quota >= ?", adjustment.Quota)
+			updates = map[string]interface{}{"quota": gorm.Expr("quota - ?", adjustment.Quota)}
+		case QuotaAdjustmentTypeRefund:
+			updates = map[string]interface{}{
+				"quota":      gorm.Expr("quota + ?", adjustment.Quota),
+				"used_quota": gorm.Expr("used_quota - ?", adjustment.Quota),
+			}
+		}
+		result := query.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if adjustment.Type == QuotaAdjustmentTypeDebit {
+				return errors.New("用户余额不足")
+			}
+			return errors.New("用户不存在")
+		}
+		if err := tx.Create(adjustment).Error; err != nil {
+			return err
+		}
+		amount := adjustment.Quota
+		if adjustment.Type == QuotaAdjustmentTypeDebit {
+			amount = -amount
+		}
+		return RecordQuotaLedger(tx, adjustment.UserId, QuotaLedgerTypeAdjustment, amount, strconv.Itoa(adjustment.Id))
+	})
+	if err != nil {
+		return err
+	}
+
+	delta := int64(adjustment.Quota)
+	if adjustment.Type == QuotaAdjustmentTypeDebit {
+		delta = -delta
+	}
+	if err := cacheIncrUserQuota(adjustment.UserId, delta); err != nil {
+		common.SysError("failed to update user quota cache: " + err.Error())
+	}
+	if consumeLog != nil && consumeLog.TokenId != 0 {
+		token, err := GetTokenById(consumeLog.TokenId)
+		if err == nil && !token.UnlimitedQuota {
+			if err := IncreaseTokenQuota(token.Id, token.Key, adjustment.Quota); err != nil {
+				common.SysError("failed to refund token quota: " + err.Error())
+			}
+		}
+	}
+	recordQuotaAdjustmentLog(adjustment, consumeLog)
+	return nil
+}
+
+// recordQuotaAdjustmentLog 在用户日志中记录额度调整，other 中的 admin_info 不会展示给用户
+func recordQuotaAdjustmentLog(adjustment *QuotaAdjustment, consumeLog *Log) {
+	username, _ := GetUsernameById(adjustment.UserId, false)
+	var content string
+	switch adjustment.Type {
+	case QuotaAdjustmentTypeCredit:
+		content = fmt.Sprintf("管理员增加额度 %s，原因：%s", common.LogQuota(adjustment.Quota), adjustment.Reason)
+	case QuotaAdjustmentTypeDebit:
+		content = fmt.Sprintf("管理员扣除额度 %s，原因：%s", common.LogQuota(adjustment.Quota), adjustment.Reason)
+	case QuotaAdjustmentTypeRefund:
+		content = fmt.Sprintf("管理员退还消费额度 %s，原因：%s", common.LogQuota(adjustment.Quota), adjustment.Reason)
+	}
+	other := map[string]interface{}{
+		"adjustment_id":   adjustment.Id,
+		"adjustment_type": adjustment.Type,
+		"admin_info": map[string]interface{}{
+			"admin_id":       adjustment.AdminId,
+			"admin_username": adjustment.AdminUsername,
+		},
+	}
+	log := &Log{
+		UserId:    adjustment.UserId,
+		Username:  username,
+		CreatedAt: adjustment.CreatedTime,
+		Type:      LogTypeAdjustment,
+		Content:   content,
+		Quota:     adjustment.Quota,
+	}
+	if consumeLog != nil {
+		other["log_id"] = consumeLog.Id
+		other["log_created_at"] = consumeLog.CreatedAt
+		log.TokenName = consumeLog.TokenName
+		log.TokenId = consumeLog.TokenId
+		log.ModelName = consumeLog.ModelName
+		log.Group = consumeLog.Group
+	}
+	log.Other = common.MapToJsonStr(other)
+	if err := insertLog(log); err != nil {
+		common.SysError("failed to record log: " + err.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAdjustUserQuotaRefundLimit(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestUser(t, "admin", 0)
+	user := createTestUser(t, "refund", 100)
+	other := createTestUser(t, "other", 0)
+	consumeLog := &Log{UserId: user.Id, Type: LogTypeConsume, Quota: 500, ModelName: "gpt-4o"}
+	if err := LOG_DB.Create(consumeLog).Error; err != nil {
+		t.Fatal(err)
+	}
+	refund := func(userId int, quota int) error {
+		return AdjustUserQuota(&QuotaAdjustment{
+			UserId:  userId,
+			Type:    QuotaAdjustmentTypeRefund,
+			Quota:   quota,
+			LogId:   consumeLog.Id,
+			Reason:  "upstream error",
+			AdminId: admin.Id,
+		}, 0)
+	}
+
+	if err := refund(other.Id, 100); err == nil {
+		t.Fatal("expected refund of another user's log to fail")
+	}
+	if err := refund(user.Id, 300); err != nil {
+		t.Fatal(err)
+	}
+	if err := refund(user.Id, 201); err == nil {
+		t.Fatal("expected refund above the remaining log quota to fail")
+	}
+	if err := refund(user.Id, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := refund(user.Id, 1); err == nil {
+		t.Fatal("expected refund of a fully refunded log to fail")
+	}
+
+	if quota := getTestUserQuota(t, user.Id); quota != 600 {
+		t.Fatalf("expected quota 600, got %d", quota)
+	}
+	if balance := getTestLedgerBalance(t, user.Id); balance != 500 {
+		t.Fatalf("expected ledger to record refunds of 500, got %d", balance)
+	}
+}
+
+func TestAdjustUserQuotaRefundExcludesSubscription(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestUser(t, "admin", 0)
+	user := createTestUser(t, "subscriber", 0)
+	// 500 的消费中 400 由套餐抵扣，只有余额支付的 100 可以退款
+	consumeLog := &Log{UserId: user.Id, Type: LogTypeConsume, Quota: 500, ModelName: "gpt-4o", Other: `{"subscription_quota":400}`}
+	if err := LOG_DB.Create(consumeLog).Error; err != nil {
+		t.Fatal(err)
+	}
+	refund := func(quota int) error {
+		return AdjustUserQuota(&QuotaAdjustment{
+			UserId:  user.Id,
+			Type:    QuotaAdjustmentTypeRefund,
+			Quota:   quota,
+			LogId:   consumeLog.Id,
+			Reason:  "upstream error",
+			AdminId: admin.Id,
+		}, 0)
+	}
+
+	if err := refund(101); err == nil {
+		t.Fatal("expected refund of the subscription-covered part to fail")
+	}
+	if err := refund(100); err != nil {
+		t.Fatal(err)
+	}
+	if err := refund(1); err == nil {
+		t.Fatal("expected the balance-charged part to be fully refunded")
+	}
+	if quota := getTestUserQuota(t, user.Id); quota != 100 {
+		t.Fatalf("expected quota 100, got %d", quota)
+	}
+}
+
+func TestAdjustUserQuotaDailyCap(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestUser(t, "admin", 0)
+	user := createTestUser(t, "capped", 0)
+	adjust := func(adjustmentType string, quota int) error {
+		return AdjustUserQuota(&QuotaAdjustment{
+			UserId:  user.Id,
+			Type:    adjustmentType,
+			Quota:   quota,
+			Reason:  "compensation",
+			AdminId: admin.Id,
+		}, 1000)
+	}
+
+	if err := adjust(QuotaAdjustmentTypeCredit, 600); err != nil {
+		t.Fatal(err)
+	}
+	if err := adjust(QuotaAdjustmentTypeDebit, 700); err == nil {
+		t.Fatal("expected debit above the balance to fail")
+	}
+	if err := adjust(QuotaAdjustmentTypeDebit, 300); err != nil {
+		t.Fatal(err)
+	}
+	if err := adjust(QuotaAdjustmentTypeCredit, 101); !errors.Is(err, ErrQuotaAdjustmentCapExceeded) {
+		t.Fatalf("expected daily cap error, got %v", err)
+	}
+	if err := adjust(QuotaAdjustmentTypeCredit, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if quota := getTestUserQuota(t, user.Id); quota != 400 {
+		t.Fatalf("expected quota 400, got %d", quota)
+	}
+	if balance := getTestLedgerBalance(t, user.Id); balance != 400 {
+		t.Fatalf("expected ledger balance 400, got %d", balance)
+	}
+}
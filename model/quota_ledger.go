@@ -0,0 +1,328 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	QuotaLedgerTypeOpening      = "opening"       // 启用账本时已有的余额
+	QuotaLedgerTypeTopUp        = "topup"         // 在线充值
+	QuotaLedgerTypeTopUpRefund  = "topup_refund"  // 支付退款扣回的额度
+	QuotaLedgerTypeRedemption   = "redemption"    // 兑换码
+	QuotaLedgerTypePreConsume   = "pre_consume"   // 请求预扣费
+	QuotaLedgerTypeConsume      = "consume"       // 请求结算
+	QuotaLedgerTypeRefund       = "refund"        // 返还预扣费、任务失败退款
+	QuotaLedgerTypeAffTransfer  = "aff_transfer"  // 邀请额度转入余额
+	QuotaLedgerTypeReward       = "reward"        // 注册与邀请赠送
+	QuotaLedgerTypeAdjustment   = "adjustment"    // 管理员调整额度
+	QuotaLedgerTypeManage       = "manage"        // 管理员直接修改余额
+	QuotaLedgerTypeSubscription = "subscription"  // 订阅套餐扣费
+	QuotaLedgerTypeCreditSettle = "credit_settle" // 结清信用账单
+)
+
+var errQuotaLedgerAppendOnly = errors.New("额度账本只能追加，不能修改或删除")
+
+// QuotaLedgerEntry 额度账本分录，只追加、不修改。每笔额度变动写入 TxId 相同、金额相反的两条分录：
+// 用户账户 user:<id> 与对方账户（系统账户 system:<type>，邀请额度转入时为邀请额度账户 aff:<id>），
+// 因此所有分录的金额合计恒为 0，用户账户的分录合计即为用户当前余额
+type QuotaLedgerEntry struct {
+	Id          int    `json:"id"`
+	TxId        string `json:"tx_id" gorm:"type:varchar(32);index"`
+	Account     string `json:"account" gorm:"type:varchar(64);index;index:idx_quota_ledger_account_time,priority:1"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Type        string `json:"type" gorm:"type:varchar(32);index"`
+	Amount      int    `json:"amount"`
+	RefId       string `json:"ref_id" gorm:"type:varchar(64)"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint;index;index:idx_quota_ledger_account_time,priority:2"`
+}
+
+func (entry *QuotaLedgerEntry) BeforeUpdate(tx *gorm.DB) error {
+	return errQuotaLedgerAppendOnly
+}
+
+func (entry *QuotaLedgerEntry) BeforeDelete(tx *gorm.DB) error {
+	return errQuotaLedgerAppendOnly
+}
+
+// QuotaLedgerCheckpoint 用户账户在 CheckpointTime 时刻（不含）的账本余额，每天记录一次，
+// 只为上次记录以来有分录的用户记录。计算余额时从最近的检查点加上之后的分录，不必合计用户的全部分录
+type QuotaLedgerCheckpoint struct {
+	Id             int   `json:"id"`
+	UserId         int   `json:"user_id" gorm:"uniqueIndex:idx_quota_ledger_checkpoint_user_time,priority:1"`
+	CheckpointTime int64 `json:"checkpoint_time" gorm:"bigint;uniqueIndex:idx_quota_ledger_checkpoint_user_time,priority:2;index"`
+	Balance        int   `json:"balance"`
+	CreatedTime    int64 `json:"created_time" gorm:"bigint"`
+}
+
+// QuotaLedgerCheckpointDelay 检查点只覆盖至少这么久之前的分录，保证这些分录所在的事务都已提交
+const QuotaLedgerCheckpointDelay = 10 * time.Minute
+
+// quotaLedgerOpeningsOptionKey 记录期初余额完成后写入的配置项，之后注册的用户不需要期初余额
+const quotaLedgerOpeningsOptionKey = "QuotaLedgerOpeningsRecorded"
+
+func quotaLedgerUserAccount(userId int) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+// newQuotaLedgerEntries 生成一笔额度变动的两条分录，amount 为正时增加用户余额，为负时减少
+func newQuotaLedgerEntries(userId int, ledgerType string, amount int, refId string) []*QuotaLedgerEntry {
+	if amount == 0 {
+		return nil
+	}
+	counterAccount := "system:" + ledgerType
+	if ledgerType == QuotaLedgerTypeAffTransfer {
+		counterAccount = fmt.Sprintf("aff:%d", userId)
+	}
+	txId := common.GetUUID()
+	now := common.GetTimestamp()
+	return []*QuotaLedgerEntry{
+		{TxId: txId, Account: quotaLedgerUserAccount(userId), UserId: userId, Type: ledgerType, Amount: amount, RefId: refId, CreatedTime: now},
+		{TxId: txId, Account: counterAccount, UserId: userId, Type: ledgerType, Amount: -amount, RefId: refId, CreatedTime: now},
+	}
+}
+
+// RecordQuotaLedger 在事务 tx 中记录一笔额度变动，调用方需在同一事务中修改 User.Quota
+func RecordQuotaLedger(tx *gorm.DB, userId int, ledgerType string, amount int, refId string) error {
+	entries := newQuotaLedgerEntries(userId, ledgerType, amount, refId)
+	if len(entries) == 0 {
+		return nil
+	}
+	return tx.Create(&entries).Error
+}
+
+// updateUserQuotaWithLedger 在同一事务中修改用户余额并写入账本分录
+func updateUserQuotaWithLedger(id int, delta int, entries []*QuotaLedgerEntry) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if delta != 0 {
+			err := tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta)).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.Create(&entries).Error
+	})
+}
+
+// GetQuotaLedgerEntries 分页返回账本分录，userId 为 0 时返回所有用户的分录
+func GetQuotaLedgerEntries(userId int, ledgerType string, pageInfo *common.PageInfo) (entries []*QuotaLedgerEntry, total int64, err error) {
+	query := DB.Model(&QuotaLedgerEntry{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if ledgerType != "" {
+		query = query.Where("type = ?", ledgerType)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&entries).Error
+	return entries, total, err
+}
+
+// getLatestQuotaLedgerCheckpointTime 返回最近一次记录检查点的时刻，没有检查点时返回 0
+func getLatestQuotaLedgerCheckpointTime(tx *gorm.DB) (int64, error) {
+	var checkpointTime int64
+	err := tx.Model(&QuotaLedgerCheckpoint{}).Select("COALESCE(MAX(checkpoint_time), 0)").Scan(&checkpointTime).Error
+	return checkpointTime, err
+}
+
+// getQuotaLedgerCheckpointBalances 返回用户在 at 时刻或之前最近的检查点余额，没有检查点的用户不在结果中
+func getQuotaLedgerCheckpointBalances(tx *gorm.DB, userIds []int, at int64) (map[int]int, error) {
+	var checkpoints []*QuotaLedgerCheckpoint
+	err := tx.Table("quota_ledger_checkpoints AS c").Select("c.user_id, c.balance").
+		Where("c.user_id IN ?", userIds).
+		Where("c.checkpoint_time = (SELECT MAX(c2.checkpoint_time) FROM quota_ledger_checkpoints c2 WHERE c2.user_id = c.user_id AND c2.checkpoint_time <= ?)", at).
+		Scan(&checkpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[int]int, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		balances[checkpoint.UserId] = checkpoint.Balance
+	}
+	return balances, nil
+}
+
+// GetQuotaLedgerBalances 返回用户账户的账本余额，即最近的检查点加上之后的分录合计，没有分录的用户余额为 0。
+// 每次检查点都覆盖了上一次以来有分录的所有用户，因此最近一次检查点之后的分录合计加上各用户最近的检查点即为余额
+func GetQuotaLedgerBalances(userIds []int) (map[int]int, error) {
+	checkpointTime, err := getLatestQuotaLedgerCheckpointTime(DB)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := getQuotaLedgerCheckpointBalances(DB, userIds, checkpointTime)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]string, 0, len(userIds))
+	for _, id := range userIds {
+		accounts = append(accounts, quotaLedgerUserAccount(id))
+	}
+	var rows []struct {
+		UserId  int
+		Balance int
+	}
+	err = DB.Model(&QuotaLedgerEntry{}).Select("user_id, SUM(amount) AS balance").
+		Where("account IN ? AND created_time >= ?", accounts, checkpointTime).Group("user_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		balances[row.UserId] += row.Balance
+	}
+	return balances, nil
+}
+
+// GetQuotaLedgerBalanceAt 返回用户在 at 时刻的账本余额，即 at 之前最近的检查点加上之后、at 之前的分录合计。
+// 启用账本时记录的期初余额总是计入，因此启用账本之前的时刻以期初余额近似
+func GetQuotaLedgerBalanceAt(userId int, at int64) (int, error) {
+	var checkpoint QuotaLedgerCheckpoint
+	err := DB.Where("user_id = ? AND checkpoint_time <= ?", userId, at).Order("checkpoint_time desc").Limit(1).Find(&checkpoint).Error
+	if err != nil {
+		return 0, err
+	}
+	var balance int
+	err = DB.Model(&QuotaLedgerEntry{}).Select("COALESCE(SUM(amount), 0)").
+		Where("account = ? AND created_time >= ? AND (created_time < ? OR type = ?)", quotaLedgerUserAccount(userId), checkpoint.CheckpointTime, at, QuotaLedgerTypeOpening).
+		Scan(&balance).Error
+	return checkpoint.Balance + balance, err
+}
+
+// CreateQuotaLedgerCheckpoints 为上次检查点以来有分录的用户记录 at 时刻的检查点，返回记录的用户数。
+// at 不晚于上次检查点时不做任何事；所有检查点在同一事务中写入，中途失败不会留下不完整的一批
+func CreateQuotaLedgerCheckpoints(at int64) (int, error) {
+	count := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		lastTime, err := getLatestQuotaLedgerCheckpointTime(tx)
+		if err != nil || lastTime >= at {
+			return err
+		}
+		var rows []struct {
+			UserId int
+			Amount int
+		}
+		err = tx.Model(&QuotaLedgerEntry{}).Select("user_id, SUM(amount) AS amount").
+			Where("account LIKE ? AND created_time >= ? AND created_time < ?", "user:%", lastTime, at).
+			Group("user_id").Order("user_id").Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		now := common.GetTimestamp()
+		for start := 0; start < len(rows); start += 500 {
+			batch := rows[start:min(start+500, len(rows))]
+			userIds := make([]int, 0, len(batch))
+			for _, row := range batch {
+				userIds = append(userIds, row.UserId)
+			}
+			balances, err := getQuotaLedgerCheckpointBalances(tx, userIds, lastTime)
+			if err != nil {
+				return err
+			}
+			checkpoints := make([]*QuotaLedgerCheckpoint, 0, len(batch))
+			for _, row := range batch {
+				checkpoints = append(checkpoints, &QuotaLedgerCheckpoint{
+					UserId:         row.UserId,
+					CheckpointTime: at,
+					Balance:        balances[row.UserId] + row.Amount,
+					CreatedTime:    now,
+				})
+			}
+			if err := tx.Create(&checkpoints).Error; err != nil {
+				return err
+			}
+		}
+		count = len(rows)
+		return nil
+	})
+	return count, err
+}
+
+// SumQuotaLedgerAmount 返回用户账户在 start 之后（含）指定类型分录的合计
+func SumQuotaLedgerAmount(userId int, ledgerTypes []string, start int64) (int, error) {
+	var amount int
+	err := DB.Model(&QuotaLedgerEntry{}).Select("COALESCE(SUM(amount), 0)").
+		Where("account = ? AND type IN ? AND created_time >= ?", quotaLedgerUserAccount(userId), ledgerTypes, start).
+		Scan(&amount).Error
+	return amount, err
+}
+
+// UserQuotaBalance 用户余额与账本余额
+type UserQuotaBalance struct {
+	Id     int
+	Quota  int
+	Ledger int
+}
+
+// GetUserQuotaBalances 按 id 顺序返回 afterId 之后的 limit 个用户（包括已注销的用户）的余额与账本余额
+func GetUserQuotaBalances(afterId int, limit int) ([]*UserQuotaBalance, error) {
+	var users []*UserQuotaBalance
+	err := DB.Unscoped().Model(&User{}).Select("id, quota").Where("id > ?", afterId).
+		Order("id").Limit(limit).Scan(&users).Error
+	if err != nil || len(users) == 0 {
+		return users, err
+	}
+	userIds := make([]int, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.Id)
+	}
+	balances, err := GetQuotaLedgerBalances(userIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		user.Ledger = balances[user.Id]
+	}
+	return users, nil
+}
+
+// InitQuotaLedgerOpenings 为启用账本前已有余额的用户记录期初余额，全部记录完成后不再执行。
+// 从节点可能已经在处理请求并写入分录，因此在锁定用户记录后以余额减去已有分录的合计作为期初余额，
+// 与并发的额度变动互不遗漏、不重复。返回记录期初余额的用户数
+func InitQuotaLedgerOpenings() (int, error) {
+	common.OptionMapRWMutex.RLock()
+	recorded := common.OptionMap[quotaLedgerOpeningsOptionKey] == "true"
+	common.OptionMapRWMutex.RUnlock()
+	if recorded {
+		return 0, nil
+	}
+	var userIds []int
+	err := DB.Unscoped().Model(&User{}).Where("quota <> 0").
+		Where("NOT EXISTS (?)", DB.Model(&QuotaLedgerEntry{}).Select("1").
+			Where("quota_ledger_entries.user_id = users.id AND quota_ledger_entries.type = ?", QuotaLedgerTypeOpening)).
+		Pluck("id", &userIds).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, userId := range userIds {
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			var quota int
+			err := tx.Unscoped().Model(&User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ?", userId).Select("quota").Scan(&quota).Error
+			if err != nil {
+				return err
+			}
+			var ledger int
+			err = tx.Model(&QuotaLedgerEntry{}).Select("COALESCE(SUM(amount), 0)").
+				Where("account = ?", quotaLedgerUserAccount(userId)).Scan(&ledger).Error
+			if err != nil || quota == ledger {
+				return err
+			}
+			count++
+			return RecordQuotaLedger(tx, userId, QuotaLedgerTypeOpening, quota-ledger, "")
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, UpdateOption(quotaLedgerOpeningsOptionKey, "true")
+}
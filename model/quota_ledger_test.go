@@ -0,0 +1,124 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func TestInitQuotaLedgerOpeningsCountsExistingEntries(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "opening", 1000)
+	// 记录期初余额之前从节点已经为用户写入了分录
+	if err := updateUserQuotaWithLedger(user.Id, 50, newQuotaLedgerEntries(user.Id, QuotaLedgerTypeTopUp, 50, "")); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := InitQuotaLedgerOpenings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 opening, got %d", count)
+	}
+	if balance := getTestLedgerBalance(t, user.Id); balance != 1050 {
+		t.Fatalf("expected ledger balance 1050, got %d", balance)
+	}
+
+	// 全部记录完成后不再执行
+	createTestUser(t, "later", 300)
+	if count, err = InitQuotaLedgerOpenings(); err != nil || count != 0 {
+		t.Fatalf("expected openings to be skipped, got %d, %v", count, err)
+	}
+}
+
+func TestBatchQuotaLedgerEntriesNettedPerFlush(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "batch", 1000)
+	addUserQuotaRecord(user.Id, -10, QuotaLedgerTypeConsume)
+	addUserQuotaRecord(user.Id, -20, QuotaLedgerTypeConsume)
+	addUserQuotaRecord(user.Id, 5, QuotaLedgerTypeRefund)
+	batchUpdate()
+
+	if quota := getTestUserQuota(t, user.Id); quota != 975 {
+		t.Fatalf("expected quota 975, got %d", quota)
+	}
+	var entries []*QuotaLedgerEntry
+	if err := DB.Where("account = ?", quotaLedgerUserAccount(user.Id)).Order("type").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected one user entry per type, got %d", len(entries))
+	}
+	if entries[0].Type != QuotaLedgerTypeConsume || entries[0].Amount != -30 || entries[1].Type != QuotaLedgerTypeRefund || entries[1].Amount != 5 {
+		t.Fatalf("unexpected entries: %+v %+v", entries[0], entries[1])
+	}
+}
+
+func TestQuotaLedgerCheckpoints(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "checkpoint", 0)
+	other := createTestUser(t, "idle", 0)
+	now := common.GetTimestamp()
+	insert := func(userId int, ledgerType string, amount int, createdTime int64) {
+		t.Helper()
+		entries := newQuotaLedgerEntries(userId, ledgerType, amount, "")
+		for _, entry := range entries {
+			entry.CreatedTime = createdTime
+		}
+		if err := DB.Create(&entries).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert(user.Id, QuotaLedgerTypeTopUp, 1000, now-300)
+	insert(other.Id, QuotaLedgerTypeTopUp, 70, now-300)
+	insert(user.Id, QuotaLedgerTypeConsume, -100, now-200)
+
+	count, err := CreateQuotaLedgerCheckpoints(now - 150)
+	if err != nil || count != 2 {
+		t.Fatalf("expected checkpoints for 2 users, got %d, %v", count, err)
+	}
+	if count, err = CreateQuotaLedgerCheckpoints(now - 150); err != nil || count != 0 {
+		t.Fatalf("expected no checkpoints at the same time, got %d, %v", count, err)
+	}
+
+	insert(user.Id, QuotaLedgerTypeConsume, -50, now-100)
+	// 第二次检查点只为有新分录的用户记录，并在上一次的余额上累加
+	if count, err = CreateQuotaLedgerCheckpoints(now - 50); err != nil || count != 1 {
+		t.Fatalf("expected checkpoint for 1 user, got %d, %v", count, err)
+	}
+	var checkpoint QuotaLedgerCheckpoint
+	if err := DB.Where("user_id = ? AND checkpoint_time = ?", user.Id, now-50).First(&checkpoint).Error; err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Balance != 850 {
+		t.Fatalf("expected checkpoint balance 850, got %d", checkpoint.Balance)
+	}
+
+	insert(user.Id, QuotaLedgerTypeRefund, 20, now)
+	if balance := getTestLedgerBalance(t, user.Id); balance != 870 {
+		t.Fatalf("expected balance 870, got %d", balance)
+	}
+	if balance := getTestLedgerBalance(t, other.Id); balance != 70 {
+		t.Fatalf("expected idle user balance 70, got %d", balance)
+	}
+
+	cases := []struct {
+		at       int64
+		expected int
+	}{
+		{now - 250, 1000},
+		{now - 150, 900},
+		{now - 120, 900},
+		{now - 50, 850},
+		{now + 1, 870},
+	}
+	for _, c := range cases {
+		balance, err := GetQuotaLedgerBalanceAt(user.Id, c.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance != c.expected {
+			t.Errorf("balance at %d: expected %d, got %d", c.at-now, c.expected, balance)
+		}
+	}
+}
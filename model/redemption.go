@@ -139,6 +139,12 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if err != nil {
 			return err
 		}
+		if err = applyCreditPayment(tx, userId, redemption.Quota); err != nil {
+			return err
+		}
+		if err = RecordQuotaLedger(tx, userId, QuotaLedgerTypeRedemption, redemption.Quota, strconv.Itoa(redemption.Id)); err != nil {
+			return err
+		}
 		redemption.RedeemedTime = common.GetTimestamp()
 		redemption.Status = common.RedemptionCodeStatusUsed
 		redemption.UsedUserId = userId
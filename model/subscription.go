@@ -0,0 +1,510 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errSubscriptionInsufficientBalance = errors.New("余额不足")
+
+// SubscriptionPlan 订阅套餐，每个周期从用户余额扣除 Price 并发放 Quota 的套餐额度，
+// 请求优先使用套餐额度，用完后再使用余额
+type SubscriptionPlan struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+	// Price 每个周期的费用（额度单位）
+	Price        int `json:"price" gorm:"default:0"`
+	Quota        int `json:"quota" gorm:"default:0"`
+	DurationDays int `json:"duration_days" gorm:"default:30"`
+	// Models 与 Groups 为逗号分隔的列表，为空时不限制，模型名称支持以 * 结尾的前缀匹配
+	Models string `json:"models" gorm:"type:text"`
+	Groups string `json:"groups" gorm:"column:allowed_groups;type:varchar(255)"`
+	// RolloverPercent 续费时剩余套餐额度结转到下个周期的百分比，RolloverCap 为结转上限，0 表示不限制
+	RolloverPercent int   `json:"rollover_percent" gorm:"default:0"`
+	RolloverCap     int   `json:"rollover_cap" gorm:"default:0"`
+	Status          int   `json:"status" gorm:"default:1"`
+	CreatedTime     int64 `json:"created_time" gorm:"bigint"`
+}
+
+// UserSubscription 用户的订阅，Models 与 Groups 为订阅或续费时套餐的快照
+type UserSubscription struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index:idx_user_subscriptions_user_status"`
+	PlanId      int    `json:"plan_id" gorm:"index"`
+	PlanName    string `json:"plan_name" gorm:"type:varchar(64)"`
+	Models      string `json:"models" gorm:"type:text"`
+	Groups      string `json:"groups" gorm:"column:allowed_groups;type:varchar(255)"`
+	Allowance   int    `json:"allowance" gorm:"default:0"`
+	Remaining   int    `json:"remaining" gorm:"default:0"`
+	PeriodStart int64  `json:"period_start" gorm:"bigint"`
+	PeriodEnd   int64  `json:"period_end" gorm:"bigint;index"`
+	AutoRenew   bool   `json:"auto_renew"`
+	Status      int    `json:"status" gorm:"default:1;index:idx_user_subscriptions_user_status"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func (plan *SubscriptionPlan) validate() error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if plan.Price < 0 || plan.Quota <= 0 {
+		return errors.New("套餐价格不能为负数，套餐额度必须大于 0")
+	}
+	if plan.DurationDays <= 0 {
+		return errors.New("套餐周期必须大于 0 天")
+	}
+	if plan.RolloverPercent < 0 || plan.RolloverPercent > 100 || plan.RolloverCap < 0 {
+		return errors.New("结转比例必须在 0 到 100 之间，结转上限不能为负数")
+	}
+	if plan.Status == 0 {
+		plan.Status = common.SubscriptionPlanStatusEnabled
+	}
+	return nil
+}
+
+func GetAllSubscriptionPlans(onlyEnabled bool) ([]*SubscriptionPlan, error) {
+	var plans []*SubscriptionPlan
+	query := DB.Order("id asc")
+	if onlyEnabled {
+		query = query.Where("status = ?", common.SubscriptionPlanStatusEnabled)
+	}
+	err := query.Find(&plans).Error
+	return plans, err
+}
+
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	var plan SubscriptionPlan
+	if err := DB.First(&plan, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (plan *SubscriptionPlan) Insert() error {
+	if err := plan.validate(); err != nil {
+		return err
+	}
+	plan.CreatedTime = common.GetTimestamp()
+	return DB.Create(plan).Error
+}
+
+// Update 修改套餐，已有的订阅在下次续费时才会使用新的设置
+func (plan *SubscriptionPlan) Update() error {
+	if err := plan.validate(); err != nil {
+		return err
+	}
+	return DB.Model(plan).Select("name", "description", "price", "quota", "duration_days", "models", "allowed_groups",
+		"rollover_percent", "rollover_cap", "status").Updates(plan).Error
+}
+
+// DeleteSubscriptionPlanById 删除套餐，仍有生效中订阅的套餐只能禁用
+func DeleteSubscriptionPlanById(id int) error {
+	var count int64
+	err := DB.Model(&UserSubscription{}).Where("plan_id = ? AND status = ?", id, common.SubscriptionStatusActive).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该套餐仍有生效中的订阅，请先禁用套餐")
+	}
+	return DB.Delete(&SubscriptionPlan{}, "id = ?", id).Error
+}
+
+func splitSubscriptionList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Matches 判断订阅是否可用于指定模型与分组
+func (sub *UserSubscription) Matches(modelName string, group string) bool {
+	if groups := splitSubscriptionList(sub.Groups); len(groups) > 0 && !common.StringsContains(groups, group) {
+		return false
+	}
+	models := splitSubscriptionList(sub.Models)
+	if len(models) == 0 {
+		return true
+	}
+	for _, m := range models {
+		if m == modelName {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(m, "*"); ok && strings.HasPrefix(modelName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func GetUserSubscriptions(userId int, onlyActive bool) ([]*UserSubscription, error) {
+	var subs []*UserSubscription
+	query := DB.Where("user_id = ?", userId)
+	if onlyActive {
+		query = query.Where("status = ? AND period_end > ?", common.SubscriptionStatusActive, common.GetTimestamp())
+	}
+	err := query.Order("period_end asc").Find(&subs).Error
+	return subs, err
+}
+
+func GetAllUserSubscriptions(userId int, pageInfo *common.PageInfo) (subs []*UserSubscription, total int64, err error) {
+	query := DB.Model(&UserSubscription{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&subs).Error
+	return subs, total, err
+}
+
+// GetSubscriptionRemaining 返回用户可用于指定模型与分组的套餐额度之和
+func GetSubscriptionRemaining(userId int, modelName string, group string) (int, error) {
+	subs, err := GetUserSubscriptions(userId, true)
+	if err != nil {
+		return 0, err
+	}
+	remaining := 0
+	for _, sub := range subs {
+		if sub.Remaining > 0 && sub.Matches(modelName, group) {
+			remaining += sub.Remaining
+		}
+	}
+	return remaining, nil
+}
+
+// ConsumeSubscriptionQuota 从可用的订阅中扣除额度，先到期的订阅优先，返回实际扣除的额度
+func ConsumeSubscriptionQuota(userId int, modelName string, group string, quota int) (int, error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+	subs, err := GetUserSubscriptions(userId, true)
+	if err != nil {
+		return 0, err
+	}
+	consumed := 0
+	for _, sub := range subs {
+		if consumed >= quota {
+			break
+		}
+		if sub.Remaining <= 0 || !sub.Matches(modelName, group) {
+			continue
+		}
+		take := min(quota-consumed, sub.Remaining)
+		result := DB.Model(&UserSubscription{}).Where("id = ? AND remaining >= ?", sub.Id, take).
+			Update("remaining", gorm.Expr("remaining - ?", take))
+		if result.Error != nil {
+			return consumed, result.Error
+		}
+		// 并发请求已经用掉了这部分额度，跳过该订阅
+		if result.RowsAffected == 0 {
+			continue
+		}
+		consumed += take
+	}
+	return consumed, nil
+}
+
+// RefundSubscriptionQuota 将失败任务从套餐中扣除的额度退回到仍在生效的订阅，后到期的订阅优先，
+// 退回后不超过订阅本周期的总额度。订阅已经到期或终止时无法退回的部分作废，返回实际退回的额度
+func RefundSubscriptionQuota(userId int, quota int) (int, error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+	subs, err := GetUserSubscriptions(userId, true)
+	if err != nil {
+		return 0, err
+	}
+	refunded := 0
+	for i := len(subs) - 1; i >= 0 && refunded < quota; i-- {
+		sub := subs[i]
+		give := min(quota-refunded, sub.Allowance-sub.Remaining)
+		if give <= 0 {
+			continue
+		}
+		result := DB.Model(&UserSubscription{}).Where("id = ? AND remaining + ? <= allowance", sub.Id, give).
+			Update("remaining", gorm.Expr("remaining + ?", give))
+		if result.Error != nil {
+			return refunded, result.Error
+		}
+		if result.RowsAffected > 0 {
+			refunded += give
+		}
+	}
+	return refunded, nil
+}
+
+// RefundTaskQuota 退还失败的异步任务费用，余额支付的部分退回余额，套餐抵扣的部分退回套餐
+func RefundTaskQuota(userId int, quota int, subscriptionQuota int) error {
+	subscriptionQuota = min(subscriptionQuota, quota)
+	if balanceQuota := quota - subscriptionQuota; balanceQuota > 0 {
+		if err := IncreaseUserQuota(userId, balanceQuota, false, QuotaLedgerTypeRefund); err != nil {
+			return err
+		}
+	}
+	refunded, err := RefundSubscriptionQuota(userId, subscriptionQuota)
+	if err != nil {
+		return err
+	}
+	if refunded < subscriptionQuota {
+		RecordLog(userId, LogTypeSystem, fmt.Sprintf("订阅已到期，%s 套餐额度无法退回", common.LogQuota(subscriptionQuota-refunded)))
+	}
+	return nil
+}
+
+func newUserSubscription(userId int, plan *SubscriptionPlan, autoRenew bool, now int64) *UserSubscription {
+	return &UserSubscription{
+		UserId:      userId,
+		PlanId:      plan.Id,
+		PlanName:    plan.Name,
+		Models:      plan.Models,
+		Groups:      plan.Groups,
+		Allowance:   plan.Quota,
+		Remaining:   plan.Quota,
+		PeriodStart: now,
+		PeriodEnd:   now + int64(plan.DurationDays)*86400,
+		AutoRenew:   autoRenew,
+		Status:      common.SubscriptionStatusActive,
+		CreatedTime: now,
+	}
+}
+
+// extendUserSubscriptionUntil 在事务中将用户的订阅到期时间延长到 periodEnd，提交后需要清除用户缓存
+func extendUserSubscriptionUntil(tx *gorm.DB, userId int, periodEnd int64) error {
+	return tx.Model(&User{}).Where("id = ? AND subscription_until < ?", userId, periodEnd).
+		Update("subscription_until", periodEnd).Error
+}
+
+// refreshUserSubscriptionUntil 按生效中的订阅重新计算用户的订阅到期时间
+func refreshUserSubscriptionUntil(userId int) error {
+	var until int64
+	err := DB.Model(&UserSubscription{}).Where("user_id = ? AND status = ?", userId, common.SubscriptionStatusActive).
+		Select("COALESCE(MAX(period_end), 0)").Scan(&until).Error
+	if err != nil {
+		return err
+	}
+	if err = DB.Model(&User{}).Where("id = ?", userId).Update("subscription_until", until).Error; err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
+
+// MigrateUserSubscriptionUntil 为升级前已有订阅的用户补全订阅到期时间
+func MigrateUserSubscriptionUntil() error {
+	var userIds []int
+	err := DB.Model(&UserSubscription{}).Where("status = ? AND period_end > ?", common.SubscriptionStatusActive, common.GetTimestamp()).
+		Where("user_id IN (?)", DB.Model(&User{}).Select("id").Where("subscription_until = 0")).
+		Distinct("user_id").Pluck("user_id", &userIds).Error
+	if err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if err = refreshUserSubscriptionUntil(userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chargeSubscriptionPrice 在事务中从用户余额扣除套餐费用，余额不足时返回错误
+func chargeSubscriptionPrice(tx *gorm.DB, userId int, price int) error {
+	if price <= 0 {
+		return nil
+	}
+	result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, price).Update("quota", gorm.Expr("quota - ?", price))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errSubscriptionInsufficientBalance
+	}
+	return RecordQuotaLedger(tx, userId, QuotaLedgerTypeSubscription, -price, "")
+}
+
+// SubscribePlan 用户使用余额订阅套餐，同一套餐只能有一个生效中的订阅
+func SubscribePlan(userId int, planId int, autoRenew bool) (*UserSubscription, error) {
+	plan, err := GetSubscriptionPlanById(planId)
+	if err != nil || plan.Status != common.SubscriptionPlanStatusEnabled {
+		return nil, errors.New("套餐不存在或已下架")
+	}
+	now := common.GetTimestamp()
+	sub := newUserSubscription(userId, plan, autoRenew, now)
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 先锁定用户记录，同一用户的并发订阅请求串行执行，不会重复订阅同一套餐
+		var lockedId int
+		err := tx.Unscoped().Model(&User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userId).Pluck("id", &lockedId).Error
+		if err != nil {
+			return err
+		}
+		var count int64
+		err = tx.Model(&UserSubscription{}).Where("user_id = ? AND plan_id = ? AND status = ?", userId, planId, common.SubscriptionStatusActive).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("已订阅该套餐")
+		}
+		if err = chargeSubscriptionPrice(tx, userId, plan.Price); err != nil {
+			return err
+		}
+		if err = tx.Create(sub).Error; err != nil {
+			return err
+		}
+		return extendUserSubscriptionUntil(tx, userId, sub.PeriodEnd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	if plan.Price > 0 {
+		if err := cacheDecrUserQuota(userId, int64(plan.Price)); err != nil {
+			common.SysError("failed to decrease user quota cache: " + err.Error())
+		}
+	}
+	RecordLog(userId, LogTypeSystem, fmt.Sprintf("订阅套餐「%s」，扣除余额 %s，获得套餐额度 %s", plan.Name, common.LogQuota(plan.Price), common.LogQuota(plan.Quota)))
+	return sub, nil
+}
+
+// GrantSubscription 管理员为用户开通套餐，不扣除余额
+func GrantSubscription(userId int, planId int, autoRenew bool, adminName string) (*UserSubscription, error) {
+	plan, err := GetSubscriptionPlanById(planId)
+	if err != nil {
+		return nil, errors.New("套餐不存在")
+	}
+	sub := newUserSubscription(userId, plan, autoRenew, common.GetTimestamp())
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sub).Error; err != nil {
+			return err
+		}
+		return extendUserSubscriptionUntil(tx, userId, sub.PeriodEnd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := invalidateUserCache(userId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	RecordLog(userId, LogTypeManage, fmt.Sprintf("管理员 %s 开通套餐「%s」，套餐额度 %s", adminName, plan.Name, common.LogQuota(plan.Quota)))
+	return sub, nil
+}
+
+func SetSubscriptionAutoRenew(userId int, id int, autoRenew bool) error {
+	result := DB.Model(&UserSubscription{}).Where("id = ? AND user_id = ? AND status = ?", id, userId, common.SubscriptionStatusActive).
+		Update("auto_renew", autoRenew)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("订阅不存在或已失效")
+	}
+	return nil
+}
+
+// CancelSubscription 立即终止订阅，剩余的套餐额度作废
+func CancelSubscription(id int) (*UserSubscription, error) {
+	var sub UserSubscription
+	if err := DB.First(&sub, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if sub.Status != common.SubscriptionStatusActive {
+		return nil, errors.New("订阅已失效")
+	}
+	err := DB.Model(&sub).Updates(map[string]interface{}{"status": common.SubscriptionStatusCancelled, "auto_renew": false}).Error
+	if err != nil {
+		return nil, err
+	}
+	if err = refreshUserSubscriptionUntil(sub.UserId); err != nil {
+		common.SysError(fmt.Sprintf("failed to refresh subscription of user %d: %s", sub.UserId, err.Error()))
+	}
+	return &sub, nil
+}
+
+// GetDueSubscriptions 返回已到期但仍处于生效状态的订阅
+func GetDueSubscriptions(now int64, limit int) ([]*UserSubscription, error) {
+	var subs []*UserSubscription
+	err := DB.Where("status = ? AND period_end <= ?", common.SubscriptionStatusActive, now).Order("period_end asc").Limit(limit).Find(&subs).Error
+	return subs, err
+}
+
+// RenewSubscription 处理到期的订阅：开启自动续费且余额足够时扣费并开始新周期，按套餐设置结转剩余额度，否则将订阅标记为过期。
+// 返回是否续费成功以及未续费的原因。
+// 续费只从余额扣费：支付渠道（PaymentProvider）只支持用户发起的一次性支付，不能在后台代扣，
+// 需要自动续费的用户应通过在线充值保持余额充足，余额不足时订阅过期并通知用户
+func RenewSubscription(sub *UserSubscription, now int64) (bool, string, error) {
+	plan, err := GetSubscriptionPlanById(sub.PlanId)
+	reason := ""
+	switch {
+	case !sub.AutoRenew:
+		reason = "未开启自动续费"
+	case err != nil || plan.Status != common.SubscriptionPlanStatusEnabled:
+		reason = "套餐已下架"
+	}
+	if reason != "" {
+		err = DB.Model(sub).Where("status = ?", common.SubscriptionStatusActive).Update("status", common.SubscriptionStatusExpired).Error
+		return false, reason, err
+	}
+	rollover := sub.Remaining * plan.RolloverPercent / 100
+	if plan.RolloverCap > 0 && rollover > plan.RolloverCap {
+		rollover = plan.RolloverCap
+	}
+	periodStart := sub.PeriodEnd
+	// 长时间停机后错过的周期不补扣，从当前时间开始新周期
+	if periodStart+int64(plan.DurationDays)*86400 <= now {
+		periodStart = now
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := chargeSubscriptionPrice(tx, sub.UserId, plan.Price); err != nil {
+			return err
+		}
+		result := tx.Model(&UserSubscription{}).Where("id = ? AND status = ? AND period_end = ?", sub.Id, common.SubscriptionStatusActive, sub.PeriodEnd).
+			Updates(map[string]interface{}{
+				"plan_name":      plan.Name,
+				"models":         plan.Models,
+				"allowed_groups": plan.Groups,
+				"allowance":      plan.Quota + rollover,
+				"remaining":      plan.Quota + rollover,
+				"period_start":   periodStart,
+				"period_end":     periodStart + int64(plan.DurationDays)*86400,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订阅已被其他节点处理")
+		}
+		return extendUserSubscriptionUntil(tx, sub.UserId, periodStart+int64(plan.DurationDays)*86400)
+	})
+	if err != nil {
+		if errors.Is(err, errSubscriptionInsufficientBalance) {
+			err = DB.Model(sub).Where("status = ?", common.SubscriptionStatusActive).Update("status", common.SubscriptionStatusExpired).Error
+			return false, "余额不足", err
+		}
+		return false, "", err
+	}
+	if err := invalidateUserCache(sub.UserId); err != nil {
+		common.SysError("failed to invalidate user cache: " + err.Error())
+	}
+	if plan.Price > 0 {
+		if err := cacheDecrUserQuota(sub.UserId, int64(plan.Price)); err != nil {
+			common.SysError("failed to decrease user quota cache: " + err.Error())
+		}
+	}
+	RecordLog(sub.UserId, LogTypeSystem, fmt.Sprintf("套餐「%s」自动续费，扣除余额 %s，新周期套餐额度 %s（含结转 %s）",
+		plan.Name, common.LogQuota(plan.Price), common.LogQuota(plan.Quota+rollover), common.LogQuota(rollover)))
+	return true, "", nil
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func TestSubscribePlanRejectsDuplicate(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", 3000)
+	plan := &SubscriptionPlan{Name: "pro", Price: 1000, Quota: 5000, DurationDays: 30, Status: common.SubscriptionPlanStatusEnabled}
+	if err := DB.Create(plan).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := SubscribePlan(user.Id, plan.Id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Remaining != 5000 || getTestUserQuota(t, user.Id) != 2000 {
+		t.Fatalf("expected the plan to be charged once, remaining %d, quota %d", sub.Remaining, getTestUserQuota(t, user.Id))
+	}
+	if _, err = SubscribePlan(user.Id, plan.Id, false); err == nil {
+		t.Fatal("expected a second active subscription to the same plan to be rejected")
+	}
+	if quota := getTestUserQuota(t, user.Id); quota != 2000 {
+		t.Fatalf("expected the duplicate subscription not to be charged, quota %d", quota)
+	}
+}
@@ -39,6 +39,8 @@ type Task struct {
 	Properties Properties            `json:"properties" gorm:"type:json"`
 
 	Data json.RawMessage `json:"data" gorm:"type:json"`
+	// SubscriptionQuota 费用中由订阅套餐抵扣的部分，任务失败时退回套餐
+	SubscriptionQuota int `json:"subscription_quota" gorm:"default:0"`
 }
 
 func (t *Task) SetData(data any) {
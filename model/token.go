@@ -13,7 +13,8 @@ import (
 type Token struct {
 	Id                 int            `json:"id"`
 	UserId             int            `json:"user_id" gorm:"index"`
-	Key                string         `json:"key" gorm:"type:char(48);uniqueIndex"`
+	Key                string         `json:"-" gorm:"type:char(64);uniqueIndex"` // peppered hash of the key, see common.HashTokenKey
+	KeyPrefix          string         `json:"key_prefix" gorm:"type:varchar(16);default:''"`
 	Status             int            `json:"status" gorm:"default:1"`
 	Name               string         `json:"name" gorm:"index" `
 	CreatedTime        int64          `json:"created_time" gorm:"bigint"`
@@ -29,10 +30,24 @@ type Token struct {
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
+// TokenKeyPrefixLength is the number of leading key characters kept in plaintext for display
+const TokenKeyPrefixLength = 6
+
 func (token *Token) Clean() {
 	token.Key = ""
 }
 
+// SetKey stores the hash of the given plaintext key, the plaintext itself is never persisted
+func (token *Token) SetKey(key string) {
+	token.Key = common.HashTokenKey(key)
+	token.KeyPrefix = key[:min(len(key), TokenKeyPrefixLength)]
+}
+
+// MaskedKey returns the displayable form of the token key, e.g. sk-abcdef******
+func (token *Token) MaskedKey() string {
+	return "sk-" + token.KeyPrefix + "******"
+}
+
 func (token *Token) GetIpLimitsMap() map[string]any {
 	// delete empty spaces
 	//split with \n
@@ -63,10 +78,17 @@ func GetAllUserTokens(userId int, startIdx int, num int) ([]*Token, error) {
 }
 
 func SearchUserTokens(userId int, keyword string, token string) (tokens []*Token, err error) {
+	tx := DB.Where("user_id = ?", userId).Where("name LIKE ?", "%"+keyword+"%")
 	if token != "" {
-		token = strings.Trim(token, "sk-")
+		token = strings.TrimPrefix(token, "sk-")
+		if len(token) > TokenKeyPrefixLength {
+			// a full key can only be matched through its hash
+			tx = tx.Where(commonKeyCol+" = ?", common.HashTokenKey(token))
+		} else {
+			tx = tx.Where("key_prefix LIKE ?", token+"%")
+		}
 	}
-	err = DB.Where("user_id = ?", userId).Where("name LIKE ?", "%"+keyword+"%").Where(commonKeyCol+" LIKE ?", "%"+token+"%").Find(&tokens).Error
+	err = tx.Find(&tokens).Error
 	return tokens, err
 }
 
@@ -141,7 +163,18 @@ func GetTokenById(id int) (*Token, error) {
 	return &token, err
 }
 
+// GetTokenByKey looks a token up by its plaintext key
 func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
+	token, err = GetTokenByKeyHash(common.HashTokenKey(key), fromDB)
+	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		// the key may not have been hashed yet by MigrateTokenKeys
+		return migrateLegacyTokenKey(key)
+	}
+	return token, err
+}
+
+// GetTokenByKeyHash looks a token up by the stored hash, as kept in Token.Key
+func GetTokenByKeyHash(hash string, fromDB bool) (token *Token, err error) {
 	defer func() {
 		// Update Redis cache asynchronously on successful DB read
 		if shouldUpdateRedis(fromDB, err) && token != nil {
@@ -154,17 +187,104 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 	}()
 	if !fromDB && common.RedisEnabled {
 		// Try Redis first
-		token, err := cacheGetTokenByKey(key)
+		token, err := cacheGetTokenByKey(hash)
 		if err == nil {
 			return token, nil
 		}
 		// Don't return error - fall through to DB
 	}
 	fromDB = true
-	err = DB.Where(commonKeyCol+" = ?", key).First(&token).Error
+	err = DB.Where(commonKeyCol+" = ?", hash).First(&token).Error
 	return token, err
 }
 
+func migrateLegacyTokenKey(key string) (*Token, error) {
+	var token *Token
+	err := DB.Where(commonKeyCol+" = ? AND key_prefix = ?", key, "").First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	token.SetKey(key)
+	err = DB.Model(token).Select("key", "key_prefix").Updates(token).Error
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// MigrateTokenKeys replaces every plaintext key left in the tokens table with its hash
+func MigrateTokenKeys() error {
+	var migrated int
+	for {
+		var tokens []*Token
+		err := DB.Unscoped().Where("key_prefix = ?", "").Limit(100).Find(&tokens).Error
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			break
+		}
+		for _, token := range tokens {
+			token.SetKey(strings.TrimSpace(token.Key))
+			err = DB.Unscoped().Model(token).Select("key", "key_prefix").Updates(token).Error
+			if err != nil {
+				return err
+			}
+		}
+		migrated += len(tokens)
+	}
+	if migrated > 0 {
+		common.SysLog(fmt.Sprintf("hashed %d plaintext token keys", migrated))
+	}
+	return nil
+}
+
+// RegenerateKey replaces the token key, the old key stops working immediately
+func (token *Token) RegenerateKey(key string) (err error) {
+	oldHash := token.Key
+	defer func() {
+		if shouldUpdateRedis(true, err) {
+			gopool.Go(func() {
+				err := cacheDeleteToken(oldHash)
+				if err != nil {
+					common.SysError("failed to delete token cache: " + err.Error())
+				}
+			})
+		}
+	}()
+	token.SetKey(key)
+	return DB.Model(token).Select("key", "key_prefix").Updates(token).Error
+}
+
+// ChatTokenName is the name of the token used by the web chat links, its key is regenerated every time a chat link is opened
+const ChatTokenName = "web-chat"
+
+// GetOrCreateChatToken returns the user's web chat token, creating an unlimited one on first use
+func GetOrCreateChatToken(userId int) (*Token, error) {
+	var token Token
+	err := DB.Where("user_id = ? AND name = ?", userId, ChatTokenName).First(&token).Error
+	if err == nil {
+		return &token, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	token = Token{
+		UserId:         userId,
+		Name:           ChatTokenName,
+		CreatedTime:    common.GetTimestamp(),
+		AccessedTime:   common.GetTimestamp(),
+		ExpiredTime:    -1,
+		UnlimitedQuota: true,
+	}
+	token.SetKey(key)
+	return &token, token.Insert()
+}
+
 func (token *Token) Insert() error {
 	var err error
 	err = DB.Create(token).Error
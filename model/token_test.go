@@ -0,0 +1,99 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func createTestToken(t *testing.T, userId int, key string) *Token {
+	t.Helper()
+	token := &Token{UserId: userId, Name: "test", Status: common.TokenStatusEnabled, ExpiredTime: -1, RemainQuota: 1000}
+	token.SetKey(key)
+	if err := DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestTokenKeyStoredAsHash(t *testing.T) {
+	setupTestDB(t)
+	key := "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKL"
+	token := createTestToken(t, 1, key)
+
+	var stored Token
+	if err := DB.First(&stored, token.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Key == key || stored.Key != common.HashTokenKey(key) {
+		t.Fatalf("expected the stored key to be the hash, got %s", stored.Key)
+	}
+	if stored.KeyPrefix != "abcdef" || stored.MaskedKey() != "sk-abcdef******" {
+		t.Fatalf("unexpected key prefix %s", stored.KeyPrefix)
+	}
+
+	validated, err := ValidateUserToken(key)
+	if err != nil || validated.Id != token.Id {
+		t.Fatalf("expected the plaintext key to validate, got %v", err)
+	}
+	if _, err := ValidateUserToken(stored.Key); err == nil {
+		t.Fatal("expected the stored hash not to be usable as a key")
+	}
+
+	tokens, err := SearchUserTokens(1, "", "sk-"+key)
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("expected the full key to find the token, got %d, %v", len(tokens), err)
+	}
+	tokens, err = SearchUserTokens(1, "", "abc")
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("expected the key prefix to find the token, got %d, %v", len(tokens), err)
+	}
+}
+
+func TestLegacyTokenKeyMigration(t *testing.T) {
+	setupTestDB(t)
+	legacyKeys := []string{"legacy0000000000000000000000000000000000000000001", "legacy0000000000000000000000000000000000000000002"}
+	for _, key := range legacyKeys {
+		// 升级前的令牌以明文保存，key_prefix 为空
+		if err := DB.Create(&Token{UserId: 1, Key: key, Status: common.TokenStatusEnabled, ExpiredTime: -1, RemainQuota: 1000}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 未迁移的令牌在首次使用时迁移
+	token, err := GetTokenByKey(legacyKeys[0], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Key != common.HashTokenKey(legacyKeys[0]) || token.KeyPrefix != "legacy" {
+		t.Fatalf("expected the key to be hashed on lookup, got %+v", token)
+	}
+
+	if err := MigrateTokenKeys(); err != nil {
+		t.Fatal(err)
+	}
+	var plaintext int64
+	DB.Model(&Token{}).Where(commonKeyCol+" IN ?", legacyKeys).Count(&plaintext)
+	if plaintext != 0 {
+		t.Fatalf("expected no plaintext keys left, got %d", plaintext)
+	}
+	if _, err := ValidateUserToken(legacyKeys[1]); err != nil {
+		t.Fatalf("expected the migrated key to keep working, got %v", err)
+	}
+}
+
+func TestRegenerateTokenKey(t *testing.T) {
+	setupTestDB(t)
+	oldKey := "old000000000000000000000000000000000000000000000"
+	newKey := "new000000000000000000000000000000000000000000000"
+	token := createTestToken(t, 1, oldKey)
+
+	if err := token.RegenerateKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateUserToken(oldKey); err == nil {
+		t.Fatal("expected the old key to stop working")
+	}
+	if validated, err := ValidateUserToken(newKey); err != nil || validated.Id != token.Id {
+		t.Fatalf("expected the new key to work, got %v", err)
+	}
+}
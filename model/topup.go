@@ -1,5 +1,13 @@
 package model
 
+import (
+	"errors"
+	"one-api/common"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
 type TopUp struct {
 	Id         int     `json:"id"`
 	UserId     int     `json:"user_id" gorm:"index"`
@@ -8,6 +16,10 @@ type TopUp struct {
 	TradeNo    string  `json:"trade_no"`
 	CreateTime int64   `json:"create_time"`
 	Status     string  `json:"status"`
+	// PaymentMethod 支付方式，易支付为具体的支付类型，Stripe 为 stripe
+	PaymentMethod string `json:"payment_method" gorm:"type:varchar(50)"`
+	// PaymentIntent 第三方支付单号，支付成功前可能为第三方的订单号（如 Stripe 的 checkout session id）
+	PaymentIntent string `json:"payment_intent" gorm:"type:varchar(255);index"`
 }
 
 func (topUp *TopUp) Insert() error {
@@ -41,3 +53,97 @@ func GetTopUpByTradeNo(tradeNo string) *TopUp {
 	}
 	return topUp
 }
+
+func GetAllTopUps(status string, startIdx int, num int) (topUps []*TopUp, total int64, err error) {
+	query := DB.Model(&TopUp{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&topUps).Error
+	return topUps, total, err
+}
+
+// GetPendingTopUps 按 id 顺序返回 id 大于 afterId 且创建时间不晚于 createdBefore 的待支付订单，用于分页遍历
+func GetPendingTopUps(createdBefore int64, afterId int, limit int) (topUps []*TopUp, err error) {
+	err = DB.Where("status = ? AND create_time <= ? AND id > ?", "pending", createdBefore, afterId).
+		Order("id").Limit(limit).Find(&topUps).Error
+	return topUps, err
+}
+
+// ExpireTopUp 将仍处于待支付状态的订单标记为过期，过期的订单收到支付成功的回调时仍会入账
+func ExpireTopUp(id int) error {
+	return DB.Model(&TopUp{}).Where("id = ? AND status = ?", id, "pending").Update("status", "expired").Error
+}
+
+// TopUpQuota 返回订单对应的额度
+func (topUp *TopUp) TopUpQuota() int {
+	dAmount := decimal.NewFromInt(topUp.Amount)
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	return int(dAmount.Mul(dQuotaPerUnit).IntPart())
+}
+
+// CompleteTopUp marks a pending order as paid and credits the quota to the user,
+// the conditional status update makes it safe to call more than once for the same order
+func CompleteTopUp(tradeNo string) (topUp *TopUp, quota int, err error) {
+	return completeTopUp(tradeNo, nil)
+}
+
+// CompleteTopUpWithReceipt 与 CompleteTopUp 相同，同时在同一事务中记录支付凭证并保存第三方支付单号，
+// 凭证的 ExternalId 唯一，重复的回调不会重复入账
+func CompleteTopUpWithReceipt(tradeNo string, receipt *PaymentReceipt) (topUp *TopUp, quota int, err error) {
+	return completeTopUp(tradeNo, receipt)
+}
+
+func completeTopUp(tradeNo string, receipt *PaymentReceipt) (topUp *TopUp, quota int, err error) {
+	topUp = GetTopUpByTradeNo(tradeNo)
+	if topUp == nil {
+		return nil, 0, errors.New("订单不存在")
+	}
+	if topUp.Status != "pending" && topUp.Status != "expired" {
+		return topUp, 0, errors.New("订单状态不是待支付")
+	}
+	quota = topUp.TopUpQuota()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": "success"}
+		if receipt != nil {
+			updates["payment_intent"] = receipt.ExternalId
+		}
+		result := tx.Model(&TopUp{}).Where("id = ? AND status IN ?", topUp.Id, []string{"pending", "expired"}).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订单状态不是待支付")
+		}
+		if receipt != nil {
+			receipt.TopUpId = topUp.Id
+			receipt.UserId = topUp.UserId
+			receipt.Type = PaymentReceiptTypePayment
+			receipt.Quota = quota
+			if err := receipt.insert(tx); err != nil {
+				return err
+			}
+		}
+		if err := applyCreditPayment(tx, topUp.UserId, quota); err != nil {
+			return err
+		}
+		if err := RecordQuotaLedger(tx, topUp.UserId, QuotaLedgerTypeTopUp, quota, topUp.TradeNo); err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
+	if err != nil {
+		return topUp, 0, err
+	}
+	topUp.Status = "success"
+	if receipt != nil {
+		topUp.PaymentIntent = receipt.ExternalId
+	}
+	if err := cacheIncrUserQuota(topUp.UserId, int64(quota)); err != nil {
+		common.SysError("failed to increase user quota cache: " + err.Error())
+	}
+	return topUp, quota, nil
+}
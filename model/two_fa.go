@@ -0,0 +1,226 @@
+package model
+
+import (
+	"errors"
+	"one-api/common"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TwoFA stores the TOTP secret of a user, a record with Enabled=false is an enrollment that was not confirmed yet
+type TwoFA struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"uniqueIndex"`
+	Secret       string `json:"-" gorm:"type:text"`
+	Enabled      bool   `json:"enabled" gorm:"default:false"`
+	LastUsedStep int64  `json:"-" gorm:"bigint;default:0"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	EnabledTime  int64  `json:"enabled_time" gorm:"bigint;default:0"`
+}
+
+// TwoFABackupCode is a single-use recovery code, only its bcrypt hash is stored
+type TwoFABackupCode struct {
+	Id       int    `json:"id"`
+	UserId   int    `json:"user_id" gorm:"index"`
+	CodeHash string `json:"-" gorm:"type:varchar(255)"`
+	UsedTime int64  `json:"used_time" gorm:"bigint;default:0"`
+}
+
+const TwoFABackupCodeCount = 10
+
+var (
+	ErrTwoFANotEnabled     = errors.New("未启用两步验证")
+	ErrTwoFAAlreadyEnabled = errors.New("已启用两步验证")
+	ErrTwoFAInvalidCode    = errors.New("验证码错误或已使用")
+)
+
+func (twoFA *TwoFA) getSecret() (string, error) {
+	return common.DecryptSecret(twoFA.Secret)
+}
+
+func (twoFA *TwoFA) setSecret(secret string) error {
+	if !common.CryptoSecretConfigured {
+		// a random CRYPTO_SECRET would not survive a restart
+		twoFA.Secret = secret
+		return nil
+	}
+	encrypted, err := common.EncryptSecret(secret)
+	if err != nil {
+		return err
+	}
+	twoFA.Secret = encrypted
+	return nil
+}
+
+func GetTwoFAByUserId(userId int) (*TwoFA, error) {
+	var twoFA TwoFA
+	err := DB.Where("user_id = ?", userId).First(&twoFA).Error
+	if err != nil {
+		return nil, err
+	}
+	return &twoFA, nil
+}
+
+// IsTwoFAEnabled reports whether the user has confirmed a TOTP enrollment
+func IsTwoFAEnabled(userId int) bool {
+	var count int64
+	err := DB.Model(&TwoFA{}).Where("user_id = ? AND enabled = ?", userId, true).Count(&count).Error
+	if err != nil {
+		common.SysError("failed to check 2fa status: " + err.Error())
+		return false
+	}
+	return count > 0
+}
+
+// StartTwoFAEnrollment creates or replaces an unconfirmed TOTP secret and returns it in plaintext
+func StartTwoFAEnrollment(userId int) (string, error) {
+	twoFA, err := GetTwoFAByUserId(userId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if twoFA != nil && twoFA.Enabled {
+		return "", ErrTwoFAAlreadyEnabled
+	}
+	secret, err := common.GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	if twoFA == nil {
+		twoFA = &TwoFA{UserId: userId}
+	}
+	if err = twoFA.setSecret(secret); err != nil {
+		return "", err
+	}
+	twoFA.CreatedTime = common.GetTimestamp()
+	twoFA.LastUsedStep = 0
+	if err = DB.Save(twoFA).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ConfirmTwoFAEnrollment enables 2FA once the user proved possession of the secret,
+// it returns the freshly generated recovery codes in plaintext
+func ConfirmTwoFAEnrollment(userId int, code string) ([]string, error) {
+	twoFA, err := GetTwoFAByUserId(userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("请先生成两步验证密钥")
+		}
+		return nil, err
+	}
+	if twoFA.Enabled {
+		return nil, ErrTwoFAAlreadyEnabled
+	}
+	if err = twoFA.verifyTOTP(code); err != nil {
+		return nil, err
+	}
+	twoFA.Enabled = true
+	twoFA.EnabledTime = common.GetTimestamp()
+	if err = DB.Model(twoFA).Select("enabled", "enabled_time").Updates(twoFA).Error; err != nil {
+		return nil, err
+	}
+	return RegenerateTwoFABackupCodes(userId)
+}
+
+func (twoFA *TwoFA) verifyTOTP(code string) error {
+	secret, err := twoFA.getSecret()
+	if err != nil {
+		return err
+	}
+	step, ok := common.ValidateTOTPCode(secret, code, time.Now())
+	if !ok || step <= twoFA.LastUsedStep {
+		return ErrTwoFAInvalidCode
+	}
+	// conditional update so that two concurrent requests cannot both consume the same code
+	result := DB.Model(&TwoFA{}).Where("id = ? AND last_used_step < ?", twoFA.Id, step).Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTwoFAInvalidCode
+	}
+	twoFA.LastUsedStep = step
+	return nil
+}
+
+// VerifyTwoFA accepts either a TOTP code or an unused recovery code
+func VerifyTwoFA(userId int, code string) error {
+	twoFA, err := GetTwoFAByUserId(userId)
+	if err != nil || !twoFA.Enabled {
+		return ErrTwoFANotEnabled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == common.TOTPDigits {
+		return twoFA.verifyTOTP(code)
+	}
+	return useTwoFABackupCode(userId, code)
+}
+
+func useTwoFABackupCode(userId int, code string) error {
+	var codes []TwoFABackupCode
+	err := DB.Where("user_id = ? AND used_time = ?", userId, 0).Find(&codes).Error
+	if err != nil {
+		return err
+	}
+	code = strings.ToLower(code)
+	for _, backupCode := range codes {
+		if !common.ValidatePasswordAndHash(code, backupCode.CodeHash) {
+			continue
+		}
+		result := DB.Model(&TwoFABackupCode{}).Where("id = ? AND used_time = ?", backupCode.Id, 0).
+			Update("used_time", common.GetTimestamp())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			break
+		}
+		return nil
+	}
+	return ErrTwoFAInvalidCode
+}
+
+// RegenerateTwoFABackupCodes replaces all recovery codes of the user
+func RegenerateTwoFABackupCodes(userId int) ([]string, error) {
+	plainCodes := make([]string, 0, TwoFABackupCodeCount)
+	records := make([]TwoFABackupCode, 0, TwoFABackupCodeCount)
+	for i := 0; i < TwoFABackupCodeCount; i++ {
+		raw := strings.ToLower(common.GetRandomString(8))
+		code := raw[:4] + "-" + raw[4:]
+		hash, err := common.Password2Hash(code)
+		if err != nil {
+			return nil, err
+		}
+		plainCodes = append(plainCodes, code)
+		records = append(records, TwoFABackupCode{UserId: userId, CodeHash: hash})
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&TwoFABackupCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plainCodes, nil
+}
+
+func CountUnusedTwoFABackupCodes(userId int) int64 {
+	var count int64
+	DB.Model(&TwoFABackupCode{}).Where("user_id = ? AND used_time = ?", userId, 0).Count(&count)
+	return count
+}
+
+// DisableTwoFA removes the TOTP secret and recovery codes of the user
+func DisableTwoFA(userId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&TwoFA{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&TwoFABackupCode{}).Error
+	})
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUpstreamCostRatio 返回渠道对某个模型的上游成本比例，未配置时返回 false
+func GetUpstreamCostRatio(setting dto.ChannelSettings, modelName string) (float64, bool) {
+	if ratio, ok := setting.UpstreamModelCostRatio[modelName]; ok && ratio >= 0 {
+		return ratio, true
+	}
+	if setting.UpstreamCostRatio > 0 {
+		return setting.UpstreamCostRatio, true
+	}
+	return 0, false
+}
+
+// computeUpstreamCost 根据渠道的上游成本比例计算本次请求的上游成本（额度单位）。
+// 消费额度已经乘过分组倍率与价格覆盖，先除去两者得到原价，倍率为 0（免费分组、原价为 0）时无法还原，记为 0
+func computeUpstreamCost(c *gin.Context, channelId int, modelName string, quota int, other map[string]interface{}) int {
+	if channelId == 0 || quota <= 0 {
+		return 0
+	}
+	setting, ok := common.GetContextKeyType[dto.ChannelSettings](c, constant.ContextKeyChannelSetting)
+	if !ok || c.GetInt("channel_id") != channelId {
+		channel, err := CacheGetChannel(channelId)
+		if err != nil {
+			return 0
+		}
+		setting = channel.GetSetting()
+	}
+	ratio, ok := GetUpstreamCostRatio(setting, modelName)
+	if !ok {
+		return 0
+	}
+	groupRatio := 1.0
+	if v, ok := other["user_group_ratio"].(float64); ok && v > 0 {
+		groupRatio = v
+	} else if v, ok := other["group_ratio"].(float64); ok {
+		groupRatio = v
+	}
+	overrideRatio := 1.0
+	if v, ok := other["price_override_ratio"].(float64); ok {
+		overrideRatio = v
+	}
+	if groupRatio <= 0 || overrideRatio <= 0 {
+		return 0
+	}
+	return int(float64(quota) / groupRatio / overrideRatio * ratio)
+}
+
+// SumChannelUpstreamCost 统计渠道在时间范围内消费日志的上游成本
+func SumChannelUpstreamCost(channelId int, startTimestamp int64, endTimestamp int64) (int64, error) {
+	var cost int64
+	err := LOG_DB.Model(&Log{}).
+		Where("type = ? AND channel_id = ? AND created_at > ? AND created_at <= ?", LogTypeConsume, channelId, startTimestamp, endTimestamp).
+		Select("COALESCE(SUM(upstream_cost), 0)").Scan(&cost).Error
+	return cost, err
+}
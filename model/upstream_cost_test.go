@@ -0,0 +1,45 @@
+package model
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestComputeUpstreamCost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("channel_id", 7)
+	common.SetContextKey(c, constant.ContextKeyChannelSetting, dto.ChannelSettings{
+		UpstreamCostRatio:      0.5,
+		UpstreamModelCostRatio: map[string]float64{"gpt-4o": 0.25},
+	})
+
+	cases := []struct {
+		name      string
+		modelName string
+		quota     int
+		other     map[string]interface{}
+		expected  int
+	}{
+		{"base price", "gpt-4", 1000, map[string]interface{}{"group_ratio": 1.0}, 500},
+		{"per model ratio", "gpt-4o", 1000, map[string]interface{}{"group_ratio": 1.0}, 250},
+		{"group ratio removed", "gpt-4", 2000, map[string]interface{}{"group_ratio": 2.0}, 500},
+		{"user group ratio preferred", "gpt-4", 3000, map[string]interface{}{"group_ratio": 1.0, "user_group_ratio": 3.0}, 500},
+		// 协议价只影响售价，上游成本按原价计算
+		{"price override removed", "gpt-4", 800, map[string]interface{}{"group_ratio": 1.0, "price_override_ratio": 0.8}, 500},
+		{"free override", "gpt-4", 800, map[string]interface{}{"group_ratio": 1.0, "price_override_ratio": 0.0}, 0},
+		{"free group", "gpt-4", 800, map[string]interface{}{"group_ratio": 0.0}, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if cost := computeUpstreamCost(c, 7, tc.modelName, tc.quota, tc.other); cost != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, cost)
+			}
+		})
+	}
+}
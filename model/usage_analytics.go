@@ -0,0 +1,279 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 支持的分组维度，另外可以用 tag:<key> 按请求标签分组
+var usageAnalyticsDimensions = map[string]bool{
+	"model":       true,
+	"channel":     true,
+	"token":       true,
+	"group":       true,
+	"user":        true,
+	"status_code": true,
+}
+
+// UsageAnalyticsQuery 用量分析查询条件，Bucket 为空时不按时间分桶
+type UsageAnalyticsQuery struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	Bucket         string
+	GroupBy        []string
+	UserId         int
+	TokenId        int
+	ChannelId      int
+	ModelName      string
+	Group          string
+	Tag            string // key=value
+}
+
+type UsageAnalyticsItem struct {
+	Bucket           int64             `json:"bucket,omitempty"`
+	ModelName        string            `json:"model_name,omitempty"`
+	ChannelId        int               `json:"channel_id,omitempty"`
+	TokenId          int               `json:"token_id,omitempty"`
+	TokenName        string            `json:"token_name,omitempty"`
+	Group            string            `json:"group,omitempty"`
+	UserId           int               `json:"user_id,omitempty"`
+	Username         string            `json:"username,omitempty"`
+	StatusCode       int               `json:"status_code,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	Requests         int64             `json:"requests"`
+	Errors           int64             `json:"errors"`
+	ErrorRate        float64           `json:"error_rate"`
+	PromptTokens     int64             `json:"prompt_tokens"`
+	CompletionTokens int64             `json:"completion_tokens"`
+	CachedTokens     int64             `json:"cached_tokens"`
+	Quota            int64             `json:"quota"`
+	UpstreamCost     int64             `json:"upstream_cost,omitempty"`
+	Margin           int64             `json:"margin,omitempty"`
+	MarginRate       float64           `json:"margin_rate,omitempty"`
+	AvgLatency       float64           `json:"avg_latency"`
+	P50Latency       float64           `json:"p50_latency"`
+	P95Latency       float64           `json:"p95_latency"`
+	AvgTtft          float64           `json:"avg_ttft"`
+	P50Ttft          float64           `json:"p50_ttft"`
+	P95Ttft          float64           `json:"p95_ttft"`
+
+	useTimeSum     int64
+	ttftSum        int64
+	ttftCount      int64
+	latencyBuckets []int64
+	ttftBuckets    []int64
+}
+
+func (item *UsageAnalyticsItem) add(rollup *UsageRollup) {
+	item.Requests += int64(rollup.RequestCount)
+	item.Errors += int64(rollup.ErrorCount)
+	item.PromptTokens += int64(rollup.PromptTokens)
+	item.CompletionTokens += int64(rollup.CompletionTokens)
+	item.CachedTokens += int64(rollup.CachedTokens)
+	item.Quota += int64(rollup.Quota)
+	item.UpstreamCost += int64(rollup.UpstreamCost)
+	item.useTimeSum += int64(rollup.UseTimeSum)
+	item.ttftSum += rollup.TtftSum
+	item.ttftCount += int64(rollup.TtftCount)
+	for i, count := range rollup.latencyBuckets() {
+		item.latencyBuckets[i] += int64(*count)
+	}
+	for i, count := range rollup.ttftBuckets() {
+		item.ttftBuckets[i] += int64(*count)
+	}
+	if rollup.Username != "" {
+		item.Username = rollup.Username
+	}
+	if rollup.TokenName != "" {
+		item.TokenName = rollup.TokenName
+	}
+}
+
+func (item *UsageAnalyticsItem) finish() {
+	if item.Requests > 0 {
+		item.ErrorRate = float64(item.Errors) / float64(item.Requests)
+		item.AvgLatency = float64(item.useTimeSum) / float64(item.Requests)
+	}
+	// 毛利只对配置了上游成本的渠道有意义
+	if item.UpstreamCost > 0 {
+		item.Margin = item.Quota - item.UpstreamCost
+		if item.Quota > 0 {
+			item.MarginRate = float64(item.Margin) / float64(item.Quota)
+		}
+	}
+	if item.ttftCount > 0 {
+		item.AvgTtft = float64(item.ttftSum) / float64(item.ttftCount)
+	}
+	item.P50Latency = histogramPercentile(item.latencyBuckets, usageLatencyBounds, 0.5)
+	item.P95Latency = histogramPercentile(item.latencyBuckets, usageLatencyBounds, 0.95)
+	item.P50Ttft = histogramPercentile(item.ttftBuckets, usageTtftBounds, 0.5)
+	item.P95Ttft = histogramPercentile(item.ttftBuckets, usageTtftBounds, 0.95)
+}
+
+// histogramPercentile 在分位数所在的桶内线性插值，落在溢出桶时返回最大上界
+func histogramPercentile(counts []int64, bounds []float64, p float64) float64 {
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	target := p * float64(total)
+	var cumulative float64
+	for i, count := range counts {
+		if count == 0 || cumulative+float64(count) < target {
+			cumulative += float64(count)
+			continue
+		}
+		if i >= len(bounds) {
+			return bounds[len(bounds)-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = bounds[i-1]
+		}
+		return lower + (bounds[i]-lower)*(target-cumulative)/float64(count)
+	}
+	return bounds[len(bounds)-1]
+}
+
+func usageAnalyticsBucket(bucket string, timestamp int64) int64 {
+	t := time.Unix(timestamp, 0)
+	switch bucket {
+	case "minute", "hour":
+		return timestamp
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Unix()
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).Unix()
+	}
+	return 0
+}
+
+// QueryUsageAnalytics 从汇总表中按时间分桶与分组维度聚合用量，
+// 按分钟分桶时使用分钟汇总，其余使用小时汇总，时间范围按汇总粒度对齐
+func QueryUsageAnalytics(query *UsageAnalyticsQuery) ([]*UsageAnalyticsItem, error) {
+	if query.Bucket != "" && query.Bucket != "minute" && query.Bucket != "hour" && query.Bucket != "day" && query.Bucket != "month" {
+		return nil, errors.New("不支持的时间分桶：" + query.Bucket)
+	}
+	groupBy := make(map[string]bool, len(query.GroupBy))
+	tagKeys := make([]string, 0)
+	for _, dimension := range query.GroupBy {
+		if dimension = strings.TrimSpace(dimension); dimension == "" {
+			continue
+		}
+		if tagKey, ok := strings.CutPrefix(dimension, "tag:"); ok && tagKey != "" {
+			tagKeys = append(tagKeys, tagKey)
+			continue
+		}
+		if !usageAnalyticsDimensions[dimension] {
+			return nil, errors.New("不支持的分组维度：" + dimension)
+		}
+		groupBy[dimension] = true
+	}
+	period := UsageRollupPeriodHour
+	if query.Bucket == "minute" {
+		period = UsageRollupPeriodMinute
+	}
+
+	tx := DB.Model(&UsageRollup{}).Where("period = ?", period)
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("bucket_start >= ?", query.StartTimestamp-query.StartTimestamp%int64(period))
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("bucket_start <= ?", query.EndTimestamp)
+	}
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.TokenId != 0 {
+		tx = tx.Where("token_id = ?", query.TokenId)
+	}
+	if query.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", query.ChannelId)
+	}
+	if query.ModelName != "" {
+		tx = tx.Where("model_name = ?", query.ModelName)
+	}
+	if query.Group != "" {
+		tx = tx.Where("group_name = ?", query.Group)
+	}
+	if query.Tag != "" {
+		tx = whereLogTag(tx, "tags", query.Tag)
+	}
+	rows, err := tx.Order("bucket_start asc").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[string]*UsageAnalyticsItem)
+	for rows.Next() {
+		var rollup UsageRollup
+		if err = DB.ScanRows(rows, &rollup); err != nil {
+			return nil, err
+		}
+		item := &UsageAnalyticsItem{Bucket: usageAnalyticsBucket(query.Bucket, rollup.BucketStart)}
+		if groupBy["model"] {
+			item.ModelName = rollup.ModelName
+		}
+		if groupBy["channel"] {
+			item.ChannelId = rollup.ChannelId
+		}
+		if groupBy["token"] {
+			item.TokenId = rollup.TokenId
+		}
+		if groupBy["group"] {
+			item.Group = rollup.Group
+		}
+		if groupBy["user"] {
+			item.UserId = rollup.UserId
+		}
+		if groupBy["status_code"] {
+			item.StatusCode = rollup.StatusCode
+		}
+		key := fmt.Sprintf("%d|%s|%d|%d|%s|%d|%d", item.Bucket, item.ModelName, item.ChannelId, item.TokenId, item.Group, item.UserId, item.StatusCode)
+		if len(tagKeys) > 0 {
+			tags := parseLogTags(rollup.Tags)
+			item.Tags = make(map[string]string, len(tagKeys))
+			for _, tagKey := range tagKeys {
+				item.Tags[tagKey] = tags[tagKey]
+				key += "|" + tags[tagKey]
+			}
+		}
+		if existing, ok := items[key]; ok {
+			item = existing
+		} else {
+			item.latencyBuckets = make([]int64, len(usageLatencyBounds)+1)
+			item.ttftBuckets = make([]int64, len(usageTtftBounds)+1)
+			items[key] = item
+		}
+		item.add(&rollup)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]*UsageAnalyticsItem, 0, len(items))
+	for _, item := range items {
+		if !groupBy["token"] {
+			item.TokenName = ""
+		}
+		if !groupBy["user"] {
+			item.Username = ""
+		}
+		item.finish()
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bucket != result[j].Bucket {
+			return result[i].Bucket < result[j].Bucket
+		}
+		return result[i].Quota > result[j].Quota
+	})
+	return result, nil
+}
@@ -0,0 +1,269 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UsageRollupPeriodMinute = 60
+	UsageRollupPeriodHour   = 3600
+)
+
+// 分钟级汇总数据量较大，只保留最近一段时间
+const usageRollupMinuteRetention = 7 * 24 * time.Hour
+
+// 延迟分布的桶上界（秒），最后一个桶统计超过最大上界的请求
+var usageLatencyBounds = []float64{1, 2, 3, 5, 10, 20, 30, 60, 120}
+
+// 首字时间分布的桶上界（毫秒）
+var usageTtftBounds = []float64{200, 500, 1000, 2000, 3000, 5000, 10000, 30000}
+
+// UsageRollup 按分钟与小时汇总的用量数据，由消费日志与错误请求增量维护，
+// 延迟与首字时间以固定分桶的直方图保存，用于估算分位数
+type UsageRollup struct {
+	Id               int    `json:"id"`
+	Period           int    `json:"period" gorm:"uniqueIndex:idx_usage_rollup_key,priority:1"`
+	BucketStart      int64  `json:"bucket_start" gorm:"bigint;uniqueIndex:idx_usage_rollup_key,priority:2;index"`
+	UserId           int    `json:"user_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:3"`
+	Username         string `json:"username" gorm:"size:64;default:''"`
+	TokenId          int    `json:"token_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:4"`
+	TokenName        string `json:"token_name" gorm:"size:255;default:''"`
+	ModelName        string `json:"model_name" gorm:"size:128;uniqueIndex:idx_usage_rollup_key,priority:5;default:''"`
+	ChannelId        int    `json:"channel_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:6"`
+	Group            string `json:"group" gorm:"column:group_name;size:64;uniqueIndex:idx_usage_rollup_key,priority:7;default:''"`
+	StatusCode       int    `json:"status_code" gorm:"uniqueIndex:idx_usage_rollup_key,priority:8"`
+	Tags             string `json:"tags" gorm:"size:255;uniqueIndex:idx_usage_rollup_key,priority:9;default:''"`
+	RequestCount     int    `json:"request_count" gorm:"default:0"`
+	ErrorCount       int    `json:"error_count" gorm:"default:0"`
+	PromptTokens     int    `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int    `json:"completion_tokens" gorm:"default:0"`
+	CachedTokens     int    `json:"cached_tokens" gorm:"default:0"`
+	Quota            int    `json:"quota" gorm:"default:0"`
+	UpstreamCost     int    `json:"upstream_cost" gorm:"default:0"`
+	UseTimeSum       int    `json:"use_time_sum" gorm:"default:0"`
+	TtftSum          int64  `json:"ttft_sum" gorm:"default:0"`
+	TtftCount        int    `json:"ttft_count" gorm:"default:0"`
+	LatencyBucket0   int    `json:"-" gorm:"default:0"`
+	LatencyBucket1   int    `json:"-" gorm:"default:0"`
+	LatencyBucket2   int    `json:"-" gorm:"default:0"`
+	LatencyBucket3   int    `json:"-" gorm:"default:0"`
+	LatencyBucket4   int    `json:"-" gorm:"default:0"`
+	LatencyBucket5   int    `json:"-" gorm:"default:0"`
+	LatencyBucket6   int    `json:"-" gorm:"default:0"`
+	LatencyBucket7   int    `json:"-" gorm:"default:0"`
+	LatencyBucket8   int    `json:"-" gorm:"default:0"`
+	LatencyBucket9   int    `json:"-" gorm:"default:0"`
+	TtftBucket0      int    `json:"-" gorm:"default:0"`
+	TtftBucket1      int    `json:"-" gorm:"default:0"`
+	TtftBucket2      int    `json:"-" gorm:"default:0"`
+	TtftBucket3      int    `json:"-" gorm:"default:0"`
+	TtftBucket4      int    `json:"-" gorm:"default:0"`
+	TtftBucket5      int    `json:"-" gorm:"default:0"`
+	TtftBucket6      int    `json:"-" gorm:"default:0"`
+	TtftBucket7      int    `json:"-" gorm:"default:0"`
+	TtftBucket8      int    `json:"-" gorm:"default:0"`
+}
+
+func (r *UsageRollup) latencyBuckets() []*int {
+	return []*int{&r.LatencyBucket0, &r.LatencyBucket1, &r.LatencyBucket2, &r.LatencyBucket3, &r.LatencyBucket4,
+		&r.LatencyBucket5, &r.LatencyBucket6, &r.LatencyBucket7, &r.LatencyBucket8, &r.LatencyBucket9}
+}
+
+func (r *UsageRollup) ttftBuckets() []*int {
+	return []*int{&r.TtftBucket0, &r.TtftBucket1, &r.TtftBucket2, &r.TtftBucket3, &r.TtftBucket4,
+		&r.TtftBucket5, &r.TtftBucket6, &r.TtftBucket7, &r.TtftBucket8}
+}
+
+// UsageRollupRecord 一次请求的用量，StatusCode 为 0 时视为成功
+type UsageRollupRecord struct {
+	CreatedAt        int64
+	UserId           int
+	Username         string
+	TokenId          int
+	TokenName        string
+	ModelName        string
+	ChannelId        int
+	Group            string
+	StatusCode       int
+	PromptTokens     int
+	CompletionTokens int
+	CachedTokens     int
+	Quota            int
+	UpstreamCost     int
+	UseTimeSeconds   int
+	TtftMs           int // 0 表示没有首字时间，例如非流式请求
+	Tags             string
+}
+
+// otherInt 读取日志 Other 中的数值字段，兼容 int 与 JSON 解析得到的 float64
+func otherInt(other map[string]interface{}, key string) int {
+	switch v := other[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+type usageRollupKey struct {
+	period      int
+	bucketStart int64
+	userId      int
+	tokenId     int
+	modelName   string
+	channelId   int
+	group       string
+	statusCode  int
+	tags        string
+}
+
+var usageRollupCache = make(map[usageRollupKey]*UsageRollup)
+var usageRollupCacheLock sync.Mutex
+
+// RecordUsageRollup 将请求计入内存中的分钟与小时汇总，由 UpdateUsageRollups 定期写入数据库
+func RecordUsageRollup(record UsageRollupRecord) {
+	if !common.UsageRollupEnabled {
+		return
+	}
+	if record.CreatedAt == 0 {
+		record.CreatedAt = common.GetTimestamp()
+	}
+	if record.StatusCode == 0 {
+		record.StatusCode = 200
+	}
+	usageRollupCacheLock.Lock()
+	defer usageRollupCacheLock.Unlock()
+	for _, period := range []int{UsageRollupPeriodMinute, UsageRollupPeriodHour} {
+		key := usageRollupKey{
+			period:      period,
+			bucketStart: record.CreatedAt - record.CreatedAt%int64(period),
+			userId:      record.UserId,
+			tokenId:     record.TokenId,
+			modelName:   record.ModelName,
+			channelId:   record.ChannelId,
+			group:       record.Group,
+			statusCode:  record.StatusCode,
+			tags:        record.Tags,
+		}
+		rollup, ok := usageRollupCache[key]
+		if !ok {
+			rollup = &UsageRollup{
+				Period:      key.period,
+				BucketStart: key.bucketStart,
+				UserId:      key.userId,
+				TokenId:     key.tokenId,
+				ModelName:   key.modelName,
+				ChannelId:   key.channelId,
+				Group:       key.group,
+				StatusCode:  key.statusCode,
+				Tags:        key.tags,
+			}
+			usageRollupCache[key] = rollup
+		}
+		rollup.Username = record.Username
+		rollup.TokenName = record.TokenName
+		rollup.RequestCount++
+		if record.StatusCode >= 400 {
+			rollup.ErrorCount++
+		}
+		rollup.PromptTokens += record.PromptTokens
+		rollup.CompletionTokens += record.CompletionTokens
+		rollup.CachedTokens += record.CachedTokens
+		rollup.Quota += record.Quota
+		rollup.UpstreamCost += record.UpstreamCost
+		rollup.UseTimeSum += record.UseTimeSeconds
+		*rollup.latencyBuckets()[sort.SearchFloat64s(usageLatencyBounds, float64(record.UseTimeSeconds))]++
+		if record.TtftMs > 0 {
+			rollup.TtftSum += int64(record.TtftMs)
+			rollup.TtftCount++
+			*rollup.ttftBuckets()[sort.SearchFloat64s(usageTtftBounds, float64(record.TtftMs))]++
+		}
+	}
+}
+
+func UpdateUsageRollups() {
+	lastCleanup := time.Now()
+	for {
+		time.Sleep(time.Minute)
+		SaveUsageRollupCache()
+		if common.IsMasterNode && time.Since(lastCleanup) > time.Hour {
+			lastCleanup = time.Now()
+			before := time.Now().Add(-usageRollupMinuteRetention).Unix()
+			if err := DB.Where("period = ? AND bucket_start < ?", UsageRollupPeriodMinute, before).Delete(&UsageRollup{}).Error; err != nil {
+				common.SysError("failed to delete expired usage rollups: " + err.Error())
+			}
+		}
+	}
+}
+
+// SaveUsageRollupCache 将内存中的汇总累加到数据库，已存在的行按增量更新
+func SaveUsageRollupCache() {
+	usageRollupCacheLock.Lock()
+	cache := usageRollupCache
+	usageRollupCache = make(map[usageRollupKey]*UsageRollup)
+	usageRollupCacheLock.Unlock()
+
+	for _, rollup := range cache {
+		if err := saveUsageRollup(rollup); err != nil {
+			common.SysError("failed to save usage rollup: " + err.Error())
+		}
+	}
+}
+
+func saveUsageRollup(rollup *UsageRollup) error {
+	updated, err := increaseUsageRollup(rollup)
+	if err != nil || updated {
+		return err
+	}
+	if err = DB.Create(rollup).Error; err != nil {
+		// 其他节点可能已经插入了同一行，再尝试累加一次
+		if updated, _ = increaseUsageRollup(rollup); updated {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func increaseUsageRollup(rollup *UsageRollup) (bool, error) {
+	updates := map[string]interface{}{
+		"username":          rollup.Username,
+		"token_name":        rollup.TokenName,
+		"request_count":     gorm.Expr("request_count + ?", rollup.RequestCount),
+		"error_count":       gorm.Expr("error_count + ?", rollup.ErrorCount),
+		"prompt_tokens":     gorm.Expr("prompt_tokens + ?", rollup.PromptTokens),
+		"completion_tokens": gorm.Expr("completion_tokens + ?", rollup.CompletionTokens),
+		"cached_tokens":     gorm.Expr("cached_tokens + ?", rollup.CachedTokens),
+		"quota":             gorm.Expr("quota + ?", rollup.Quota),
+		"upstream_cost":     gorm.Expr("upstream_cost + ?", rollup.UpstreamCost),
+		"use_time_sum":      gorm.Expr("use_time_sum + ?", rollup.UseTimeSum),
+		"ttft_sum":          gorm.Expr("ttft_sum + ?", rollup.TtftSum),
+		"ttft_count":        gorm.Expr("ttft_count + ?", rollup.TtftCount),
+	}
+	for i, count := range rollup.latencyBuckets() {
+		if *count > 0 {
+			column := fmt.Sprintf("latency_bucket%d", i)
+			updates[column] = gorm.Expr(column+" + ?", *count)
+		}
+	}
+	for i, count := range rollup.ttftBuckets() {
+		if *count > 0 {
+			column := fmt.Sprintf("ttft_bucket%d", i)
+			updates[column] = gorm.Expr(column+" + ?", *count)
+		}
+	}
+	result := DB.Model(&UsageRollup{}).
+		Where("period = ? AND bucket_start = ? AND user_id = ? AND token_id = ? AND model_name = ? AND channel_id = ? AND group_name = ? AND status_code = ? AND tags = ?",
+			rollup.Period, rollup.BucketStart, rollup.UserId, rollup.TokenId, rollup.ModelName, rollup.ChannelId, rollup.Group, rollup.StatusCode, rollup.Tags).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
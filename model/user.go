@@ -43,6 +43,13 @@ type User struct {
 	LinuxDOId        string         `json:"linux_do_id" gorm:"column:linux_do_id;index"`
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
+	AdminRoleId      int            `json:"admin_role_id" gorm:"type:int;default:0;column:admin_role_id"` // custom admin role, 0 means default admin permissions
+	CreditLimit      int            `json:"credit_limit" gorm:"type:int;default:0"`                       // 账期额度，余额可以透支到 -CreditLimit
+	CreditStatus     int            `json:"credit_status" gorm:"type:int;default:0"`                      // 账期逾期状态
+	Permissions      []string       `json:"permissions,omitempty" gorm:"-:all"`                           // effective permissions, only filled for the current user
+
+	// SubscriptionUntil 生效中订阅的最晚到期时间，超过该时间后请求无需再查询订阅
+	SubscriptionUntil int64 `json:"subscription_until" gorm:"bigint;default:0"`
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -54,6 +61,12 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		CreditLimit:  user.CreditLimit,
+		CreditStatus: user.CreditStatus,
+		AdminRoleId:  user.AdminRoleId,
+
+		SubscriptionUntil: user.SubscriptionUntil,
 	}
 	return cache
 }
@@ -302,6 +315,9 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 	if err := tx.Save(user).Error; err != nil {
 		return err
 	}
+	if err := RecordQuotaLedger(tx, user.Id, QuotaLedgerTypeAffTransfer, quota, ""); err != nil {
+		return err
+	}
 
 	// 提交事务
 	return tx.Commit().Error
@@ -318,16 +334,21 @@ func (user *User) Insert(inviterId int) error {
 	user.Quota = common.QuotaForNewUser
 	//user.SetAccessToken(common.GetUUID())
 	user.AffCode = common.GetRandomString(4)
-	result := DB.Create(user)
-	if result.Error != nil {
-		return result.Error
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return RecordQuotaLedger(tx, user.Id, QuotaLedgerTypeReward, user.Quota, "")
+	})
+	if err != nil {
+		return err
 	}
 	if common.QuotaForNewUser > 0 {
 		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", common.LogQuota(common.QuotaForNewUser)))
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true, QuotaLedgerTypeReward)
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", common.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -367,24 +388,23 @@ func (user *User) Edit(updatePassword bool) error {
 	}
 
 	newUser := *user
+	// 额度只能通过 AdjustUserQuota 调整，这里不修改 quota，保证每次变动都有原因并记录到账本
 	updates := map[string]interface{}{
 		"username":     newUser.Username,
 		"display_name": newUser.DisplayName,
 		"group":        newUser.Group,
-		"quota":        newUser.Quota,
 		"remark":       newUser.Remark,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
 	}
 
-	DB.First(&user, user.Id)
 	if err = DB.Model(user).Updates(updates).Error; err != nil {
 		return err
 	}
 
 	// Update cache
-	return updateUserCache(*user)
+	return invalidateUserCache(user.Id)
 }
 
 func (user *User) Delete() error {
@@ -661,7 +681,8 @@ func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error)
 	return userBase.GetSetting(), nil
 }
 
-func IncreaseUserQuota(id int, quota int, db bool) (err error) {
+// IncreaseUserQuota 增加用户余额，ledgerType 为记录到额度账本的变动类型
+func IncreaseUserQuota(id int, quota int, db bool, ledgerType string) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -672,21 +693,18 @@ func IncreaseUserQuota(id int, quota int, db bool) (err error) {
 		}
 	})
 	if !db && common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
+		addUserQuotaRecord(id, quota, ledgerType)
 		return nil
 	}
-	return increaseUserQuota(id, quota)
+	return increaseUserQuota(id, quota, ledgerType)
 }
 
-func increaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
+func increaseUserQuota(id int, quota int, ledgerType string) (err error) {
+	return updateUserQuotaWithLedger(id, quota, newQuotaLedgerEntries(id, ledgerType, quota, ""))
 }
 
-func DecreaseUserQuota(id int, quota int) (err error) {
+// DecreaseUserQuota 减少用户余额，ledgerType 为记录到额度账本的变动类型
+func DecreaseUserQuota(id int, quota int, ledgerType string) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -697,28 +715,24 @@ func DecreaseUserQuota(id int, quota int) (err error) {
 		}
 	})
 	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
+		addUserQuotaRecord(id, -quota, ledgerType)
 		return nil
 	}
-	return decreaseUserQuota(id, quota)
+	return decreaseUserQuota(id, quota, ledgerType)
 }
 
-func decreaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
-	if err != nil {
-		return err
-	}
-	return err
+func decreaseUserQuota(id int, quota int, ledgerType string) (err error) {
+	return updateUserQuotaWithLedger(id, -quota, newQuotaLedgerEntries(id, ledgerType, -quota, ""))
 }
 
-func DeltaUpdateUserQuota(id int, delta int) (err error) {
+func DeltaUpdateUserQuota(id int, delta int, ledgerType string) (err error) {
 	if delta == 0 {
 		return nil
 	}
 	if delta > 0 {
-		return IncreaseUserQuota(id, delta, false)
+		return IncreaseUserQuota(id, delta, false, ledgerType)
 	} else {
-		return DecreaseUserQuota(id, -delta)
+		return DecreaseUserQuota(id, -delta, ledgerType)
 	}
 }
 
@@ -732,6 +746,12 @@ func GetRootUser() (user *User) {
 	return user
 }
 
+// GetSpendReportUsers 返回订阅了用量报告的启用用户
+func GetSpendReportUsers() (users []*User, err error) {
+	err = DB.Where("status = ? AND setting LIKE ?", common.UserStatusEnabled, "%spend_report_%").Find(&users).Error
+	return users, err
+}
+
 func UpdateUserUsedQuotaAndRequestCount(id int, quota int) {
 	if common.BatchUpdateEnabled {
 		addNewRecord(BatchUpdateTypeUsedQuota, id, quota)
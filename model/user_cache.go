@@ -22,6 +22,12 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+
+	CreditLimit  int `json:"credit_limit"`
+	CreditStatus int `json:"credit_status"`
+	AdminRoleId  int `json:"admin_role_id"`
+
+	SubscriptionUntil int64 `json:"subscription_until"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -31,6 +37,8 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserEmail, user.Email)
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyUserCreditLimit, user.CreditLimit)
+	common.SetContextKey(c, constant.ContextKeyUserSubscriptionUntil, user.SubscriptionUntil)
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -107,6 +115,12 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+
+		CreditLimit:  user.CreditLimit,
+		CreditStatus: user.CreditStatus,
+		AdminRoleId:  user.AdminRoleId,
+
+		SubscriptionUntil: user.SubscriptionUntil,
 	}
 
 	return userCache, nil
@@ -22,6 +22,10 @@ const (
 var batchUpdateStores []map[int]int
 var batchUpdateLocks []sync.Mutex
 
+// batchQuotaLedgerAmounts 等待批量写入的额度账本金额，按用户与分录类型累计，
+// 每次批量更新时每种类型只写入一笔分录；与用户余额的批量更新共用锁并在同一事务中写入
+var batchQuotaLedgerAmounts = make(map[int]map[string]int)
+
 func init() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateStores = append(batchUpdateStores, make(map[int]int))
@@ -48,6 +52,17 @@ func addNewRecord(type_ int, id int, value int) {
 	}
 }
 
+// addUserQuotaRecord 暂存用户余额的变动与对应的账本分录
+func addUserQuotaRecord(id int, delta int, ledgerType string) {
+	batchUpdateLocks[BatchUpdateTypeUserQuota].Lock()
+	defer batchUpdateLocks[BatchUpdateTypeUserQuota].Unlock()
+	batchUpdateStores[BatchUpdateTypeUserQuota][id] += delta
+	if batchQuotaLedgerAmounts[id] == nil {
+		batchQuotaLedgerAmounts[id] = make(map[string]int)
+	}
+	batchQuotaLedgerAmounts[id][ledgerType] += delta
+}
+
 func batchUpdate() {
 	// check if there's any data to update
 	hasData := false
@@ -70,12 +85,21 @@ func batchUpdate() {
 		batchUpdateLocks[i].Lock()
 		store := batchUpdateStores[i]
 		batchUpdateStores[i] = make(map[int]int)
+		var ledgerAmounts map[int]map[string]int
+		if i == BatchUpdateTypeUserQuota {
+			ledgerAmounts = batchQuotaLedgerAmounts
+			batchQuotaLedgerAmounts = make(map[int]map[string]int)
+		}
 		batchUpdateLocks[i].Unlock()
 		// TODO: maybe we can combine updates with same key?
 		for key, value := range store {
 			switch i {
 			case BatchUpdateTypeUserQuota:
-				err := increaseUserQuota(key, value)
+				var entries []*QuotaLedgerEntry
+				for ledgerType, amount := range ledgerAmounts[key] {
+					entries = append(entries, newQuotaLedgerEntries(key, ledgerType, amount, "")...)
+				}
+				err := updateUserQuotaWithLedger(key, value, entries)
 				if err != nil {
 					common.SysError("failed to batch update user quota: " + err.Error())
 				}
@@ -102,9 +102,15 @@ type RelayInfo struct {
 	UserSetting          dto.UserSetting
 	UserEmail            string
 	UserQuota            int
+	UserCreditLimit      int // 账期额度，余额可以透支到 -UserCreditLimit
 	RelayFormat          string
 	SendResponseCount    int
 	ChannelCreateTime    int64
+
+	// UserSubscriptionUntil 用户生效中订阅的最晚到期时间，已过期时不再查询订阅
+	UserSubscriptionUntil int64
+	// SubscriptionReserved 预扣费时从套餐中预留、尚未结算的额度
+	SubscriptionReserved int
 	ThinkingContentInfo
 	*ClaudeConvertInfo
 	*RerankerInfo
@@ -226,6 +232,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 
 	info := &RelayInfo{
 		UserQuota:         common.GetContextKeyInt(c, constant.ContextKeyUserQuota),
+		UserCreditLimit:   common.GetContextKeyInt(c, constant.ContextKeyUserCreditLimit),
 		UserEmail:         common.GetContextKeyString(c, constant.ContextKeyUserEmail),
 		isFirstResponse:   true,
 		RelayMode:         relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -284,6 +291,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 	if ok {
 		info.UserSetting = userSetting
 	}
+	info.UserSubscriptionUntil = common.GetContextKeyInt64(c, constant.ContextKeyUserSubscriptionUntil)
 
 	return info
 }
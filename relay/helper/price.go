@@ -3,6 +3,8 @@ package helper
 import (
 	"fmt"
 	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/setting/ratio_setting"
 
@@ -13,6 +15,8 @@ type GroupRatioInfo struct {
 	GroupRatio        float64
 	GroupSpecialRatio float64
 	HasSpecialRatio   bool
+	// ScheduleRatio 时段与促销倍率，已经乘入 GroupRatio 与 GroupSpecialRatio
+	ScheduleRatio float64
 }
 
 type PriceData struct {
@@ -25,12 +29,107 @@ type PriceData struct {
 	UsePrice               bool
 	ShouldPreConsumedQuota int
 	GroupRatioInfo         GroupRatioInfo
+	// PriceTier 当前适用的阶梯倍率，nil 表示使用模型的基础倍率
+	PriceTier *ratio_setting.ModelPriceTier
+	// PriceOverride 用户或令牌的价格覆盖，在阶梯倍率之后应用
+	PriceOverride *model.PriceOverride
+
+	tierBase *priceTierBase
+}
+
+// priceTierBase 应用阶梯前的基础倍率，结算时按实际提示词数量重新选择阶梯
+type priceTierBase struct {
+	modelRatio      float64
+	completionRatio float64
+	cacheRatio      float64
 }
 
 func (p PriceData) ToSetting() string {
 	return fmt.Sprintf("ModelPrice: %f, ModelRatio: %f, CompletionRatio: %f, CacheRatio: %f, GroupRatio: %f, UsePrice: %t, CacheCreationRatio: %f, ShouldPreConsumedQuota: %d, ImageRatio: %f", p.ModelPrice, p.ModelRatio, p.CompletionRatio, p.CacheRatio, p.GroupRatioInfo.GroupRatio, p.UsePrice, p.CacheCreationRatio, p.ShouldPreConsumedQuota, p.ImageRatio)
 }
 
+// ApplyPriceTier 根据提示词 token 数选择模型的阶梯倍率，预扣费时使用估算的数量，结算时使用实际数量再次调用；
+// 价格覆盖的倍率乘数与补全倍率在阶梯之后应用
+func (p *PriceData) ApplyPriceTier(modelName string, promptTokens int) {
+	if p.UsePrice {
+		return
+	}
+	if p.tierBase == nil {
+		p.tierBase = &priceTierBase{
+			modelRatio:      p.ModelRatio,
+			completionRatio: p.CompletionRatio,
+			cacheRatio:      p.CacheRatio,
+		}
+	}
+	p.ModelRatio = p.tierBase.modelRatio
+	p.CompletionRatio = p.tierBase.completionRatio
+	p.CacheRatio = p.tierBase.cacheRatio
+	p.PriceTier = nil
+	if tier, ok := ratio_setting.GetModelPriceTier(modelName, promptTokens); ok {
+		p.PriceTier = tier
+		p.ModelRatio = tier.ModelRatio
+		if tier.CompletionRatio > 0 {
+			p.CompletionRatio = tier.CompletionRatio
+		}
+		if tier.CacheRatio > 0 {
+			p.CacheRatio = tier.CacheRatio
+		}
+	}
+	if p.PriceOverride != nil {
+		if p.PriceOverride.RatioMultiplier > 0 {
+			p.ModelRatio *= p.PriceOverride.RatioMultiplier
+		}
+		if p.PriceOverride.CompletionRatio > 0 {
+			p.CompletionRatio = p.PriceOverride.CompletionRatio
+		}
+	}
+}
+
+// PriceOverrideRatio 返回价格覆盖后的价格与覆盖前原价之比，用于从消费额度还原原价计算上游成本，
+// 原价按模型当前的价格或倍率（含阶梯）计算；没有价格覆盖时返回 1，原价为 0 无法还原时返回 0
+func (p *PriceData) PriceOverrideRatio(modelName string, promptTokens int, completionTokens int) float64 {
+	if p.PriceOverride == nil {
+		return 1
+	}
+	base := PriceData{}
+	base.ModelPrice, base.UsePrice = ratio_setting.GetModelPrice(modelName, false)
+	if !base.UsePrice {
+		base.ModelRatio, _ = ratio_setting.GetModelRatio(modelName)
+		base.CompletionRatio = ratio_setting.GetCompletionRatio(modelName)
+		base.ApplyPriceTier(modelName, promptTokens)
+	}
+	baseQuota := base.estimateQuota(promptTokens, completionTokens)
+	if baseQuota <= 0 {
+		return 0
+	}
+	return p.estimateQuota(promptTokens, completionTokens) / baseQuota
+}
+
+// estimateQuota 不含分组倍率的额度估算，只用于比较覆盖前后的价格
+func (p *PriceData) estimateQuota(promptTokens int, completionTokens int) float64 {
+	if p.UsePrice {
+		return p.ModelPrice * common.QuotaPerUnit
+	}
+	return (float64(promptTokens) + float64(completionTokens)*p.CompletionRatio) * p.ModelRatio
+}
+
+// ApplyPriceOverride 查找用户或令牌的价格覆盖：设置了按次价格时改为按次计费，
+// 按次计费的模型按倍率乘数调整价格，按量计费的倍率在 ApplyPriceTier 中调整
+func ApplyPriceOverride(c *gin.Context, info *relaycommon.RelayInfo, modelPrice float64, usePrice bool) (*model.PriceOverride, float64, bool) {
+	override, ok := model.GetPriceOverride(info.UserId, info.TokenId, info.OriginModelName)
+	if !ok {
+		return nil, modelPrice, usePrice
+	}
+	common.SetContextKey(c, constant.ContextKeyPriceOverride, override)
+	if override.ModelPrice > 0 {
+		return override, override.ModelPrice, true
+	}
+	if usePrice && override.RatioMultiplier > 0 {
+		modelPrice *= override.RatioMultiplier
+	}
+	return override, modelPrice, usePrice
+}
+
 // HandleGroupRatio checks for "auto_group" in the context and updates the group ratio and relayInfo.UsingGroup if present
 func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) GroupRatioInfo {
 	groupRatioInfo := GroupRatioInfo{
@@ -59,22 +158,35 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) GroupR
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// 按请求开始时间计算时段与促销倍率，并记录到上下文中供日志使用
+	schedule := ratio_setting.GetPriceSchedule(relayInfo.OriginModelName, relayInfo.UsingGroup, relayInfo.StartTime)
+	groupRatioInfo.ScheduleRatio = schedule.Ratio
+	if len(schedule.Rules) > 0 {
+		groupRatioInfo.GroupRatio *= schedule.Ratio
+		if groupRatioInfo.HasSpecialRatio {
+			groupRatioInfo.GroupSpecialRatio *= schedule.Ratio
+		}
+		common.SetContextKey(ctx, constant.ContextKeyPriceSchedule, schedule)
+	}
+
 	return groupRatioInfo
 }
 
 func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens int, maxTokens int) (PriceData, error) {
 	modelPrice, usePrice := ratio_setting.GetModelPrice(info.OriginModelName, false)
+	priceOverride, modelPrice, usePrice := ApplyPriceOverride(c, info, modelPrice, usePrice)
 
 	groupRatioInfo := HandleGroupRatio(c, info)
 
 	var preConsumedQuota int
+	var preConsumedTokens int
 	var modelRatio float64
 	var completionRatio float64
 	var cacheRatio float64
 	var imageRatio float64
 	var cacheCreationRatio float64
 	if !usePrice {
-		preConsumedTokens := common.PreConsumedQuota
+		preConsumedTokens = common.PreConsumedQuota
 		if maxTokens != 0 {
 			preConsumedTokens = promptTokens + maxTokens
 		}
@@ -93,8 +205,6 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		cacheRatio, _ = ratio_setting.GetCacheRatio(info.OriginModelName)
 		cacheCreationRatio, _ = ratio_setting.GetCreateCacheRatio(info.OriginModelName)
 		imageRatio, _ = ratio_setting.GetImageRatio(info.OriginModelName)
-		ratio := modelRatio * groupRatioInfo.GroupRatio
-		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
 		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	}
@@ -109,6 +219,11 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		ImageRatio:             imageRatio,
 		CacheCreationRatio:     cacheCreationRatio,
 		ShouldPreConsumedQuota: preConsumedQuota,
+		PriceOverride:          priceOverride,
+	}
+	if !usePrice {
+		priceData.ApplyPriceTier(info.OriginModelName, promptTokens)
+		priceData.ShouldPreConsumedQuota = int(float64(preConsumedTokens) * priceData.ModelRatio * groupRatioInfo.GroupRatio)
 	}
 
 	if common.DebugEnabled {
@@ -119,9 +234,12 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 }
 
 type PerCallPriceData struct {
-	ModelPrice     float64
-	Quota          int
-	GroupRatioInfo GroupRatioInfo
+	ModelPrice      float64
+	Quota           int
+	GroupRatioInfo  GroupRatioInfo
+	PriceOverrideId int
+	// PriceOverrideRatio 覆盖后价格与原价之比，没有价格覆盖时为 0
+	PriceOverrideRatio float64
 }
 
 // ModelPriceHelperPerCall 按次计费的 PriceHelper (MJ、Task)
@@ -138,15 +256,29 @@ func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) PerCal
 			modelPrice = defaultPrice
 		}
 	}
+	basePrice := modelPrice
+	priceOverride, modelPrice, _ := ApplyPriceOverride(c, info, modelPrice, true)
 	quota := int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	priceData := PerCallPriceData{
 		ModelPrice:     modelPrice,
 		Quota:          quota,
 		GroupRatioInfo: groupRatioInfo,
 	}
+	if priceOverride != nil {
+		priceData.PriceOverrideId = priceOverride.Id
+		priceData.PriceOverrideRatio = PerCallPriceOverrideRatio(basePrice, modelPrice)
+	}
 	return priceData
 }
 
+// PerCallPriceOverrideRatio 按次计费时覆盖后价格与原价之比，原价为 0 时返回 0
+func PerCallPriceOverrideRatio(basePrice float64, modelPrice float64) float64 {
+	if basePrice <= 0 {
+		return 0
+	}
+	return modelPrice / basePrice
+}
+
 func ContainPriceOrRatio(modelName string) bool {
 	_, ok := ratio_setting.GetModelPrice(modelName, false)
 	if ok {
@@ -0,0 +1,83 @@
+package helper
+
+import (
+	"math"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/ratio_setting"
+	"testing"
+)
+
+func TestApplyPriceTierReselectsOnSettlement(t *testing.T) {
+	previous := ratio_setting.ModelTierRatio2JSONString()
+	if err := ratio_setting.UpdateModelTierRatioByJSONString(`{"test-model":[{"min_prompt_tokens":1000,"model_ratio":4,"completion_ratio":6}]}`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ratio_setting.UpdateModelTierRatioByJSONString(previous) })
+
+	price := PriceData{ModelRatio: 2, CompletionRatio: 3, CacheRatio: 0.5}
+	// 预扣费时估算的提示词超过阈值
+	price.ApplyPriceTier("test-model", 5000)
+	if price.PriceTier == nil || price.ModelRatio != 4 || price.CompletionRatio != 6 || price.CacheRatio != 0.5 {
+		t.Fatalf("expected the tier to apply, got %+v", price)
+	}
+	// 结算时实际提示词低于阈值，恢复基础倍率
+	price.ApplyPriceTier("test-model", 800)
+	if price.PriceTier != nil || price.ModelRatio != 2 || price.CompletionRatio != 3 {
+		t.Fatalf("expected the base ratios after settlement, got %+v", price)
+	}
+
+	// 价格覆盖在阶梯之后应用
+	price.PriceOverride = &model.PriceOverride{RatioMultiplier: 0.5, CompletionRatio: 2}
+	price.ApplyPriceTier("test-model", 5000)
+	if price.ModelRatio != 2 || price.CompletionRatio != 2 {
+		t.Fatalf("expected the override on top of the tier, got %+v", price)
+	}
+
+	fixed := PriceData{ModelPrice: 0.1, UsePrice: true, ModelRatio: 2}
+	fixed.ApplyPriceTier("test-model", 5000)
+	if fixed.PriceTier != nil || fixed.ModelRatio != 2 {
+		t.Fatal("expected fixed-price models to ignore tiers")
+	}
+}
+
+func TestPriceOverrideRatio(t *testing.T) {
+	const modelName = "gpt-4o"
+	modelRatio, _ := ratio_setting.GetModelRatio(modelName)
+	completionRatio := ratio_setting.GetCompletionRatio(modelName)
+	baseQuota := (1000 + 500*completionRatio) * modelRatio
+
+	newPrice := func(override *model.PriceOverride) PriceData {
+		price := PriceData{ModelRatio: modelRatio, CompletionRatio: completionRatio, PriceOverride: override}
+		price.ApplyPriceTier(modelName, 1000)
+		return price
+	}
+
+	withoutOverride := newPrice(nil)
+	if ratio := withoutOverride.PriceOverrideRatio(modelName, 1000, 500); ratio != 1 {
+		t.Fatalf("expected 1 without an override, got %f", ratio)
+	}
+	multiplier := newPrice(&model.PriceOverride{RatioMultiplier: 0.8})
+	if ratio := multiplier.PriceOverrideRatio(modelName, 1000, 500); math.Abs(ratio-0.8) > 1e-9 {
+		t.Fatalf("expected 0.8 for a ratio multiplier, got %f", ratio)
+	}
+	completion := newPrice(&model.PriceOverride{CompletionRatio: 1})
+	expected := (1000 + 500*1.0) * modelRatio / baseQuota
+	if ratio := completion.PriceOverrideRatio(modelName, 1000, 500); math.Abs(ratio-expected) > 1e-9 {
+		t.Fatalf("expected %f for a completion ratio override, got %f", expected, ratio)
+	}
+	fixed := PriceData{ModelPrice: 0.02, UsePrice: true, PriceOverride: &model.PriceOverride{ModelPrice: 0.02}}
+	expected = 0.02 * common.QuotaPerUnit / baseQuota
+	if ratio := fixed.PriceOverrideRatio(modelName, 1000, 500); math.Abs(ratio-expected) > 1e-9 {
+		t.Fatalf("expected %f for a fixed price override, got %f", expected, ratio)
+	}
+}
+
+func TestPerCallPriceOverrideRatio(t *testing.T) {
+	if ratio := PerCallPriceOverrideRatio(0.1, 0.05); ratio != 0.5 {
+		t.Fatalf("expected 0.5, got %f", ratio)
+	}
+	if ratio := PerCallPriceOverrideRatio(0, 0.05); ratio != 0 {
+		t.Fatalf("expected 0 for a free base price, got %f", ratio)
+	}
+}
@@ -197,7 +197,8 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 		}
 	}
 
-	if userQuota-priceData.Quota < 0 {
+	subscriptionRemaining := service.GetModelSubscriptionRemaining(relayInfo, modelName)
+	if userQuota+common.GetContextKeyInt(c, constant.ContextKeyUserCreditLimit)+subscriptionRemaining-priceData.Quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
@@ -210,16 +211,26 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	if err != nil {
 		return &mjResp.Response
 	}
+	subscriptionQuota := 0
+	if mjResp.StatusCode == 200 && mjResp.Response.Code == 1 {
+		// 先从套餐中扣除，抵扣的额度记录在任务上，任务失败时退回套餐
+		subscriptionQuota = service.ConsumeModelSubscriptionQuota(c, relayInfo, modelName, priceData.Quota)
+	}
 	defer func() {
 		if mjResp.StatusCode == 200 && mjResp.Response.Code == 1 {
-			err := service.PostConsumeQuota(relayInfo, priceData.Quota, 0, true)
-			if err != nil {
-				common.SysError("error consuming token remain quota: " + err.Error())
+			if balanceQuota := priceData.Quota - subscriptionQuota; balanceQuota > 0 {
+				err := service.PostConsumeQuota(relayInfo, balanceQuota, 0, true)
+				if err != nil {
+					common.SysError("error consuming token remain quota: " + err.Error())
+				}
 			}
 
 			tokenName := c.GetString("token_name")
 			logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, constant.MjActionSwapFace)
 			other := service.GenerateMjOtherInfo(priceData)
+			if subscriptionQuota > 0 {
+				other["subscription_quota"] = subscriptionQuota
+			}
 			model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
 				ChannelId: channelId,
 				ModelName: modelName,
@@ -254,6 +265,8 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
+
+		SubscriptionQuota: subscriptionQuota,
 	}
 	err = midjourneyTask.Insert()
 	if err != nil {
@@ -285,8 +298,12 @@ func RelayMidjourneyTaskImageSeed(c *gin.Context) *dto.MidjourneyResponse {
 	if channel.Status != common.ChannelStatusEnabled {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
 	}
+	key, err := channel.GetKey()
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
+	}
 	c.Set("channel_id", originTask.ChannelId)
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 
 	requestURL := getMjRequestPath(c.Request.URL.String())
 	fullRequestURL := fmt.Sprintf("%s%s", channel.GetBaseURL(), requestURL)
@@ -464,9 +481,13 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 			if channel.Status != common.ChannelStatusEnabled {
 				return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
 			}
+			key, err := channel.GetKey()
+			if err != nil {
+				return service.MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
+			}
 			c.Set("base_url", channel.GetBaseURL())
 			c.Set("channel_id", originTask.ChannelId)
-			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 			log.Printf("检测到此操作为放大、变换、重绘，获取原channel信息: %s,%s", strconv.Itoa(originTask.ChannelId), channel.GetBaseURL())
 		}
 		midjRequest.Prompt = originTask.Prompt
@@ -504,7 +525,11 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		}
 	}
 
-	if consumeQuota && userQuota-priceData.Quota < 0 {
+	subscriptionRemaining := 0
+	if consumeQuota {
+		subscriptionRemaining = service.GetModelSubscriptionRemaining(relayInfo, modelName)
+	}
+	if consumeQuota && userQuota+common.GetContextKeyInt(c, constant.ContextKeyUserCreditLimit)+subscriptionRemaining-priceData.Quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
@@ -517,15 +542,22 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	}
 	midjResponse := &midjResponseWithStatus.Response
 
+	subscriptionQuota := 0
 	defer func() {
 		if consumeQuota && midjResponseWithStatus.StatusCode == 200 {
-			err := service.PostConsumeQuota(relayInfo, priceData.Quota, 0, true)
-			if err != nil {
-				common.SysError("error consuming token remain quota: " + err.Error())
+			// 套餐抵扣的部分在保存任务前已经扣除，这里只从余额中扣除剩余部分
+			if balanceQuota := priceData.Quota - subscriptionQuota; balanceQuota > 0 {
+				err := service.PostConsumeQuota(relayInfo, balanceQuota, 0, true)
+				if err != nil {
+					common.SysError("error consuming token remain quota: " + err.Error())
+				}
 			}
 			tokenName := c.GetString("token_name")
 			logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s，ID %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, midjRequest.Action, midjResponse.Result)
 			other := service.GenerateMjOtherInfo(priceData)
+			if subscriptionQuota > 0 {
+				other["subscription_quota"] = subscriptionQuota
+			}
 			model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
 				ChannelId: channelId,
 				ModelName: modelName,
@@ -611,6 +643,11 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		midjourneyTask.Progress = "100%"
 		midjourneyTask.Status = "SUCCESS"
 	}
+	if consumeQuota && midjResponseWithStatus.StatusCode == 200 {
+		// 先从套餐中扣除，抵扣的额度记录在任务上，任务失败时退回套餐
+		subscriptionQuota = service.ConsumeModelSubscriptionQuota(c, relayInfo, modelName, priceData.Quota)
+		midjourneyTask.SubscriptionQuota = subscriptionQuota
+	}
 	err = midjourneyTask.Insert()
 	if err != nil {
 		return &dto.MidjourneyResponse{
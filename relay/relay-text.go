@@ -281,19 +281,34 @@ func checkRequestSensitive(textRequest *dto.GeneralOpenAIRequest, info *relaycom
 }
 
 // 预扣费并返回用户剩余配额
-func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (int, int, *dto.OpenAIErrorWithStatusCode) {
+func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommon.RelayInfo) (_ int, _ int, openaiErr *dto.OpenAIErrorWithStatusCode) {
 	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
 	if err != nil {
 		return 0, 0, service.OpenAIErrorWrapperLocal(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
-	if userQuota <= 0 {
+	relayInfo.UserQuota = userQuota
+	// 优先从套餐中预留额度，结算时多退少补；套餐不足的部分仍按余额、账期额度与令牌额度检查并预扣
+	if reserved := service.ReserveSubscriptionQuota(c, relayInfo, preConsumedQuota); reserved > 0 {
+		defer func() {
+			if openaiErr != nil {
+				service.ReleaseSubscriptionQuota(c, relayInfo)
+			}
+		}()
+		common.LogInfo(c, fmt.Sprintf("user %d reserved subscription quota %s", relayInfo.UserId, common.FormatQuota(reserved)))
+		preConsumedQuota -= reserved
+		if preConsumedQuota == 0 {
+			return 0, userQuota, nil
+		}
+	}
+	// 账期用户的余额可以透支到 -UserCreditLimit
+	availableQuota := userQuota + relayInfo.UserCreditLimit
+	if availableQuota <= 0 {
 		return 0, 0, service.OpenAIErrorWrapperLocal(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
 	}
-	if userQuota-preConsumedQuota < 0 {
-		return 0, 0, service.OpenAIErrorWrapperLocal(fmt.Errorf("chat pre-consumed quota failed, user quota: %s, need quota: %s", common.FormatQuota(userQuota), common.FormatQuota(preConsumedQuota)), "insufficient_user_quota", http.StatusForbidden)
+	if availableQuota-preConsumedQuota < 0 {
+		return 0, 0, service.OpenAIErrorWrapperLocal(fmt.Errorf("chat pre-consumed quota failed, user quota: %s, need quota: %s", common.FormatQuota(availableQuota), common.FormatQuota(preConsumedQuota)), "insufficient_user_quota", http.StatusForbidden)
 	}
-	relayInfo.UserQuota = userQuota
-	if userQuota > 100*preConsumedQuota {
+	if availableQuota > 100*preConsumedQuota {
 		// 用户额度充足，判断令牌额度是否充足
 		if !relayInfo.TokenUnlimited {
 			// 非无限令牌，判断令牌额度是否充足
@@ -316,7 +331,7 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 		if err != nil {
 			return 0, 0, service.OpenAIErrorWrapperLocal(err, "pre_consume_token_quota_failed", http.StatusForbidden)
 		}
-		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota)
+		err = model.DecreaseUserQuota(relayInfo.UserId, preConsumedQuota, model.QuotaLedgerTypePreConsume)
 		if err != nil {
 			return 0, 0, service.OpenAIErrorWrapperLocal(err, "decrease_user_quota_failed", http.StatusInternalServerError)
 		}
@@ -325,6 +340,7 @@ func preConsumeQuota(c *gin.Context, preConsumedQuota int, relayInfo *relaycommo
 }
 
 func returnPreConsumedQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, userQuota int, preConsumedQuota int) {
+	service.ReleaseSubscriptionQuota(c, relayInfo)
 	if preConsumedQuota != 0 {
 		gopool.Go(func() {
 			relayInfoCopy := *relayInfo
@@ -349,6 +365,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	}
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
+	// 按实际提示词数量重新选择阶梯倍率
+	priceData.ApplyPriceTier(relayInfo.OriginModelName, promptTokens)
 	cacheTokens := usage.PromptTokensDetails.CachedTokens
 	imageTokens := usage.PromptTokensDetails.ImageTokens
 	audioTokens := usage.PromptTokensDetails.AudioTokens
@@ -472,9 +490,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	var logContent string
 	if !priceData.UsePrice {
 		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，分组倍率 %.2f", modelRatio, completionRatio, groupRatio)
+		if priceData.PriceTier != nil {
+			logContent += fmt.Sprintf("，阶梯价格（提示超过 %d tokens）", priceData.PriceTier.MinPromptTokens)
+		}
 	} else {
 		logContent = fmt.Sprintf("模型价格 %.2f，分组倍率 %.2f", modelPrice, groupRatio)
 	}
+	if scheduleRatio := priceData.GroupRatioInfo.ScheduleRatio; scheduleRatio != 0 && scheduleRatio != 1 {
+		logContent += fmt.Sprintf("，分组倍率已包含时段倍率 %.2f", scheduleRatio)
+	}
+	if priceData.PriceOverride != nil {
+		logContent += fmt.Sprintf("，使用价格覆盖 #%d", priceData.PriceOverride.Id)
+	}
 
 	// record all the consume log even if quota is 0
 	if totalTokens == 0 {
@@ -489,7 +516,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
-	quotaDelta := quota - preConsumedQuota
+	subscriptionQuota := service.ConsumeSubscriptionQuota(ctx, relayInfo, quota)
+	quotaDelta := quota - preConsumedQuota - subscriptionQuota
 	if quotaDelta != 0 {
 		err := service.PostConsumeQuota(relayInfo, quotaDelta, preConsumedQuota, true)
 		if err != nil {
@@ -510,6 +538,12 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logContent += ", " + extraContent
 	}
 	other := service.GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, cacheTokens, cacheRatio, modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if priceData.PriceTier != nil {
+		other["price_tier"] = priceData.PriceTier.MinPromptTokens
+	}
+	if priceData.PriceOverride != nil {
+		other["price_override_ratio"] = priceData.PriceOverrideRatio(modelName, promptTokens, completionTokens)
+	}
 	if imageTokens != 0 {
 		other["image"] = true
 		other["image_ratio"] = imageRatio
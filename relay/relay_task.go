@@ -13,6 +13,7 @@ import (
 	"one-api/model"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting/ratio_setting"
 
@@ -51,10 +52,16 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		}
 	}
 
+	basePrice := modelPrice
+	priceOverride, modelPrice, _ := helper.ApplyPriceOverride(c, relayInfo.RelayInfo, modelPrice, true)
+
 	// 预扣
 	groupRatio := ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	var ratio float64
 	userGroupRatio, hasUserGroupRatio := ratio_setting.GetGroupGroupRatio(relayInfo.UserGroup, relayInfo.UsingGroup)
+	schedule := ratio_setting.GetPriceSchedule(relayInfo.OriginModelName, relayInfo.UsingGroup, relayInfo.StartTime)
+	groupRatio *= schedule.Ratio
+	userGroupRatio *= schedule.Ratio
 	if hasUserGroupRatio {
 		ratio = modelPrice * userGroupRatio
 	} else {
@@ -66,7 +73,8 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		return
 	}
 	quota := int(ratio * common.QuotaPerUnit)
-	if userQuota-quota < 0 {
+	subscriptionRemaining := service.GetModelSubscriptionRemaining(relayInfo.RelayInfo, modelName)
+	if userQuota+relayInfo.UserCreditLimit+subscriptionRemaining-quota < 0 {
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
 		return
 	}
@@ -90,9 +98,14 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 			if channel.Status != common.ChannelStatusEnabled {
 				return service.TaskErrorWrapperLocal(errors.New("该任务所属渠道已被禁用"), "task_channel_disable", http.StatusBadRequest)
 			}
+			key, err := channel.GetKey()
+			if err != nil {
+				taskErr = service.TaskErrorWrapperLocal(err, "channel_key_decrypt_failed", http.StatusInternalServerError)
+				return
+			}
 			c.Set("base_url", channel.GetBaseURL())
 			c.Set("channel_id", originTask.ChannelId)
-			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 
 			relayInfo.BaseUrl = channel.GetBaseURL()
 			relayInfo.ChannelId = originTask.ChannelId
@@ -118,13 +131,16 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		return
 	}
 
+	subscriptionQuota := 0
 	defer func() {
 		// release quota
 		if relayInfo.ConsumeQuota && taskErr == nil {
-
-			err := service.PostConsumeQuota(relayInfo.RelayInfo, quota, 0, true)
-			if err != nil {
-				common.SysError("error consuming token remain quota: " + err.Error())
+			// 套餐抵扣的部分在提交任务时已经扣除，这里只从余额中扣除剩余部分
+			if balanceQuota := quota - subscriptionQuota; balanceQuota > 0 {
+				err := service.PostConsumeQuota(relayInfo.RelayInfo, balanceQuota, 0, true)
+				if err != nil {
+					common.SysError("error consuming token remain quota: " + err.Error())
+				}
 			}
 			if quota != 0 {
 				tokenName := c.GetString("token_name")
@@ -139,6 +155,17 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 				if hasUserGroupRatio {
 					other["user_group_ratio"] = userGroupRatio
 				}
+				if priceOverride != nil {
+					other["price_override_id"] = priceOverride.Id
+					other["price_override_ratio"] = helper.PerCallPriceOverrideRatio(basePrice, modelPrice)
+				}
+				if len(schedule.Rules) > 0 {
+					other["schedule_ratio"] = schedule.Ratio
+					other["schedule_rules"] = schedule.Rules
+				}
+				if subscriptionQuota > 0 {
+					other["subscription_quota"] = subscriptionQuota
+				}
 				model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
 					ChannelId: relayInfo.ChannelId,
 					ModelName: modelName,
@@ -161,14 +188,20 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		return
 	}
 	relayInfo.ConsumeQuota = true
+	// 先从套餐中扣除，抵扣的额度记录在任务上，任务失败时退回套餐
+	subscriptionQuota = service.ConsumeModelSubscriptionQuota(c, relayInfo.RelayInfo, modelName, quota)
 	// insert task
 	task := model.InitTask(platform, relayInfo)
 	task.TaskID = taskID
 	task.Quota = quota
+	task.SubscriptionQuota = subscriptionQuota
 	task.Data = taskData
 	task.Action = relayInfo.Action
 	err = task.Insert()
 	if err != nil {
+		if _, refundErr := model.RefundSubscriptionQuota(relayInfo.UserId, subscriptionQuota); refundErr != nil {
+			common.SysError("error refunding subscription quota: " + refundErr.Error())
+		}
 		taskErr = service.TaskErrorWrapper(err, "insert_task_failed", http.StatusInternalServerError)
 		return
 	}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"one-api/common"
 	"one-api/controller"
 	"one-api/middleware"
 
@@ -42,9 +43,16 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Login)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.LoginTwoFA)
+			userRoute.POST("/login/2fa/setup", middleware.CriticalRateLimit(), controller.LoginTwoFASetup)
+			userRoute.POST("/passkey/login/begin", middleware.CriticalRateLimit(), controller.BeginPasskeyLogin)
+			userRoute.POST("/passkey/login/finish", middleware.CriticalRateLimit(), controller.FinishPasskeyLogin)
+			userRoute.POST("/passkey/2fa/begin", middleware.CriticalRateLimit(), controller.BeginPasskeyTwoFA)
+			userRoute.POST("/passkey/2fa/finish", middleware.CriticalRateLimit(), controller.FinishPasskeyTwoFA)
 			//userRoute.POST("/tokenlog", middleware.CriticalRateLimit(), controller.TokenLog)
 			userRoute.GET("/logout", controller.Logout)
 			userRoute.GET("/epay/notify", controller.EpayNotify)
+			userRoute.POST("/stripe/webhook", controller.StripeWebhook)
 			userRoute.GET("/groups", controller.GetUserGroups)
 
 			selfRoute := userRoute.Group("/")
@@ -53,31 +61,53 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/self/groups", controller.GetUserGroups)
 				selfRoute.GET("/self", controller.GetSelf)
 				selfRoute.GET("/models", controller.GetUserModels)
+				selfRoute.GET("/self/price_overrides", controller.GetSelfPriceOverrides)
+				selfRoute.GET("/self/subscriptions", controller.GetSelfSubscriptions)
+				selfRoute.POST("/self/subscription", middleware.CriticalRateLimit(), controller.Subscribe)
+				selfRoute.PUT("/self/subscription/auto_renew", controller.UpdateSelfSubscriptionAutoRenew)
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.POST("/pay", controller.RequestEpay)
+				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripeCheckout)
+				selfRoute.GET("/self/payment_receipts", controller.GetSelfPaymentReceipts)
+				selfRoute.GET("/self/invoices", controller.GetSelfInvoices)
+				selfRoute.GET("/self/invoices/:id", controller.GetSelfInvoice)
+				selfRoute.GET("/self/credit", controller.GetSelfCredit)
+				selfRoute.POST("/self/credit", middleware.CriticalRateLimit(), controller.ApplySelfCredit)
+				selfRoute.GET("/self/credit/statements", controller.GetSelfCreditStatements)
 				selfRoute.POST("/amount", controller.RequestAmount)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
+				selfRoute.GET("/self/2fa", controller.GetSelfTwoFA)
+				selfRoute.POST("/self/2fa/setup", controller.SetupSelfTwoFA)
+				selfRoute.POST("/self/2fa/enable", middleware.CriticalRateLimit(), controller.EnableSelfTwoFA)
+				selfRoute.POST("/self/2fa/disable", middleware.CriticalRateLimit(), controller.DisableSelfTwoFA)
+				selfRoute.POST("/self/2fa/backup_codes", middleware.CriticalRateLimit(), controller.RegenerateSelfTwoFABackupCodes)
+				selfRoute.GET("/passkey", controller.GetSelfPasskeys)
+				selfRoute.POST("/passkey/register/begin", controller.BeginPasskeyRegistration)
+				selfRoute.POST("/passkey/register/finish", controller.FinishPasskeyRegistration)
+				selfRoute.DELETE("/passkey/:id", controller.DeleteSelfPasskey)
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
+			adminRoute.Use(middleware.PermissionAuth(common.PermissionManageUsers))
 			{
 				adminRoute.GET("/", controller.GetAllUsers)
 				adminRoute.GET("/search", controller.SearchUsers)
 				adminRoute.GET("/:id", controller.GetUser)
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
+				adminRoute.GET("/quota_adjustment", controller.GetQuotaAdjustments)
+				adminRoute.POST("/quota_adjustment", controller.AdjustUserQuota)
 				adminRoute.PUT("/", controller.UpdateUser)
 				adminRoute.DELETE("/:id", controller.DeleteUser)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
+		optionRoute.Use(middleware.PermissionAuth(common.PermissionManageOptions))
 		{
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
@@ -85,19 +115,20 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
-		ratioSyncRoute.Use(middleware.RootAuth())
+		ratioSyncRoute.Use(middleware.PermissionAuth(common.PermissionManageOptions))
 		{
 			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
 			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
 		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
+		channelRoute.Use(middleware.PermissionAuth(common.PermissionManageChannels))
 		{
 			channelRoute.GET("/", controller.GetAllChannels)
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
 			channelRoute.GET("/:id", controller.GetChannel)
+			channelRoute.GET("/:id/key", middleware.PermissionAuth(common.PermissionViewChannelKeys), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
@@ -115,6 +146,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/fetch_models", controller.FetchModels)
 			channelRoute.POST("/batch/tag", controller.BatchSetChannelTag)
 			channelRoute.GET("/tag/models", controller.GetTagModels)
+			channelRoute.POST("/reencrypt", middleware.RootAuth(), controller.ReencryptChannelSecrets)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -123,12 +155,14 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.GET("/search", controller.SearchTokens)
 			tokenRoute.GET("/:id", controller.GetToken)
 			tokenRoute.POST("/", controller.AddToken)
+			tokenRoute.POST("/chat_key", controller.GetChatTokenKey)
+			tokenRoute.POST("/:id/regenerate", middleware.CriticalRateLimit(), controller.RegenerateToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
+		redemptionRoute.Use(middleware.PermissionAuth(common.PermissionIssueRedemptions))
 		{
 			redemptionRoute.GET("/", controller.GetAllRedemptions)
 			redemptionRoute.GET("/search", controller.SearchRedemptions)
@@ -139,17 +173,26 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetAllLogs)
+		logRoute.DELETE("/", middleware.PermissionAuth(common.PermissionManageLogs), controller.DeleteHistoryLogs)
+		logRoute.POST("/retention/run", middleware.PermissionAuth(common.PermissionManageLogs), controller.RunLogRetention)
+		logRoute.GET("/stat", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetLogsStat)
+		logRoute.GET("/export", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.ExportAllLogs)
+		logRoute.GET("/payload", middleware.PermissionAuth(common.PermissionViewLogPayloads), controller.GetLogPayload)
+		logRoute.GET("/writer", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetLogWriterStats)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/search", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
+		logRoute.GET("/self/export", middleware.UserAuth(), controller.ExportUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 
 		dataRoute := apiRouter.Group("/data")
-		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
+		dataRoute.GET("/", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
+		dataRoute.GET("/analytics", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetUsageAnalytics)
+		dataRoute.GET("/analytics/self", middleware.UserAuth(), controller.GetUserUsageAnalytics)
+		dataRoute.GET("/margin", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetMarginReport)
+		dataRoute.GET("/report/self", middleware.UserAuth(), controller.GetUserSpendReport)
 
 		logRoute.Use(middleware.CORS())
 		{
@@ -157,18 +200,87 @@ func SetApiRouter(router *gin.Engine) {
 
 		}
 		groupRoute := apiRouter.Group("/group")
-		groupRoute.Use(middleware.AdminAuth())
+		groupRoute.Use(middleware.AnyPermissionAuth(common.PermissionManageChannels, common.PermissionManageUsers))
 		{
 			groupRoute.GET("/", controller.GetGroups)
 		}
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetAllMidjourney)
 
 		taskRoute := apiRouter.Group("/task")
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
-			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/", middleware.PermissionAuth(common.PermissionViewAllLogs), controller.GetAllTask)
+		}
+
+		topUpRoute := apiRouter.Group("/topup")
+		topUpRoute.Use(middleware.PermissionAuth(common.PermissionApprovePayments))
+		{
+			topUpRoute.GET("/", controller.GetAllTopUps)
+			topUpRoute.POST("/complete", controller.AdminCompleteTopUp)
+			topUpRoute.POST("/refund", controller.AdminRefundTopUp)
+		}
+
+		invoiceRoute := apiRouter.Group("/invoice")
+		invoiceRoute.Use(middleware.PermissionAuth(common.PermissionApprovePayments))
+		{
+			invoiceRoute.GET("/", controller.GetAllInvoices)
+			invoiceRoute.GET("/:id", controller.GetInvoice)
+			invoiceRoute.POST("/generate", controller.GenerateInvoice)
+		}
+
+		creditRoute := apiRouter.Group("/credit")
+		creditRoute.Use(middleware.PermissionAuth(common.PermissionApprovePayments))
+		{
+			creditRoute.GET("/", controller.GetCreditAccounts)
+			creditRoute.POST("/:id/:action", controller.ReviewCreditAccount)
+			creditRoute.GET("/statement", controller.GetAllCreditStatements)
+			creditRoute.POST("/statement/:id/settle", controller.SettleCreditStatement)
+		}
+
+		quotaLedgerRoute := apiRouter.Group("/quota_ledger")
+		quotaLedgerRoute.Use(middleware.PermissionAuth(common.PermissionApprovePayments))
+		{
+			quotaLedgerRoute.GET("/", controller.GetQuotaLedgerEntries)
+			quotaLedgerRoute.GET("/reconcile", controller.GetQuotaLedgerReport)
+			quotaLedgerRoute.POST("/reconcile", middleware.CriticalRateLimit(), controller.ReconcileQuotaLedger)
+		}
+
+		priceOverrideRoute := apiRouter.Group("/price_override")
+		priceOverrideRoute.Use(middleware.PermissionAuth(common.PermissionManageUsers))
+		{
+			priceOverrideRoute.GET("/", controller.GetPriceOverrides)
+			priceOverrideRoute.POST("/", controller.AddPriceOverride)
+			priceOverrideRoute.PUT("/", controller.UpdatePriceOverride)
+			priceOverrideRoute.DELETE("/:id", controller.DeletePriceOverride)
+		}
+
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.Use(middleware.PermissionAuth(common.PermissionManageUsers))
+		{
+			subscriptionRoute.GET("/", controller.GetAllSubscriptions)
+			subscriptionRoute.GET("/plans", controller.GetSubscriptionPlans)
+			subscriptionRoute.POST("/grant", controller.GrantSubscription)
+			subscriptionRoute.POST("/:id/cancel", controller.CancelSubscription)
+		}
+		subscriptionPlanRoute := apiRouter.Group("/subscription_plan")
+		subscriptionPlanRoute.Use(middleware.PermissionAuth(common.PermissionManageOptions))
+		{
+			subscriptionPlanRoute.GET("/", controller.GetSubscriptionPlans)
+			subscriptionPlanRoute.POST("/", controller.AddSubscriptionPlan)
+			subscriptionPlanRoute.PUT("/", controller.UpdateSubscriptionPlan)
+			subscriptionPlanRoute.DELETE("/:id", controller.DeleteSubscriptionPlan)
+		}
+
+		adminRoleRoute := apiRouter.Group("/admin_role")
+		adminRoleRoute.Use(middleware.RootAuth())
+		{
+			adminRoleRoute.GET("/", controller.GetAdminRoles)
+			adminRoleRoute.POST("/", controller.AddAdminRole)
+			adminRoleRoute.PUT("/", controller.UpdateAdminRole)
+			adminRoleRoute.DELETE("/:id", controller.DeleteAdminRole)
+			adminRoleRoute.POST("/assign", controller.AssignAdminRole)
 		}
 	}
 }
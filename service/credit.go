@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"time"
+)
+
+// GenerateCreditStatement 生成账期用户在 [start, end) 内的结算单，同时生成同一账单期的月度账单，
+// 本期消费与期末余额取自月度账单。本期欠款为本期消费中超出余额的部分；
+// 账单期结束后到生成结算单之前的充值与兑换先抵扣较早的欠款，剩余部分计入本期已付款
+func GenerateCreditStatement(account *model.CreditAccount, start time.Time, end time.Time) (*model.CreditStatement, error) {
+	user, err := model.GetUserById(account.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = GenerateInvoice(user.Id, start, end); err != nil && !errors.Is(err, model.ErrInvoiceExists) {
+		return nil, err
+	}
+	invoiceId, err := model.GetInvoiceIdByPeriod(user.Id, start.Unix())
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := model.GetInvoiceById(invoiceId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	usage := invoice.UsageQuota
+	closing := invoice.ClosingBalance
+	paidAfter, err := model.SumQuotaLedgerAmount(user.Id, []string{model.QuotaLedgerTypeTopUp, model.QuotaLedgerTypeRedemption}, end.Unix())
+	if err != nil {
+		return nil, err
+	}
+	outstanding, err := model.GetUnpaidCreditQuota(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	amountDue := max(0, min(usage, -closing))
+	// 账单期结束时的欠款扣除之后的付款，即为所有结算单合计应未付的欠款
+	unpaid := max(0, -closing-paidAfter) - outstanding
+	unpaid = min(max(unpaid, 0), amountDue)
+
+	termsDays := account.TermsDays
+	if termsDays <= 0 {
+		termsDays = system_setting.GetCreditSettings().TermsDays
+	}
+	statement := &model.CreditStatement{
+		UserId:         user.Id,
+		PeriodStart:    start.Unix(),
+		PeriodEnd:      end.Unix(),
+		InvoiceId:      invoiceId,
+		UsageQuota:     usage,
+		ClosingBalance: closing,
+		AmountDue:      amountDue,
+		PaidQuota:      amountDue - unpaid,
+		DueTime:        end.AddDate(0, 0, termsDays).Unix(),
+	}
+	if err = model.InsertCreditStatement(statement); err != nil {
+		return nil, err
+	}
+	if statement.Status == model.CreditStatementStatusUnpaid {
+		content := fmt.Sprintf("您 %s 的账期结算单已生成，应付额度 %s，请在 %s 前付清", start.Format("2006-01"),
+			common.LogQuota(statement.AmountDue-statement.PaidQuota), time.Unix(statement.DueTime, 0).Format("2006-01-02"))
+		notifyCreditUser(user, "账期结算单已生成", content)
+	}
+	return statement, nil
+}
+
+// GenerateCreditStatements 为已开通账期、还没有上月结算单的用户生成结算单，账单期按账单时区划分
+func GenerateCreditStatements(now time.Time) {
+	start, end := spendReportRange(SpendReportMonthly, now.In(system_setting.GetInvoiceSettings().Location()))
+	accounts, err := model.GetCreditAccountsWithoutStatement(start.Unix(), end.Unix())
+	if err != nil {
+		common.SysError("failed to get credit accounts: " + err.Error())
+		return
+	}
+	generated := 0
+	for _, account := range accounts {
+		_, err = GenerateCreditStatement(account, start, end)
+		if errors.Is(err, model.ErrCreditStatementExists) {
+			continue
+		}
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to generate credit statement for user %d: %s", account.UserId, err.Error()))
+			continue
+		}
+		generated++
+	}
+	if generated > 0 {
+		common.SysLog(fmt.Sprintf("generated %d credit statements for %s", generated, start.Format("2006-01")))
+	}
+}
+
+// creditOverdueStatus 根据最早逾期的结算单计算用户的逾期状态
+func creditOverdueStatus(statement *model.CreditStatement, now time.Time) int {
+	if statement == nil {
+		return model.UserCreditStatusNormal
+	}
+	settings := system_setting.GetCreditSettings()
+	overdueDays := int(now.Sub(time.Unix(statement.DueTime, 0)).Hours() / 24)
+	if settings.SuspendAfterDays > 0 && overdueDays >= settings.SuspendAfterDays {
+		return model.UserCreditStatusSuspended
+	}
+	if settings.ThrottleRequestsPerMinute > 0 && overdueDays >= settings.ThrottleAfterDays {
+		return model.UserCreditStatusThrottled
+	}
+	return model.UserCreditStatusNormal
+}
+
+// UpdateCreditOverdueStatus 根据逾期未付的结算单限制或暂停用户，付清后恢复
+func UpdateCreditOverdueStatus(now time.Time) {
+	userIds, err := model.GetCreditOverdueUserIds(now.Unix())
+	if err != nil {
+		common.SysError("failed to get credit overdue users: " + err.Error())
+		return
+	}
+	for _, userId := range userIds {
+		statement, err := model.GetOldestOverdueCreditStatement(userId, now.Unix())
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to get overdue credit statement of user %d: %s", userId, err.Error()))
+			continue
+		}
+		status := creditOverdueStatus(statement, now)
+		changed, err := model.UpdateUserCreditStatus(userId, status)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to update credit status of user %d: %s", userId, err.Error()))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		var title, content string
+		switch status {
+		case model.UserCreditStatusThrottled:
+			title = "账期结算单已逾期"
+			content = fmt.Sprintf("您的账期结算单已于 %s 到期，目前仍未付清，API 请求频率已被限制，请尽快充值付清欠款", time.Unix(statement.DueTime, 0).Format("2006-01-02"))
+		case model.UserCreditStatusSuspended:
+			title = "账户已暂停调用"
+			content = fmt.Sprintf("您的账期结算单已于 %s 到期，逾期未付，API 调用已暂停，请充值付清欠款后恢复", time.Unix(statement.DueTime, 0).Format("2006-01-02"))
+		default:
+			title = "账户已恢复"
+			content = "您的逾期结算单已付清，API 调用已恢复正常"
+		}
+		model.RecordLog(userId, model.LogTypeSystem, content)
+		user, err := model.GetUserById(userId, false)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to get user %d: %s", userId, err.Error()))
+			continue
+		}
+		notifyCreditUser(user, title, content)
+	}
+}
+
+func notifyCreditUser(user *model.User, title string, content string) {
+	err := NotifyUser(user.Id, user.Email, user.GetSetting(), dto.NewNotify(dto.NotifyTypeCredit, title, content, nil))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to notify user %d: %s", user.Id, err.Error()))
+	}
+}
+
+// StartCreditJob 每 10 分钟检查逾期的结算单，并在每月 1 日设置的时间之后补齐缺少的上月结算单，
+// 因此停机错过生成时间或生成失败的结算单会在之后的检查中生成，只应在主节点调用
+func StartCreditJob() {
+	for {
+		settings := system_setting.GetCreditSettings()
+		now := time.Now().In(system_setting.GetInvoiceSettings().Location())
+		if now.Day() > 1 || now.Hour() >= settings.RunHour {
+			GenerateCreditStatements(now)
+		}
+		UpdateCreditOverdueStatus(now)
+		time.Sleep(10 * time.Minute)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"one-api/model"
+	"testing"
+	"time"
+)
+
+func createTestCreditAccount(t *testing.T, userId int, reviewedTime int64) *model.CreditAccount {
+	t.Helper()
+	account := &model.CreditAccount{UserId: userId, CreditLimit: 1000000, TermsDays: 15, Status: model.CreditAccountStatusActive, ReviewedTime: reviewedTime}
+	if err := model.DB.Create(account).Error; err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+func TestGenerateCreditStatementSettlesLaterPayments(t *testing.T) {
+	setupTestDB(t)
+	start, end, err := InvoiceMonthRange("2026-08")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid := start.Add(48 * time.Hour).Unix()
+
+	// 本期消费 600000，余额 100000，欠款 500000，账单期结束后充值 200000
+	user := createTestUser(t, "credit", 0)
+	account := createTestCreditAccount(t, user.Id, start.Unix()-100)
+	createTestConsumeLog(t, user.Id, "gpt-4o", 600000, mid, "")
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeOpening, 100000, start.Unix()-100)
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeConsume, -600000, mid)
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeTopUp, 200000, end.Unix()+100)
+
+	statement, err := GenerateCreditStatement(account, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.UsageQuota != 600000 || statement.ClosingBalance != -500000 {
+		t.Fatalf("expected usage 600000 and closing balance -500000, got %+v", statement)
+	}
+	if statement.AmountDue != 500000 || statement.PaidQuota != 200000 || statement.Status != model.CreditStatementStatusUnpaid {
+		t.Fatalf("expected 500000 due with 200000 paid, got %+v", statement)
+	}
+	if statement.InvoiceId == 0 || statement.DueTime != end.AddDate(0, 0, 15).Unix() {
+		t.Fatalf("expected statement linked to the invoice and due in 15 days, got %+v", statement)
+	}
+	if _, err := GenerateCreditStatement(account, start, end); !errors.Is(err, model.ErrCreditStatementExists) {
+		t.Fatalf("expected duplicated statement to be rejected, got %v", err)
+	}
+
+	// 期末余额不为负的用户没有欠款
+	paid := createTestUser(t, "prepaid", 0)
+	paidAccount := createTestCreditAccount(t, paid.Id, start.Unix()-100)
+	createTestConsumeLog(t, paid.Id, "gpt-4o", 100000, mid, "")
+	insertTestLedgerEntry(t, paid.Id, model.QuotaLedgerTypeOpening, 300000, start.Unix()-100)
+	insertTestLedgerEntry(t, paid.Id, model.QuotaLedgerTypeConsume, -100000, mid)
+	statement, err = GenerateCreditStatement(paidAccount, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.AmountDue != 0 || statement.Status != model.CreditStatementStatusPaid {
+		t.Fatalf("expected nothing due, got %+v", statement)
+	}
+}
+
+func TestGenerateCreditStatementsCatchesUpMissingAccounts(t *testing.T) {
+	setupTestDB(t)
+	start, end, err := InvoiceMonthRange("2026-08")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := createTestUser(t, "first", 0)
+	second := createTestUser(t, "second", 0)
+	late := createTestUser(t, "late", 0)
+	firstAccount := createTestCreditAccount(t, first.Id, start.Unix())
+	createTestCreditAccount(t, second.Id, start.Unix())
+	// 账单期结束后才开通的账户不生成上月结算单
+	createTestCreditAccount(t, late.Id, end.Unix()+100)
+	if _, err := GenerateCreditStatement(firstAccount, start, end); err != nil {
+		t.Fatal(err)
+	}
+
+	GenerateCreditStatements(end.Add(72 * time.Hour))
+
+	var statements []*model.CreditStatement
+	if err := model.DB.Where("period_start = ?", start.Unix()).Order("user_id").Find(&statements).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 2 || statements[0].UserId != first.Id || statements[1].UserId != second.Id {
+		t.Fatalf("expected statements for the first and second users only, got %d", len(statements))
+	}
+}
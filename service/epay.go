@@ -1,7 +1,20 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"one-api/model"
 	"one-api/setting"
+	"strconv"
+	"strings"
+
+	"github.com/Calcium-Ion/go-epay/epay"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 )
 
 func GetCallbackAddress() string {
@@ -10,3 +23,163 @@ func GetCallbackAddress() string {
 	}
 	return setting.CustomCallbackAddress
 }
+
+// EpayProvider 易支付渠道，查询与退款使用易支付通用的 api.php 接口
+type EpayProvider struct{}
+
+func (p *EpayProvider) Name() string {
+	return PaymentProviderEpay
+}
+
+func (p *EpayProvider) Enabled() bool {
+	return setting.PayAddress != "" && setting.EpayId != "" && setting.EpayKey != ""
+}
+
+func (p *EpayProvider) client() (*epay.Client, error) {
+	if !p.Enabled() {
+		return nil, errors.New("当前管理员未配置支付信息")
+	}
+	return epay.NewClient(&epay.Config{
+		PartnerID: setting.EpayId,
+		Key:       setting.EpayKey,
+	}, setting.PayAddress)
+}
+
+func (p *EpayProvider) CreateOrder(order *PaymentOrder) (*PaymentOrderResult, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	notifyUrl, err := url.Parse(order.NotifyUrl)
+	if err != nil {
+		return nil, err
+	}
+	returnUrl, err := url.Parse(order.ReturnUrl)
+	if err != nil {
+		return nil, err
+	}
+	uri, params, err := client.Purchase(&epay.PurchaseArgs{
+		Type:           order.PaymentMethod,
+		ServiceTradeNo: order.TradeNo,
+		Name:           order.Name,
+		Money:          strconv.FormatFloat(order.Money, 'f', 2, 64),
+		Device:         epay.PC,
+		NotifyUrl:      notifyUrl,
+		ReturnUrl:      returnUrl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentOrderResult{Url: uri, Params: params}, nil
+}
+
+func (p *EpayProvider) VerifyCallback(c *gin.Context) (*PaymentEvent, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	query := c.Request.URL.Query()
+	params := lo.Reduce(lo.Keys(query), func(r map[string]string, t string, i int) map[string]string {
+		r[t] = query.Get(t)
+		return r
+	}, map[string]string{})
+	verifyInfo, err := client.Verify(params)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyInfo.VerifyStatus {
+		return nil, errors.New("易支付回调签名验证失败")
+	}
+	event := &PaymentEvent{
+		TradeNo:    verifyInfo.ServiceTradeNo,
+		ExternalId: verifyInfo.TradeNo,
+	}
+	if verifyInfo.TradeStatus == epay.StatusTradeSuccess {
+		event.Type = PaymentEventPaid
+		event.Money, _ = strconv.ParseFloat(verifyInfo.Money, 64)
+	}
+	return event, nil
+}
+
+// epayApiResponse 易支付 api.php 的返回，不同实现中数字字段可能是字符串
+type epayApiResponse struct {
+	Code       json.Number `json:"code"`
+	Msg        string      `json:"msg"`
+	TradeNo    string      `json:"trade_no"`
+	OutTradeNo string      `json:"out_trade_no"`
+	Money      string      `json:"money"`
+	Status     json.Number `json:"status"`
+}
+
+func (p *EpayProvider) api(act string, params url.Values) (*epayApiResponse, error) {
+	params.Set("act", act)
+	params.Set("pid", setting.EpayId)
+	params.Set("key", setting.EpayKey)
+	resp, err := paymentHttpClient().PostForm(strings.TrimSuffix(setting.PayAddress, "/")+"/api.php", params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result epayApiResponse
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid epay response: %s", string(body))
+	}
+	return &result, nil
+}
+
+func (p *EpayProvider) QueryOrder(topUp *model.TopUp) (*PaymentEvent, error) {
+	if !p.Enabled() {
+		return nil, errors.New("当前管理员未配置支付信息")
+	}
+	result, err := p.api("order", url.Values{"out_trade_no": {topUp.TradeNo}})
+	if err != nil {
+		return nil, err
+	}
+	if result.Code.String() != "1" {
+		return nil, fmt.Errorf("epay query failed: %s", result.Msg)
+	}
+	event := &PaymentEvent{
+		TradeNo:    topUp.TradeNo,
+		ExternalId: result.TradeNo,
+	}
+	if result.Status.String() == "1" {
+		event.Type = PaymentEventPaid
+		event.Money, _ = strconv.ParseFloat(result.Money, 64)
+	}
+	return event, nil
+}
+
+// Refund 易支付没有退款回调，退款接口成功即返回退款事件
+func (p *EpayProvider) Refund(topUp *model.TopUp, money float64) (*PaymentEvent, error) {
+	if !p.Enabled() {
+		return nil, errors.New("当前管理员未配置支付信息")
+	}
+	refunded, err := model.GetTopUpRefundedMoney(topUp.Id)
+	if err != nil {
+		return nil, err
+	}
+	result, err := p.api("refund", url.Values{
+		"out_trade_no": {topUp.TradeNo},
+		"money":        {strconv.FormatFloat(money, 'f', 2, 64)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Code.String() != "1" {
+		return nil, fmt.Errorf("易支付退款失败：%s", result.Msg)
+	}
+	total := refunded + money
+	return &PaymentEvent{
+		Type:       PaymentEventRefunded,
+		TradeNo:    topUp.TradeNo,
+		ExternalId: topUp.PaymentIntent,
+		Money:      total,
+		RefundId:   fmt.Sprintf("%s:refund:%.2f", topUp.TradeNo, total),
+	}, nil
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"time"
+)
+
+// InvoiceParty 账单的开票方或收票方
+type InvoiceParty struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	TaxId   string `json:"tax_id,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// InvoiceAmount 额度及其对应的金额（美元）
+type InvoiceAmount struct {
+	Quota  int     `json:"quota"`
+	Amount float64 `json:"amount"`
+}
+
+type InvoiceTopUpItem struct {
+	TradeNo       string  `json:"trade_no"`
+	Date          string  `json:"date"`
+	PaymentMethod string  `json:"payment_method"`
+	Money         float64 `json:"money"`
+	Quota         int     `json:"quota"`
+	Status        string  `json:"status"`
+}
+
+// InvoiceUsageItem 单个模型的应付消费，Quota 已扣除套餐抵扣的部分 SubscriptionQuota
+type InvoiceUsageItem struct {
+	model.InvoiceUsage
+	SubscriptionQuota int64   `json:"subscription_quota,omitempty"`
+	Amount            float64 `json:"amount"`
+}
+
+// InvoiceStatement 账单快照，保存在 Invoice.Content 中，JSON 与 PDF 下载均由它生成。
+// 期初余额 + 充值 + 兑换 - 消费 + 其他调整 = 期末余额，其他调整包括管理员修改额度、套餐费用与退款等
+type InvoiceStatement struct {
+	Number         string             `json:"number"`
+	IssueDate      string             `json:"issue_date"`
+	PeriodStart    string             `json:"period_start"`
+	PeriodEnd      string             `json:"period_end"`
+	Currency       string             `json:"currency"`
+	Seller         InvoiceParty       `json:"seller"`
+	Customer       InvoiceParty       `json:"customer"`
+	OpeningBalance InvoiceAmount      `json:"opening_balance"`
+	TopUps         InvoiceAmount      `json:"top_ups"`
+	Redemptions    InvoiceAmount      `json:"redemptions"`
+	Usage          InvoiceAmount      `json:"usage"`
+	Adjustments    InvoiceAmount      `json:"adjustments"`
+	ClosingBalance InvoiceAmount      `json:"closing_balance"`
+	UsageItems     []InvoiceUsageItem `json:"usage_items"`
+	TopUpItems     []InvoiceTopUpItem `json:"top_up_items"`
+	Subtotal       float64            `json:"subtotal"`
+	TaxName        string             `json:"tax_name"`
+	TaxRate        float64            `json:"tax_rate"`
+	Tax            float64            `json:"tax"`
+	Total          float64            `json:"total"`
+	Footer         string             `json:"footer,omitempty"`
+}
+
+func roundMoney(money float64) float64 {
+	return math.Round(money*100) / 100
+}
+
+func newInvoiceAmount(quota int) InvoiceAmount {
+	return InvoiceAmount{Quota: quota, Amount: roundMoney(float64(quota) / common.QuotaPerUnit)}
+}
+
+func sumTopUpQuota(topUps []*model.TopUp) int {
+	quota := 0
+	for _, topUp := range topUps {
+		quota += topUp.TopUpQuota()
+	}
+	return quota
+}
+
+// BuildInvoice 生成用户在 [start, end) 内的账单，返回的账单尚未分配编号
+func BuildInvoice(user *model.User, start time.Time, end time.Time) (*model.Invoice, *InvoiceStatement, error) {
+	settings := system_setting.GetInvoiceSettings()
+	usage, err := model.GetInvoiceUsage(user.Id, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+	topUps, err := model.GetUserSuccessTopUps(user.Id, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+	redeemed, err := model.SumUserRedeemedQuota(user.Id, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+	subscriptionQuota, err := model.GetInvoiceSubscriptionQuota(user.Id, start.Unix(), end.Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+	// 期初与期末余额取自额度账本，包含管理员调整、退款、套餐费用等所有余额变动
+	opening, err := model.GetQuotaLedgerBalanceAt(user.Id, start.Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+	closing, err := model.GetQuotaLedgerBalanceAt(user.Id, end.Unix())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statement := &InvoiceStatement{
+		PeriodStart: start.Format("2006-01-02"),
+		PeriodEnd:   end.Add(-time.Second).Format("2006-01-02"),
+		Currency:    "USD",
+		Seller: InvoiceParty{
+			Name:    settings.CompanyName,
+			Address: settings.CompanyAddress,
+			TaxId:   settings.CompanyTaxId,
+			Email:   settings.CompanyEmail,
+		},
+		Customer: InvoiceParty{
+			Name:  user.Username,
+			Email: user.Email,
+		},
+		UsageItems: make([]InvoiceUsageItem, 0, len(usage)),
+		TopUpItems: make([]InvoiceTopUpItem, 0, len(topUps)),
+		TaxName:    settings.TaxName,
+		TaxRate:    settings.TaxRate,
+		Footer:     settings.Footer,
+	}
+	if user.DisplayName != "" {
+		statement.Customer.Name = user.DisplayName
+	}
+	// 套餐抵扣的消费已在订阅时支付，不计入应付消费与税额
+	usageQuota := 0
+	for _, item := range usage {
+		covered := int64(subscriptionQuota[item.ModelName])
+		item.Quota -= covered
+		usageQuota += int(item.Quota)
+		statement.UsageItems = append(statement.UsageItems, InvoiceUsageItem{
+			InvoiceUsage:      *item,
+			SubscriptionQuota: covered,
+			Amount:            roundMoney(float64(item.Quota) / common.QuotaPerUnit),
+		})
+	}
+	for _, topUp := range topUps {
+		statement.TopUpItems = append(statement.TopUpItems, InvoiceTopUpItem{
+			TradeNo:       topUp.TradeNo,
+			Date:          time.Unix(topUp.CreateTime, 0).In(start.Location()).Format("2006-01-02"),
+			PaymentMethod: topUp.PaymentMethod,
+			Money:         topUp.Money,
+			Quota:         topUp.TopUpQuota(),
+			Status:        topUp.Status,
+		})
+	}
+	topUpQuota := sumTopUpQuota(topUps)
+
+	adjustment := closing - opening - topUpQuota - redeemed + usageQuota
+
+	statement.OpeningBalance = newInvoiceAmount(opening)
+	statement.TopUps = newInvoiceAmount(topUpQuota)
+	statement.Redemptions = newInvoiceAmount(redeemed)
+	statement.Usage = newInvoiceAmount(usageQuota)
+	statement.Adjustments = newInvoiceAmount(adjustment)
+	statement.ClosingBalance = newInvoiceAmount(closing)
+	statement.Subtotal = statement.Usage.Amount
+	statement.Tax = roundMoney(statement.Subtotal * statement.TaxRate / 100)
+	statement.Total = roundMoney(statement.Subtotal + statement.Tax)
+
+	invoice := &model.Invoice{
+		UserId:          user.Id,
+		Username:        user.Username,
+		PeriodStart:     start.Unix(),
+		PeriodEnd:       end.Unix(),
+		OpeningBalance:  opening,
+		TopUpQuota:      topUpQuota,
+		RedemptionQuota: redeemed,
+		UsageQuota:      usageQuota,
+		AdjustmentQuota: adjustment,
+		ClosingBalance:  closing,
+		Subtotal:        statement.Subtotal,
+		TaxRate:         statement.TaxRate,
+		Tax:             statement.Tax,
+		Total:           statement.Total,
+	}
+	return invoice, statement, nil
+}
+
+// GenerateInvoice 生成并保存用户在 [start, end) 内的账单，账单编号在保存时分配
+func GenerateInvoice(userId int, start time.Time, end time.Time) (*model.Invoice, error) {
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	invoice, statement, err := BuildInvoice(user, start, end)
+	if err != nil {
+		return nil, err
+	}
+	settings := system_setting.GetInvoiceSettings()
+	err = model.InsertInvoice(invoice, settings.NumberStart, func(invoice *model.Invoice) error {
+		invoice.Number = fmt.Sprintf("%s%s%0*d", settings.NumberPrefix, start.Format("200601"), settings.NumberDigits, invoice.Sequence)
+		statement.Number = invoice.Number
+		statement.IssueDate = time.Unix(invoice.CreatedTime, 0).Format("2006-01-02")
+		content, err := json.Marshal(statement)
+		if err != nil {
+			return err
+		}
+		invoice.Content = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// GetInvoiceStatement 解析账单快照
+func GetInvoiceStatement(invoice *model.Invoice) (*InvoiceStatement, error) {
+	var statement InvoiceStatement
+	if err := json.Unmarshal([]byte(invoice.Content), &statement); err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// InvoiceMonthRange 返回 month（格式 2006-01）在账单时区对应的账单期
+func InvoiceMonthRange(month string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, system_setting.GetInvoiceSettings().Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// StartInvoiceJob 每月 1 日在设置的时间生成上月账单，只应在主节点调用
+func StartInvoiceJob() {
+	lastRunDate := ""
+	for {
+		settings := system_setting.GetInvoiceSettings()
+		now := time.Now().In(settings.Location())
+		today := now.Format("2006-01-02")
+		if settings.Enabled && now.Day() == 1 && now.Hour() == settings.RunHour && lastRunDate != today {
+			lastRunDate = today
+			GenerateMonthlyInvoices(now)
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
+
+// GenerateMonthlyInvoices 为上月有消费或充值的用户生成账单，账单期按账单时区划分，已生成的账单会被跳过
+func GenerateMonthlyInvoices(now time.Time) {
+	start, end := spendReportRange(SpendReportMonthly, now.In(system_setting.GetInvoiceSettings().Location()))
+	userIds, err := model.GetInvoiceUserIds(start.Unix(), end.Unix())
+	if err != nil {
+		common.SysError("failed to get invoice users: " + err.Error())
+		return
+	}
+	generated := 0
+	for _, userId := range userIds {
+		_, err = GenerateInvoice(userId, start, end)
+		if errors.Is(err, model.ErrInvoiceExists) {
+			continue
+		}
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to generate invoice for user %d: %s", userId, err.Error()))
+			continue
+		}
+		generated++
+	}
+	if generated > 0 {
+		common.SysLog(fmt.Sprintf("generated %d invoices for %s", generated, start.Format("2006-01")))
+	}
+}
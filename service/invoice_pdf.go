@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 账单 PDF 使用 PDF 标准字体，不需要嵌入字体文件：ASCII 文本使用 Helvetica，
+// 包含中文等其他字符的文本使用 Adobe 亚洲字体包的 STSong-Light
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func newPdfDocument() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.newPage()
+	return doc
+}
+
+func (doc *pdfDocument) newPage() {
+	doc.pages = append(doc.pages, &bytes.Buffer{})
+	doc.y = pdfPageHeight - pdfMargin
+}
+
+func (doc *pdfDocument) page() *bytes.Buffer {
+	return doc.pages[len(doc.pages)-1]
+}
+
+// advance 向下移动 height，剩余空间不足时换页
+func (doc *pdfDocument) advance(height float64) {
+	doc.y -= height
+	if doc.y < pdfMargin {
+		doc.newPage()
+		doc.y -= height
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func pdfEscape(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", " ", "\n", " ")
+	return replacer.Replace(s)
+}
+
+// pdfTextWidth 估算 Helvetica 文本的宽度，用于数字列右对齐
+func pdfTextWidth(s string, size float64) float64 {
+	width := 0.0
+	for _, r := range s {
+		switch {
+		case r == '.' || r == ',' || r == ' ':
+			width += 278
+		case r == '-':
+			width += 333
+		case r == '%':
+			width += 889
+		default:
+			width += 556
+		}
+	}
+	return width * size / 1000
+}
+
+func (doc *pdfDocument) text(x float64, size float64, bold bool, s string) {
+	if s == "" {
+		return
+	}
+	buf := doc.page()
+	if isASCII(s) {
+		font := "F1"
+		if bold {
+			font = "F3"
+		}
+		fmt.Fprintf(buf, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, doc.y, pdfEscape(s))
+		return
+	}
+	var hex strings.Builder
+	for _, unit := range utf16.Encode([]rune(strings.ReplaceAll(s, "\n", " "))) {
+		fmt.Fprintf(&hex, "%04X", unit)
+	}
+	fmt.Fprintf(buf, "BT /F2 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, doc.y, hex.String())
+}
+
+func (doc *pdfDocument) textRight(right float64, size float64, bold bool, s string) {
+	doc.text(right-pdfTextWidth(s, size), size, bold, s)
+}
+
+func (doc *pdfDocument) rule() {
+	fmt.Fprintf(doc.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, doc.y-4, pdfPageWidth-pdfMargin, doc.y-4)
+}
+
+// bytes 输出 PDF 文件，对象依次为目录、页面树、字体，之后每页一个页面对象与一个内容流
+func (doc *pdfDocument) bytes() []byte {
+	objects := []string{
+		"", // 目录，页面对象编号确定后填充
+		"", // 页面树
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UTF16-H /DescendantFonts [6 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor 7 0 R >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	}
+	kids := make([]string, 0, len(doc.pages))
+	for _, page := range doc.pages {
+		pageId := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageId))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 5 0 R /F3 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, pageId+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()),
+		)
+	}
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+func formatMoney(money float64) string {
+	return strconv.FormatFloat(money, 'f', 2, 64)
+}
+
+func truncateText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// RenderInvoicePDF 将账单快照渲染为 PDF
+func RenderInvoicePDF(statement *InvoiceStatement) []byte {
+	doc := newPdfDocument()
+	right := pdfPageWidth - pdfMargin
+
+	// 抬头：左侧为开票方，右侧为账单信息
+	top := doc.y
+	doc.text(pdfMargin, 16, true, statement.Seller.Name)
+	for _, line := range []string{statement.Seller.Address, statement.Seller.Email} {
+		if line != "" {
+			doc.advance(13)
+			doc.text(pdfMargin, 9, false, line)
+		}
+	}
+	if statement.Seller.TaxId != "" {
+		doc.advance(13)
+		doc.text(pdfMargin, 9, false, "Tax ID: "+statement.Seller.TaxId)
+	}
+	sellerBottom := doc.y
+	doc.y = top
+	doc.textRight(right, 16, true, "STATEMENT")
+	for _, line := range []string{
+		"No. " + statement.Number,
+		"Issue date: " + statement.IssueDate,
+		"Period: " + statement.PeriodStart + " - " + statement.PeriodEnd,
+	} {
+		doc.advance(13)
+		doc.textRight(right, 9, false, line)
+	}
+	doc.y = min(doc.y, sellerBottom)
+
+	doc.advance(30)
+	doc.text(pdfMargin, 10, true, "Bill to")
+	doc.advance(14)
+	doc.text(pdfMargin, 9, false, statement.Customer.Name)
+	if statement.Customer.Email != "" {
+		doc.advance(13)
+		doc.text(pdfMargin, 9, false, statement.Customer.Email)
+	}
+
+	// 余额变动汇总
+	doc.advance(28)
+	doc.text(pdfMargin, 11, true, "Account summary")
+	doc.textRight(right-110, 9, true, "Quota")
+	doc.textRight(right, 9, true, "Amount ("+statement.Currency+")")
+	doc.rule()
+	for _, row := range []struct {
+		name   string
+		amount InvoiceAmount
+		bold   bool
+	}{
+		{"Opening balance", statement.OpeningBalance, false},
+		{"Top-ups", statement.TopUps, false},
+		{"Redemptions", statement.Redemptions, false},
+		{"Usage", InvoiceAmount{Quota: -statement.Usage.Quota, Amount: -statement.Usage.Amount}, false},
+		{"Other adjustments", statement.Adjustments, false},
+		{"Closing balance", statement.ClosingBalance, true},
+	} {
+		doc.advance(15)
+		doc.text(pdfMargin, 9, row.bold, row.name)
+		doc.textRight(right-110, 9, row.bold, strconv.Itoa(row.amount.Quota))
+		doc.textRight(right, 9, row.bold, formatMoney(row.amount.Amount))
+	}
+
+	// 按模型的消费明细
+	doc.advance(28)
+	doc.text(pdfMargin, 11, true, "Usage by model")
+	doc.textRight(right-250, 9, true, "Requests")
+	doc.textRight(right-180, 9, true, "Prompt")
+	doc.textRight(right-110, 9, true, "Completion")
+	doc.textRight(right, 9, true, "Amount ("+statement.Currency+")")
+	doc.rule()
+	if len(statement.UsageItems) == 0 {
+		doc.advance(15)
+		doc.text(pdfMargin, 9, false, "No usage in this period")
+	}
+	for _, item := range statement.UsageItems {
+		doc.advance(15)
+		doc.text(pdfMargin, 9, false, truncateText(item.ModelName, 36))
+		doc.textRight(right-250, 9, false, strconv.FormatInt(item.Requests, 10))
+		doc.textRight(right-180, 9, false, strconv.FormatInt(item.PromptTokens, 10))
+		doc.textRight(right-110, 9, false, strconv.FormatInt(item.CompletionTokens, 10))
+		doc.textRight(right, 9, false, formatMoney(item.Amount))
+	}
+
+	// 充值明细
+	if len(statement.TopUpItems) > 0 {
+		doc.advance(28)
+		doc.text(pdfMargin, 11, true, "Top-ups")
+		doc.textRight(right-180, 9, true, "Method")
+		doc.textRight(right-110, 9, true, "Paid")
+		doc.textRight(right, 9, true, "Quota")
+		doc.rule()
+		for _, item := range statement.TopUpItems {
+			doc.advance(15)
+			doc.text(pdfMargin, 9, false, item.Date+"  "+truncateText(item.TradeNo, 32))
+			doc.textRight(right-180, 9, false, item.PaymentMethod)
+			doc.textRight(right-110, 9, false, formatMoney(item.Money))
+			doc.textRight(right, 9, false, strconv.Itoa(item.Quota))
+		}
+	}
+
+	// 应付合计
+	doc.advance(28)
+	doc.text(right-220, 9, false, "Subtotal")
+	doc.textRight(right, 9, false, formatMoney(statement.Subtotal))
+	doc.advance(15)
+	doc.text(right-220, 9, false, fmt.Sprintf("%s (%s%%)", statement.TaxName, strconv.FormatFloat(statement.TaxRate, 'f', -1, 64)))
+	doc.textRight(right, 9, false, formatMoney(statement.Tax))
+	doc.advance(17)
+	doc.text(right-220, 10, true, "Total ("+statement.Currency+")")
+	doc.textRight(right, 10, true, formatMoney(statement.Total))
+
+	if statement.Footer != "" {
+		doc.advance(40)
+		for _, line := range strings.Split(statement.Footer, "\n") {
+			doc.text(pdfMargin, 8, false, line)
+			doc.advance(11)
+		}
+	}
+	return doc.bytes()
+}
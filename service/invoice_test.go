@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/model"
+	"testing"
+	"time"
+)
+
+func createTestConsumeLog(t *testing.T, userId int, modelName string, quota int, createdAt int64, other string) {
+	t.Helper()
+	log := &model.Log{UserId: userId, Type: model.LogTypeConsume, ModelName: modelName, Quota: quota, CreatedAt: createdAt, Other: other}
+	if err := model.LOG_DB.Create(log).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateInvoiceBalancesFromLedger(t *testing.T) {
+	setupTestDB(t)
+	start, end, err := InvoiceMonthRange("2026-08")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, "invoiced", 0)
+	mid := start.Add(48 * time.Hour).Unix()
+	createTestConsumeLog(t, user.Id, "gpt-4o", 250000, mid, "")
+	createTestConsumeLog(t, user.Id, "gpt-4o", 250000, mid, "")
+	createTestConsumeLog(t, user.Id, "claude", 100000, mid, "")
+	// 订阅套餐抵扣的消费不计入应付消费
+	createTestConsumeLog(t, user.Id, "claude", 100000, mid, `{"subscription_quota":100000}`)
+	createTestConsumeLog(t, user.Id, "claude", 100000, end.Unix()+10, "")
+	topUp := &model.TopUp{UserId: user.Id, Amount: 2, Money: 14, TradeNo: "T1", CreateTime: mid, Status: "success", PaymentMethod: "alipay"}
+	if err := model.DB.Create(topUp).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeOpening, 700000, start.Unix()-100)
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeTopUp, 1000000, mid)
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeConsume, -600000, mid)
+	if _, err := model.CreateQuotaLedgerCheckpoints(mid + 1); err != nil {
+		t.Fatal(err)
+	}
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeAdjustment, 50000, mid+3600)
+	insertTestLedgerEntry(t, user.Id, model.QuotaLedgerTypeConsume, -100000, end.Unix()+10)
+
+	invoice, err := GenerateInvoice(user.Id, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.OpeningBalance != 700000 || invoice.ClosingBalance != 1150000 {
+		t.Fatalf("expected balances 700000 -> 1150000, got %d -> %d", invoice.OpeningBalance, invoice.ClosingBalance)
+	}
+	if invoice.TopUpQuota != 2*int(common.QuotaPerUnit) || invoice.UsageQuota != 600000 || invoice.AdjustmentQuota != 50000 {
+		t.Fatalf("unexpected invoice totals: %+v", invoice)
+	}
+	statement, err := GetInvoiceStatement(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range statement.UsageItems {
+		if item.ModelName == "claude" && (item.Quota != 100000 || item.SubscriptionQuota != 100000) {
+			t.Fatalf("expected claude usage 100000 with 100000 covered by subscription, got %+v", item)
+		}
+	}
+	if _, err := GenerateInvoice(user.Id, start, end); !errors.Is(err, model.ErrInvoiceExists) {
+		t.Fatalf("expected duplicated invoice to be rejected, got %v", err)
+	}
+
+	// 下一期的期初余额等于本期的期末余额
+	nextStart, nextEnd, _ := InvoiceMonthRange("2026-09")
+	next, err := GenerateInvoice(user.Id, nextStart, nextEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.OpeningBalance != invoice.ClosingBalance || next.ClosingBalance != 1050000 || next.Sequence != invoice.Sequence+1 {
+		t.Fatalf("unexpected next invoice: %+v", next)
+	}
+}
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
+	"one-api/model"
 	relaycommon "one-api/relay/common"
 	"one-api/relay/helper"
+	"one-api/setting/ratio_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +23,16 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["model_price"] = modelPrice
 	other["user_group_ratio"] = userGroupRatio
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if schedule, ok := common.GetContextKeyType[ratio_setting.PriceScheduleResult](ctx, constant.ContextKeyPriceSchedule); ok {
+		other["schedule_ratio"] = schedule.Ratio
+		other["schedule_rules"] = schedule.Rules
+	}
+	if override, ok := common.GetContextKeyType[*model.PriceOverride](ctx, constant.ContextKeyPriceOverride); ok {
+		other["price_override_id"] = override.Id
+	}
+	if subscriptionQuota := common.GetContextKeyInt(ctx, constant.ContextKeySubscriptionQuota); subscriptionQuota > 0 {
+		other["subscription_quota"] = subscriptionQuota
+	}
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
@@ -72,5 +86,12 @@ func GenerateMjOtherInfo(priceData helper.PerCallPriceData) map[string]interface
 	if priceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = priceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	if priceData.GroupRatioInfo.ScheduleRatio != 1 {
+		other["schedule_ratio"] = priceData.GroupRatioInfo.ScheduleRatio
+	}
+	if priceData.PriceOverrideId != 0 {
+		other["price_override_id"] = priceData.PriceOverrideId
+		other["price_override_ratio"] = priceData.PriceOverrideRatio
+	}
 	return other
 }
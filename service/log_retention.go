@@ -0,0 +1,233 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const logRetentionBatchSize = 1000
+
+type logRetentionRule struct {
+	logType int
+	name    string
+	days    int
+}
+
+var logRetentionLock sync.Mutex
+
+func getLogRetentionRules(settings *system_setting.LogRetentionSettings) []logRetentionRule {
+	return []logRetentionRule{
+		{model.LogTypeConsume, "consume", settings.ConsumeDays},
+		{model.LogTypeError, "error", settings.ErrorDays},
+		{model.LogTypeTopup, "topup", settings.TopupDays},
+		{model.LogTypeManage, "manage", settings.ManageDays},
+		{model.LogTypeSystem, "system", settings.SystemDays},
+	}
+}
+
+// StartLogRetentionJob 每天在设置的时间清理过期日志，只应在主节点调用
+func StartLogRetentionJob() {
+	lastRunDate := ""
+	for {
+		settings := system_setting.GetLogRetentionSettings()
+		now := time.Now()
+		today := now.Format("2006-01-02")
+		if settings.Enabled && now.Hour() == settings.RunHour && lastRunDate != today {
+			lastRunDate = today
+			if _, err := RunLogRetention(context.Background()); err != nil {
+				common.SysError("log retention failed: " + err.Error())
+			}
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
+
+// RunLogRetention 按类型删除过期日志，开启归档时先导出再删除，结果记录为系统日志
+func RunLogRetention(ctx context.Context) (string, error) {
+	if !logRetentionLock.TryLock() {
+		return "", errors.New("日志清理正在进行中")
+	}
+	defer logRetentionLock.Unlock()
+
+	settings := *system_setting.GetLogRetentionSettings()
+	results := make([]string, 0)
+	var runErr error
+	for _, rule := range getLogRetentionRules(&settings) {
+		if rule.days <= 0 {
+			continue
+		}
+		before := time.Now().AddDate(0, 0, -rule.days).Unix()
+		maxId := 0
+		archived := 0
+		if settings.ArchiveEnabled {
+			var location string
+			var err error
+			archived, maxId, location, err = archiveLogs(ctx, &settings, rule, before)
+			if err != nil {
+				runErr = fmt.Errorf("归档 %s 日志失败: %w", rule.name, err)
+				results = append(results, runErr.Error())
+				continue
+			}
+			if archived == 0 {
+				continue
+			}
+			results = append(results, fmt.Sprintf("归档 %s 日志 %d 条至 %s", rule.name, archived, location))
+		}
+		deleted, err := model.DeleteLogsBefore(ctx, rule.logType, before, maxId, 1000)
+		if err != nil {
+			runErr = fmt.Errorf("删除 %s 日志失败: %w", rule.name, err)
+			results = append(results, runErr.Error())
+			continue
+		}
+		if deleted > 0 {
+			results = append(results, fmt.Sprintf("删除 %d 天前的 %s 日志 %d 条", rule.days, rule.name, deleted))
+		}
+	}
+	summary := "日志清理完成"
+	if len(results) > 0 {
+		summary += "：" + strings.Join(results, "；")
+	}
+	model.RecordLog(0, model.LogTypeSystem, summary)
+	common.SysLog(summary)
+	return summary, runErr
+}
+
+// archiveLogs 导出过期日志为 gzip 压缩的 JSONL，返回导出条数与最大日志 ID
+func archiveLogs(ctx context.Context, settings *system_setting.LogRetentionSettings, rule logRetentionRule, before int64) (int, int, string, error) {
+	fileName := fmt.Sprintf("logs-%s-%s-%d.jsonl.gz", rule.name, time.Unix(before, 0).Format("20060102"), time.Now().Unix())
+	dir := settings.ArchiveDir
+	if settings.ArchiveTarget == "s3" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, 0, "", err
+	}
+	tmpPath := filepath.Join(dir, fileName+".tmp")
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer os.Remove(tmpPath)
+
+	count, maxId, err := writeLogArchive(ctx, file, rule.logType, before)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || count == 0 {
+		return 0, 0, "", err
+	}
+
+	if settings.ArchiveTarget == "s3" {
+		location, err := uploadLogArchiveToS3(ctx, settings, tmpPath, fileName)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		return count, maxId, location, nil
+	}
+	finalPath := filepath.Join(dir, fileName)
+	if err = os.Rename(tmpPath, finalPath); err != nil {
+		return 0, 0, "", err
+	}
+	return count, maxId, finalPath, nil
+}
+
+func writeLogArchive(ctx context.Context, file *os.File, logType int, before int64) (int, int, error) {
+	gz := gzip.NewWriter(file)
+	encoder := json.NewEncoder(gz)
+	count, lastId := 0, 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		logs, err := model.GetLogsBefore(logType, before, lastId, logRetentionBatchSize)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, log := range logs {
+			if err = encoder.Encode(log); err != nil {
+				return 0, 0, err
+			}
+			lastId = log.Id
+		}
+		count += len(logs)
+		if len(logs) < logRetentionBatchSize {
+			break
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return 0, 0, err
+	}
+	return count, lastId, nil
+}
+
+func uploadLogArchiveToS3(ctx context.Context, settings *system_setting.LogRetentionSettings, filePath string, fileName string) (string, error) {
+	if settings.S3Bucket == "" || settings.S3AccessKey == "" || settings.S3SecretKey == "" {
+		return "", errors.New("S3 存储桶或密钥未配置")
+	}
+	region := settings.S3Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := strings.TrimRight(settings.S3Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	key := path.Join(strings.Trim(settings.S3Prefix, "/"), fileName)
+	if settings.S3PathStyle {
+		endpointUrl.Path = path.Join(endpointUrl.Path, settings.S3Bucket, key)
+	} else {
+		endpointUrl.Host = settings.S3Bucket + "." + endpointUrl.Host
+		endpointUrl.Path = path.Join(endpointUrl.Path, key)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpointUrl.String(), file)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	credentials := aws.Credentials{AccessKeyID: settings.S3AccessKey, SecretAccessKey: settings.S3SecretKey}
+	if err = v4.NewSigner().SignHTTP(ctx, credentials, req, "UNSIGNED-PAYLOAD", "s3", region, time.Now()); err != nil {
+		return "", err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("S3 返回状态码 %d", resp.StatusCode)
+	}
+	return "s3://" + settings.S3Bucket + "/" + key, nil
+}
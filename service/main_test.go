@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 为每个测试创建独立的内存 SQLite 数据库，迁移订阅、支付、账单与额度账本相关的表
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.DB = db
+	model.LOG_DB = db
+	common.UsingSQLite = true
+	common.RedisEnabled = false
+	err = db.AutoMigrate(
+		&model.User{},
+		&model.Log{},
+		&model.UserSubscription{},
+		&model.TopUp{},
+		&model.PaymentReceipt{},
+		&model.Redemption{},
+		&model.Invoice{},
+		&model.CreditAccount{},
+		&model.CreditStatement{},
+		&model.QuotaLedgerEntry{},
+		&model.QuotaLedgerCheckpoint{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createTestUser(t *testing.T, username string, quota int) *model.User {
+	t.Helper()
+	user := &model.User{Username: username, Password: "12345678", AffCode: username, Group: "default", Quota: quota}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func getTestUserQuota(t *testing.T, userId int) int {
+	t.Helper()
+	var quota int
+	if err := model.DB.Model(&model.User{}).Where("id = ?", userId).Select("quota").Scan(&quota).Error; err != nil {
+		t.Fatal(err)
+	}
+	return quota
+}
+
+func getTestLedgerBalance(t *testing.T, userId int) int {
+	t.Helper()
+	balances, err := model.GetQuotaLedgerBalances([]int{userId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balances[userId]
+}
+
+// insertTestLedgerEntry 直接写入用户账户的一条分录，用于构造指定时间的余额变动
+func insertTestLedgerEntry(t *testing.T, userId int, ledgerType string, amount int, createdTime int64) {
+	t.Helper()
+	entry := &model.QuotaLedgerEntry{
+		Account:     fmt.Sprintf("user:%d", userId),
+		UserId:      userId,
+		Type:        ledgerType,
+		Amount:      amount,
+		CreatedTime: createdTime,
+	}
+	if err := model.DB.Create(entry).Error; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"sync"
+	"time"
+)
+
+const (
+	// orderLockTTL 分布式锁的过期时间，防止持有锁的节点崩溃后订单永远无法处理
+	orderLockTTL = 30 * time.Second
+	// orderLockWait 获取分布式锁的最长等待时间
+	orderLockWait = 10 * time.Second
+)
+
+var errOrderLockTimeout = errors.New("获取订单锁超时")
+
+// tradeNo lock
+var orderLocks sync.Map
+var createLock sync.Mutex
+
+// 当前节点持有的分布式锁的令牌，只在持有本地锁时读写
+var orderLockTokens sync.Map
+
+func orderLockKey(tradeNo string) string {
+	return "order_lock:" + tradeNo
+}
+
+func localOrderLock(tradeNo string) *sync.Mutex {
+	lock, ok := orderLocks.Load(tradeNo)
+	if !ok {
+		createLock.Lock()
+		defer createLock.Unlock()
+		lock, ok = orderLocks.Load(tradeNo)
+		if !ok {
+			lock = new(sync.Mutex)
+			orderLocks.Store(tradeNo, lock)
+		}
+	}
+	return lock.(*sync.Mutex)
+}
+
+// LockOrder 对给定订单号加锁。节点内先获取本地锁，启用 Redis 时再获取分布式锁，
+// 保证多节点部署下同一订单的回调、对账与人工确认不会并发处理
+func LockOrder(tradeNo string) error {
+	lock := localOrderLock(tradeNo)
+	lock.Lock()
+	if !common.RedisEnabled {
+		return nil
+	}
+	token := common.GetRandomString(16)
+	deadline := time.Now().Add(orderLockWait)
+	for {
+		ok, err := common.RedisSetNX(orderLockKey(tradeNo), token, orderLockTTL)
+		if err != nil {
+			lock.Unlock()
+			return err
+		}
+		if ok {
+			orderLockTokens.Store(tradeNo, token)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			lock.Unlock()
+			return errOrderLockTimeout
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// UnlockOrder 释放给定订单号的锁
+func UnlockOrder(tradeNo string) {
+	if token, ok := orderLockTokens.LoadAndDelete(tradeNo); ok {
+		if err := common.RedisDelIfEqual(orderLockKey(tradeNo), token.(string)); err != nil {
+			common.SysError("failed to release order lock: " + err.Error())
+		}
+	}
+	lock, ok := orderLocks.Load(tradeNo)
+	if ok {
+		lock.(*sync.Mutex).Unlock()
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"one-api/common"
+	"one-api/setting/system_setting"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const payloadRedactedText = "[REDACTED]"
+
+// ShouldCapturePayload 判断请求是否命中采集范围，命中后再按采样率抽样
+func ShouldCapturePayload(userId int, tokenId int, group string) bool {
+	settings := system_setting.GetPayloadCaptureSettings()
+	if !settings.Enabled || !common.CryptoSecretConfigured {
+		return false
+	}
+	matched := slices.Contains(settings.UserIds, userId) ||
+		(tokenId != 0 && slices.Contains(settings.TokenIds, tokenId)) ||
+		(group != "" && slices.Contains(settings.Groups, group))
+	if !matched {
+		return false
+	}
+	return settings.SampleRate >= 1 || rand.Float64() < settings.SampleRate
+}
+
+var (
+	redactPatternsLock sync.Mutex
+	redactPatternsKey  string
+	redactPatterns     []*regexp.Regexp
+)
+
+// getRedactPatterns 缓存编译后的正则，配置变化时重新编译，无效的正则会被忽略
+func getRedactPatterns(patterns []string) []*regexp.Regexp {
+	key := strings.Join(patterns, "\x00")
+	redactPatternsLock.Lock()
+	defer redactPatternsLock.Unlock()
+	if key == redactPatternsKey && redactPatterns != nil {
+		return redactPatterns
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			common.SysError("invalid payload redact pattern " + pattern + ": " + err.Error())
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	redactPatternsKey = key
+	redactPatterns = compiled
+	return compiled
+}
+
+// RedactPayload 先按 JSON 路径脱敏，再按正则脱敏
+func RedactPayload(body string) string {
+	settings := system_setting.GetPayloadCaptureSettings()
+	if len(settings.RedactJSONPaths) > 0 {
+		var data any
+		if err := json.Unmarshal([]byte(body), &data); err == nil {
+			for _, path := range settings.RedactJSONPaths {
+				if path = strings.TrimSpace(path); path != "" {
+					data = redactJSONPath(data, strings.Split(path, "."))
+				}
+			}
+			if redacted, err := json.Marshal(data); err == nil {
+				body = string(redacted)
+			}
+		}
+	}
+	for _, re := range getRedactPatterns(settings.RedactPatterns) {
+		body = re.ReplaceAllString(body, payloadRedactedText)
+	}
+	return body
+}
+
+func redactJSONPath(data any, path []string) any {
+	if len(path) == 0 {
+		return payloadRedactedText
+	}
+	key, rest := path[0], path[1:]
+	switch value := data.(type) {
+	case map[string]any:
+		if key == "*" {
+			for k, v := range value {
+				value[k] = redactJSONPath(v, rest)
+			}
+		} else if v, ok := value[key]; ok {
+			value[key] = redactJSONPath(v, rest)
+		}
+	case []any:
+		for i, v := range value {
+			if key == "*" || key == strconv.Itoa(i) {
+				value[i] = redactJSONPath(v, rest)
+			}
+		}
+	}
+	return data
+}
+
+// AssembleStreamResponse 将 SSE 响应拼接为完整文本，兼容 OpenAI、Claude 与 Gemini 的流式格式，
+// 无法识别时返回原始内容
+func AssembleStreamResponse(raw []byte) string {
+	var text strings.Builder
+	recognized := false
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 64*1024), len(raw)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Text  string `json:"text"`
+				Delta struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+			Candidates []struct {
+				Content struct {
+					Parts []struct {
+						Text string `json:"text"`
+					} `json:"parts"`
+				} `json:"content"`
+			} `json:"candidates"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			recognized = true
+			text.WriteString(choice.Delta.ReasoningContent)
+			text.WriteString(choice.Delta.Content)
+			text.WriteString(choice.Text)
+		}
+		if chunk.Delta.Text != "" {
+			recognized = true
+			text.WriteString(chunk.Delta.Text)
+		}
+		for _, candidate := range chunk.Candidates {
+			recognized = true
+			for _, part := range candidate.Content.Parts {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+	if !recognized {
+		return string(raw)
+	}
+	return text.String()
+}
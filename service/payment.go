@@ -0,0 +1,281 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PaymentProviderEpay   = "epay"
+	PaymentProviderStripe = "stripe"
+)
+
+// 支付渠道回调或查询得到的事件类型，为空表示无需处理（如未支付、无关事件）
+const (
+	PaymentEventPaid     = "paid"
+	PaymentEventRefunded = "refunded"
+	PaymentEventReceipt  = "receipt"
+	// PaymentEventExpired 支付渠道报告订单已关闭或过期，不会再被支付
+	PaymentEventExpired = "expired"
+)
+
+var ErrPaymentOrderNotFound = errors.New("订单不存在")
+
+// PaymentOrder 创建支付订单所需的信息
+type PaymentOrder struct {
+	TradeNo       string
+	Name          string
+	Money         float64
+	PaymentMethod string
+	Email         string
+	NotifyUrl     string
+	ReturnUrl     string
+	CancelUrl     string
+}
+
+// PaymentOrderResult 创建支付订单的结果，Params 不为空时需要以表单 POST 到 Url，否则直接跳转 Url
+type PaymentOrderResult struct {
+	Url    string
+	Params map[string]string
+	// ExternalId 第三方的订单号（如 Stripe 的 checkout session id），用于之后查询订单状态
+	ExternalId string
+}
+
+// PaymentEvent 支付渠道回调、查询或退款得到的结果
+type PaymentEvent struct {
+	Type    string
+	TradeNo string
+	// ExternalId 第三方支付单号
+	ExternalId string
+	// Money 支付金额；退款事件为累计退款金额
+	Money    float64
+	Currency string
+	// RefundId 退款凭证的唯一标识，同一次退款的重复通知应当相同
+	RefundId   string
+	ReceiptUrl string
+}
+
+// PaymentProvider 第三方支付渠道
+type PaymentProvider interface {
+	Name() string
+	Enabled() bool
+	// CreateOrder 创建支付订单，返回支付跳转信息
+	CreateOrder(order *PaymentOrder) (*PaymentOrderResult, error)
+	// VerifyCallback 校验并解析支付回调，签名无效时返回错误
+	VerifyCallback(c *gin.Context) (*PaymentEvent, error)
+	// QueryOrder 主动查询订单的支付状态，用于对账未收到回调的订单
+	QueryOrder(topUp *model.TopUp) (*PaymentEvent, error)
+	// Refund 发起退款。渠道同步返回退款结果时返回退款事件，通过回调通知退款结果的渠道返回 nil
+	Refund(topUp *model.TopUp, money float64) (*PaymentEvent, error)
+}
+
+// GetPaymentProvider 根据订单的支付方式返回支付渠道，Stripe 以外的支付方式均通过易支付处理
+func GetPaymentProvider(paymentMethod string) PaymentProvider {
+	if paymentMethod == PaymentProviderStripe {
+		return &StripeProvider{}
+	}
+	return &EpayProvider{}
+}
+
+func paymentHttpClient() *http.Client {
+	client := GetHttpClient()
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// CompletePaymentOrder 处理支付成功事件，为订单入账并记录支付凭证。
+// 回调、对账与人工确认都可能处理同一订单，因此在订单锁内完成，已处理的订单直接返回
+func CompletePaymentOrder(provider PaymentProvider, event *PaymentEvent) (int, error) {
+	if err := LockOrder(event.TradeNo); err != nil {
+		return 0, err
+	}
+	defer UnlockOrder(event.TradeNo)
+	topUp := model.GetTopUpByTradeNo(event.TradeNo)
+	if topUp == nil {
+		return 0, ErrPaymentOrderNotFound
+	}
+	// 对账标记为过期的订单仍可能被延迟支付，收到支付成功事件时照常入账
+	if topUp.Status != "pending" && topUp.Status != "expired" {
+		return 0, nil
+	}
+	if event.Money > 0 && math.Abs(event.Money-topUp.Money) > 0.01 {
+		return 0, fmt.Errorf("订单 %s 的支付金额 %.2f 与订单金额 %.2f 不一致", topUp.TradeNo, event.Money, topUp.Money)
+	}
+	receipt := &model.PaymentReceipt{
+		Provider:   provider.Name(),
+		ExternalId: event.ExternalId,
+		Money:      topUp.Money,
+		Currency:   event.Currency,
+		ReceiptUrl: event.ReceiptUrl,
+	}
+	if receipt.ExternalId == "" {
+		receipt.ExternalId = provider.Name() + ":" + topUp.TradeNo
+	}
+	topUp, quota, err := model.CompleteTopUpWithReceipt(topUp.TradeNo, receipt)
+	if errors.Is(err, model.ErrPaymentReceiptDuplicated) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	money := strconv.FormatFloat(topUp.Money, 'f', 2, 64)
+	if receipt.Currency != "" {
+		money += " " + strings.ToUpper(receipt.Currency)
+	}
+	model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%s，支付渠道：%s", common.LogQuota(quota), money, provider.Name()))
+	return quota, nil
+}
+
+// ApplyPaymentRefund 处理退款事件，从用户余额中扣回对应额度，重复的退款事件不会重复扣除
+func ApplyPaymentRefund(provider PaymentProvider, event *PaymentEvent) error {
+	var topUp *model.TopUp
+	if event.ExternalId != "" {
+		topUp = model.GetTopUpByPaymentIntent(event.ExternalId)
+	}
+	if topUp == nil && event.TradeNo != "" {
+		topUp = model.GetTopUpByTradeNo(event.TradeNo)
+	}
+	if topUp == nil {
+		return ErrPaymentOrderNotFound
+	}
+	if err := LockOrder(topUp.TradeNo); err != nil {
+		return err
+	}
+	defer UnlockOrder(topUp.TradeNo)
+	return applyPaymentRefund(provider, topUp, event)
+}
+
+// applyPaymentRefund 在订单锁内扣回退款对应的额度
+func applyPaymentRefund(provider PaymentProvider, topUp *model.TopUp, event *PaymentEvent) error {
+	receipt := &model.PaymentReceipt{
+		Provider:   provider.Name(),
+		ExternalId: event.RefundId,
+		Currency:   event.Currency,
+		ReceiptUrl: event.ReceiptUrl,
+	}
+	topUp, quota, err := model.RefundTopUp(topUp, receipt, event.Money)
+	if errors.Is(err, model.ErrPaymentReceiptDuplicated) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("充值订单 %s 退款 %.2f %s，扣除额度: %v", topUp.TradeNo, receipt.Money, strings.ToUpper(receipt.Currency), common.LogQuota(quota)))
+	return nil
+}
+
+// RefundPaymentOrder 通过支付渠道为充值订单退款。可退金额的检查与发起退款在同一订单锁内完成，
+// 避免并发的退款请求都通过检查；同步返回退款结果的渠道在锁内扣回额度，其余渠道在收到回调后扣回
+func RefundPaymentOrder(tradeNo string, money float64) (*model.TopUp, error) {
+	if err := LockOrder(tradeNo); err != nil {
+		return nil, err
+	}
+	defer UnlockOrder(tradeNo)
+	topUp := model.GetTopUpByTradeNo(tradeNo)
+	if topUp == nil {
+		return nil, ErrPaymentOrderNotFound
+	}
+	if topUp.Status != "success" {
+		return nil, errors.New("订单状态不是已支付")
+	}
+	refunded, err := model.GetTopUpRefundedMoney(topUp.Id)
+	if err != nil {
+		return nil, err
+	}
+	if refunded+money > topUp.Money+0.005 {
+		return nil, fmt.Errorf("退款金额超过订单可退金额 %.2f", topUp.Money-refunded)
+	}
+	provider := GetPaymentProvider(topUp.PaymentMethod)
+	event, err := provider.Refund(topUp, money)
+	if err != nil {
+		return nil, err
+	}
+	if event != nil {
+		if err := applyPaymentRefund(provider, topUp, event); err != nil {
+			return nil, fmt.Errorf("退款成功但扣回额度失败：%w", err)
+		}
+	}
+	return topUp, nil
+}
+
+const (
+	// 只对账创建时间超过 paymentReconcileMinAge 的待支付订单，太新的订单可能还在支付中；
+	// 超过 paymentReconcileMaxAge 仍未支付的订单视为已放弃，标记为过期
+	paymentReconcileMinAge = 5 * time.Minute
+	paymentReconcileMaxAge = 72 * time.Hour
+	paymentReconcileBatch  = 100
+)
+
+// ReconcilePendingTopUps 主动查询未收到回调的待支付订单，已支付的订单补充入账，
+// 支付渠道报告已过期或长时间未支付的订单标记为过期，返回补入账的订单数
+func ReconcilePendingTopUps(now time.Time) int {
+	createdBefore := now.Add(-paymentReconcileMinAge).Unix()
+	abandonedBefore := now.Add(-paymentReconcileMaxAge).Unix()
+	completed := 0
+	lastId := 0
+	for {
+		topUps, err := model.GetPendingTopUps(createdBefore, lastId, paymentReconcileBatch)
+		if err != nil {
+			common.SysError("failed to get pending top-ups: " + err.Error())
+			return completed
+		}
+		if len(topUps) == 0 {
+			return completed
+		}
+		lastId = topUps[len(topUps)-1].Id
+		for _, topUp := range topUps {
+			if reconcilePendingTopUp(topUp, abandonedBefore) {
+				completed++
+			}
+		}
+	}
+}
+
+// reconcilePendingTopUp 对账单个待支付订单，返回是否补充入账
+func reconcilePendingTopUp(topUp *model.TopUp, abandonedBefore int64) bool {
+	provider := GetPaymentProvider(topUp.PaymentMethod)
+	if !provider.Enabled() {
+		return false
+	}
+	event, err := provider.QueryOrder(topUp)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to query %s order %s: %s", provider.Name(), topUp.TradeNo, err.Error()))
+		return false
+	}
+	if event != nil && event.Type == PaymentEventPaid {
+		quota, err := CompletePaymentOrder(provider, event)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to complete %s order %s: %s", provider.Name(), topUp.TradeNo, err.Error()))
+			return false
+		}
+		if quota > 0 {
+			common.SysLog(fmt.Sprintf("reconciled %s order %s without callback", provider.Name(), topUp.TradeNo))
+		}
+		return quota > 0
+	}
+	if (event != nil && event.Type == PaymentEventExpired) || topUp.CreateTime < abandonedBefore {
+		if err := model.ExpireTopUp(topUp.Id); err != nil {
+			common.SysError(fmt.Sprintf("failed to expire %s order %s: %s", provider.Name(), topUp.TradeNo, err.Error()))
+		}
+	}
+	return false
+}
+
+// StartPaymentReconcileJob 定期对账待支付订单，只应在主节点运行
+func StartPaymentReconcileJob() {
+	for {
+		time.Sleep(5 * time.Minute)
+		ReconcilePendingTopUps(time.Now())
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createTestTopUp(t *testing.T, userId int, tradeNo string, paymentIntent string, createTime time.Time) *model.TopUp {
+	t.Helper()
+	topUp := &model.TopUp{
+		UserId:        userId,
+		Amount:        2,
+		Money:         10,
+		TradeNo:       tradeNo,
+		PaymentIntent: paymentIntent,
+		PaymentMethod: PaymentProviderStripe,
+		CreateTime:    createTime.Unix(),
+		Status:        "pending",
+	}
+	if err := model.DB.Create(topUp).Error; err != nil {
+		t.Fatal(err)
+	}
+	return topUp
+}
+
+func TestCompletePaymentOrderCreditsOnce(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "payer", 0)
+	createTestTopUp(t, user.Id, "T1", "cs_1", time.Now())
+	provider := &StripeProvider{}
+	event := &PaymentEvent{Type: PaymentEventPaid, TradeNo: "T1", ExternalId: "pi_1", Money: 10, Currency: "usd"}
+
+	// 回调、对账与重复投递可能同时处理同一订单
+	var wg sync.WaitGroup
+	credited := make([]int, 5)
+	for i := range credited {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			quota, err := CompletePaymentOrder(provider, event)
+			if err != nil {
+				t.Error(err)
+			}
+			credited[i] = quota
+		}(i)
+	}
+	wg.Wait()
+
+	expected := 2 * int(common.QuotaPerUnit)
+	total := 0
+	for _, quota := range credited {
+		total += quota
+	}
+	if total != expected {
+		t.Fatalf("expected %d credited once, got %d", expected, total)
+	}
+	if quota := getTestUserQuota(t, user.Id); quota != expected {
+		t.Fatalf("expected user quota %d, got %d", expected, quota)
+	}
+	if balance := getTestLedgerBalance(t, user.Id); balance != expected {
+		t.Fatalf("expected ledger balance %d, got %d", expected, balance)
+	}
+	var receipts int64
+	model.DB.Model(&model.PaymentReceipt{}).Where("type = ?", model.PaymentReceiptTypePayment).Count(&receipts)
+	if receipts != 1 {
+		t.Fatalf("expected 1 payment receipt, got %d", receipts)
+	}
+	if topUp := model.GetTopUpByPaymentIntent("pi_1"); topUp == nil || topUp.Status != "success" {
+		t.Fatalf("expected order to be paid and linked to the payment intent, got %+v", topUp)
+	}
+
+	// 金额不一致的支付事件不入账
+	createTestTopUp(t, user.Id, "T2", "cs_2", time.Now())
+	if _, err := CompletePaymentOrder(provider, &PaymentEvent{Type: PaymentEventPaid, TradeNo: "T2", ExternalId: "pi_2", Money: 1}); err == nil {
+		t.Fatal("expected mismatched amount to be rejected")
+	}
+}
+
+func TestApplyPaymentRefundDebitsOnce(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "refunded", 0)
+	createTestTopUp(t, user.Id, "T1", "cs_1", time.Now())
+	provider := &StripeProvider{}
+	if _, err := CompletePaymentOrder(provider, &PaymentEvent{Type: PaymentEventPaid, TradeNo: "T1", ExternalId: "pi_1", Money: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	half := &PaymentEvent{Type: PaymentEventRefunded, ExternalId: "pi_1", Money: 5, RefundId: "ch_1:500"}
+	for i := 0; i < 2; i++ {
+		if err := ApplyPaymentRefund(provider, half); err != nil {
+			t.Fatal(err)
+		}
+	}
+	quotaPerUnit := int(common.QuotaPerUnit)
+	if quota := getTestUserQuota(t, user.Id); quota != quotaPerUnit {
+		t.Fatalf("expected half of the quota to be debited once, got %d", quota)
+	}
+
+	full := &PaymentEvent{Type: PaymentEventRefunded, ExternalId: "pi_1", Money: 10, RefundId: "ch_1:1000"}
+	if err := ApplyPaymentRefund(provider, full); err != nil {
+		t.Fatal(err)
+	}
+	if quota := getTestUserQuota(t, user.Id); quota != 0 {
+		t.Fatalf("expected all quota to be debited, got %d", quota)
+	}
+	if balance := getTestLedgerBalance(t, user.Id); balance != 0 {
+		t.Fatalf("expected ledger balance 0, got %d", balance)
+	}
+	if topUp := model.GetTopUpByTradeNo("T1"); topUp.Status != "refunded" {
+		t.Fatalf("expected order to be refunded, got %s", topUp.Status)
+	}
+	if _, err := RefundPaymentOrder("T1", 1); err == nil {
+		t.Fatal("expected refund of a refunded order to fail")
+	}
+}
+
+func TestLockOrderSerializesSameOrder(t *testing.T) {
+	if err := LockOrder("T1"); err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan struct{})
+	go func() {
+		if err := LockOrder("T1"); err != nil {
+			t.Error(err)
+		}
+		close(acquired)
+		UnlockOrder("T1")
+	}()
+
+	// 其他订单不受影响
+	if err := LockOrder("T2"); err != nil {
+		t.Fatal(err)
+	}
+	UnlockOrder("T2")
+
+	select {
+	case <-acquired:
+		t.Fatal("expected the second lock to wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	UnlockOrder("T1")
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected the second lock to be acquired after unlock")
+	}
+}
+
+func TestReconcilePendingTopUps(t *testing.T) {
+	setupTestDB(t)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/v1/checkout/sessions/") {
+		case "cs_paid":
+			w.Write([]byte(`{"id":"cs_paid","client_reference_id":"PAID","payment_intent":"pi_paid","payment_status":"paid","status":"complete","amount_total":1000,"currency":"usd"}`))
+		case "cs_expired":
+			w.Write([]byte(`{"id":"cs_expired","client_reference_id":"EXPIRED","payment_status":"unpaid","status":"expired","amount_total":1000,"currency":"usd"}`))
+		default:
+			w.Write([]byte(`{"id":"cs_open","payment_status":"unpaid","status":"open","amount_total":1000,"currency":"usd"}`))
+		}
+	}))
+	defer stub.Close()
+	setting.StripeApiAddress = stub.URL
+	setting.StripeSecretKey = "sk_test"
+	setting.StripeWebhookSecret = "whsec_test"
+
+	now := time.Now()
+	user := createTestUser(t, "pending", 0)
+	createTestTopUp(t, user.Id, "PAID", "cs_paid", now.Add(-10*time.Minute))
+	createTestTopUp(t, user.Id, "EXPIRED", "cs_expired", now.Add(-10*time.Minute))
+	createTestTopUp(t, user.Id, "OPEN", "cs_open", now.Add(-10*time.Minute))
+	createTestTopUp(t, user.Id, "ABANDONED", "cs_open", now.Add(-paymentReconcileMaxAge-time.Hour))
+	createTestTopUp(t, user.Id, "RECENT", "cs_paid", now)
+
+	if completed := ReconcilePendingTopUps(now); completed != 1 {
+		t.Fatalf("expected 1 order to be completed, got %d", completed)
+	}
+	if completed := ReconcilePendingTopUps(now); completed != 0 {
+		t.Fatalf("expected no order to be completed again, got %d", completed)
+	}
+
+	expected := map[string]string{
+		"PAID":      "success",
+		"EXPIRED":   "expired",
+		"OPEN":      "pending",
+		"ABANDONED": "expired",
+		"RECENT":    "pending",
+	}
+	for tradeNo, status := range expected {
+		if topUp := model.GetTopUpByTradeNo(tradeNo); topUp.Status != status {
+			t.Errorf("order %s: expected status %s, got %s", tradeNo, status, topUp.Status)
+		}
+	}
+	if quota := getTestUserQuota(t, user.Id); quota != 2*int(common.QuotaPerUnit) {
+		t.Fatalf("expected the paid order to be credited, got %d", quota)
+	}
+
+	// 过期后才收到的支付仍然入账
+	quota, err := CompletePaymentOrder(&StripeProvider{}, &PaymentEvent{Type: PaymentEventPaid, TradeNo: "EXPIRED", ExternalId: "pi_late", Money: 10})
+	if err != nil || quota != 2*int(common.QuotaPerUnit) {
+		t.Fatalf("expected late payment to be credited, got %d, %v", quota, err)
+	}
+}
@@ -3,7 +3,6 @@ package service
 import (
 	"errors"
 	"fmt"
-	"log"
 	"math"
 	"one-api/common"
 	"one-api/constant"
@@ -13,7 +12,6 @@ import (
 	"one-api/relay/helper"
 	"one-api/setting"
 	"one-api/setting/ratio_setting"
-	"strings"
 	"time"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -35,6 +33,9 @@ type QuotaInfo struct {
 	ModelPrice    float64
 	ModelRatio    float64
 	GroupRatio    float64
+
+	// CompletionRatio 为 0 时使用模型配置的补全倍率
+	CompletionRatio float64
 }
 
 func calculateAudioQuota(info QuotaInfo) int {
@@ -48,6 +49,9 @@ func calculateAudioQuota(info QuotaInfo) int {
 	}
 
 	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName))
+	if info.CompletionRatio > 0 {
+		completionRatio = decimal.NewFromFloat(info.CompletionRatio)
+	}
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(info.ModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(info.ModelName))
 
@@ -77,7 +81,11 @@ func calculateAudioQuota(info QuotaInfo) int {
 }
 
 func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage) error {
-	if relayInfo.UsePrice {
+	modelName := relayInfo.OriginModelName
+	// 与 ModelPriceHelper 使用相同的价格覆盖、阶梯与分组倍率，按次计费的模型在 PostWssConsumeQuota 中一次性结算
+	modelPrice, usePrice := ratio_setting.GetModelPrice(modelName, false)
+	priceOverride, _, usePrice := helper.ApplyPriceOverride(ctx, relayInfo, modelPrice, usePrice)
+	if usePrice {
 		return nil
 	}
 	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
@@ -85,48 +93,36 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		return err
 	}
 
-	token, err := model.GetTokenByKey(strings.TrimLeft(relayInfo.TokenKey, "sk-"), false)
+	token, err := model.GetTokenByKeyHash(relayInfo.TokenKey, false)
 	if err != nil {
 		return err
 	}
 
-	modelName := relayInfo.OriginModelName
-	textInputTokens := usage.InputTokenDetails.TextTokens
-	textOutTokens := usage.OutputTokenDetails.TextTokens
-	audioInputTokens := usage.InputTokenDetails.AudioTokens
-	audioOutTokens := usage.OutputTokenDetails.AudioTokens
-	groupRatio := ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
-	modelRatio, _ := ratio_setting.GetModelRatio(modelName)
-
-	autoGroup, exists := ctx.Get("auto_group")
-	if exists {
-		groupRatio = ratio_setting.GetGroupRatio(autoGroup.(string))
-		log.Printf("final group ratio: %f", groupRatio)
-		relayInfo.UsingGroup = autoGroup.(string)
-	}
-
-	actualGroupRatio := groupRatio
-	userGroupRatio, ok := ratio_setting.GetGroupGroupRatio(relayInfo.UserGroup, relayInfo.UsingGroup)
-	if ok {
-		actualGroupRatio = userGroupRatio
+	priceData := helper.PriceData{
+		CompletionRatio: ratio_setting.GetCompletionRatio(modelName),
+		GroupRatioInfo:  helper.HandleGroupRatio(ctx, relayInfo),
+		PriceOverride:   priceOverride,
 	}
+	priceData.ModelRatio, _ = ratio_setting.GetModelRatio(modelName)
+	priceData.ApplyPriceTier(modelName, usage.InputTokens)
 
 	quotaInfo := QuotaInfo{
 		InputDetails: TokenDetails{
-			TextTokens:  textInputTokens,
-			AudioTokens: audioInputTokens,
+			TextTokens:  usage.InputTokenDetails.TextTokens,
+			AudioTokens: usage.InputTokenDetails.AudioTokens,
 		},
 		OutputDetails: TokenDetails{
-			TextTokens:  textOutTokens,
-			AudioTokens: audioOutTokens,
+			TextTokens:  usage.OutputTokenDetails.TextTokens,
+			AudioTokens: usage.OutputTokenDetails.AudioTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   relayInfo.UsePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: actualGroupRatio,
+		ModelName:       modelName,
+		ModelRatio:      priceData.ModelRatio,
+		GroupRatio:      priceData.GroupRatioInfo.GroupRatio,
+		CompletionRatio: priceData.CompletionRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
+	quota -= ConsumeSubscriptionQuota(ctx, relayInfo, quota)
 
 	if userQuota < quota {
 		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", common.FormatQuota(userQuota), common.FormatQuota(quota))
@@ -146,6 +142,8 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, preConsumedQuota int, userQuota int, priceData helper.PriceData, extraContent string) {
+	// 实时对话在每次响应时结算，没有用到的预留套餐额度在结束时退回
+	ReleaseSubscriptionQuota(ctx, relayInfo)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
@@ -154,8 +152,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	audioInputTokens := usage.InputTokenDetails.AudioTokens
 	audioOutTokens := usage.OutputTokenDetails.AudioTokens
 
+	priceData.ApplyPriceTier(relayInfo.OriginModelName, usage.InputTokens)
 	tokenName := ctx.GetString("token_name")
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(modelName))
+	completionRatio := decimal.NewFromFloat(priceData.CompletionRatio)
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(modelName))
 
@@ -173,10 +172,12 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		ModelName:       modelName,
+		UsePrice:        usePrice,
+		ModelPrice:      modelPrice,
+		ModelRatio:      modelRatio,
+		GroupRatio:      groupRatio,
+		CompletionRatio: priceData.CompletionRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -209,6 +210,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if priceData.PriceOverride != nil {
+		other["price_override_ratio"] = priceData.PriceOverrideRatio(relayInfo.OriginModelName, usage.InputTokens, usage.OutputTokens)
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -234,6 +238,13 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	completionTokens := usage.CompletionTokens
 	modelName := relayInfo.OriginModelName
 
+	// Claude 的 input_tokens 不含缓存部分，阶梯按完整的上下文长度判断
+	contextTokens := promptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		contextTokens += usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	priceData.ApplyPriceTier(modelName, contextTokens)
+
 	tokenName := ctx.GetString("token_name")
 	completionRatio := priceData.CompletionRatio
 	modelRatio := priceData.ModelRatio
@@ -276,6 +287,9 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	totalTokens := promptTokens + completionTokens
 
 	var logContent string
+	if priceData.PriceTier != nil {
+		logContent = fmt.Sprintf("阶梯价格（提示超过 %d tokens）", priceData.PriceTier.MinPromptTokens)
+	}
 	// record all the consume log even if quota is 0
 	if totalTokens == 0 {
 		// in this case, must be some error happened
@@ -289,7 +303,8 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
-	quotaDelta := quota - preConsumedQuota
+	subscriptionQuota := ConsumeSubscriptionQuota(ctx, relayInfo, quota)
+	quotaDelta := quota - preConsumedQuota - subscriptionQuota
 	if quotaDelta != 0 {
 		err := PostConsumeQuota(relayInfo, quotaDelta, preConsumedQuota, true)
 		if err != nil {
@@ -299,6 +314,12 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio, cacheCreationTokens, cacheCreationRatio, modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if priceData.PriceTier != nil {
+		other["price_tier"] = priceData.PriceTier.MinPromptTokens
+	}
+	if priceData.PriceOverride != nil {
+		other["price_override_ratio"] = priceData.PriceOverrideRatio(modelName, contextTokens, completionTokens)
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     promptTokens,
@@ -348,8 +369,9 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	audioInputTokens := usage.PromptTokensDetails.AudioTokens
 	audioOutTokens := usage.CompletionTokenDetails.AudioTokens
 
+	priceData.ApplyPriceTier(relayInfo.OriginModelName, usage.PromptTokens)
 	tokenName := ctx.GetString("token_name")
-	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(relayInfo.OriginModelName))
+	completionRatio := decimal.NewFromFloat(priceData.CompletionRatio)
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(relayInfo.OriginModelName))
 
@@ -367,10 +389,12 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  relayInfo.OriginModelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		ModelName:       relayInfo.OriginModelName,
+		UsePrice:        usePrice,
+		ModelPrice:      modelPrice,
+		ModelRatio:      modelRatio,
+		GroupRatio:      groupRatio,
+		CompletionRatio: priceData.CompletionRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -397,7 +421,8 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
-	quotaDelta := quota - preConsumedQuota
+	subscriptionQuota := ConsumeSubscriptionQuota(ctx, relayInfo, quota)
+	quotaDelta := quota - preConsumedQuota - subscriptionQuota
 	if quotaDelta != 0 {
 		err := PostConsumeQuota(relayInfo, quotaDelta, preConsumedQuota, true)
 		if err != nil {
@@ -411,6 +436,9 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	}
 	other := GenerateAudioOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
 		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, priceData.GroupRatioInfo.GroupSpecialRatio)
+	if priceData.PriceOverride != nil {
+		other["price_override_ratio"] = priceData.PriceOverrideRatio(relayInfo.OriginModelName, usage.PromptTokens, usage.CompletionTokens)
+	}
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
@@ -438,7 +466,7 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	//if relayInfo.TokenUnlimited {
 	//	return nil
 	//}
-	token, err := model.GetTokenByKey(relayInfo.TokenKey, false)
+	token, err := model.GetTokenByKeyHash(relayInfo.TokenKey, false)
 	if err != nil {
 		return err
 	}
@@ -455,9 +483,9 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
 	if quota > 0 {
-		err = model.DecreaseUserQuota(relayInfo.UserId, quota)
+		err = model.DecreaseUserQuota(relayInfo.UserId, quota, model.QuotaLedgerTypeConsume)
 	} else {
-		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
+		err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false, model.QuotaLedgerTypeRefund)
 	}
 	if err != nil {
 		return err
@@ -494,7 +522,7 @@ func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preCon
 		//noMoreQuota := userCache.Quota-(quota+preConsumedQuota) <= 0
 		quotaTooLow := false
 		consumeQuota := quota + preConsumedQuota
-		if relayInfo.UserQuota-consumeQuota < threshold {
+		if relayInfo.UserQuota+relayInfo.UserCreditLimit-consumeQuota < threshold {
 			quotaTooLow = true
 		}
 		if quotaTooLow {
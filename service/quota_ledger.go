@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"strings"
+	"sync"
+	"time"
+)
+
+const quotaLedgerReconcileBatch = 500
+
+// 通知中最多列出的不一致用户数
+const quotaLedgerReportMaxUsers = 20
+
+// QuotaLedgerDrift 用户余额与额度账本不一致，Drift 为余额减去账本余额
+type QuotaLedgerDrift struct {
+	UserId int `json:"user_id"`
+	Quota  int `json:"quota"`
+	Ledger int `json:"ledger"`
+	Drift  int `json:"drift"`
+}
+
+type QuotaLedgerReport struct {
+	CheckedUsers int                `json:"checked_users"`
+	Drifts       []QuotaLedgerDrift `json:"drifts"`
+	TotalDrift   int                `json:"total_drift"`
+	StartedAt    int64              `json:"started_at"`
+	FinishedAt   int64              `json:"finished_at"`
+}
+
+var (
+	quotaLedgerReconcileLock sync.Mutex
+	lastQuotaLedgerReport    *QuotaLedgerReport
+)
+
+// GetLastQuotaLedgerReport 返回本节点最近一次的核对结果，尚未核对时返回 nil
+func GetLastQuotaLedgerReport() *QuotaLedgerReport {
+	quotaLedgerReconcileLock.Lock()
+	defer quotaLedgerReconcileLock.Unlock()
+	return lastQuotaLedgerReport
+}
+
+// recheckQuotaLedgerDrift 重新读取用户的余额与账本余额，排除核对期间并发修改造成的误报
+func recheckQuotaLedgerDrift(userId int) (*QuotaLedgerDrift, error) {
+	balances, err := model.GetUserQuotaBalances(userId-1, 1)
+	if err != nil || len(balances) == 0 || balances[0].Id != userId {
+		return nil, err
+	}
+	balance := balances[0]
+	if balance.Quota == balance.Ledger {
+		return nil, nil
+	}
+	return &QuotaLedgerDrift{UserId: userId, Quota: balance.Quota, Ledger: balance.Ledger, Drift: balance.Quota - balance.Ledger}, nil
+}
+
+// ReconcileQuotaLedger 逐个核对用户的 User.Quota 与额度账本中用户账户的分录合计，报告不一致的用户
+func ReconcileQuotaLedger() (*QuotaLedgerReport, error) {
+	quotaLedgerReconcileLock.Lock()
+	defer quotaLedgerReconcileLock.Unlock()
+	report := &QuotaLedgerReport{StartedAt: common.GetTimestamp(), Drifts: []QuotaLedgerDrift{}}
+	afterId := 0
+	for {
+		balances, err := model.GetUserQuotaBalances(afterId, quotaLedgerReconcileBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(balances) == 0 {
+			break
+		}
+		for _, balance := range balances {
+			report.CheckedUsers++
+			if balance.Quota == balance.Ledger {
+				continue
+			}
+			drift, err := recheckQuotaLedgerDrift(balance.Id)
+			if err != nil {
+				return nil, err
+			}
+			if drift != nil {
+				report.Drifts = append(report.Drifts, *drift)
+				report.TotalDrift += drift.Drift
+			}
+		}
+		afterId = balances[len(balances)-1].Id
+	}
+	report.FinishedAt = common.GetTimestamp()
+	lastQuotaLedgerReport = report
+	return report, nil
+}
+
+func reportQuotaLedgerDrift(report *QuotaLedgerReport) {
+	if len(report.Drifts) == 0 {
+		common.SysLog(fmt.Sprintf("quota ledger reconciled, %d users checked, no drift", report.CheckedUsers))
+		return
+	}
+	lines := make([]string, 0, min(len(report.Drifts), quotaLedgerReportMaxUsers))
+	for i, drift := range report.Drifts {
+		if i >= quotaLedgerReportMaxUsers {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("用户 #%d：余额 %s，账本 %s，偏差 %s", drift.UserId,
+			common.LogQuota(drift.Quota), common.LogQuota(drift.Ledger), common.LogQuota(drift.Drift)))
+	}
+	content := fmt.Sprintf("核对了 %d 个用户，%d 个用户的余额与额度账本不一致，合计偏差 %s：\n%s",
+		report.CheckedUsers, len(report.Drifts), common.LogQuota(report.TotalDrift), strings.Join(lines, "\n"))
+	if len(report.Drifts) > quotaLedgerReportMaxUsers {
+		content += fmt.Sprintf("\n以及其他 %d 个用户", len(report.Drifts)-quotaLedgerReportMaxUsers)
+	}
+	common.SysError(content)
+	model.RecordLog(0, model.LogTypeSystem, content)
+	if system_setting.GetQuotaLedgerSettings().NotifyRoot {
+		NotifyRootUser("quota_ledger_drift", "额度账本核对发现不一致", content)
+	}
+}
+
+// createQuotaLedgerCheckpoints 每天记录一次账本检查点，检查点时刻为 QuotaLedgerCheckpointDelay 之前所在日期的零点（UTC）
+func createQuotaLedgerCheckpoints() {
+	at := time.Now().Add(-model.QuotaLedgerCheckpointDelay).UTC().Truncate(24 * time.Hour).Unix()
+	count, err := model.CreateQuotaLedgerCheckpoints(at)
+	if err != nil {
+		common.SysError("failed to create quota ledger checkpoints: " + err.Error())
+	} else if count > 0 {
+		common.SysLog(fmt.Sprintf("created quota ledger checkpoints for %d users", count))
+	}
+}
+
+// StartQuotaLedgerReconcileJob 记录每天的账本检查点，并按设置的间隔核对用户余额与额度账本，只应在主节点调用
+func StartQuotaLedgerReconcileJob() {
+	var lastRun time.Time
+	for {
+		time.Sleep(time.Minute)
+		createQuotaLedgerCheckpoints()
+		interval := system_setting.GetQuotaLedgerSettings().ReconcileIntervalMinutes
+		if interval <= 0 || time.Since(lastRun) < time.Duration(interval)*time.Minute {
+			continue
+		}
+		lastRun = time.Now()
+		report, err := ReconcileQuotaLedger()
+		if err != nil {
+			common.SysError("failed to reconcile quota ledger: " + err.Error())
+			continue
+		}
+		reportQuotaLedgerDrift(report)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"one-api/setting/system_setting"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 标签值只允许字母、数字与 _ . : -，避免影响按标签筛选
+var requestTagValuePattern = regexp.MustCompile(`^[A-Za-z0-9_.:\-]+$`)
+
+// RequestTagsMaxLength 格式化后标签的最大长度，与日志及用量汇总表中 tags 列的长度一致
+const RequestTagsMaxLength = 255
+
+// ParseRequestTags 解析 X-NewAPI-Tags 请求头（如 project=search,env=prod）与请求体中的 metadata 字段。
+// 请求头中的标签必须全部合法，否则返回错误；metadata 常被客户端用于其他用途，只取允许的键并忽略不合法的值，
+// 超出长度或数量上限的 metadata 标签同样被忽略。同一个键同时出现时以请求头为准
+func ParseRequestTags(header string, metadata map[string]any) (map[string]string, error) {
+	settings := system_setting.GetRequestTagSettings()
+	if len(settings.AllowedKeys) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("标签 %s 格式错误，应为 key=value", pair)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := validateRequestTag(settings, key, value); err != nil {
+			return nil, err
+		}
+		tags[key] = value
+	}
+	if len(FormatRequestTags(tags)) > RequestTagsMaxLength {
+		return nil, fmt.Errorf("标签总长度不能超过 %d 个字符", RequestTagsMaxLength)
+	}
+	if settings.MaxTags > 0 && len(tags) > settings.MaxTags {
+		return nil, fmt.Errorf("标签数量不能超过 %d 个", settings.MaxTags)
+	}
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if settings.MaxTags > 0 && len(tags) >= settings.MaxTags {
+			break
+		}
+		if _, ok := tags[key]; ok {
+			continue
+		}
+		text, ok := metadata[key].(string)
+		if !ok || validateRequestTag(settings, key, text) != nil {
+			continue
+		}
+		tags[key] = text
+		if len(FormatRequestTags(tags)) > RequestTagsMaxLength {
+			delete(tags, key)
+		}
+	}
+	return tags, nil
+}
+
+func validateRequestTag(settings *system_setting.RequestTagSettings, key string, value string) error {
+	if !slices.Contains(settings.AllowedKeys, key) {
+		return fmt.Errorf("标签键 %s 不在允许列表中", key)
+	}
+	if !requestTagValuePattern.MatchString(value) {
+		return fmt.Errorf("标签 %s 的值只能包含字母、数字与 _ . : -", key)
+	}
+	if settings.MaxValueLength > 0 && len(value) > settings.MaxValueLength {
+		return fmt.Errorf("标签 %s 的值不能超过 %d 个字符", key, settings.MaxValueLength)
+	}
+	return nil
+}
+
+// FormatRequestTags 按键排序后格式化为 key=value,key2=value2，与日志中保存的格式一致
+func FormatRequestTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
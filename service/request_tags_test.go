@@ -0,0 +1,41 @@
+package service
+
+import (
+	"one-api/setting/system_setting"
+	"testing"
+)
+
+func withRequestTagSettings(t *testing.T, allowedKeys []string, maxTags int) {
+	t.Helper()
+	settings := system_setting.GetRequestTagSettings()
+	previous := *settings
+	settings.AllowedKeys = allowedKeys
+	settings.MaxTags = maxTags
+	t.Cleanup(func() { *settings = previous })
+}
+
+func TestParseRequestTagsMaxTags(t *testing.T) {
+	withRequestTagSettings(t, []string{"project", "env", "team", "user"}, 2)
+
+	// metadata 中多余的标签被忽略，不影响请求
+	tags, err := ParseRequestTags("project=search", map[string]any{"env": "prod", "team": "infra", "user": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags["project"] != "search" || tags["env"] != "prod" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	tags, err = ParseRequestTags("", map[string]any{"env": "prod", "team": "infra", "user": "alice", "other": "x"})
+	if err != nil || len(tags) != 2 {
+		t.Fatalf("expected metadata to be truncated to 2 tags, got %v, %v", tags, err)
+	}
+
+	// 请求头自身超出数量上限时返回错误
+	if _, err = ParseRequestTags("project=search,env=prod,team=infra", nil); err == nil {
+		t.Fatal("expected an error when the header exceeds the tag limit")
+	}
+	if _, err = ParseRequestTags("project=search,unknown=x", nil); err == nil {
+		t.Fatal("expected an error for a header tag outside the allowed keys")
+	}
+}
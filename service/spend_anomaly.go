@@ -0,0 +1,294 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	SpendAnomalySpendSpike   = "spend_spike"
+	SpendAnomalyRequestSpike = "request_spike"
+	SpendAnomalyNewModel     = "new_model"
+	SpendAnomalyNewIp        = "new_ip"
+)
+
+// 同一异常在冷却时间内只处理一次，避免持续异常时反复通知
+const spendAnomalyCooldown = 6 * time.Hour
+
+// SpendAnomaly 检测到的一次异常，TokenId 为 0 表示用户级别的异常
+type SpendAnomaly struct {
+	Kind      string `json:"kind"`
+	UserId    int    `json:"user_id"`
+	Username  string `json:"username"`
+	TokenId   int    `json:"token_id"`
+	TokenName string `json:"token_name"`
+	Detail    string `json:"detail"`
+	// Subject 区分同一令牌的不同异常对象，例如新出现的模型或 IP
+	Subject string `json:"subject,omitempty"`
+}
+
+func (a *SpendAnomaly) key() string {
+	return fmt.Sprintf("%s|%d|%d|%s", a.Kind, a.UserId, a.TokenId, a.Subject)
+}
+
+func (a *SpendAnomaly) target() string {
+	if a.TokenId == 0 {
+		return fmt.Sprintf("用户「%s」（#%d）", a.Username, a.UserId)
+	}
+	return fmt.Sprintf("用户「%s」（#%d）的令牌「%s」（#%d）", a.Username, a.UserId, a.TokenName, a.TokenId)
+}
+
+// spendUsage 一个用户或令牌在统计时段内的用量
+type spendUsage struct {
+	userId    int
+	username  string
+	tokenId   int
+	tokenName string
+	quota     int64
+	requests  int64
+	models    map[string]bool
+}
+
+type spendUsageKey struct {
+	userId  int
+	tokenId int
+}
+
+// collectSpendUsage 按用户与令牌汇总用量，令牌 id 为 0 的条目是用户的合计
+func collectSpendUsage(query *model.UsageAnalyticsQuery) (map[spendUsageKey]*spendUsage, error) {
+	query.GroupBy = []string{"user", "token", "model"}
+	items, err := model.QueryUsageAnalytics(query)
+	if err != nil {
+		return nil, err
+	}
+	usages := make(map[spendUsageKey]*spendUsage)
+	get := func(key spendUsageKey) *spendUsage {
+		usage, ok := usages[key]
+		if !ok {
+			usage = &spendUsage{userId: key.userId, tokenId: key.tokenId, models: make(map[string]bool)}
+			usages[key] = usage
+		}
+		return usage
+	}
+	for _, item := range items {
+		keys := []spendUsageKey{{userId: item.UserId}}
+		if item.TokenId != 0 {
+			keys = append(keys, spendUsageKey{userId: item.UserId, tokenId: item.TokenId})
+		}
+		for _, key := range keys {
+			usage := get(key)
+			usage.quota += item.Quota
+			usage.requests += item.Requests
+			usage.models[item.ModelName] = true
+			if item.Username != "" {
+				usage.username = item.Username
+			}
+			if key.tokenId != 0 && item.TokenName != "" {
+				usage.tokenName = item.TokenName
+			}
+		}
+	}
+	return usages, nil
+}
+
+// DetectSpendAnomalies 将最近一小时的用量与之前 BaselineHours 小时的平均每小时用量比较，
+// 没有历史用量的用户与令牌不参与判断
+func DetectSpendAnomalies(now time.Time) ([]*SpendAnomaly, error) {
+	settings := system_setting.GetSpendAnomalySettings()
+	recentStart := now.Unix() - 3600
+	// 基线只使用最近一小时之前完整的小时汇总
+	baselineEnd := recentStart - recentStart%3600
+	baselineHours := settings.BaselineHours
+	if baselineHours <= 0 {
+		baselineHours = 168
+	}
+	recent, err := collectSpendUsage(&model.UsageAnalyticsQuery{
+		StartTimestamp: recentStart,
+		EndTimestamp:   now.Unix(),
+		Bucket:         "minute",
+	})
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := collectSpendUsage(&model.UsageAnalyticsQuery{
+		StartTimestamp: baselineEnd - int64(baselineHours)*3600,
+		EndTimestamp:   baselineEnd - 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	anomalies := make([]*SpendAnomaly, 0)
+	for key, usage := range recent {
+		base, ok := baseline[key]
+		if !ok || base.requests == 0 {
+			continue
+		}
+		newAnomaly := func(kind string, subject string, detail string) *SpendAnomaly {
+			return &SpendAnomaly{
+				Kind:      kind,
+				UserId:    usage.userId,
+				Username:  usage.username,
+				TokenId:   usage.tokenId,
+				TokenName: usage.tokenName,
+				Detail:    detail,
+				Subject:   subject,
+			}
+		}
+		avgQuota := float64(base.quota) / float64(baselineHours)
+		if float64(usage.quota) >= settings.MinSpend*common.QuotaPerUnit && float64(usage.quota) > avgQuota*settings.SpikeMultiplier {
+			anomalies = append(anomalies, newAnomaly(SpendAnomalySpendSpike, "",
+				fmt.Sprintf("最近一小时消费 %s，过去 %d 小时平均每小时消费 %s", common.FormatQuota(int(usage.quota)), baselineHours, common.FormatQuota(int(avgQuota)))))
+		}
+		avgRequests := float64(base.requests) / float64(baselineHours)
+		if usage.requests >= int64(settings.MinRequests) && float64(usage.requests) > avgRequests*settings.SpikeMultiplier {
+			anomalies = append(anomalies, newAnomaly(SpendAnomalyRequestSpike, "",
+				fmt.Sprintf("最近一小时请求 %d 次，过去 %d 小时平均每小时请求 %.1f 次", usage.requests, baselineHours, avgRequests)))
+		}
+		// 新模型与新 IP 只按令牌判断
+		if key.tokenId == 0 {
+			continue
+		}
+		if settings.DetectNewModel {
+			for _, modelName := range sortedNewKeys(usage.models, base.models) {
+				anomalies = append(anomalies, newAnomaly(SpendAnomalyNewModel, modelName,
+					fmt.Sprintf("开始使用过去 %d 小时内未使用过的模型 %s", baselineHours, modelName)))
+			}
+		}
+		if settings.DetectNewIp {
+			ips, err := newTokenIps(key.tokenId, baselineEnd-int64(baselineHours)*3600, recentStart, now.Unix())
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to get ips of token %d: %s", key.tokenId, err.Error()))
+				continue
+			}
+			for _, ip := range ips {
+				anomalies = append(anomalies, newAnomaly(SpendAnomalyNewIp, ip,
+					fmt.Sprintf("开始从过去 %d 小时内未出现过的 IP %s 发起请求", baselineHours, ip)))
+			}
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].key() < anomalies[j].key()
+	})
+	return anomalies, nil
+}
+
+func sortedNewKeys(current map[string]bool, previous map[string]bool) []string {
+	keys := make([]string, 0)
+	for key := range current {
+		if !previous[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// newTokenIps 返回令牌最近出现、但在基线时段内没有出现过的 IP，基线时段没有 IP 记录时不判断
+func newTokenIps(tokenId int, baselineStart int64, recentStart int64, end int64) ([]string, error) {
+	previous, err := model.GetTokenLogIps(tokenId, baselineStart, recentStart)
+	if err != nil || len(previous) == 0 {
+		return nil, err
+	}
+	current, err := model.GetTokenLogIps(tokenId, recentStart, end+1)
+	if err != nil {
+		return nil, err
+	}
+	currentMap := make(map[string]bool, len(current))
+	for _, ip := range current {
+		currentMap[ip] = true
+	}
+	previousMap := make(map[string]bool, len(previous))
+	for _, ip := range previous {
+		previousMap[ip] = true
+	}
+	return sortedNewKeys(currentMap, previousMap), nil
+}
+
+var spendAnomalyAlerted = make(map[string]time.Time)
+var spendAnomalyAlertedLock sync.Mutex
+
+// HandleSpendAnomalies 按设置处理检测到的异常：禁用令牌、通知管理员与用户，冷却时间内的重复异常会被忽略
+func HandleSpendAnomalies(anomalies []*SpendAnomaly, now time.Time) {
+	settings := system_setting.GetSpendAnomalySettings()
+	spendAnomalyAlertedLock.Lock()
+	defer spendAnomalyAlertedLock.Unlock()
+	for key, alertedAt := range spendAnomalyAlerted {
+		if now.Sub(alertedAt) > spendAnomalyCooldown {
+			delete(spendAnomalyAlerted, key)
+		}
+	}
+	for _, anomaly := range anomalies {
+		if _, ok := spendAnomalyAlerted[anomaly.key()]; ok {
+			continue
+		}
+		spendAnomalyAlerted[anomaly.key()] = now
+		content := fmt.Sprintf("%s用量异常：%s", anomaly.target(), anomaly.Detail)
+		if settings.SuspendToken && anomaly.TokenId != 0 &&
+			(anomaly.Kind == SpendAnomalySpendSpike || anomaly.Kind == SpendAnomalyRequestSpike) {
+			if suspended, err := suspendAnomalyToken(anomaly.TokenId); err != nil {
+				common.SysError(fmt.Sprintf("failed to suspend token %d: %s", anomaly.TokenId, err.Error()))
+			} else if suspended {
+				content += "，令牌已被自动禁用"
+			}
+		}
+		common.SysLog(content)
+		model.RecordLog(anomaly.UserId, model.LogTypeSystem, content)
+		subject := fmt.Sprintf("%s用量异常", anomaly.target())
+		notifyType := fmt.Sprintf("%s_%d_%d", dto.NotifyTypeSpendAnomaly, anomaly.UserId, anomaly.TokenId)
+		if settings.NotifyRoot {
+			NotifyRootUser(notifyType, subject, content)
+		}
+		if settings.NotifyUser {
+			notifySpendAnomalyUser(anomaly, notifyType, subject, content)
+		}
+	}
+}
+
+func suspendAnomalyToken(tokenId int) (bool, error) {
+	token, err := model.GetTokenById(tokenId)
+	if err != nil {
+		return false, err
+	}
+	if token.Status != common.TokenStatusEnabled {
+		return false, nil
+	}
+	token.Status = common.TokenStatusDisabled
+	return true, token.SelectUpdate()
+}
+
+func notifySpendAnomalyUser(anomaly *SpendAnomaly, notifyType string, subject string, content string) {
+	user, err := model.GetUserById(anomaly.UserId, false)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get user %d: %s", anomaly.UserId, err.Error()))
+		return
+	}
+	notify := dto.NewNotify(notifyType, subject, content, nil)
+	notify.Data = anomaly
+	if err = NotifyUser(user.Id, user.Email, user.GetSetting(), notify); err != nil {
+		common.SysError(fmt.Sprintf("failed to notify user %d: %s", user.Id, err.Error()))
+	}
+}
+
+// StartSpendAnomalyJob 每 10 分钟检测一次用量异常，只应在主节点调用
+func StartSpendAnomalyJob() {
+	for {
+		time.Sleep(10 * time.Minute)
+		if !system_setting.GetSpendAnomalySettings().Enabled || !common.UsageRollupEnabled {
+			continue
+		}
+		now := time.Now()
+		anomalies, err := DetectSpendAnomalies(now)
+		if err != nil {
+			common.SysError("failed to detect spend anomalies: " + err.Error())
+			continue
+		}
+		HandleSpendAnomalies(anomalies, now)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting/system_setting"
+	"sort"
+	"time"
+)
+
+const (
+	SpendReportDaily   = "daily"
+	SpendReportWeekly  = "weekly"
+	SpendReportMonthly = "monthly"
+)
+
+var spendReportPeriodNames = map[string]string{
+	SpendReportDaily:   "昨日",
+	SpendReportWeekly:  "上周",
+	SpendReportMonthly: "上月",
+}
+
+type SpendReportItem struct {
+	Name      string `json:"name"`
+	Requests  int64  `json:"requests"`
+	Quota     int64  `json:"quota"`
+	QuotaText string `json:"quota_text"`
+}
+
+// SpendReport 用户在一个周期内的用量汇总，同时作为报告模板的数据
+type SpendReport struct {
+	Period          string            `json:"period"`
+	PeriodName      string            `json:"period_name"`
+	StartTimestamp  int64             `json:"start_timestamp"`
+	EndTimestamp    int64             `json:"end_timestamp"`
+	StartDate       string            `json:"start_date"`
+	EndDate         string            `json:"end_date"`
+	Username        string            `json:"username"`
+	Requests        int64             `json:"requests"`
+	Errors          int64             `json:"errors"`
+	Quota           int64             `json:"quota"`
+	QuotaText       string            `json:"quota_text"`
+	RemainQuota     int               `json:"remain_quota"`
+	RemainQuotaText string            `json:"remain_quota_text"`
+	TopModels       []SpendReportItem `json:"top_models"`
+	TopTokens       []SpendReportItem `json:"top_tokens"`
+}
+
+const defaultSpendReportTemplate = `<p>您好 {{.Username}}，以下是您{{.PeriodName}}（{{.StartDate}} 至 {{.EndDate}}）的用量报告：</p>
+<ul>
+<li>消费额度：{{.QuotaText}}</li>
+<li>请求次数：{{.Requests}}，其中失败 {{.Errors}} 次</li>
+<li>剩余额度：{{.RemainQuotaText}}</li>
+</ul>
+{{if .TopModels}}<p>消费最多的模型：</p>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>模型</th><th>请求次数</th><th>消费额度</th></tr>
+{{range .TopModels}}<tr><td>{{.Name}}</td><td>{{.Requests}}</td><td>{{.QuotaText}}</td></tr>
+{{end}}</table>{{end}}
+{{if .TopTokens}}<p>消费最多的令牌：</p>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>令牌</th><th>请求次数</th><th>消费额度</th></tr>
+{{range .TopTokens}}<tr><td>{{.Name}}</td><td>{{.Requests}}</td><td>{{.QuotaText}}</td></tr>
+{{end}}</table>{{end}}`
+
+// spendReportRange 返回报告周期的起止时间，结束时间不包含在内
+func spendReportRange(period string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case SpendReportWeekly:
+		// 以周一为一周的开始
+		offset := (int(today.Weekday()) + 6) % 7
+		end := today.AddDate(0, 0, -offset)
+		return end.AddDate(0, 0, -7), end
+	case SpendReportMonthly:
+		end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return end.AddDate(0, -1, 0), end
+	}
+	return today.AddDate(0, 0, -1), today
+}
+
+// BuildSpendReport 根据用量汇总生成用户的用量报告
+func BuildSpendReport(user *model.User, period string, now time.Time) (*SpendReport, error) {
+	start, end := spendReportRange(period, now)
+	report := &SpendReport{
+		Period:          period,
+		PeriodName:      spendReportPeriodNames[period],
+		StartTimestamp:  start.Unix(),
+		EndTimestamp:    end.Unix(),
+		StartDate:       start.Format("2006-01-02"),
+		EndDate:         end.Add(-time.Second).Format("2006-01-02"),
+		Username:        user.Username,
+		RemainQuota:     user.Quota,
+		RemainQuotaText: common.FormatQuota(user.Quota),
+	}
+	query := &model.UsageAnalyticsQuery{
+		StartTimestamp: start.Unix(),
+		EndTimestamp:   end.Unix() - 1,
+		UserId:         user.Id,
+		GroupBy:        []string{"model"},
+	}
+	models, err := model.QueryUsageAnalytics(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range models {
+		report.Requests += item.Requests
+		report.Errors += item.Errors
+		report.Quota += item.Quota
+		report.TopModels = append(report.TopModels, newSpendReportItem(item.ModelName, item))
+	}
+	report.QuotaText = common.FormatQuota(int(report.Quota))
+	query.GroupBy = []string{"token"}
+	tokens, err := model.QueryUsageAnalytics(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range tokens {
+		report.TopTokens = append(report.TopTokens, newSpendReportItem(item.TokenName, item))
+	}
+	topN := system_setting.GetSpendReportSettings().TopN
+	report.TopModels = topSpendReportItems(report.TopModels, topN)
+	report.TopTokens = topSpendReportItems(report.TopTokens, topN)
+	return report, nil
+}
+
+func newSpendReportItem(name string, item *model.UsageAnalyticsItem) SpendReportItem {
+	return SpendReportItem{
+		Name:      name,
+		Requests:  item.Requests,
+		Quota:     item.Quota,
+		QuotaText: common.FormatQuota(int(item.Quota)),
+	}
+}
+
+func topSpendReportItems(items []SpendReportItem, n int) []SpendReportItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Quota > items[j].Quota
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// RenderSpendReport 使用设置中的模板渲染报告，模板无效时回退到默认模板
+func RenderSpendReport(report *SpendReport) (string, error) {
+	text := system_setting.GetSpendReportSettings().Template
+	if text == "" {
+		text = defaultSpendReportTemplate
+	}
+	tmpl, err := template.New("spend_report").Parse(text)
+	if err != nil {
+		common.SysError("invalid spend report template: " + err.Error())
+		tmpl = template.Must(template.New("spend_report").Parse(defaultSpendReportTemplate))
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// StartSpendReportJob 每天在设置的时间发送用量报告，只应在主节点调用
+func StartSpendReportJob() {
+	lastRunDate := ""
+	for {
+		settings := system_setting.GetSpendReportSettings()
+		now := time.Now()
+		today := now.Format("2006-01-02")
+		if settings.Enabled && now.Hour() == settings.RunHour && lastRunDate != today {
+			lastRunDate = today
+			SendSpendReports(now)
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
+
+// SendSpendReports 向订阅的用户发送到期的报告：每日报告每天发送，每周报告在周一发送，每月报告在 1 日发送
+func SendSpendReports(now time.Time) {
+	users, err := model.GetSpendReportUsers()
+	if err != nil {
+		common.SysError("failed to get spend report users: " + err.Error())
+		return
+	}
+	sent := 0
+	for _, user := range users {
+		setting := user.GetSetting()
+		periods := make([]string, 0, 3)
+		if setting.SpendReportDaily {
+			periods = append(periods, SpendReportDaily)
+		}
+		if setting.SpendReportWeekly && now.Weekday() == time.Monday {
+			periods = append(periods, SpendReportWeekly)
+		}
+		if setting.SpendReportMonthly && now.Day() == 1 {
+			periods = append(periods, SpendReportMonthly)
+		}
+		for _, period := range periods {
+			if err = sendSpendReport(user, setting, period, now); err != nil {
+				common.SysError(fmt.Sprintf("failed to send %s spend report to user %d: %s", period, user.Id, err.Error()))
+				continue
+			}
+			sent++
+		}
+	}
+	if sent > 0 {
+		common.SysLog(fmt.Sprintf("sent %d spend reports", sent))
+	}
+}
+
+func sendSpendReport(user *model.User, setting dto.UserSetting, period string, now time.Time) error {
+	report, err := BuildSpendReport(user, period, now)
+	if err != nil {
+		return err
+	}
+	content, err := RenderSpendReport(report)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("%s用量报告（%s 至 %s）", report.PeriodName, report.StartDate, report.EndDate)
+	notify := dto.NewNotify(dto.NotifyTypeSpendReport+"_"+period, title, content, nil)
+	notify.Data = report
+	return NotifyUser(user.Id, user.Email, setting, notify)
+}
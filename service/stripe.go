@@ -0,0 +1,280 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/setting"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StripeSignatureTolerance webhook 签名时间戳允许的最大偏差
+const StripeSignatureTolerance = 5 * time.Minute
+
+// stripe webhook 请求体的最大长度
+const maxStripeWebhookBodySize = 1 << 16
+
+// Stripe 中以最小货币单位为整数单位的货币，金额不需要乘以 100
+var stripeZeroDecimalCurrencies = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true, "krw": true, "mga": true,
+	"pyg": true, "rwf": true, "ugx": true, "vnd": true, "vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// StripeAmountToMoney 将 Stripe 的最小货币单位金额转换为常规金额
+func StripeAmountToMoney(amount int64, currency string) float64 {
+	if stripeZeroDecimalCurrencies[strings.ToLower(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
+
+// MoneyToStripeAmount 将常规金额转换为 Stripe 的最小货币单位金额
+func MoneyToStripeAmount(money float64, currency string) int64 {
+	if stripeZeroDecimalCurrencies[strings.ToLower(currency)] {
+		return int64(math.Round(money))
+	}
+	return int64(math.Round(money * 100))
+}
+
+// stripeRequest 调用 Stripe API，idempotencyKey 不为空时作为幂等键
+func stripeRequest(method string, path string, form url.Values, idempotencyKey string) ([]byte, error) {
+	if setting.StripeSecretKey == "" {
+		return nil, errors.New("stripe secret key is not configured")
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(setting.StripeApiAddress, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+setting.StripeSecretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	resp, err := paymentHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var stripeErr dto.StripeError
+		if err := json.Unmarshal(respBody, &stripeErr); err == nil && stripeErr.Error.Message != "" {
+			return nil, fmt.Errorf("stripe error: %s", stripeErr.Error.Message)
+		}
+		return nil, fmt.Errorf("stripe error: status code %d", resp.StatusCode)
+	}
+	return respBody, nil
+}
+
+// StripeProvider Stripe 渠道，使用 Checkout Session 收款，支付与退款结果均以 webhook 为准
+type StripeProvider struct{}
+
+func (p *StripeProvider) Name() string {
+	return PaymentProviderStripe
+}
+
+func (p *StripeProvider) Enabled() bool {
+	return setting.StripeSecretKey != "" && setting.StripeWebhookSecret != ""
+}
+
+// CreateOrder 创建一次性支付的 Checkout Session，订单号同时作为幂等键，
+// 并写入 client_reference_id 与 payment intent 的 metadata，便于 webhook 找回订单
+func (p *StripeProvider) CreateOrder(order *PaymentOrder) (*PaymentOrderResult, error) {
+	currency := strings.ToLower(setting.StripeCurrency)
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", order.ReturnUrl)
+	form.Set("cancel_url", order.CancelUrl)
+	form.Set("client_reference_id", order.TradeNo)
+	form.Set("metadata[trade_no]", order.TradeNo)
+	form.Set("payment_intent_data[metadata][trade_no]", order.TradeNo)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", currency)
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(MoneyToStripeAmount(order.Money, currency), 10))
+	form.Set("line_items[0][price_data][product_data][name]", order.Name)
+	if order.Email != "" {
+		form.Set("customer_email", order.Email)
+	}
+	body, err := stripeRequest(http.MethodPost, "/v1/checkout/sessions", form, order.TradeNo)
+	if err != nil {
+		return nil, err
+	}
+	var session dto.StripeCheckoutSession
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, err
+	}
+	if session.Url == "" {
+		return nil, errors.New("stripe checkout session has no url")
+	}
+	return &PaymentOrderResult{Url: session.Url, ExternalId: session.Id}, nil
+}
+
+func stripeSessionEvent(session *dto.StripeCheckoutSession) *PaymentEvent {
+	tradeNo := session.ClientReferenceId
+	if tradeNo == "" {
+		tradeNo = session.Metadata["trade_no"]
+	}
+	event := &PaymentEvent{
+		TradeNo:    tradeNo,
+		ExternalId: session.PaymentIntent,
+		Money:      StripeAmountToMoney(session.AmountTotal, session.Currency),
+		Currency:   session.Currency,
+	}
+	if event.ExternalId == "" {
+		event.ExternalId = session.Id
+	}
+	// 异步支付方式（如银行转账）在 checkout.session.async_payment_succeeded 时才到账
+	if session.PaymentStatus == "paid" {
+		event.Type = PaymentEventPaid
+	} else if session.Status == "expired" {
+		event.Type = PaymentEventExpired
+	}
+	return event
+}
+
+func (p *StripeProvider) VerifyCallback(c *gin.Context) (*PaymentEvent, error) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxStripeWebhookBodySize))
+	if err != nil {
+		return nil, err
+	}
+	err = VerifyStripeSignature(payload, c.GetHeader("Stripe-Signature"), setting.StripeWebhookSecret, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var stripeEvent dto.StripeEvent
+	if err := json.Unmarshal(payload, &stripeEvent); err != nil {
+		return nil, err
+	}
+	switch stripeEvent.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		var session dto.StripeCheckoutSession
+		if err := json.Unmarshal(stripeEvent.Data.Object, &session); err != nil {
+			return nil, err
+		}
+		return stripeSessionEvent(&session), nil
+	case "charge.succeeded", "charge.refunded":
+		var charge dto.StripeCharge
+		if err := json.Unmarshal(stripeEvent.Data.Object, &charge); err != nil {
+			return nil, err
+		}
+		event := &PaymentEvent{
+			ExternalId: charge.PaymentIntent,
+			Currency:   charge.Currency,
+			ReceiptUrl: charge.ReceiptUrl,
+		}
+		if stripeEvent.Type == "charge.succeeded" {
+			event.Type = PaymentEventReceipt
+			return event, nil
+		}
+		// 累计退款金额作为退款凭证的唯一标识，同一次退款的重复回调不会重复扣回额度
+		event.Type = PaymentEventRefunded
+		event.Money = StripeAmountToMoney(charge.AmountRefunded, charge.Currency)
+		event.RefundId = fmt.Sprintf("%s:%d", charge.Id, charge.AmountRefunded)
+		return event, nil
+	}
+	return &PaymentEvent{}, nil
+}
+
+// QueryOrder 通过创建订单时保存的 checkout session id 查询支付状态
+func (p *StripeProvider) QueryOrder(topUp *model.TopUp) (*PaymentEvent, error) {
+	if !strings.HasPrefix(topUp.PaymentIntent, "cs_") {
+		return nil, nil
+	}
+	body, err := stripeRequest(http.MethodGet, "/v1/checkout/sessions/"+url.PathEscape(topUp.PaymentIntent), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var session dto.StripeCheckoutSession
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, err
+	}
+	event := stripeSessionEvent(&session)
+	event.TradeNo = topUp.TradeNo
+	return event, nil
+}
+
+// Refund 退款结果通过 charge.refunded webhook 通知，额度在收到 webhook 时扣回
+func (p *StripeProvider) Refund(topUp *model.TopUp, money float64) (*PaymentEvent, error) {
+	if topUp.PaymentIntent == "" || strings.HasPrefix(topUp.PaymentIntent, "cs_") {
+		return nil, errors.New("订单没有 Stripe 支付记录")
+	}
+	refunded, err := model.GetTopUpRefundedMoney(topUp.Id)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("payment_intent", topUp.PaymentIntent)
+	form.Set("amount", strconv.FormatInt(MoneyToStripeAmount(money, setting.StripeCurrency), 10))
+	idempotencyKey := fmt.Sprintf("%s:refund:%.2f", topUp.TradeNo, refunded+money)
+	_, err = stripeRequest(http.MethodPost, "/v1/refunds", form, idempotencyKey)
+	return nil, err
+}
+
+func computeStripeSignature(payload []byte, secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignStripePayload 生成 Stripe-Signature 请求头，用于本地模拟 webhook 发送方
+func SignStripePayload(payload []byte, secret string, timestamp int64) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, computeStripeSignature(payload, secret, timestamp))
+}
+
+// VerifyStripeSignature 校验 Stripe-Signature 请求头，任一 v1 签名匹配且时间戳在容忍范围内即通过
+func VerifyStripeSignature(payload []byte, header string, secret string, now time.Time) error {
+	if secret == "" {
+		return errors.New("stripe webhook secret is not configured")
+	}
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return errors.New("invalid stripe signature header")
+	}
+	if math.Abs(float64(now.Unix()-timestamp)) > StripeSignatureTolerance.Seconds() {
+		return errors.New("stripe signature timestamp is outside the tolerance")
+	}
+	expected := computeStripeSignature(payload, secret, timestamp)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return errors.New("stripe signature mismatch")
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/setting"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyStripeSignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+	header := SignStripePayload(payload, "whsec_test", now.Unix())
+
+	if err := VerifyStripeSignature(payload, header, "whsec_test", now); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	// 任一 v1 签名匹配即通过，兼容轮换 webhook secret 期间的多个签名
+	if err := VerifyStripeSignature(payload, header+",v1=deadbeef", "whsec_test", now); err != nil {
+		t.Fatalf("expected signature list to pass, got %v", err)
+	}
+	if err := VerifyStripeSignature(payload, header, "whsec_other", now); err == nil {
+		t.Fatal("expected signature with another secret to fail")
+	}
+	if err := VerifyStripeSignature([]byte(`{"id":"evt_2"}`), header, "whsec_test", now); err == nil {
+		t.Fatal("expected tampered payload to fail")
+	}
+	if err := VerifyStripeSignature(payload, header, "whsec_test", now.Add(StripeSignatureTolerance+time.Minute)); err == nil {
+		t.Fatal("expected stale signature to fail")
+	}
+	if err := VerifyStripeSignature(payload, "v1=abc", "whsec_test", now); err == nil {
+		t.Fatal("expected header without timestamp to fail")
+	}
+}
+
+func TestMoneyToStripeAmount(t *testing.T) {
+	if amount := MoneyToStripeAmount(12.345, "usd"); amount != 1235 {
+		t.Fatalf("expected 1235 cents, got %d", amount)
+	}
+	if amount := MoneyToStripeAmount(500, "jpy"); amount != 500 {
+		t.Fatalf("expected 500 yen, got %d", amount)
+	}
+	if money := StripeAmountToMoney(1500, "usd"); money != 15 {
+		t.Fatalf("expected 15 dollars, got %v", money)
+	}
+}
+
+func TestStripeVerifyCallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting.StripeWebhookSecret = "whsec_test"
+	send := func(body string, signature string) (*PaymentEvent, error) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/stripe/webhook", strings.NewReader(body))
+		c.Request.Header.Set("Stripe-Signature", signature)
+		return (&StripeProvider{}).VerifyCallback(c)
+	}
+
+	paid := `{"id":"evt_1","type":"checkout.session.completed","data":{"object":{"id":"cs_1","client_reference_id":"T1","payment_intent":"pi_1","payment_status":"paid","amount_total":1500,"currency":"usd"}}}`
+	if _, err := send(paid, "t=1,v1=bad"); err == nil {
+		t.Fatal("expected invalid signature to be rejected")
+	}
+	event, err := send(paid, SignStripePayload([]byte(paid), "whsec_test", time.Now().Unix()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != PaymentEventPaid || event.TradeNo != "T1" || event.ExternalId != "pi_1" || event.Money != 15 {
+		t.Fatalf("unexpected paid event: %+v", event)
+	}
+
+	refunded := `{"id":"evt_2","type":"charge.refunded","data":{"object":{"id":"ch_1","payment_intent":"pi_1","amount":1500,"amount_refunded":750,"currency":"usd"}}}`
+	event, err = send(refunded, SignStripePayload([]byte(refunded), "whsec_test", time.Now().Unix()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != PaymentEventRefunded || event.Money != 7.5 || event.RefundId != "ch_1:750" {
+		t.Fatalf("unexpected refund event: %+v", event)
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hasActiveSubscription 根据用户缓存中的订阅到期时间判断是否需要查询订阅，没有生效中订阅的用户不产生额外的数据库查询
+func hasActiveSubscription(relayInfo *relaycommon.RelayInfo) bool {
+	return relayInfo.UserSubscriptionUntil > common.GetTimestamp()
+}
+
+// GetSubscriptionRemaining 返回本次请求可以使用的套餐额度
+func GetSubscriptionRemaining(relayInfo *relaycommon.RelayInfo) int {
+	return GetModelSubscriptionRemaining(relayInfo, relayInfo.OriginModelName)
+}
+
+// GetModelSubscriptionRemaining 返回指定模型可以使用的套餐额度，用于按操作计费的异步任务
+func GetModelSubscriptionRemaining(relayInfo *relaycommon.RelayInfo, modelName string) int {
+	if !hasActiveSubscription(relayInfo) {
+		return 0
+	}
+	remaining, err := model.GetSubscriptionRemaining(relayInfo.UserId, modelName, relayInfo.UsingGroup)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get subscription remaining of user %d: %s", relayInfo.UserId, err.Error()))
+		return 0
+	}
+	return remaining
+}
+
+// ReserveSubscriptionQuota 预扣费时从套餐中预留额度，返回预留的额度，剩余部分由调用方按余额预扣。
+// 预留的额度在结算时由 ConsumeSubscriptionQuota 多退少补，请求失败时由 ReleaseSubscriptionQuota 退回
+func ReserveSubscriptionQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, quota int) int {
+	if quota <= 0 || !hasActiveSubscription(relayInfo) {
+		return 0
+	}
+	reserved := consumeSubscriptionQuota(ctx, relayInfo, relayInfo.OriginModelName, quota)
+	relayInfo.SubscriptionReserved += reserved
+	return reserved
+}
+
+// ReleaseSubscriptionQuota 退回预扣费时预留的套餐额度
+func ReleaseSubscriptionQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) {
+	reserved := relayInfo.SubscriptionReserved
+	relayInfo.SubscriptionReserved = 0
+	refundSubscriptionQuota(ctx, relayInfo, reserved)
+}
+
+// ConsumeSubscriptionQuota 结算时优先从套餐中扣除本次消费，返回套餐抵扣的额度，剩余部分由调用方从余额中扣除。
+// 套餐抵扣的部分不计入令牌额度
+func ConsumeSubscriptionQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, quota int) int {
+	return ConsumeModelSubscriptionQuota(ctx, relayInfo, relayInfo.OriginModelName, quota)
+}
+
+// ConsumeModelSubscriptionQuota 与 ConsumeSubscriptionQuota 相同，但按指定模型匹配套餐。
+// 已预留的额度先用于抵扣，多余的部分退回套餐，不足的部分再从套餐中扣除
+func ConsumeModelSubscriptionQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string, quota int) int {
+	reserved := relayInfo.SubscriptionReserved
+	relayInfo.SubscriptionReserved = 0
+	consumed := min(reserved, max(quota, 0))
+	refundSubscriptionQuota(ctx, relayInfo, reserved-consumed)
+	if quota > consumed && hasActiveSubscription(relayInfo) {
+		consumed += consumeSubscriptionQuota(ctx, relayInfo, modelName, quota-consumed)
+	}
+	if consumed > 0 {
+		// 实时对话会多次结算，累计记录到上下文中
+		total := common.GetContextKeyInt(ctx, constant.ContextKeySubscriptionQuota) + consumed
+		common.SetContextKey(ctx, constant.ContextKeySubscriptionQuota, total)
+	}
+	return consumed
+}
+
+func consumeSubscriptionQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string, quota int) int {
+	consumed, err := model.ConsumeSubscriptionQuota(relayInfo.UserId, modelName, relayInfo.UsingGroup, quota)
+	if err != nil {
+		common.LogError(ctx, "error consuming subscription quota: "+err.Error())
+	}
+	return consumed
+}
+
+func refundSubscriptionQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, quota int) {
+	if quota <= 0 {
+		return
+	}
+	if _, err := model.RefundSubscriptionQuota(relayInfo.UserId, quota); err != nil {
+		common.LogError(ctx, "error refunding reserved subscription quota: "+err.Error())
+	}
+}
+
+func notifySubscriptionExpired(sub *model.UserSubscription, reason string) {
+	content := fmt.Sprintf("您订阅的套餐「%s」已到期，未能自动续费：%s", sub.PlanName, reason)
+	model.RecordLog(sub.UserId, model.LogTypeSystem, content)
+	user, err := model.GetUserById(sub.UserId, false)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get user %d: %s", sub.UserId, err.Error()))
+		return
+	}
+	err = NotifyUser(user.Id, user.Email, user.GetSetting(), dto.NewNotify(dto.NotifyTypeSubscription, "套餐已到期", content, nil))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to notify user %d: %s", user.Id, err.Error()))
+	}
+}
+
+// RenewDueSubscriptions 续费或终止所有已到期的订阅
+func RenewDueSubscriptions(now time.Time) {
+	for {
+		subs, err := model.GetDueSubscriptions(now.Unix(), 100)
+		if err != nil {
+			common.SysError("failed to get due subscriptions: " + err.Error())
+			return
+		}
+		if len(subs) == 0 {
+			return
+		}
+		for _, sub := range subs {
+			renewed, reason, err := model.RenewSubscription(sub, now.Unix())
+			if err != nil {
+				// 出错的订阅留到下一轮处理，避免本轮反复重试
+				common.SysError(fmt.Sprintf("failed to renew subscription %d: %s", sub.Id, err.Error()))
+				return
+			}
+			if !renewed && sub.AutoRenew {
+				notifySubscriptionExpired(sub, reason)
+			}
+		}
+	}
+}
+
+// StartSubscriptionRenewalJob 每分钟处理一次到期的订阅，只应在主节点调用
+func StartSubscriptionRenewalJob() {
+	for {
+		time.Sleep(time.Minute)
+		RenewDueSubscriptions(time.Now())
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func createTestSubscription(t *testing.T, userId int, allowance int) *model.UserSubscription {
+	t.Helper()
+	now := common.GetTimestamp()
+	sub := &model.UserSubscription{
+		UserId:      userId,
+		PlanName:    "test",
+		Allowance:   allowance,
+		Remaining:   allowance,
+		PeriodStart: now,
+		PeriodEnd:   now + 86400,
+		Status:      common.SubscriptionStatusActive,
+	}
+	if err := model.DB.Create(sub).Error; err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func getTestSubscriptionRemaining(t *testing.T, id int) int {
+	t.Helper()
+	var remaining int
+	if err := model.DB.Model(&model.UserSubscription{}).Where("id = ?", id).Select("remaining").Scan(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	return remaining
+}
+
+func newTestSubscriptionRequest(userId int) (*gin.Context, *relaycommon.RelayInfo) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	return c, &relaycommon.RelayInfo{
+		UserId:                userId,
+		OriginModelName:       "gpt-4o",
+		UsingGroup:            "default",
+		UserSubscriptionUntil: common.GetTimestamp() + 86400,
+	}
+}
+
+func TestSubscriptionReservationSettlement(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", 0)
+	sub := createTestSubscription(t, user.Id, 1000)
+
+	// 两个并发请求的预留额度之和不会超过套餐剩余额度
+	c1, info1 := newTestSubscriptionRequest(user.Id)
+	if reserved := ReserveSubscriptionQuota(c1, info1, 600); reserved != 600 {
+		t.Fatalf("expected 600 reserved, got %d", reserved)
+	}
+	c2, info2 := newTestSubscriptionRequest(user.Id)
+	if reserved := ReserveSubscriptionQuota(c2, info2, 600); reserved != 400 {
+		t.Fatalf("expected the remaining 400 reserved, got %d", reserved)
+	}
+	if remaining := getTestSubscriptionRemaining(t, sub.Id); remaining != 0 {
+		t.Fatalf("expected the allowance to be fully reserved, got %d", remaining)
+	}
+
+	// 实际消费少于预留时退回多余的部分
+	if consumed := ConsumeSubscriptionQuota(c1, info1, 200); consumed != 200 {
+		t.Fatalf("expected 200 settled from the reservation, got %d", consumed)
+	}
+	if remaining := getTestSubscriptionRemaining(t, sub.Id); remaining != 400 {
+		t.Fatalf("expected 400 back in the subscription, got %d", remaining)
+	}
+	// 实际消费多于预留时从套餐中补扣
+	if consumed := ConsumeSubscriptionQuota(c2, info2, 700); consumed != 700 {
+		t.Fatalf("expected 700 settled, got %d", consumed)
+	}
+	if remaining := getTestSubscriptionRemaining(t, sub.Id); remaining != 100 {
+		t.Fatalf("expected 100 left in the subscription, got %d", remaining)
+	}
+	if total := common.GetContextKeyInt(c2, constant.ContextKeySubscriptionQuota); total != 700 {
+		t.Fatalf("expected the settled subscription quota in the context, got %d", total)
+	}
+	if info1.SubscriptionReserved != 0 || info2.SubscriptionReserved != 0 {
+		t.Fatal("expected the reservations to be cleared after settlement")
+	}
+
+	// 请求失败时退回预留的额度
+	c3, info3 := newTestSubscriptionRequest(user.Id)
+	if reserved := ReserveSubscriptionQuota(c3, info3, 500); reserved != 100 {
+		t.Fatalf("expected 100 reserved, got %d", reserved)
+	}
+	ReleaseSubscriptionQuota(c3, info3)
+	if remaining := getTestSubscriptionRemaining(t, sub.Id); remaining != 100 {
+		t.Fatalf("expected the reservation to be released, got %d", remaining)
+	}
+}
+
+func TestSubscriptionReservationSkippedWithoutActiveSubscription(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "bob", 0)
+	sub := createTestSubscription(t, user.Id, 1000)
+	c, info := newTestSubscriptionRequest(user.Id)
+	info.UserSubscriptionUntil = 0
+	if reserved := ReserveSubscriptionQuota(c, info, 500); reserved != 0 {
+		t.Fatalf("expected no reservation without an active subscription, got %d", reserved)
+	}
+	if remaining := getTestSubscriptionRemaining(t, sub.Id); remaining != 1000 {
+		t.Fatalf("expected the subscription untouched, got %d", remaining)
+	}
+}
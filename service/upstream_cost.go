@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/system_setting"
+)
+
+// CheckChannelBalanceDrift 比较两次余额查询之间的余额减少量与日志中计算的上游成本，偏差超过阈值时通知管理员。
+// 余额增加（充值）或渠道未配置上游成本比例时跳过
+func CheckChannelBalanceDrift(channel *model.Channel, prevBalance float64, prevUpdatedTime int64, balance float64) {
+	settings := system_setting.GetUpstreamCostSettings()
+	if !settings.DriftAlertEnabled || !common.LogConsumeEnabled || prevUpdatedTime == 0 {
+		return
+	}
+	setting := channel.GetSetting()
+	if setting.UpstreamCostRatio <= 0 && len(setting.UpstreamModelCostRatio) == 0 {
+		return
+	}
+	rate := setting.BalanceUSDRate
+	if rate <= 0 {
+		rate = 1
+	}
+	spent := (prevBalance - balance) * rate
+	if spent < 0 {
+		return
+	}
+	cost, err := model.SumChannelUpstreamCost(channel.Id, prevUpdatedTime, common.GetTimestamp())
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to sum upstream cost of channel %d: %s", channel.Id, err.Error()))
+		return
+	}
+	computed := float64(cost) / common.QuotaPerUnit
+	drift := spent - computed
+	if math.Abs(drift) < settings.DriftMinAmount {
+		return
+	}
+	if computed > 0 && math.Abs(drift) <= computed*settings.DriftThreshold {
+		return
+	}
+	subject := fmt.Sprintf("通道「%s」（#%d）余额变化与计算成本不符", channel.Name, channel.Id)
+	content := fmt.Sprintf("通道「%s」（#%d）自上次更新余额以来，上游余额减少 $%.4f，根据日志计算的上游成本为 $%.4f，偏差 $%.4f，请检查上游成本比例配置或上游账单",
+		channel.Name, channel.Id, spent, computed, drift)
+	common.SysLog(content)
+	NotifyRootUser(fmt.Sprintf("channel_balance_drift_%d", channel.Id), subject, content)
+}
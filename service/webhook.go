@@ -19,6 +19,7 @@ type WebhookPayload struct {
 	Title     string        `json:"title"`
 	Content   string        `json:"content"`
 	Values    []interface{} `json:"values,omitempty"`
+	Data      interface{}   `json:"data,omitempty"`
 	Timestamp int64         `json:"timestamp"`
 }
 
@@ -43,6 +44,7 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		Title:     data.Title,
 		Content:   content,
 		Values:    data.Values,
+		Data:      data.Data,
 		Timestamp: time.Now().Unix(),
 	}
 
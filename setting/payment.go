@@ -9,6 +9,15 @@ var EpayKey = ""
 var Price = 7.3
 var MinTopUp = 1
 
+// Stripe 支付设置，StripeApiAddress 可改为本地模拟服务的地址用于测试
+var StripeApiAddress = "https://api.stripe.com"
+var StripeSecretKey = ""
+var StripeWebhookSecret = ""
+var StripeCurrency = "usd"
+
+// StripeUnitPrice 每美金额度对应的 Stripe 收款金额，单位为 StripeCurrency
+var StripeUnitPrice = 1.0
+
 var PayMethods = []map[string]string{
 	{
 		"name":  "支付宝",
@@ -299,6 +299,11 @@ func InitRatioSettings() {
 	imageRatioMap = defaultImageRatio
 	imageRatioMapMutex.Unlock()
 
+	// initialize modelTierRatioMap
+	modelTierRatioMapMutex.Lock()
+	modelTierRatioMap = defaultModelTierRatio
+	modelTierRatioMapMutex.Unlock()
+
 }
 
 func GetModelPriceMap() map[string]float64 {
@@ -0,0 +1,278 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/setting/config"
+	"strings"
+	"time"
+)
+
+// OffPeakRule 每天固定时段的价格倍率，EndTime 早于 StartTime 时表示跨越零点
+type OffPeakRule struct {
+	Name       string  `json:"name"`
+	StartTime  string  `json:"start_time"` // HH:MM
+	EndTime    string  `json:"end_time"`   // HH:MM
+	Multiplier float64 `json:"multiplier"`
+	// Models 与 Groups 为空时对所有模型、分组生效，模型名称支持以 * 结尾的前缀匹配
+	Models []string `json:"models"`
+	Groups []string `json:"groups"`
+}
+
+// PricePromotion 管理员安排的限时促销，生效时间为 [StartTimestamp, EndTimestamp)
+type PricePromotion struct {
+	Name           string   `json:"name"`
+	StartTimestamp int64    `json:"start_timestamp"`
+	EndTimestamp   int64    `json:"end_timestamp"`
+	Multiplier     float64  `json:"multiplier"`
+	Models         []string `json:"models"`
+	Groups         []string `json:"groups"`
+}
+
+type PriceScheduleSettings struct {
+	Enabled bool `json:"enabled"`
+	// Timezone 时段规则使用的时区，例如 Asia/Shanghai，留空使用服务器时区
+	Timezone   string           `json:"timezone"`
+	OffPeak    []OffPeakRule    `json:"off_peak"`
+	Promotions []PricePromotion `json:"promotions"`
+}
+
+// 默认配置
+var priceScheduleSettings = PriceScheduleSettings{
+	OffPeak:    []OffPeakRule{},
+	Promotions: []PricePromotion{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("price_schedule", &priceScheduleSettings)
+}
+
+func GetPriceScheduleSettings() *PriceScheduleSettings {
+	return &priceScheduleSettings
+}
+
+// CheckPriceScheduleOffPeak 校验时段规则的 JSON 配置
+func CheckPriceScheduleOffPeak(jsonStr string) error {
+	rules := make([]OffPeakRule, 0)
+	if err := json.Unmarshal([]byte(jsonStr), &rules); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("时段规则名称不能为空")
+		}
+		if rule.Multiplier < 0 {
+			return fmt.Errorf("时段规则 %s 的倍率不能为负数", rule.Name)
+		}
+		if _, err := parseClock(rule.StartTime); err != nil {
+			return fmt.Errorf("时段规则 %s 的开始时间无效：%s", rule.Name, err.Error())
+		}
+		if _, err := parseClock(rule.EndTime); err != nil {
+			return fmt.Errorf("时段规则 %s 的结束时间无效：%s", rule.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// CheckPricePromotions 校验促销的 JSON 配置
+func CheckPricePromotions(jsonStr string) error {
+	promotions := make([]PricePromotion, 0)
+	if err := json.Unmarshal([]byte(jsonStr), &promotions); err != nil {
+		return err
+	}
+	for _, p := range promotions {
+		if p.Name == "" {
+			return fmt.Errorf("促销名称不能为空")
+		}
+		if p.Multiplier < 0 {
+			return fmt.Errorf("促销 %s 的倍率不能为负数", p.Name)
+		}
+		if p.EndTimestamp <= p.StartTimestamp {
+			return fmt.Errorf("促销 %s 的结束时间必须晚于开始时间", p.Name)
+		}
+	}
+	return nil
+}
+
+// CheckPriceScheduleTimezone 校验时区名称，留空表示使用服务器时区
+func CheckPriceScheduleTimezone(timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("无效的时区 %s", timezone)
+	}
+	return nil
+}
+
+// PriceScheduleResult 请求适用的时段与促销倍率，Rules 为生效规则的名称，用于在日志中解释计费
+type PriceScheduleResult struct {
+	Ratio float64  `json:"ratio"`
+	Rules []string `json:"rules"`
+}
+
+func priceScheduleLocation(settings *PriceScheduleSettings) *time.Location {
+	if settings.Timezone != "" {
+		if loc, err := time.LoadLocation(settings.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+func matchPriceScheduleTarget(models []string, groups []string, modelName string, group string) bool {
+	if len(groups) > 0 {
+		matched := false
+		for _, g := range groups {
+			if g == group {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(models) == 0 {
+		return true
+	}
+	for _, m := range models {
+		if m == modelName {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(m, "*"); ok && strings.HasPrefix(modelName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// offPeakWindow 返回包含 now 的时段，或 now 之后最近的一个时段
+func offPeakWindow(rule OffPeakRule, now time.Time) (time.Time, time.Time, error) {
+	start, err := parseClock(rule.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parseClock(rule.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	duration := time.Duration((end-start+24*60)%(24*60)) * time.Minute
+	if duration == 0 {
+		duration = 24 * time.Hour
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// 昨天开始的时段可能跨过零点仍在生效
+	for _, day := range []int{-1, 0, 1} {
+		windowStart := today.AddDate(0, 0, day).Add(time.Duration(start) * time.Minute)
+		windowEnd := windowStart.Add(duration)
+		if now.Before(windowEnd) {
+			return windowStart, windowEnd, nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("no window found for %s", rule.Name)
+}
+
+// GetPriceSchedule 返回模型在某个分组、某个时间点适用的时段倍率：
+// 时段规则取第一个命中的规则，促销取命中规则中倍率最低的一个，两者相乘
+func GetPriceSchedule(modelName string, group string, now time.Time) PriceScheduleResult {
+	result := PriceScheduleResult{Ratio: 1}
+	settings := GetPriceScheduleSettings()
+	if !settings.Enabled {
+		return result
+	}
+	now = now.In(priceScheduleLocation(settings))
+	for _, rule := range settings.OffPeak {
+		if rule.Multiplier < 0 || !matchPriceScheduleTarget(rule.Models, rule.Groups, modelName, group) {
+			continue
+		}
+		start, _, err := offPeakWindow(rule, now)
+		if err != nil || now.Before(start) {
+			continue
+		}
+		result.Ratio *= rule.Multiplier
+		result.Rules = append(result.Rules, rule.Name)
+		break
+	}
+	var promotion *PricePromotion
+	for i, p := range settings.Promotions {
+		if p.Multiplier < 0 || now.Unix() < p.StartTimestamp || now.Unix() >= p.EndTimestamp {
+			continue
+		}
+		if !matchPriceScheduleTarget(p.Models, p.Groups, modelName, group) {
+			continue
+		}
+		if promotion == nil || p.Multiplier < promotion.Multiplier {
+			promotion = &settings.Promotions[i]
+		}
+	}
+	if promotion != nil {
+		result.Ratio *= promotion.Multiplier
+		result.Rules = append(result.Rules, promotion.Name)
+	}
+	return result
+}
+
+// PriceScheduleStatus 价格时段的当前状态，Countdown 为生效中的规则距离结束、未开始的规则距离开始的秒数
+type PriceScheduleStatus struct {
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`
+	Multiplier     float64  `json:"multiplier"`
+	Models         []string `json:"models"`
+	Groups         []string `json:"groups"`
+	Active         bool     `json:"active"`
+	StartTimestamp int64    `json:"start_timestamp"`
+	EndTimestamp   int64    `json:"end_timestamp"`
+	Countdown      int64    `json:"countdown"`
+}
+
+// GetPriceScheduleStatus 列出时段规则最近的一个时段与尚未结束的促销，供价格页面展示
+func GetPriceScheduleStatus(now time.Time) []PriceScheduleStatus {
+	settings := GetPriceScheduleSettings()
+	statuses := make([]PriceScheduleStatus, 0)
+	if !settings.Enabled {
+		return statuses
+	}
+	now = now.In(priceScheduleLocation(settings))
+	newStatus := func(name string, typ string, multiplier float64, models []string, groups []string, start int64, end int64) PriceScheduleStatus {
+		status := PriceScheduleStatus{
+			Name:           name,
+			Type:           typ,
+			Multiplier:     multiplier,
+			Models:         models,
+			Groups:         groups,
+			Active:         now.Unix() >= start,
+			StartTimestamp: start,
+			EndTimestamp:   end,
+		}
+		if status.Active {
+			status.Countdown = end - now.Unix()
+		} else {
+			status.Countdown = start - now.Unix()
+		}
+		return status
+	}
+	for _, rule := range settings.OffPeak {
+		start, end, err := offPeakWindow(rule, now)
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, newStatus(rule.Name, "off_peak", rule.Multiplier, rule.Models, rule.Groups, start.Unix(), end.Unix()))
+	}
+	for _, p := range settings.Promotions {
+		if now.Unix() >= p.EndTimestamp {
+			continue
+		}
+		statuses = append(statuses, newStatus(p.Name, "promotion", p.Multiplier, p.Models, p.Groups, p.StartTimestamp, p.EndTimestamp))
+	}
+	return statuses
+}
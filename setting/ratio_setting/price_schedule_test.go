@@ -0,0 +1,88 @@
+package ratio_setting
+
+import (
+	"testing"
+	"time"
+)
+
+func withPriceSchedule(t *testing.T, settings PriceScheduleSettings) {
+	t.Helper()
+	previous := priceScheduleSettings
+	priceScheduleSettings = settings
+	t.Cleanup(func() { priceScheduleSettings = previous })
+}
+
+func TestGetPriceScheduleOffPeak(t *testing.T) {
+	withPriceSchedule(t, PriceScheduleSettings{
+		Enabled:  true,
+		Timezone: "UTC",
+		OffPeak: []OffPeakRule{
+			{Name: "night", StartTime: "22:00", EndTime: "06:00", Multiplier: 0.5, Models: []string{"gpt-4o*"}},
+			{Name: "lunch", StartTime: "12:00", EndTime: "13:00", Multiplier: 0.8, Groups: []string{"vip"}},
+		},
+	})
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 5, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	// 跨越零点的时段在零点前后都生效
+	for _, now := range []time.Time{at(23, 0), at(2, 0), at(5, 59)} {
+		if result := GetPriceSchedule("gpt-4o-mini", "default", now); result.Ratio != 0.5 || len(result.Rules) != 1 {
+			t.Fatalf("expected the night rule at %s, got %+v", now, result)
+		}
+	}
+	if result := GetPriceSchedule("gpt-4o-mini", "default", at(6, 0)); result.Ratio != 1 {
+		t.Fatalf("expected no rule after the window ends, got %+v", result)
+	}
+	if result := GetPriceSchedule("claude-3", "default", at(23, 0)); result.Ratio != 1 {
+		t.Fatalf("expected the model filter to exclude claude-3, got %+v", result)
+	}
+	if result := GetPriceSchedule("claude-3", "vip", at(12, 30)); result.Ratio != 0.8 {
+		t.Fatalf("expected the lunch rule for vip, got %+v", result)
+	}
+	if result := GetPriceSchedule("claude-3", "default", at(12, 30)); result.Ratio != 1 {
+		t.Fatalf("expected the group filter to exclude default, got %+v", result)
+	}
+}
+
+func TestGetPriceSchedulePromotions(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	withPriceSchedule(t, PriceScheduleSettings{
+		Enabled: true,
+		OffPeak: []OffPeakRule{{Name: "all day", StartTime: "00:00", EndTime: "00:00", Multiplier: 0.5}},
+		Promotions: []PricePromotion{
+			{Name: "launch", StartTimestamp: start.Unix(), EndTimestamp: start.Add(48 * time.Hour).Unix(), Multiplier: 0.8},
+			{Name: "flash", StartTimestamp: start.Unix(), EndTimestamp: start.Add(time.Hour).Unix(), Multiplier: 0.6},
+		},
+	})
+
+	// 促销取倍率最低的一个，并与时段倍率相乘
+	result := GetPriceSchedule("gpt-4o", "default", start.Add(30*time.Minute))
+	if result.Ratio != 0.3 || len(result.Rules) != 2 || result.Rules[1] != "flash" {
+		t.Fatalf("expected all day x flash, got %+v", result)
+	}
+	result = GetPriceSchedule("gpt-4o", "default", start.Add(time.Hour))
+	if result.Ratio != 0.4 || result.Rules[1] != "launch" {
+		t.Fatalf("expected the flash promotion to end exclusively, got %+v", result)
+	}
+	if result = GetPriceSchedule("gpt-4o", "default", start.Add(-time.Second)); result.Ratio != 0.5 {
+		t.Fatalf("expected no promotion before it starts, got %+v", result)
+	}
+
+	priceScheduleSettings.Enabled = false
+	if result = GetPriceSchedule("gpt-4o", "default", start.Add(30*time.Minute)); result.Ratio != 1 || len(result.Rules) != 0 {
+		t.Fatalf("expected a disabled schedule to have no effect, got %+v", result)
+	}
+}
+
+func TestCheckPriceScheduleConfig(t *testing.T) {
+	if err := CheckPriceScheduleOffPeak(`[{"name":"night","start_time":"25:00","end_time":"06:00","multiplier":0.5}]`); err == nil {
+		t.Fatal("expected an invalid start time to be rejected")
+	}
+	if err := CheckPricePromotions(`[{"name":"p","start_timestamp":100,"end_timestamp":100,"multiplier":0.5}]`); err == nil {
+		t.Fatal("expected an empty promotion window to be rejected")
+	}
+	if err := CheckPriceScheduleTimezone("Mars/Olympus"); err == nil {
+		t.Fatal("expected an unknown timezone to be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"sort"
+	"sync"
+)
+
+// ModelPriceTier 提示词超过 MinPromptTokens 时整个请求改用的倍率，
+// CompletionRatio 与 CacheRatio 为 0 时沿用模型原本的倍率
+type ModelPriceTier struct {
+	MinPromptTokens int     `json:"min_prompt_tokens"`
+	ModelRatio      float64 `json:"model_ratio"`
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+	CacheRatio      float64 `json:"cache_ratio,omitempty"`
+}
+
+var defaultModelTierRatio = map[string][]ModelPriceTier{
+	"gemini-2.5-pro":           {{MinPromptTokens: 200000, ModelRatio: 1.25, CompletionRatio: 6}},
+	"claude-sonnet-4-20250514": {{MinPromptTokens: 200000, ModelRatio: 3, CompletionRatio: 3.75}},
+}
+
+var (
+	modelTierRatioMap      map[string][]ModelPriceTier = nil
+	modelTierRatioMapMutex                             = sync.RWMutex{}
+)
+
+func ModelTierRatio2JSONString() string {
+	modelTierRatioMapMutex.RLock()
+	defer modelTierRatioMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(modelTierRatioMap)
+	if err != nil {
+		common.SysError("error marshalling model tier ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// UpdateModelTierRatioByJSONString 更新阶梯倍率，每个模型的阶梯按 MinPromptTokens 升序保存
+func UpdateModelTierRatioByJSONString(jsonStr string) error {
+	tiers := make(map[string][]ModelPriceTier)
+	if err := json.Unmarshal([]byte(jsonStr), &tiers); err != nil {
+		return err
+	}
+	for name, modelTiers := range tiers {
+		for _, tier := range modelTiers {
+			if tier.MinPromptTokens <= 0 || tier.ModelRatio < 0 || tier.CompletionRatio < 0 || tier.CacheRatio < 0 {
+				return fmt.Errorf("模型 %s 的阶梯倍率无效：min_prompt_tokens 必须大于 0，倍率不能为负数", name)
+			}
+		}
+		sort.Slice(modelTiers, func(i, j int) bool {
+			return modelTiers[i].MinPromptTokens < modelTiers[j].MinPromptTokens
+		})
+	}
+	modelTierRatioMapMutex.Lock()
+	modelTierRatioMap = tiers
+	modelTierRatioMapMutex.Unlock()
+	return nil
+}
+
+func GetModelTierRatios(name string) []ModelPriceTier {
+	modelTierRatioMapMutex.RLock()
+	defer modelTierRatioMapMutex.RUnlock()
+	tiers, ok := modelTierRatioMap[name]
+	if !ok {
+		// 带思考预算的 gemini-2.5-pro 模型沿用 gemini-2.5-pro 的阶梯
+		tiers = modelTierRatioMap[handleThinkingBudgetModel(name, "gemini-2.5-pro", "gemini-2.5-pro")]
+	}
+	return tiers
+}
+
+// GetModelPriceTier 返回提示词 token 数适用的最高阶梯，没有超过任何阈值时返回 false
+func GetModelPriceTier(name string, promptTokens int) (*ModelPriceTier, bool) {
+	tiers := GetModelTierRatios(name)
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if promptTokens > tiers[i].MinPromptTokens {
+			tier := tiers[i]
+			return &tier, true
+		}
+	}
+	return nil, false
+}
+
+func GetModelTierRatioCopy() map[string][]ModelPriceTier {
+	modelTierRatioMapMutex.RLock()
+	defer modelTierRatioMapMutex.RUnlock()
+	copyMap := make(map[string][]ModelPriceTier, len(modelTierRatioMap))
+	for k, v := range modelTierRatioMap {
+		copyMap[k] = append([]ModelPriceTier(nil), v...)
+	}
+	return copyMap
+}
@@ -0,0 +1,44 @@
+package ratio_setting
+
+import "testing"
+
+func withModelTierRatio(t *testing.T, jsonStr string) {
+	t.Helper()
+	previous := ModelTierRatio2JSONString()
+	if err := UpdateModelTierRatioByJSONString(jsonStr); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = UpdateModelTierRatioByJSONString(previous) })
+}
+
+func TestGetModelPriceTier(t *testing.T) {
+	// 阶梯按 min_prompt_tokens 排序后选择超过的最高阈值
+	withModelTierRatio(t, `{"test-model":[{"min_prompt_tokens":200000,"model_ratio":3},{"min_prompt_tokens":32000,"model_ratio":2,"completion_ratio":5}]}`)
+
+	if _, ok := GetModelPriceTier("test-model", 32000); ok {
+		t.Fatal("expected no tier at exactly the threshold")
+	}
+	tier, ok := GetModelPriceTier("test-model", 32001)
+	if !ok || tier.ModelRatio != 2 || tier.CompletionRatio != 5 {
+		t.Fatalf("expected the 32k tier, got %+v", tier)
+	}
+	tier, ok = GetModelPriceTier("test-model", 300000)
+	if !ok || tier.ModelRatio != 3 {
+		t.Fatalf("expected the 200k tier, got %+v", tier)
+	}
+	if _, ok = GetModelPriceTier("other-model", 300000); ok {
+		t.Fatal("expected no tier for a model without tiers")
+	}
+}
+
+func TestUpdateModelTierRatioRejectsInvalidTiers(t *testing.T) {
+	for _, jsonStr := range []string{
+		`{"m":[{"min_prompt_tokens":0,"model_ratio":1}]}`,
+		`{"m":[{"min_prompt_tokens":100,"model_ratio":-1}]}`,
+		`{"m":[{"min_prompt_tokens":100,"model_ratio":1,"cache_ratio":-0.5}]}`,
+	} {
+		if err := UpdateModelTierRatioByJSONString(jsonStr); err == nil {
+			t.Errorf("expected %s to be rejected", jsonStr)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type CreditSettings struct {
+	// Enabled 开启后用户可以申请账期额度，申请需要管理员审批
+	Enabled bool `json:"enabled"`
+	// TermsDays 默认账期天数，账单在账单期结束后 TermsDays 天到期
+	TermsDays int `json:"terms_days"`
+	// RunHour 每月 1 日生成上月结算单的时间（按账单时区），错过时在之后补齐
+	RunHour int `json:"run_hour"`
+	// ThrottleAfterDays 结算单逾期超过该天数后限制请求频率，ThrottleRequestsPerMinute 为限制后每分钟的最大请求数
+	ThrottleAfterDays         int `json:"throttle_after_days"`
+	ThrottleRequestsPerMinute int `json:"throttle_requests_per_minute"`
+	// SuspendAfterDays 结算单逾期超过该天数后暂停账户的 API 调用
+	SuspendAfterDays int `json:"suspend_after_days"`
+}
+
+// 默认配置
+var defaultCreditSettings = CreditSettings{
+	TermsDays:                 30,
+	RunHour:                   3,
+	ThrottleAfterDays:         0,
+	ThrottleRequestsPerMinute: 10,
+	SuspendAfterDays:          15,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("credit", &defaultCreditSettings)
+}
+
+func GetCreditSettings() *CreditSettings {
+	return &defaultCreditSettings
+}
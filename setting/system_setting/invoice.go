@@ -0,0 +1,60 @@
+package system_setting
+
+import (
+	"one-api/setting/config"
+	"time"
+)
+
+type InvoiceSettings struct {
+	// Enabled 开启后，每月 1 日 RunHour 点为上月有消费或充值的用户生成账单
+	Enabled bool `json:"enabled"`
+	RunHour int  `json:"run_hour"`
+	// Timezone 账单期按该时区（IANA 名称，如 Asia/Shanghai）的自然月划分，RunHour 也按该时区计算
+	Timezone string `json:"timezone"`
+	// 开票方信息，显示在账单抬头
+	CompanyName    string `json:"company_name"`
+	CompanyAddress string `json:"company_address"`
+	CompanyTaxId   string `json:"company_tax_id"`
+	CompanyEmail   string `json:"company_email"`
+	// TaxName 税项名称，如 VAT、GST
+	TaxName string `json:"tax_name"`
+	// TaxRate 税率（百分比），按账单期内的消费金额计算，0 表示不计税
+	TaxRate float64 `json:"tax_rate"`
+	// 账单编号为 NumberPrefix + 年月 + 序号，序号从 NumberStart 开始全局递增，不足 NumberDigits 位时补零
+	NumberPrefix string `json:"number_prefix"`
+	NumberStart  int    `json:"number_start"`
+	NumberDigits int    `json:"number_digits"`
+	// Footer 账单底部的备注
+	Footer string `json:"footer"`
+}
+
+// 默认配置
+var defaultInvoiceSettings = InvoiceSettings{
+	RunHour:      2,
+	Timezone:     "UTC",
+	TaxName:      "Tax",
+	NumberPrefix: "INV-",
+	NumberStart:  1,
+	NumberDigits: 6,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("invoice", &defaultInvoiceSettings)
+}
+
+func GetInvoiceSettings() *InvoiceSettings {
+	return &defaultInvoiceSettings
+}
+
+// Location 返回账单时区，未设置或无效时使用 UTC
+func (s *InvoiceSettings) Location() *time.Location {
+	if s.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
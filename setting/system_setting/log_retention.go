@@ -0,0 +1,44 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type LogRetentionSettings struct {
+	// Enabled 开启后每天在 RunHour 点自动删除超过保存天数的日志，仅在主节点运行
+	Enabled bool `json:"enabled"`
+	RunHour int  `json:"run_hour"`
+	// 各类型日志的保存天数，0 表示永久保存
+	ConsumeDays int `json:"consume_days"`
+	ErrorDays   int `json:"error_days"`
+	TopupDays   int `json:"topup_days"`
+	ManageDays  int `json:"manage_days"`
+	SystemDays  int `json:"system_days"`
+	// ArchiveEnabled 删除前先导出为 gzip 压缩的 JSONL 文件，ArchiveTarget 为 local 或 s3
+	ArchiveEnabled bool   `json:"archive_enabled"`
+	ArchiveTarget  string `json:"archive_target"`
+	ArchiveDir     string `json:"archive_dir"`
+	// S3 兼容存储，Endpoint 为空时使用 AWS 官方地址
+	S3Endpoint  string `json:"s3_endpoint"`
+	S3Region    string `json:"s3_region"`
+	S3Bucket    string `json:"s3_bucket"`
+	S3Prefix    string `json:"s3_prefix"`
+	S3AccessKey string `json:"s3_access_key"`
+	S3SecretKey string `json:"s3_secret_key"`
+	S3PathStyle bool   `json:"s3_path_style"`
+}
+
+// 默认配置
+var defaultLogRetentionSettings = LogRetentionSettings{
+	RunHour:       3,
+	ArchiveTarget: "local",
+	ArchiveDir:    "./logs/archive",
+	S3Region:      "us-east-1",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_retention", &defaultLogRetentionSettings)
+}
+
+func GetLogRetentionSettings() *LogRetentionSettings {
+	return &defaultLogRetentionSettings
+}
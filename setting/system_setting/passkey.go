@@ -0,0 +1,24 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type PasskeySettings struct {
+	Enabled bool `json:"enabled"`
+	// RPID 依赖方 ID，通常为站点域名，为空时从服务器地址中解析
+	RPID          string `json:"rp_id"`
+	RPDisplayName string `json:"rp_display_name"`
+	// Origins 允许的来源，多个以逗号分隔，为空时使用服务器地址
+	Origins string `json:"origins"`
+}
+
+// 默认配置
+var defaultPasskeySettings = PasskeySettings{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("passkey", &defaultPasskeySettings)
+}
+
+func GetPasskeySettings() *PasskeySettings {
+	return &defaultPasskeySettings
+}
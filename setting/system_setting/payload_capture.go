@@ -0,0 +1,41 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type PayloadCaptureSettings struct {
+	// Enabled 开启后，命中以下用户、令牌或分组的请求会按采样率保存完整的请求与响应内容
+	Enabled    bool     `json:"enabled"`
+	UserIds    []int    `json:"user_ids"`
+	TokenIds   []int    `json:"token_ids"`
+	Groups     []string `json:"groups"`
+	SampleRate float64  `json:"sample_rate"`
+	// MaxBodyBytes 请求与响应各自保存的最大字节数，超出部分截断
+	MaxBodyBytes int `json:"max_body_bytes"`
+	// RedactPatterns 正则表达式，匹配内容替换为 [REDACTED]
+	RedactPatterns []string `json:"redact_patterns"`
+	// RedactJSONPaths 以点分隔的 JSON 路径，* 匹配任意数组元素或字段，例如 messages.*.content
+	RedactJSONPaths []string `json:"redact_json_paths"`
+	// RetentionDays 保存天数，到期后自动删除
+	RetentionDays int `json:"retention_days"`
+}
+
+// 默认配置
+var defaultPayloadCaptureSettings = PayloadCaptureSettings{
+	UserIds:         []int{},
+	TokenIds:        []int{},
+	Groups:          []string{},
+	SampleRate:      1,
+	MaxBodyBytes:    32 * 1024,
+	RedactPatterns:  []string{},
+	RedactJSONPaths: []string{},
+	RetentionDays:   7,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("payload_capture", &defaultPayloadCaptureSettings)
+}
+
+func GetPayloadCaptureSettings() *PayloadCaptureSettings {
+	return &defaultPayloadCaptureSettings
+}
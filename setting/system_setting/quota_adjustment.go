@@ -0,0 +1,20 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type QuotaAdjustmentSettings struct {
+	// DailyCapPerAdmin 每个管理员每天调整额度（增加、扣除与退款）的合计上限，0 表示不限制
+	DailyCapPerAdmin int `json:"daily_cap_per_admin"`
+}
+
+// 默认配置
+var defaultQuotaAdjustmentSettings = QuotaAdjustmentSettings{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("quota_adjustment", &defaultQuotaAdjustmentSettings)
+}
+
+func GetQuotaAdjustmentSettings() *QuotaAdjustmentSettings {
+	return &defaultQuotaAdjustmentSettings
+}
@@ -0,0 +1,25 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type QuotaLedgerSettings struct {
+	// ReconcileIntervalMinutes 核对用户余额与额度账本的间隔（分钟），0 表示不定期核对
+	ReconcileIntervalMinutes int `json:"reconcile_interval_minutes"`
+	// NotifyRoot 发现余额与账本不一致时通知超级管理员
+	NotifyRoot bool `json:"notify_root"`
+}
+
+// 默认配置
+var defaultQuotaLedgerSettings = QuotaLedgerSettings{
+	ReconcileIntervalMinutes: 60,
+	NotifyRoot:               true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("quota_ledger", &defaultQuotaLedgerSettings)
+}
+
+func GetQuotaLedgerSettings() *QuotaLedgerSettings {
+	return &defaultQuotaLedgerSettings
+}
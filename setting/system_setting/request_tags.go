@@ -0,0 +1,28 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type RequestTagSettings struct {
+	// AllowedKeys 允许的标签键，为空时不记录任何标签
+	AllowedKeys []string `json:"allowed_keys"`
+	// MaxTags 单个请求最多携带的标签数
+	MaxTags int `json:"max_tags"`
+	// MaxValueLength 标签值的最大长度
+	MaxValueLength int `json:"max_value_length"`
+}
+
+// 默认配置
+var defaultRequestTagSettings = RequestTagSettings{
+	AllowedKeys:    []string{},
+	MaxTags:        5,
+	MaxValueLength: 64,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("request_tags", &defaultRequestTagSettings)
+}
+
+func GetRequestTagSettings() *RequestTagSettings {
+	return &defaultRequestTagSettings
+}
@@ -0,0 +1,45 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type SpendAnomalySettings struct {
+	// Enabled 开启后，定期将令牌与用户最近一小时的消费、请求数与自身的历史基线比较
+	Enabled bool `json:"enabled"`
+	// BaselineHours 基线的统计时长（小时），基线为该时段内的平均每小时用量
+	BaselineHours int `json:"baseline_hours"`
+	// SpikeMultiplier 最近一小时超过基线的倍数时视为异常
+	SpikeMultiplier float64 `json:"spike_multiplier"`
+	// MinSpend 最近一小时消费低于该金额（美元）时不判断消费异常，避免低用量时误报
+	MinSpend float64 `json:"min_spend"`
+	// MinRequests 最近一小时请求数低于该值时不判断请求数异常
+	MinRequests int `json:"min_requests"`
+	// DetectNewModel 令牌开始使用基线中未出现过的模型时视为异常
+	DetectNewModel bool `json:"detect_new_model"`
+	// DetectNewIp 令牌开始从基线中未出现过的 IP 请求时视为异常，依赖用户开启 IP 记录
+	DetectNewIp bool `json:"detect_new_ip"`
+	// NotifyRoot 发现异常时通知管理员
+	NotifyRoot bool `json:"notify_root"`
+	// NotifyUser 发现异常时通知用户
+	NotifyUser bool `json:"notify_user"`
+	// SuspendToken 令牌消费或请求数异常时自动禁用该令牌
+	SuspendToken bool `json:"suspend_token"`
+}
+
+// 默认配置
+var defaultSpendAnomalySettings = SpendAnomalySettings{
+	BaselineHours:   168,
+	SpikeMultiplier: 10,
+	MinSpend:        5,
+	MinRequests:     100,
+	DetectNewModel:  true,
+	NotifyRoot:      true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("spend_anomaly", &defaultSpendAnomalySettings)
+}
+
+func GetSpendAnomalySettings() *SpendAnomalySettings {
+	return &defaultSpendAnomalySettings
+}
@@ -0,0 +1,28 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type SpendReportSettings struct {
+	// Enabled 开启后，每天在 RunHour 点向订阅的用户发送用量报告，每周报告在周一发送，每月报告在 1 日发送
+	Enabled bool `json:"enabled"`
+	RunHour int  `json:"run_hour"`
+	// TopN 报告中列出的模型与令牌数量
+	TopN int `json:"top_n"`
+	// Template 报告内容模板（Go html/template），留空使用默认模板
+	Template string `json:"template"`
+}
+
+// 默认配置
+var defaultSpendReportSettings = SpendReportSettings{
+	RunHour: 8,
+	TopN:    5,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("spend_report", &defaultSpendReportSettings)
+}
+
+func GetSpendReportSettings() *SpendReportSettings {
+	return &defaultSpendReportSettings
+}
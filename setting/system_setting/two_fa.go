@@ -0,0 +1,22 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type TwoFASettings struct {
+	// RequireForAdmin 要求管理员和超级管理员必须启用两步验证后才能登录
+	RequireForAdmin bool `json:"require_for_admin"`
+	// Issuer 显示在身份验证器应用中的名称，为空时使用系统名称
+	Issuer string `json:"issuer"`
+}
+
+// 默认配置
+var defaultTwoFASettings = TwoFASettings{}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("two_fa", &defaultTwoFASettings)
+}
+
+func GetTwoFASettings() *TwoFASettings {
+	return &defaultTwoFASettings
+}
@@ -0,0 +1,27 @@
+package system_setting
+
+import "one-api/setting/config"
+
+type UpstreamCostSettings struct {
+	// DriftAlertEnabled 开启后，更新渠道余额时比较余额减少量与日志中计算的上游成本，偏差过大时通知管理员
+	DriftAlertEnabled bool `json:"drift_alert_enabled"`
+	// DriftThreshold 相对偏差阈值，例如 0.2 表示偏差超过计算成本的 20%
+	DriftThreshold float64 `json:"drift_threshold"`
+	// DriftMinAmount 偏差金额（美元）低于该值时不告警，避免小额波动
+	DriftMinAmount float64 `json:"drift_min_amount"`
+}
+
+// 默认配置
+var defaultUpstreamCostSettings = UpstreamCostSettings{
+	DriftThreshold: 0.2,
+	DriftMinAmount: 1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("upstream_cost", &defaultUpstreamCostSettings)
+}
+
+func GetUpstreamCostSettings() *UpstreamCostSettings {
+	return &defaultUpstreamCostSettings
+}
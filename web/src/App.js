@@ -5,6 +5,7 @@ import User from './pages/User';
 import { AuthRedirect, PrivateRoute } from './helpers';
 import RegisterForm from './components/auth/RegisterForm.js';
 import LoginForm from './components/auth/LoginForm.js';
+import TwoFAVerifyForm from './components/auth/TwoFAVerifyForm.js';
 import NotFound from './pages/NotFound';
 import Setting from './pages/Setting';
 import EditUser from './pages/User/EditUser';
@@ -143,6 +144,14 @@ function App() {
             </Suspense>
           }
         />
+        <Route
+          path='/login/2fa'
+          element={
+            <Suspense fallback={<Loading></Loading>} key={location.pathname}>
+              <TwoFAVerifyForm />
+            </Suspense>
+          }
+        />
         <Route
           path='/register'
           element={
@@ -12,7 +12,9 @@ import {
   setUserData,
   onGitHubOAuthClicked,
   onOIDCClicked,
-  onLinuxDOOAuthClicked
+  onLinuxDOOAuthClicked,
+  getPasskeyAssertion,
+  isPasskeySupported,
 } from '../../helpers/index.js';
 import Turnstile from 'react-turnstile';
 import {
@@ -27,7 +29,7 @@ import Title from '@douyinfe/semi-ui/lib/es/typography/title';
 import Text from '@douyinfe/semi-ui/lib/es/typography/text';
 import TelegramLoginButton from 'react-telegram-login';
 
-import { IconGithubLogo, IconMail, IconLock } from '@douyinfe/semi-icons';
+import { IconGithubLogo, IconMail, IconLock, IconKey } from '@douyinfe/semi-icons';
 import OIDCIcon from '../common/logo/OIDCIcon.js';
 import WeChatIcon from '../common/logo/WeChatIcon.js';
 import LinuxDoIcon from '../common/logo/LinuxDoIcon.js';
@@ -54,6 +56,7 @@ const LoginForm = () => {
   const [githubLoading, setGithubLoading] = useState(false);
   const [oidcLoading, setOidcLoading] = useState(false);
   const [linuxdoLoading, setLinuxdoLoading] = useState(false);
+  const [passkeyLoading, setPasskeyLoading] = useState(false);
   const [emailLoginLoading, setEmailLoginLoading] = useState(false);
   const [loginLoading, setLoginLoading] = useState(false);
   const [resetPasswordLoading, setResetPasswordLoading] = useState(false);
@@ -103,7 +106,10 @@ const LoginForm = () => {
         `/api/oauth/wechat?code=${inputs.wechat_verification_code}`,
       );
       const { success, message, data } = res.data;
-      if (success) {
+      if (success && data.require_2fa) {
+        setShowWeChatLoginModal(false);
+        navigate('/login/2fa', { state: data });
+      } else if (success) {
         userDispatch({ type: 'login', payload: data });
         localStorage.setItem('user', JSON.stringify(data));
         setUserData(data);
@@ -142,7 +148,9 @@ const LoginForm = () => {
           },
         );
         const { success, message, data } = res.data;
-        if (success) {
+        if (success && data.require_2fa) {
+          navigate('/login/2fa', { state: data });
+        } else if (success) {
           userDispatch({ type: 'login', payload: data });
           setUserData(data);
           updateAPI();
@@ -189,7 +197,9 @@ const LoginForm = () => {
     try {
       const res = await API.get(`/api/oauth/telegram/login`, { params });
       const { success, message, data } = res.data;
-      if (success) {
+      if (success && data.require_2fa) {
+        navigate('/login/2fa', { state: data });
+      } else if (success) {
         userDispatch({ type: 'login', payload: data });
         localStorage.setItem('user', JSON.stringify(data));
         showSuccess('登录成功！');
@@ -240,6 +250,58 @@ const LoginForm = () => {
     }
   };
 
+  // 使用通行密钥登录，无需输入用户名
+  const handlePasskeyLogin = async () => {
+    if (!isPasskeySupported()) {
+      showError(t('当前浏览器不支持通行密钥'));
+      return;
+    }
+    setPasskeyLoading(true);
+    try {
+      let res = await API.post('/api/user/passkey/login/begin');
+      if (!res.data.success) {
+        showError(res.data.message);
+        return;
+      }
+      const assertion = await getPasskeyAssertion(res.data.data);
+      res = await API.post('/api/user/passkey/login/finish', assertion);
+      const { success, message, data } = res.data;
+      if (success) {
+        userDispatch({ type: 'login', payload: data });
+        localStorage.setItem('user', JSON.stringify(data));
+        setUserData(data);
+        updateAPI();
+        showSuccess('登录成功！');
+        navigate('/console');
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(t('通行密钥验证失败或已取消'));
+    } finally {
+      setPasskeyLoading(false);
+    }
+  };
+
+  const renderPasskeyButton = () => {
+    if (!status.passkey_login) {
+      return null;
+    }
+    return (
+      <Button
+        theme='outline'
+        className="w-full h-12 flex items-center justify-center !rounded-full border border-gray-200 hover:bg-gray-50 transition-colors"
+        type="tertiary"
+        icon={<IconKey size="large" />}
+        size="large"
+        onClick={handlePasskeyLogin}
+        loading={passkeyLoading}
+      >
+        <span className="ml-3">{t('使用 通行密钥 登录')}</span>
+      </Button>
+    );
+  };
+
   // 包装的邮箱登录选项点击处理
   const handleEmailLoginClick = () => {
     setEmailLoginLoading(true);
@@ -341,6 +403,8 @@ const LoginForm = () => {
                   </div>
                 )}
 
+                {renderPasskeyButton()}
+
                 <Divider margin='12px' align='center'>
                   {t('或')}
                 </Divider>
@@ -437,6 +501,8 @@ const LoginForm = () => {
                   >
                     {t('忘记密码？')}
                   </Button>
+
+                  {renderPasskeyButton()}
                 </div>
               </Form>
 
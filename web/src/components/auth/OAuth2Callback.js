@@ -23,6 +23,8 @@ const OAuth2Callback = (props) => {
       if (message === 'bind') {
         showSuccess(t('绑定成功！'));
         navigate('/console/setting');
+      } else if (data && data.require_2fa) {
+        navigate('/login/2fa', { state: data });
       } else {
         userDispatch({ type: 'login', payload: data });
         localStorage.setItem('user', JSON.stringify(data));
@@ -0,0 +1,215 @@
+import React, { useContext, useEffect, useState } from 'react';
+import { Link, useLocation, useNavigate } from 'react-router-dom';
+import { useTranslation } from 'react-i18next';
+import { QRCodeSVG } from 'qrcode.react';
+import { Button, Card, Form, Modal, Typography } from '@douyinfe/semi-ui';
+import { IconKey, IconLock } from '@douyinfe/semi-icons';
+import {
+  API,
+  getLogo,
+  getPasskeyAssertion,
+  getSystemName,
+  setUserData,
+  showError,
+  showSuccess,
+  updateAPI,
+} from '../../helpers';
+import { UserContext } from '../../context/User';
+
+const { Text, Title, Paragraph } = Typography;
+
+// 登录第二步：输入身份验证器中的验证码或恢复码；被要求启用两步验证的管理员在此完成绑定
+const TwoFAVerifyForm = () => {
+  const { t } = useTranslation();
+  const navigate = useNavigate();
+  const location = useLocation();
+  const [, userDispatch] = useContext(UserContext);
+  const setupRequired = location.state?.require_2fa_setup === true;
+  // 未携带状态（如刷新页面）时默认展示验证码输入
+  const totpEnabled = location.state?.totp !== false;
+  const passkeyEnabled = location.state?.passkey === true;
+  const [code, setCode] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [enrollment, setEnrollment] = useState(null);
+  const [passkeyLoading, setPasskeyLoading] = useState(false);
+
+  const logo = getLogo();
+  const systemName = getSystemName();
+
+  useEffect(() => {
+    if (!setupRequired) {
+      return;
+    }
+    API.post('/api/user/login/2fa/setup').then((res) => {
+      const { success, message, data } = res.data;
+      if (success) {
+        setEnrollment(data);
+      } else {
+        showError(message);
+        navigate('/login');
+      }
+    });
+  }, []);
+
+  const finishLogin = (data) => {
+    userDispatch({ type: 'login', payload: data });
+    localStorage.setItem('user', JSON.stringify(data));
+    setUserData(data);
+    updateAPI();
+    showSuccess(t('登录成功！'));
+    navigate('/console');
+  };
+
+  const handlePasskey = async () => {
+    setPasskeyLoading(true);
+    try {
+      let res = await API.post('/api/user/passkey/2fa/begin');
+      if (!res.data.success) {
+        showError(res.data.message);
+        return;
+      }
+      const assertion = await getPasskeyAssertion(res.data.data);
+      res = await API.post('/api/user/passkey/2fa/finish', assertion);
+      const { success, message, data } = res.data;
+      if (success) {
+        finishLogin(data);
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(t('通行密钥验证失败或已取消'));
+    } finally {
+      setPasskeyLoading(false);
+    }
+  };
+
+  const handleSubmit = async () => {
+    if (!code) {
+      showError(t('请输入验证码'));
+      return;
+    }
+    setLoading(true);
+    try {
+      const res = await API.post('/api/user/login/2fa', { code });
+      const { success, message, data, backup_codes } = res.data;
+      if (!success) {
+        showError(message);
+        return;
+      }
+      if (backup_codes) {
+        Modal.info({
+          title: t('请妥善保存恢复码'),
+          content: (
+            <div>
+              <Paragraph>
+                {t('每个恢复码只能使用一次，丢失身份验证器时可用于登录')}
+              </Paragraph>
+              <Paragraph copyable>{backup_codes.join('\n')}</Paragraph>
+            </div>
+          ),
+          centered: true,
+          onOk: () => finishLogin(data),
+        });
+        return;
+      }
+      finishLogin(data);
+    } catch (error) {
+      showError(t('登录失败，请重试'));
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className='relative overflow-hidden bg-gray-100 flex items-center justify-center py-12 px-4 sm:px-6 lg:px-8'>
+      <div className='w-full max-w-sm mt-[64px]'>
+        <div className='flex flex-col items-center'>
+          <div className='w-full max-w-md'>
+            <div className='flex items-center justify-center mb-6 gap-2'>
+              <img src={logo} alt='Logo' className='h-10 rounded-full' />
+              <Title heading={3} className='!text-gray-800'>
+                {systemName}
+              </Title>
+            </div>
+
+            <Card className='shadow-xl border-0 !rounded-2xl overflow-hidden'>
+              <div className='flex justify-center pt-6 pb-2'>
+                <Title heading={3} className='text-gray-800 dark:text-gray-200'>
+                  {t('两步验证')}
+                </Title>
+              </div>
+              <div className='px-2 py-8'>
+                {setupRequired && enrollment && (
+                  <div className='flex flex-col items-center mb-4 gap-2'>
+                    <Text>
+                      {t('管理员要求启用两步验证，请使用身份验证器扫描二维码')}
+                    </Text>
+                    <QRCodeSVG value={enrollment.otpauth_url} size={160} />
+                    <Text copyable type='tertiary'>
+                      {enrollment.secret}
+                    </Text>
+                  </div>
+                )}
+                {passkeyEnabled && (
+                  <Button
+                    theme='outline'
+                    type='tertiary'
+                    className='w-full !rounded-full mb-4'
+                    size='large'
+                    icon={<IconKey />}
+                    onClick={handlePasskey}
+                    loading={passkeyLoading}
+                  >
+                    {t('使用 通行密钥 验证')}
+                  </Button>
+                )}
+                {(totpEnabled || setupRequired) && (
+                  <Form className='space-y-3'>
+                    <Form.Input
+                      field='code'
+                      label={t('验证码')}
+                      placeholder={
+                        setupRequired
+                          ? t('请输入身份验证器中的6位验证码')
+                          : t('请输入6位验证码或恢复码')
+                      }
+                      size='large'
+                      value={code}
+                      onChange={(value) => setCode(value.trim())}
+                      prefix={<IconLock />}
+                    />
+                    <div className='space-y-2 pt-2'>
+                      <Button
+                        theme='solid'
+                        className='w-full !rounded-full'
+                        type='primary'
+                        htmlType='submit'
+                        size='large'
+                        onClick={handleSubmit}
+                        loading={loading}
+                      >
+                        {t('验证')}
+                      </Button>
+                    </div>
+                  </Form>
+                )}
+                <div className='mt-6 text-center text-sm'>
+                  <Text>
+                    <Link
+                      to='/login'
+                      className='text-blue-600 hover:text-blue-800 font-medium'
+                    >
+                      {t('返回登录')}
+                    </Link>
+                  </Text>
+                </div>
+              </div>
+            </Card>
+          </div>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default TwoFAVerifyForm;
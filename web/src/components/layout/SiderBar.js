@@ -5,6 +5,7 @@ import { getLucideIcon, sidebarIconColors } from '../../helpers/render.js';
 import { ChevronLeft } from 'lucide-react';
 import { useStyle, styleActions } from '../../context/Style/index.js';
 import {
+  hasPermission,
   isAdmin,
   isRoot,
   showError
@@ -113,19 +114,19 @@ const SiderBar = () => {
         text: t('渠道'),
         itemKey: 'channel',
         to: '/channel',
-        className: isAdmin() ? '' : 'tableHiddle',
+        className: hasPermission('channel.manage') ? '' : 'tableHiddle',
       },
       {
         text: t('兑换码'),
         itemKey: 'redemption',
         to: '/redemption',
-        className: isAdmin() ? '' : 'tableHiddle',
+        className: hasPermission('redemption.manage') ? '' : 'tableHiddle',
       },
       {
         text: t('用户管理'),
         itemKey: 'user',
         to: '/user',
-        className: isAdmin() ? '' : 'tableHiddle',
+        className: hasPermission('user.manage') ? '' : 'tableHiddle',
       },
       {
         text: t('系统设置'),
@@ -0,0 +1,192 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Card,
+  Checkbox,
+  Input,
+  Modal,
+  Space,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { API, showError, showSuccess } from '../../helpers';
+
+const permissionNames = {
+  'channel.manage': '管理渠道',
+  'channel.key.view': '查看渠道密钥',
+  'user.manage': '管理用户',
+  'redemption.manage': '管理兑换码',
+  'log.view_all': '查看所有日志',
+  'log.manage': '删除日志',
+  'log.payload.view': '查看请求内容',
+  'option.manage': '修改系统设置',
+  'payment.approve': '审核充值订单',
+};
+
+// 超级管理员维护自定义管理员角色
+const AdminRoleSetting = () => {
+  const { t } = useTranslation();
+  const [roles, setRoles] = useState([]);
+  const [permissions, setPermissions] = useState([]);
+  const [defaultPermissions, setDefaultPermissions] = useState([]);
+  const [editingRole, setEditingRole] = useState(null);
+  const [loading, setLoading] = useState(false);
+
+  const loadRoles = async () => {
+    const res = await API.get('/api/admin_role/');
+    const { success, message, data } = res.data;
+    if (success) {
+      setRoles(data.roles || []);
+      setPermissions(data.permissions || []);
+      setDefaultPermissions(data.default_permissions || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadRoles().then();
+  }, []);
+
+  const renderPermissions = (list) => (
+    <Space wrap>
+      {(list || []).map((p) => (
+        <Tag key={p} color='blue'>
+          {t(permissionNames[p] || p)}
+        </Tag>
+      ))}
+    </Space>
+  );
+
+  const saveRole = async () => {
+    setLoading(true);
+    try {
+      const res = editingRole.id
+        ? await API.put('/api/admin_role/', editingRole)
+        : await API.post('/api/admin_role/', editingRole);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('保存成功'));
+        setEditingRole(null);
+        await loadRoles();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const deleteRole = (role) => {
+    Modal.confirm({
+      title: t('确定要删除此角色吗？'),
+      content: role.name,
+      centered: true,
+      onOk: async () => {
+        const res = await API.delete(`/api/admin_role/${role.id}`);
+        if (res.data.success) {
+          showSuccess(t('操作成功'));
+          await loadRoles();
+        } else {
+          showError(res.data.message);
+        }
+      },
+    });
+  };
+
+  const columns = [
+    { title: t('名称'), dataIndex: 'name' },
+    { title: t('描述'), dataIndex: 'description' },
+    {
+      title: t('权限'),
+      dataIndex: 'permissions',
+      render: (list) => renderPermissions(list),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Space>
+          <Button
+            size='small'
+            onClick={() => setEditingRole({ ...record })}
+          >
+            {t('编辑')}
+          </Button>
+          <Button
+            size='small'
+            type='danger'
+            onClick={() => deleteRole(record)}
+          >
+            {t('删除')}
+          </Button>
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <Card>
+      <Typography.Title heading={5}>{t('管理员角色')}</Typography.Title>
+      <Typography.Text type='tertiary'>
+        {t('未分配角色的管理员拥有默认权限：')}
+      </Typography.Text>
+      <div className='mt-2 mb-4'>{renderPermissions(defaultPermissions)}</div>
+      <Button
+        onClick={() =>
+          setEditingRole({ name: '', description: '', permissions: [] })
+        }
+      >
+        {t('添加角色')}
+      </Button>
+      <Table
+        className='mt-4'
+        columns={columns}
+        dataSource={roles}
+        rowKey='id'
+        pagination={false}
+      />
+      <Modal
+        title={editingRole?.id ? t('编辑角色') : t('添加角色')}
+        visible={editingRole !== null}
+        onOk={saveRole}
+        onCancel={() => setEditingRole(null)}
+        confirmLoading={loading}
+        centered
+      >
+        {editingRole && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <Input
+              placeholder={t('名称')}
+              value={editingRole.name}
+              onChange={(value) =>
+                setEditingRole({ ...editingRole, name: value })
+              }
+            />
+            <Input
+              placeholder={t('描述')}
+              value={editingRole.description}
+              onChange={(value) =>
+                setEditingRole({ ...editingRole, description: value })
+              }
+            />
+            <Checkbox.Group
+              value={editingRole.permissions}
+              onChange={(value) =>
+                setEditingRole({ ...editingRole, permissions: value })
+              }
+              options={permissions.map((p) => ({
+                label: t(permissionNames[p] || p),
+                value: p,
+              }))}
+            />
+          </Space>
+        )}
+      </Modal>
+    </Card>
+  );
+};
+
+export default AdminRoleSetting;
@@ -0,0 +1,169 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { Button, Card, Input, Modal, Typography } from '@douyinfe/semi-ui';
+import { IconDelete, IconKey } from '@douyinfe/semi-icons';
+import {
+  API,
+  createPasskey,
+  isPasskeySupported,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+
+// 个人设置中的通行密钥管理
+const PasskeySetting = () => {
+  const { t } = useTranslation();
+  const [passkeys, setPasskeys] = useState([]);
+  const [showAddModal, setShowAddModal] = useState(false);
+  const [name, setName] = useState('');
+  const [loading, setLoading] = useState(false);
+
+  const loadPasskeys = async () => {
+    const res = await API.get('/api/user/passkey');
+    const { success, message, data } = res.data;
+    if (success) {
+      setPasskeys(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadPasskeys().then();
+  }, []);
+
+  const addPasskey = async () => {
+    if (!isPasskeySupported()) {
+      showError(t('当前浏览器不支持通行密钥'));
+      return;
+    }
+    setLoading(true);
+    try {
+      let res = await API.post('/api/user/passkey/register/begin');
+      if (!res.data.success) {
+        showError(res.data.message);
+        return;
+      }
+      const credential = await createPasskey(res.data.data);
+      res = await API.post(
+        `/api/user/passkey/register/finish?name=${encodeURIComponent(name)}`,
+        credential,
+      );
+      if (!res.data.success) {
+        showError(res.data.message);
+        return;
+      }
+      showSuccess(t('通行密钥添加成功'));
+      setShowAddModal(false);
+      setName('');
+      await loadPasskeys();
+    } catch (error) {
+      showError(t('通行密钥验证失败或已取消'));
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const deletePasskey = (passkey) => {
+    Modal.confirm({
+      title: t('确定要删除此通行密钥吗？'),
+      content: passkey.name,
+      centered: true,
+      onOk: async () => {
+        const res = await API.delete(`/api/user/passkey/${passkey.id}`);
+        if (res.data.success) {
+          showSuccess(t('操作成功'));
+          await loadPasskeys();
+        } else {
+          showError(res.data.message);
+        }
+      },
+    });
+  };
+
+  return (
+    <Card
+      className="!rounded-xl w-full"
+      bodyStyle={{ padding: '20px' }}
+      shadows='hover'
+    >
+      <div className="flex flex-col sm:flex-row items-start sm:justify-between gap-4">
+        <div className="flex items-start w-full sm:w-auto">
+          <div className="w-12 h-12 rounded-full bg-slate-100 flex items-center justify-center mr-4 flex-shrink-0">
+            <IconKey size="large" className="text-slate-600" />
+          </div>
+          <div>
+            <Typography.Title heading={6} className="mb-1">
+              {t('通行密钥')}
+            </Typography.Title>
+            <Typography.Text type="tertiary" className="text-sm">
+              {t('使用指纹、面容或安全密钥登录，也可作为两步验证方式')}
+            </Typography.Text>
+          </div>
+        </div>
+        <Button
+          type="primary"
+          theme="solid"
+          onClick={() => setShowAddModal(true)}
+          className="!rounded-lg !bg-slate-600 hover:!bg-slate-700 w-full sm:w-auto"
+          icon={<IconKey />}
+        >
+          {t('添加通行密钥')}
+        </Button>
+      </div>
+      {passkeys.length > 0 && (
+        <div className="mt-4 space-y-2">
+          {passkeys.map((passkey) => (
+            <div
+              key={passkey.id}
+              className="flex items-center justify-between rounded-lg bg-slate-50 px-4 py-2"
+            >
+              <div>
+                <Typography.Text strong>{passkey.name}</Typography.Text>
+                <Typography.Text type="tertiary" size="small" className="ml-3">
+                  {passkey.last_used_time
+                    ? t('最后使用于 {{time}}', {
+                        time: timestamp2string(passkey.last_used_time),
+                      })
+                    : t('创建于 {{time}}', {
+                        time: timestamp2string(passkey.created_time),
+                      })}
+                </Typography.Text>
+              </div>
+              <Button
+                type="danger"
+                theme="borderless"
+                icon={<IconDelete />}
+                onClick={() => deletePasskey(passkey)}
+              />
+            </div>
+          ))}
+        </div>
+      )}
+
+      <Modal
+        title={t('添加通行密钥')}
+        visible={showAddModal}
+        onCancel={() => setShowAddModal(false)}
+        onOk={addPasskey}
+        confirmLoading={loading}
+        size={'small'}
+        centered={true}
+      >
+        <div className="py-4">
+          <Input
+            placeholder={t('为通行密钥命名，例如：我的笔记本')}
+            value={name}
+            onChange={setName}
+            maxLength={64}
+            size="large"
+            className="!rounded-lg"
+          />
+        </div>
+      </Modal>
+    </Card>
+  );
+};
+
+export default PasskeySetting;
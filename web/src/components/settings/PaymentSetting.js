@@ -2,6 +2,9 @@ import React, { useEffect, useState } from 'react';
 import { Card, Spin } from '@douyinfe/semi-ui';
 import SettingsGeneralPayment from '../../pages/Setting/Payment/SettingsGeneralPayment.js';
 import SettingsPaymentGateway from '../../pages/Setting/Payment/SettingsPaymentGateway.js';
+import SettingsSubscriptionPlans from '../../pages/Setting/Payment/SettingsSubscriptionPlans.js';
+import SettingsCreditAccounts from '../../pages/Setting/Payment/SettingsCreditAccounts.js';
+import SettingsQuotaLedger from '../../pages/Setting/Payment/SettingsQuotaLedger.js';
 import { API, showError } from '../../helpers';
 import { useTranslation } from 'react-i18next';
 
@@ -17,6 +20,11 @@ const PaymentSetting = () => {
     TopupGroupRatio: '',
     CustomCallbackAddress: '',
     PayMethods: '',
+    StripeApiAddress: '',
+    StripeSecretKey: '',
+    StripeWebhookSecret: '',
+    StripeCurrency: 'usd',
+    StripeUnitPrice: 1,
   });
 
   let [loading, setLoading] = useState(false);
@@ -38,6 +46,7 @@ const PaymentSetting = () => {
             break;
           case 'Price':
           case 'MinTopUp':
+          case 'StripeUnitPrice':
             newInputs[item.key] = parseFloat(item.value);
             break;
           default:
@@ -80,6 +89,15 @@ const PaymentSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsPaymentGateway options={inputs} refresh={onRefresh} />
         </Card>
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsSubscriptionPlans />
+        </Card>
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsCreditAccounts />
+        </Card>
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsQuotaLedger />
+        </Card>
       </Spin>
     </>
   );
@@ -59,6 +59,8 @@ import {
 import { SiTelegram, SiWechat, SiLinux } from 'react-icons/si';
 import { Bell, Shield, Webhook, Globe, Settings, UserPlus, ShieldCheck } from 'lucide-react';
 import TelegramLoginButton from 'react-telegram-login';
+import TwoFASetting from './TwoFASetting';
+import PasskeySetting from './PasskeySetting';
 import { useTranslation } from 'react-i18next';
 
 const PersonalSetting = () => {
@@ -104,6 +106,9 @@ const PersonalSetting = () => {
     notificationEmail: '',
     acceptUnsetModelRatioModel: false,
     recordIpLog: false,
+    spendReportDaily: false,
+    spendReportWeekly: false,
+    spendReportMonthly: false,
   });
   const [modelsLoading, setModelsLoading] = useState(true);
   const [showWebhookDocs, setShowWebhookDocs] = useState(true);
@@ -149,6 +154,9 @@ const PersonalSetting = () => {
         acceptUnsetModelRatioModel:
           settings.accept_unset_model_ratio_model || false,
         recordIpLog: settings.record_ip_log || false,
+        spendReportDaily: settings.spend_report_daily || false,
+        spendReportWeekly: settings.spend_report_weekly || false,
+        spendReportMonthly: settings.spend_report_monthly || false,
       });
     }
   }, [userState?.user?.setting]);
@@ -365,6 +373,9 @@ const PersonalSetting = () => {
         accept_unset_model_ratio_model:
           notificationSettings.acceptUnsetModelRatioModel,
         record_ip_log: notificationSettings.recordIpLog,
+        spend_report_daily: notificationSettings.spendReportDaily,
+        spend_report_weekly: notificationSettings.spendReportWeekly,
+        spend_report_monthly: notificationSettings.spendReportMonthly,
       });
 
       if (res.data.success) {
@@ -1022,6 +1033,12 @@ const PersonalSetting = () => {
                           </div>
                         </Card>
 
+                        {/* 两步验证 */}
+                        <TwoFASetting />
+
+                        {/* 通行密钥 */}
+                        {status.passkey_login && <PasskeySetting />}
+
                         {/* 危险区域 */}
                         <Card
                           className="!rounded-xl border-red-200 w-full"
@@ -1301,6 +1318,57 @@ const PersonalSetting = () => {
                           </div>
                         </div>
                       </TabPane>
+
+                      <TabPane
+                        tab={t('用量报告')}
+                        itemKey='spend_report'
+                      >
+                        <div className="py-4">
+                          <div className="bg-white rounded-xl">
+                            <Typography.Text strong className="block mb-2">
+                              {t('定期接收用量报告')}
+                            </Typography.Text>
+                            <div className="text-gray-500 text-sm mb-4">
+                              {t('报告包含消费额度、请求与失败次数、消费最多的模型与令牌以及剩余额度，通过上方设置的通知方式发送')}
+                            </div>
+                            <Space vertical align='start'>
+                              <Checkbox
+                                checked={notificationSettings.spendReportDaily}
+                                onChange={(e) =>
+                                  handleNotificationSettingChange(
+                                    'spendReportDaily',
+                                    e.target.checked,
+                                  )
+                                }
+                              >
+                                {t('每日报告')}
+                              </Checkbox>
+                              <Checkbox
+                                checked={notificationSettings.spendReportWeekly}
+                                onChange={(e) =>
+                                  handleNotificationSettingChange(
+                                    'spendReportWeekly',
+                                    e.target.checked,
+                                  )
+                                }
+                              >
+                                {t('每周报告')}
+                              </Checkbox>
+                              <Checkbox
+                                checked={notificationSettings.spendReportMonthly}
+                                onChange={(e) =>
+                                  handleNotificationSettingChange(
+                                    'spendReportMonthly',
+                                    e.target.checked,
+                                  )
+                                }
+                              >
+                                {t('每月报告')}
+                              </Checkbox>
+                            </Space>
+                          </div>
+                        </div>
+                      </TabPane>
                     </Tabs>
 
                     <div className="mt-6 flex justify-end">
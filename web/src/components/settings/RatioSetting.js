@@ -17,6 +17,7 @@ const RatioSetting = () => {
     ModelPrice: '',
     ModelRatio: '',
     CacheRatio: '',
+    ModelTierRatio: '',
     CompletionRatio: '',
     GroupRatio: '',
     GroupGroupRatio: '',
@@ -42,7 +43,8 @@ const RatioSetting = () => {
           item.key === 'UserUsableGroups' ||
           item.key === 'CompletionRatio' ||
           item.key === 'ModelPrice' ||
-          item.key === 'CacheRatio'
+          item.key === 'CacheRatio' ||
+          item.key === 'ModelTierRatio'
         ) {
           try {
             item.value = JSON.stringify(JSON.parse(item.value), null, 2);
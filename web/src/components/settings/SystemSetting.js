@@ -17,9 +17,19 @@ import {
   removeTrailingSlash,
   showError,
   showSuccess,
+  verifyJSON,
 } from '../../helpers';
 import axios from 'axios';
 
+const parseJSONList = (value) => {
+  try {
+    const list = JSON.parse(value);
+    return Array.isArray(list) ? list : [];
+  } catch (e) {
+    return [];
+  }
+};
+
 const SystemSetting = () => {
   let [inputs, setInputs] = useState({
     PasswordLoginEnabled: '',
@@ -35,6 +45,66 @@ const SystemSetting = () => {
     'oidc.authorization_endpoint': '',
     'oidc.token_endpoint': '',
     'oidc.user_info_endpoint': '',
+    'two_fa.require_for_admin': '',
+    'passkey.enabled': '',
+    'passkey.rp_id': '',
+    'passkey.rp_display_name': '',
+    'passkey.origins': '',
+    'payload_capture.enabled': '',
+    'payload_capture.user_ids': '',
+    'payload_capture.token_ids': '',
+    'payload_capture.groups': '',
+    'payload_capture.sample_rate': '',
+    'payload_capture.max_body_bytes': '',
+    'payload_capture.redact_patterns': '',
+    'payload_capture.redact_json_paths': '',
+    'payload_capture.retention_days': '',
+    'request_tags.allowed_keys': '',
+    'request_tags.max_tags': '',
+    'request_tags.max_value_length': '',
+    'spend_report.enabled': '',
+    'spend_report.run_hour': '',
+    'spend_report.top_n': '',
+    'spend_report.template': '',
+    'invoice.enabled': '',
+    'invoice.run_hour': '',
+    'invoice.timezone': '',
+    'invoice.company_name': '',
+    'invoice.company_address': '',
+    'invoice.company_tax_id': '',
+    'invoice.company_email': '',
+    'invoice.tax_name': '',
+    'invoice.tax_rate': '',
+    'invoice.number_prefix': '',
+    'invoice.number_start': '',
+    'invoice.number_digits': '',
+    'invoice.footer': '',
+    'credit.enabled': '',
+    'credit.terms_days': '',
+    'credit.run_hour': '',
+    'credit.throttle_after_days': '',
+    'credit.throttle_requests_per_minute': '',
+    'credit.suspend_after_days': '',
+    'quota_adjustment.daily_cap_per_admin': '',
+    'quota_ledger.reconcile_interval_minutes': '',
+    'quota_ledger.notify_root': '',
+    'upstream_cost.drift_alert_enabled': '',
+    'upstream_cost.drift_threshold': '',
+    'upstream_cost.drift_min_amount': '',
+    'spend_anomaly.enabled': '',
+    'spend_anomaly.baseline_hours': '',
+    'spend_anomaly.spike_multiplier': '',
+    'spend_anomaly.min_spend': '',
+    'spend_anomaly.min_requests': '',
+    'spend_anomaly.detect_new_model': '',
+    'spend_anomaly.detect_new_ip': '',
+    'spend_anomaly.notify_root': '',
+    'spend_anomaly.notify_user': '',
+    'spend_anomaly.suspend_token': '',
+    'price_schedule.enabled': '',
+    'price_schedule.timezone': '',
+    'price_schedule.off_peak': '',
+    'price_schedule.promotions': '',
     Notice: '',
     SMTPServer: '',
     SMTPPort: '',
@@ -85,6 +155,8 @@ const SystemSetting = () => {
       data.forEach((item) => {
         switch (item.key) {
           case 'TopupGroupRatio':
+          case 'price_schedule.off_peak':
+          case 'price_schedule.promotions':
             item.value = JSON.stringify(JSON.parse(item.value), null, 2);
             break;
           case 'EmailDomainWhitelist':
@@ -103,9 +175,34 @@ const SystemSetting = () => {
           case 'SMTPSSLEnabled':
           case 'LinuxDOOAuthEnabled':
           case 'oidc.enabled':
+          case 'two_fa.require_for_admin':
+          case 'passkey.enabled':
+          case 'payload_capture.enabled':
+          case 'spend_report.enabled':
+          case 'invoice.enabled':
+          case 'credit.enabled':
+          case 'quota_ledger.notify_root':
+          case 'upstream_cost.drift_alert_enabled':
+          case 'spend_anomaly.enabled':
+          case 'spend_anomaly.detect_new_model':
+          case 'spend_anomaly.detect_new_ip':
+          case 'spend_anomaly.notify_root':
+          case 'spend_anomaly.notify_user':
+          case 'spend_anomaly.suspend_token':
+          case 'price_schedule.enabled':
           case 'WorkerAllowHttpImageRequestEnabled':
             item.value = item.value === 'true';
             break;
+          case 'payload_capture.user_ids':
+          case 'payload_capture.token_ids':
+          case 'payload_capture.groups':
+          case 'request_tags.allowed_keys':
+            item.value = parseJSONList(item.value).join(',');
+            break;
+          case 'payload_capture.redact_patterns':
+          case 'payload_capture.redact_json_paths':
+            item.value = parseJSONList(item.value).join('\n');
+            break;
           case 'Price':
           case 'MinTopUp':
             item.value = parseFloat(item.value);
@@ -409,6 +506,223 @@ const SystemSetting = () => {
     await updateOptions(options);
   };
 
+  const submitPasskeySettings = async () => {
+    const options = [];
+    ['passkey.rp_id', 'passkey.rp_display_name', 'passkey.origins'].forEach(
+      (key) => {
+        if (originInputs[key] !== inputs[key]) {
+          options.push({ key, value: inputs[key] });
+        }
+      },
+    );
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitPayloadCaptureSettings = async () => {
+    const splitList = (value, separator) =>
+      (value || '')
+        .split(separator)
+        .map((item) => item.trim())
+        .filter((item) => item !== '');
+    const toIds = (value) =>
+      JSON.stringify(
+        splitList(value, ',')
+          .map((id) => parseInt(id))
+          .filter((id) => !isNaN(id)),
+      );
+    const values = {
+      'payload_capture.user_ids': toIds(inputs['payload_capture.user_ids']),
+      'payload_capture.token_ids': toIds(inputs['payload_capture.token_ids']),
+      'payload_capture.groups': JSON.stringify(
+        splitList(inputs['payload_capture.groups'], ','),
+      ),
+      'payload_capture.sample_rate': String(
+        inputs['payload_capture.sample_rate'],
+      ),
+      'payload_capture.max_body_bytes': String(
+        inputs['payload_capture.max_body_bytes'],
+      ),
+      'payload_capture.redact_patterns': JSON.stringify(
+        splitList(inputs['payload_capture.redact_patterns'], '\n'),
+      ),
+      'payload_capture.redact_json_paths': JSON.stringify(
+        splitList(inputs['payload_capture.redact_json_paths'], '\n'),
+      ),
+      'payload_capture.retention_days': String(
+        inputs['payload_capture.retention_days'],
+      ),
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitRequestTagSettings = async () => {
+    const values = {
+      'request_tags.allowed_keys': JSON.stringify(
+        (inputs['request_tags.allowed_keys'] || '')
+          .split(',')
+          .map((item) => item.trim())
+          .filter((item) => item !== ''),
+      ),
+      'request_tags.max_tags': String(inputs['request_tags.max_tags']),
+      'request_tags.max_value_length': String(
+        inputs['request_tags.max_value_length'],
+      ),
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitSpendReportSettings = async () => {
+    const values = {
+      'spend_report.run_hour': String(inputs['spend_report.run_hour']),
+      'spend_report.top_n': String(inputs['spend_report.top_n']),
+      'spend_report.template': inputs['spend_report.template'],
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitInvoiceSettings = async () => {
+    const values = {
+      'invoice.run_hour': String(inputs['invoice.run_hour']),
+      'invoice.timezone': inputs['invoice.timezone'],
+      'invoice.company_name': inputs['invoice.company_name'],
+      'invoice.company_address': inputs['invoice.company_address'],
+      'invoice.company_tax_id': inputs['invoice.company_tax_id'],
+      'invoice.company_email': inputs['invoice.company_email'],
+      'invoice.tax_name': inputs['invoice.tax_name'],
+      'invoice.tax_rate': String(inputs['invoice.tax_rate']),
+      'invoice.number_prefix': inputs['invoice.number_prefix'],
+      'invoice.number_start': String(inputs['invoice.number_start']),
+      'invoice.number_digits': String(inputs['invoice.number_digits']),
+      'invoice.footer': inputs['invoice.footer'],
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitCreditSettings = async () => {
+    const values = {
+      'credit.terms_days': String(inputs['credit.terms_days']),
+      'credit.run_hour': String(inputs['credit.run_hour']),
+      'credit.throttle_after_days': String(
+        inputs['credit.throttle_after_days'],
+      ),
+      'credit.throttle_requests_per_minute': String(
+        inputs['credit.throttle_requests_per_minute'],
+      ),
+      'credit.suspend_after_days': String(
+        inputs['credit.suspend_after_days'],
+      ),
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitQuotaAdjustmentSettings = async () => {
+    await updateOptions([
+      {
+        key: 'quota_adjustment.daily_cap_per_admin',
+        value: String(inputs['quota_adjustment.daily_cap_per_admin']),
+      },
+    ]);
+  };
+
+  const submitQuotaLedgerSettings = async () => {
+    const values = {
+      'quota_ledger.reconcile_interval_minutes': String(
+        inputs['quota_ledger.reconcile_interval_minutes'],
+      ),
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitUpstreamCostSettings = async () => {
+    const values = {
+      'upstream_cost.drift_threshold': String(
+        inputs['upstream_cost.drift_threshold'],
+      ),
+      'upstream_cost.drift_min_amount': String(
+        inputs['upstream_cost.drift_min_amount'],
+      ),
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitSpendAnomalySettings = async () => {
+    const values = {
+      'spend_anomaly.baseline_hours': String(
+        inputs['spend_anomaly.baseline_hours'],
+      ),
+      'spend_anomaly.spike_multiplier': String(
+        inputs['spend_anomaly.spike_multiplier'],
+      ),
+      'spend_anomaly.min_spend': String(inputs['spend_anomaly.min_spend']),
+      'spend_anomaly.min_requests': String(
+        inputs['spend_anomaly.min_requests'],
+      ),
+    };
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
+  const submitPriceScheduleSettings = async () => {
+    const values = {
+      'price_schedule.timezone': inputs['price_schedule.timezone'],
+      'price_schedule.off_peak': inputs['price_schedule.off_peak'],
+      'price_schedule.promotions': inputs['price_schedule.promotions'],
+    };
+    for (const key of ['price_schedule.off_peak', 'price_schedule.promotions']) {
+      if (originInputs[key] !== inputs[key] && !verifyJSON(inputs[key])) {
+        showError('时段规则或促销不是合法的 JSON 字符串');
+        return;
+      }
+    }
+    const options = Object.keys(values)
+      .filter((key) => originInputs[key] !== inputs[key])
+      .map((key) => ({ key, value: values[key] }));
+    if (options.length > 0) {
+      await updateOptions(options);
+    }
+  };
+
   const submitTurnstile = async () => {
     const options = [];
 
@@ -579,6 +893,15 @@ const SystemSetting = () => {
                       >
                         启用 Turnstile 用户校验
                       </Form.Checkbox>
+                      <Form.Checkbox
+                        field="['two_fa.require_for_admin']"
+                        noLabel
+                        onChange={(e) =>
+                          handleCheckboxChange('two_fa.require_for_admin', e)
+                        }
+                      >
+                        要求管理员启用两步验证后才能登录
+                      </Form.Checkbox>
                     </Col>
                     <Col xs={24} sm={24} md={12} lg={12} xl={12}>
                       <Form.Checkbox
@@ -626,6 +949,15 @@ const SystemSetting = () => {
                       >
                         允许通过 OIDC 进行登录
                       </Form.Checkbox>
+                      <Form.Checkbox
+                        field="['passkey.enabled']"
+                        noLabel
+                        onChange={(e) =>
+                          handleCheckboxChange('passkey.enabled', e)
+                        }
+                      >
+                        允许通过通行密钥（Passkey）进行登录
+                      </Form.Checkbox>
                     </Col>
                   </Row>
                 </Form.Section>
@@ -955,6 +1287,595 @@ const SystemSetting = () => {
                 </Form.Section>
               </Card>
 
+              <Card>
+                <Form.Section text='配置通行密钥'>
+                  <Text>
+                    依赖方 ID 需为访问站点的域名或其上级域名，留空则使用服务器地址的域名
+                  </Text>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['passkey.rp_id']"
+                        label='依赖方 ID'
+                        placeholder='example.com'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['passkey.rp_display_name']"
+                        label='显示名称'
+                        placeholder='留空则使用系统名称'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['passkey.origins']"
+                        label='允许的来源'
+                        placeholder='https://example.com，多个以逗号分隔'
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitPasskeySettings}>保存通行密钥设置</Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='请求内容采集'>
+                  <Text>
+                    命中以下用户、令牌或分组的请求会按采样率保存完整的请求与响应，用于排查问题与合规审计；内容只会加密存储，需先设置
+                    CRYPTO_SECRET 环境变量
+                  </Text>
+                  <Form.Checkbox
+                    field="['payload_capture.enabled']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('payload_capture.enabled', e)
+                    }
+                  >
+                    启用请求内容采集
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['payload_capture.user_ids']"
+                        label='用户 ID'
+                        placeholder='多个以逗号分隔'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['payload_capture.token_ids']"
+                        label='令牌 ID'
+                        placeholder='多个以逗号分隔'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['payload_capture.groups']"
+                        label='分组'
+                        placeholder='多个以逗号分隔'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['payload_capture.sample_rate']"
+                        label='采样率'
+                        placeholder='0 到 1 之间，1 为全部采集'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['payload_capture.max_body_bytes']"
+                        label='单个请求或响应最大字节数'
+                        placeholder='不超过 48128'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['payload_capture.retention_days']"
+                        label='保存天数'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.TextArea
+                        field="['payload_capture.redact_patterns']"
+                        label='脱敏正则'
+                        placeholder='每行一个正则表达式，匹配内容替换为 [REDACTED]'
+                        autosize
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.TextArea
+                        field="['payload_capture.redact_json_paths']"
+                        label='脱敏 JSON 路径'
+                        placeholder='每行一个，例如 messages.*.content'
+                        autosize
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitPayloadCaptureSettings}>
+                    保存请求内容采集设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='请求标签'>
+                  <Text>
+                    客户端可通过 X-NewAPI-Tags 请求头（如
+                    project=search,env=prod）或请求体的 metadata
+                    字段为请求打上标签，用于按项目归属成本；只记录允许的标签键，留空则不记录
+                  </Text>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['request_tags.allowed_keys']"
+                        label='允许的标签键'
+                        placeholder='多个以逗号分隔，例如 project,env'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['request_tags.max_tags']"
+                        label='单个请求最多标签数'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['request_tags.max_value_length']"
+                        label='标签值最大长度'
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitRequestTagSettings}>
+                    保存请求标签设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='用量报告'>
+                  <Text>
+                    每天在指定时间向订阅的用户发送用量报告，每周报告在周一发送，每月报告在
+                    1 日发送；报告数据来自用量汇总，需保持 USAGE_ROLLUP_ENABLED 开启
+                  </Text>
+                  <Form.Checkbox
+                    field="['spend_report.enabled']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('spend_report.enabled', e)
+                    }
+                  >
+                    启用用量报告
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['spend_report.run_hour']"
+                        label='发送时间（点）'
+                        placeholder='0 到 23'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['spend_report.top_n']"
+                        label='列出的模型与令牌数量'
+                      />
+                    </Col>
+                    <Col xs={24}>
+                      <Form.TextArea
+                        field="['spend_report.template']"
+                        label='报告模板'
+                        placeholder='Go html/template 模板，可用字段：.Username .PeriodName .StartDate .EndDate .QuotaText .Requests .Errors .RemainQuotaText .TopModels .TopTokens；留空使用默认模板'
+                        autosize
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitSpendReportSettings}>
+                    保存用量报告设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='月度账单'>
+                  <Text>
+                    每月 1
+                    日在指定时间为上月有消费或充值的用户生成账单，用户可在钱包页面下载
+                    PDF 或 JSON 格式的账单；账单编号为前缀 + 年月 + 序号
+                  </Text>
+                  <Form.Checkbox
+                    field="['invoice.enabled']"
+                    noLabel
+                    onChange={(e) => handleCheckboxChange('invoice.enabled', e)}
+                  >
+                    启用月度账单
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['invoice.company_name']"
+                        label='公司名称'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['invoice.company_tax_id']"
+                        label='税号'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['invoice.company_address']"
+                        label='公司地址'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['invoice.company_email']"
+                        label='联系邮箱'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['invoice.tax_name']"
+                        label='税项名称'
+                        placeholder='例如 VAT、GST'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['invoice.tax_rate']"
+                        label='税率（%）'
+                        placeholder='0 表示不计税'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['invoice.run_hour']"
+                        label='生成时间（点）'
+                        placeholder='0 到 23'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['invoice.number_prefix']"
+                        label='编号前缀'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['invoice.number_start']"
+                        label='起始序号'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['invoice.number_digits']"
+                        label='序号位数'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['invoice.timezone']"
+                        label='账单时区'
+                        placeholder='例如 UTC、Asia/Shanghai，账单期与生成时间按该时区计算'
+                      />
+                    </Col>
+                    <Col xs={24}>
+                      <Form.TextArea
+                        field="['invoice.footer']"
+                        label='账单备注'
+                        placeholder='显示在账单底部，例如付款说明'
+                        autosize
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitInvoiceSettings}>保存月度账单设置</Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='账期额度'>
+                  <Text>
+                    开启后用户可在钱包页面申请账期额度，管理员在支付设置中审批；每月 1
+                    日按账单时区生成上月结算单，错过生成时间时自动补齐；结算单到期后逾期天数达到设置值时限制请求频率或暂停
+                    API 调用，付清后自动恢复
+                  </Text>
+                  <Form.Checkbox
+                    field="['credit.enabled']"
+                    noLabel
+                    onChange={(e) => handleCheckboxChange('credit.enabled', e)}
+                  >
+                    允许用户申请账期额度
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['credit.terms_days']"
+                        label='默认账期（天）'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['credit.run_hour']"
+                        label='结算单生成时间（点）'
+                        placeholder='0 到 23'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['credit.throttle_after_days']"
+                        label='逾期限流天数'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['credit.throttle_requests_per_minute']"
+                        label='限流后每分钟请求数'
+                        placeholder='0 表示不限流'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                      <Form.Input
+                        field="['credit.suspend_after_days']"
+                        label='逾期暂停天数'
+                        placeholder='0 表示不暂停'
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitCreditSettings}>保存账期额度设置</Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='额度调整'>
+                  <Text>
+                    管理员可在用户管理中增加或扣除用户额度，或在日志中退还消费额度，调整需填写原因并记录在用户日志中；
+                    每个管理员每天调整的额度合计不能超过上限
+                  </Text>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['quota_adjustment.daily_cap_per_admin']"
+                        label='每个管理员每日调整上限（额度）'
+                        placeholder='0 表示不限制'
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitQuotaAdjustmentSettings}>
+                    保存额度调整设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='额度账本'>
+                  <Text>
+                    所有额度变动都会记录到只追加的额度账本中，系统按设置的间隔核对用户余额与账本余额，可在支付设置中查看账本与核对结果
+                  </Text>
+                  <Form.Checkbox
+                    field="['quota_ledger.notify_root']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('quota_ledger.notify_root', e)
+                    }
+                  >
+                    发现不一致时通知超级管理员
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['quota_ledger.reconcile_interval_minutes']"
+                        label='核对间隔（分钟）'
+                        placeholder='0 表示不定期核对'
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitQuotaLedgerSettings}>
+                    保存额度账本设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='上游成本'>
+                  <Text>
+                    在渠道额外设置中配置 upstream_cost_ratio
+                    后，消费日志会记录上游成本，可在 /api/data/margin
+                    查看毛利报告；开启余额偏差告警后，更新渠道余额时若余额减少量与计算的上游成本偏差过大，将通知管理员
+                  </Text>
+                  <Form.Checkbox
+                    field="['upstream_cost.drift_alert_enabled']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('upstream_cost.drift_alert_enabled', e)
+                    }
+                  >
+                    启用余额偏差告警
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['upstream_cost.drift_threshold']"
+                        label='偏差比例阈值'
+                        placeholder='例如 0.2 表示偏差超过计算成本的 20%'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['upstream_cost.drift_min_amount']"
+                        label='最小告警金额（美元）'
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitUpstreamCostSettings}>
+                    保存上游成本设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='用量异常检测'>
+                  <Text>
+                    每 10
+                    分钟将令牌与用户最近一小时的消费、请求数与自身的历史平均值比较，超过倍数或出现新模型、新
+                    IP 时按以下设置处理；数据来自用量汇总，没有历史用量的用户与令牌不参与判断
+                  </Text>
+                  <Form.Checkbox
+                    field="['spend_anomaly.enabled']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('spend_anomaly.enabled', e)
+                    }
+                  >
+                    启用用量异常检测
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['spend_anomaly.baseline_hours']"
+                        label='基线时长（小时）'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['spend_anomaly.spike_multiplier']"
+                        label='异常倍数'
+                        placeholder='例如 10 表示超过平均每小时用量的 10 倍'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['spend_anomaly.min_spend']"
+                        label='最小消费金额（美元/小时）'
+                      />
+                    </Col>
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['spend_anomaly.min_requests']"
+                        label='最小请求数（次/小时）'
+                      />
+                    </Col>
+                  </Row>
+                  <Form.Checkbox
+                    field="['spend_anomaly.detect_new_model']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('spend_anomaly.detect_new_model', e)
+                    }
+                  >
+                    令牌使用新模型时告警
+                  </Form.Checkbox>
+                  <Form.Checkbox
+                    field="['spend_anomaly.detect_new_ip']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('spend_anomaly.detect_new_ip', e)
+                    }
+                  >
+                    令牌从新 IP 请求时告警（需用户开启 IP 记录）
+                  </Form.Checkbox>
+                  <Form.Checkbox
+                    field="['spend_anomaly.notify_root']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('spend_anomaly.notify_root', e)
+                    }
+                  >
+                    通知管理员
+                  </Form.Checkbox>
+                  <Form.Checkbox
+                    field="['spend_anomaly.notify_user']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('spend_anomaly.notify_user', e)
+                    }
+                  >
+                    通知用户
+                  </Form.Checkbox>
+                  <Form.Checkbox
+                    field="['spend_anomaly.suspend_token']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('spend_anomaly.suspend_token', e)
+                    }
+                  >
+                    消费或请求数异常时自动禁用令牌
+                  </Form.Checkbox>
+                  <Button onClick={submitSpendAnomalySettings}>
+                    保存用量异常检测设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
+              <Card>
+                <Form.Section text='时段价格'>
+                  <Text>
+                    按时段或限时促销调整价格，倍率与分组倍率相乘；时段规则取第一个命中的规则，促销取命中的促销中倍率最低的一个，两者同时命中时相乘
+                  </Text>
+                  <Form.Checkbox
+                    field="['price_schedule.enabled']"
+                    noLabel
+                    onChange={(e) =>
+                      handleCheckboxChange('price_schedule.enabled', e)
+                    }
+                  >
+                    启用时段价格
+                  </Form.Checkbox>
+                  <Row
+                    gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+                  >
+                    <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                      <Form.Input
+                        field="['price_schedule.timezone']"
+                        label='时区'
+                        placeholder='例如 Asia/Shanghai，留空使用服务器时区'
+                      />
+                    </Col>
+                    <Col xs={24}>
+                      <Form.TextArea
+                        field="['price_schedule.off_peak']"
+                        label='时段规则'
+                        placeholder='[{"name": "夜间优惠", "start_time": "00:00", "end_time": "08:00", "multiplier": 0.5, "models": ["gpt-4o*"], "groups": []}]，结束时间早于开始时间表示跨越零点，models 与 groups 为空表示全部'
+                        autosize
+                      />
+                    </Col>
+                    <Col xs={24}>
+                      <Form.TextArea
+                        field="['price_schedule.promotions']"
+                        label='限时促销'
+                        placeholder='[{"name": "周年庆", "start_timestamp": 1767225600, "end_timestamp": 1767830400, "multiplier": 0.8, "models": [], "groups": ["default"]}]'
+                        autosize
+                      />
+                    </Col>
+                  </Row>
+                  <Button onClick={submitPriceScheduleSettings}>
+                    保存时段价格设置
+                  </Button>
+                </Form.Section>
+              </Card>
+
               <Card>
                 <Form.Section text='配置 Turnstile'>
                   <Text>用以支持用户校验</Text>
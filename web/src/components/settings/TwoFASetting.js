@@ -0,0 +1,194 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import { QRCodeSVG } from 'qrcode.react';
+import { Button, Card, Input, Modal, Space, Tag, Typography } from '@douyinfe/semi-ui';
+import { ShieldCheck } from 'lucide-react';
+import { API, showError, showSuccess } from '../../helpers';
+
+// 个人设置中的两步验证管理：绑定、关闭、重新生成恢复码
+const TwoFASetting = () => {
+  const { t } = useTranslation();
+  const [status, setStatus] = useState({
+    enabled: false,
+    backup_codes_remaining: 0,
+    required: false,
+  });
+  const [enrollment, setEnrollment] = useState(null);
+  // action: setup | disable | backup_codes
+  const [action, setAction] = useState('');
+  const [code, setCode] = useState('');
+  const [loading, setLoading] = useState(false);
+
+  const loadStatus = async () => {
+    const res = await API.get('/api/user/self/2fa');
+    const { success, message, data } = res.data;
+    if (success) {
+      setStatus(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadStatus().then();
+  }, []);
+
+  const showBackupCodes = (codes) => {
+    Modal.info({
+      title: t('请妥善保存恢复码'),
+      content: (
+        <div>
+          <Typography.Paragraph>
+            {t('每个恢复码只能使用一次，丢失身份验证器时可用于登录')}
+          </Typography.Paragraph>
+          <Typography.Paragraph copyable>{codes.join('\n')}</Typography.Paragraph>
+        </div>
+      ),
+      centered: true,
+    });
+  };
+
+  const closeModal = () => {
+    setAction('');
+    setCode('');
+    setEnrollment(null);
+  };
+
+  const startSetup = async () => {
+    const res = await API.post('/api/user/self/2fa/setup');
+    const { success, message, data } = res.data;
+    if (success) {
+      setEnrollment(data);
+      setAction('setup');
+    } else {
+      showError(message);
+    }
+  };
+
+  const submit = async () => {
+    if (!code) {
+      showError(t('请输入验证码'));
+      return;
+    }
+    const urls = {
+      setup: '/api/user/self/2fa/enable',
+      disable: '/api/user/self/2fa/disable',
+      backup_codes: '/api/user/self/2fa/backup_codes',
+    };
+    setLoading(true);
+    try {
+      const res = await API.post(urls[action], { code });
+      const { success, message, data } = res.data;
+      if (!success) {
+        showError(message);
+        return;
+      }
+      showSuccess(t('操作成功'));
+      closeModal();
+      if (data && data.backup_codes) {
+        showBackupCodes(data.backup_codes);
+      }
+      await loadStatus();
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <Card
+      className="!rounded-xl w-full"
+      bodyStyle={{ padding: '20px' }}
+      shadows='hover'
+    >
+      <div className="flex flex-col sm:flex-row items-start sm:justify-between gap-4">
+        <div className="flex items-start w-full sm:w-auto">
+          <div className="w-12 h-12 rounded-full bg-slate-100 flex items-center justify-center mr-4 flex-shrink-0">
+            <ShieldCheck size={20} className="text-slate-600" />
+          </div>
+          <div>
+            <Typography.Title heading={6} className="mb-1">
+              {t('两步验证')}{' '}
+              {status.enabled ? (
+                <Tag color='green'>{t('已启用')}</Tag>
+              ) : (
+                <Tag color='grey'>{t('未启用')}</Tag>
+              )}
+            </Typography.Title>
+            <Typography.Text type="tertiary" className="text-sm">
+              {status.enabled
+                ? t('剩余恢复码：{{count}} 个', {
+                    count: status.backup_codes_remaining,
+                  })
+                : t('登录时除密码外还需输入身份验证器中的验证码')}
+            </Typography.Text>
+          </div>
+        </div>
+        {status.enabled ? (
+          <Space>
+            <Button
+              onClick={() => setAction('backup_codes')}
+              className="!rounded-lg"
+            >
+              {t('重新生成恢复码')}
+            </Button>
+            {!status.required && (
+              <Button
+                type="danger"
+                onClick={() => setAction('disable')}
+                className="!rounded-lg"
+              >
+                {t('关闭')}
+              </Button>
+            )}
+          </Space>
+        ) : (
+          <Button
+            type="primary"
+            theme="solid"
+            onClick={startSetup}
+            className="!rounded-lg !bg-slate-600 hover:!bg-slate-700 w-full sm:w-auto"
+          >
+            {t('启用')}
+          </Button>
+        )}
+      </div>
+
+      <Modal
+        title={t('两步验证')}
+        visible={action !== ''}
+        onCancel={closeModal}
+        onOk={submit}
+        confirmLoading={loading}
+        size={'small'}
+        centered={true}
+      >
+        <div className="space-y-4 py-4">
+          {action === 'setup' && enrollment && (
+            <div className="flex flex-col items-center gap-2">
+              <Typography.Text>
+                {t('请使用身份验证器扫描二维码，或手动输入密钥')}
+              </Typography.Text>
+              <QRCodeSVG value={enrollment.otpauth_url} size={160} />
+              <Typography.Text copyable type="tertiary">
+                {enrollment.secret}
+              </Typography.Text>
+            </div>
+          )}
+          <Input
+            placeholder={
+              action === 'setup'
+                ? t('请输入身份验证器中的6位验证码')
+                : t('请输入6位验证码或恢复码')
+            }
+            value={code}
+            onChange={(value) => setCode(value.trim())}
+            size="large"
+            className="!rounded-lg"
+          />
+        </div>
+      </Modal>
+    </Card>
+  );
+};
+
+export default TwoFASetting;
@@ -4,6 +4,7 @@ import {
   API,
   copy,
   getTodayStartTimestamp,
+  hasPermission,
   isAdmin,
   showError,
   showSuccess,
@@ -18,6 +19,7 @@ import {
   renderModelPriceSimple,
   renderNumber,
   renderQuota,
+  renderQuotaWithPrompt,
   stringToColor,
   getLogOther,
   renderModelTag,
@@ -39,7 +41,10 @@ import {
   Card,
   Typography,
   Divider,
+  Dropdown,
   Form,
+  Input,
+  InputNumber,
 } from '@douyinfe/semi-ui';
 import {
   IllustrationNoResult,
@@ -106,6 +111,12 @@ const LogsTable = () => {
             {t('错误')}
           </Tag>
         );
+      case 6:
+        return (
+          <Tag color='indigo' size='large' shape='circle'>
+            {t('调整')}
+          </Tag>
+        );
       default:
         return (
           <Tag color='grey' size='large' shape='circle'>
@@ -544,6 +555,15 @@ const LogsTable = () => {
       title: t('花费'),
       dataIndex: 'quota',
       render: (text, record, index) => {
+        if (record.type === 6) {
+          const other = getLogOther(record.other);
+          return (
+            <>
+              {other?.adjustment_type === 'debit' ? '-' : '+'}
+              {renderQuota(text, 6)}
+            </>
+          );
+        }
         return record.type === 0 || record.type === 2 || record.type === 5 ? (
           <>{renderQuota(text, 6)}</>
         ) : (
@@ -764,10 +784,12 @@ const LogsTable = () => {
   const [showStat, setShowStat] = useState(false);
   const [loading, setLoading] = useState(false);
   const [loadingStat, setLoadingStat] = useState(false);
+  const [exporting, setExporting] = useState(false);
   const [activePage, setActivePage] = useState(1);
   const [logCount, setLogCount] = useState(ITEMS_PER_PAGE);
   const [pageSize, setPageSize] = useState(ITEMS_PER_PAGE);
   const [logType, setLogType] = useState(0);
+  const [refundingLog, setRefundingLog] = useState(null);
   const isAdminUser = isAdmin();
   let now = new Date();
 
@@ -778,6 +800,7 @@ const LogsTable = () => {
     model_name: '',
     channel: '',
     group: '',
+    tag: '',
     dateRange: [
       timestamp2string(getTodayStartTimestamp()),
       timestamp2string(now.getTime() / 1000 + 3600),
@@ -818,6 +841,7 @@ const LogsTable = () => {
       end_timestamp,
       channel: formValues.channel || '',
       group: formValues.group || '',
+      tag: formValues.tag || '',
       logType: formValues.logType ? parseInt(formValues.logType) : 0,
     };
   };
@@ -829,12 +853,13 @@ const LogsTable = () => {
       start_timestamp,
       end_timestamp,
       group,
+      tag,
       logType: formLogType,
     } = getFormValues();
     const currentLogType = formLogType !== undefined ? formLogType : logType;
     let localStartTimestamp = Date.parse(start_timestamp) / 1000;
     let localEndTimestamp = Date.parse(end_timestamp) / 1000;
-    let url = `/api/log/self/stat?type=${currentLogType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&group=${group}`;
+    let url = `/api/log/self/stat?type=${currentLogType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&group=${group}&tag=${tag}`;
     url = encodeURI(url);
     let res = await API.get(url);
     const { success, message, data } = res.data;
@@ -854,12 +879,13 @@ const LogsTable = () => {
       end_timestamp,
       channel,
       group,
+      tag,
       logType: formLogType,
     } = getFormValues();
     const currentLogType = formLogType !== undefined ? formLogType : logType;
     let localStartTimestamp = Date.parse(start_timestamp) / 1000;
     let localEndTimestamp = Date.parse(end_timestamp) / 1000;
-    let url = `/api/log/stat?type=${currentLogType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&group=${group}`;
+    let url = `/api/log/stat?type=${currentLogType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&group=${group}&tag=${tag}`;
     url = encodeURI(url);
     let res = await API.get(url);
     const { success, message, data } = res.data;
@@ -870,6 +896,44 @@ const LogsTable = () => {
     }
   };
 
+  const exportLogs = async (format) => {
+    const {
+      username,
+      token_name,
+      model_name,
+      start_timestamp,
+      end_timestamp,
+      channel,
+      group,
+      tag,
+      logType: formLogType,
+    } = getFormValues();
+    const currentLogType = formLogType !== undefined ? formLogType : logType;
+    let localStartTimestamp = Date.parse(start_timestamp) / 1000;
+    let localEndTimestamp = Date.parse(end_timestamp) / 1000;
+    let url = '';
+    if (isAdminUser) {
+      url = `/api/log/export?format=${format}&type=${currentLogType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&group=${group}&tag=${tag}`;
+    } else {
+      url = `/api/log/self/export?format=${format}&type=${currentLogType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&group=${group}&tag=${tag}`;
+    }
+    url = encodeURI(url);
+    setExporting(true);
+    try {
+      const res = await API.get(url, { responseType: 'blob' });
+      const blob = new Blob([res.data]);
+      const link = document.createElement('a');
+      link.href = window.URL.createObjectURL(blob);
+      link.download = `usage-${Date.now()}.${format}`;
+      link.click();
+      window.URL.revokeObjectURL(link.href);
+    } catch (error) {
+      showError(error.message);
+    } finally {
+      setExporting(false);
+    }
+  };
+
   const handleEyeClick = async () => {
     if (loadingStat) {
       return;
@@ -916,6 +980,58 @@ const LogsTable = () => {
     }
   };
 
+  const showLogPayload = async (logId) => {
+    const res = await API.get(`/api/log/payload?log_id=${logId}`);
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    const preStyle = {
+      maxHeight: 300,
+      overflow: 'auto',
+      whiteSpace: 'pre-wrap',
+      wordBreak: 'break-all',
+    };
+    Modal.info({
+      title: t('请求内容'),
+      width: 800,
+      centered: true,
+      content: (
+        <div>
+          {data.truncated && (
+            <Typography.Text type='warning'>
+              {t('内容超出大小限制，已截断')}
+            </Typography.Text>
+          )}
+          <Typography.Title heading={6}>{t('请求')}</Typography.Title>
+          <pre style={preStyle}>{data.request}</pre>
+          <Typography.Title heading={6}>{t('响应')}</Typography.Title>
+          <pre style={preStyle}>{data.response}</pre>
+        </div>
+      ),
+    });
+  };
+
+  // 管理员退还一条消费日志的额度
+  const refundLog = async () => {
+    const res = await API.post('/api/user/quota_adjustment', {
+      user_id: refundingLog.log.user_id,
+      type: 'refund',
+      quota: refundingLog.quota,
+      log_id: refundingLog.log.id,
+      reason: refundingLog.reason,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('退款成功'));
+      setRefundingLog(null);
+      await refresh();
+    } else {
+      showError(message);
+    }
+  };
+
   const setLogsFormat = (logs) => {
     let expandDatesLocal = {};
     for (let i = 0; i < logs.length; i++) {
@@ -948,6 +1064,53 @@ const LogsTable = () => {
           value: `${logs[i].channel} - ${logs[i].channel_name || '[未知]'}`,
         });
       }
+      // 套餐抵扣的部分不退回余额，只能退还余额支付的额度
+      const refundable = logs[i].quota - (other?.subscription_quota || 0);
+      if (
+        logs[i].type === 2 &&
+        isAdminUser &&
+        hasPermission('user.manage') &&
+        refundable > 0
+      ) {
+        const log = logs[i];
+        expandDataLocal.push({
+          key: t('退款'),
+          value: (
+            <Button
+              size='small'
+              onClick={() =>
+                setRefundingLog({
+                  log,
+                  quota: refundable,
+                  refundable,
+                  reason: '',
+                })
+              }
+            >
+              {t('退还额度')}
+            </Button>
+          ),
+        });
+      }
+      if (logs[i].type === 6 && other?.log_id) {
+        expandDataLocal.push({
+          key: t('关联消费日志'),
+          value: isAdminUser
+            ? `#${other.log_id} ${timestamp2string(other.log_created_at)}`
+            : timestamp2string(other.log_created_at),
+        });
+      }
+      if (other?.request_id && hasPermission('log.payload.view')) {
+        const logId = logs[i].id;
+        expandDataLocal.push({
+          key: t('请求内容'),
+          value: (
+            <Button size='small' onClick={() => showLogPayload(logId)}>
+              {t('查看')}
+            </Button>
+          ),
+        });
+      }
       if (other?.ws || other?.audio) {
         expandDataLocal.push({
           key: t('语音输入'),
@@ -1088,6 +1251,42 @@ const LogsTable = () => {
           });
         }
       }
+      if (other?.price_tier) {
+        expandDataLocal.push({
+          key: t('阶梯价格'),
+          value: t('提示超过 {{tokens}} tokens', { tokens: other.price_tier }),
+        });
+      }
+      if (other?.schedule_ratio !== undefined) {
+        expandDataLocal.push({
+          key: t('时段倍率'),
+          value: `${other.schedule_ratio}x${other.schedule_rules ? `（${other.schedule_rules.join('，')}）` : ''}`,
+        });
+      }
+      if (other?.price_override_id) {
+        expandDataLocal.push({
+          key: t('价格覆盖'),
+          value: `#${other.price_override_id}`,
+        });
+      }
+      if (other?.subscription_quota) {
+        expandDataLocal.push({
+          key: t('套餐抵扣'),
+          value: renderQuota(other.subscription_quota, 6),
+        });
+      }
+      if (isAdminUser && logs[i].upstream_cost) {
+        expandDataLocal.push({
+          key: t('上游成本'),
+          value: renderQuota(logs[i].upstream_cost, 6),
+        });
+      }
+      if (logs[i].tags) {
+        expandDataLocal.push({
+          key: t('标签'),
+          value: logs[i].tags,
+        });
+      }
       expandDatesLocal[logs[i].key] = expandDataLocal;
     }
 
@@ -1107,6 +1306,7 @@ const LogsTable = () => {
       end_timestamp,
       channel,
       group,
+      tag,
       logType: formLogType,
     } = getFormValues();
 
@@ -1121,9 +1321,9 @@ const LogsTable = () => {
     let localStartTimestamp = Date.parse(start_timestamp) / 1000;
     let localEndTimestamp = Date.parse(end_timestamp) / 1000;
     if (isAdminUser) {
-      url = `/api/log/?p=${startIdx}&page_size=${pageSize}&type=${currentLogType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&group=${group}`;
+      url = `/api/log/?p=${startIdx}&page_size=${pageSize}&type=${currentLogType}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&group=${group}&tag=${tag}`;
     } else {
-      url = `/api/log/self/?p=${startIdx}&page_size=${pageSize}&type=${currentLogType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&group=${group}`;
+      url = `/api/log/self/?p=${startIdx}&page_size=${pageSize}&type=${currentLogType}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&group=${group}&tag=${tag}`;
     }
     url = encodeURI(url);
     const res = await API.get(url);
@@ -1206,6 +1406,40 @@ const LogsTable = () => {
   return (
     <>
       {renderColumnSelector()}
+      <Modal
+        title={t('退还额度')}
+        visible={refundingLog !== null}
+        onOk={refundLog}
+        onCancel={() => setRefundingLog(null)}
+        centered
+      >
+        {refundingLog && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <Text type='tertiary'>
+              {refundingLog.log.username} · {refundingLog.log.model_name} ·{' '}
+              {timestamp2string(refundingLog.log.created_at)}
+            </Text>
+            <InputNumber
+              prefix={t('退款额度')}
+              value={refundingLog.quota}
+              min={1}
+              max={refundingLog.refundable}
+              onChange={(value) =>
+                setRefundingLog({ ...refundingLog, quota: value })
+              }
+              suffix={renderQuotaWithPrompt(refundingLog.quota)}
+              style={{ width: '100%' }}
+            />
+            <Input
+              prefix={t('原因')}
+              value={refundingLog.reason}
+              onChange={(value) =>
+                setRefundingLog({ ...refundingLog, reason: value })
+              }
+            />
+          </Space>
+        )}
+      </Modal>
       <Card
         className='!rounded-2xl mb-4'
         title={
@@ -1315,6 +1549,14 @@ const LogsTable = () => {
                     pure
                   />
 
+                  <Form.Input
+                    field='tag'
+                    prefix={<IconSearch />}
+                    placeholder={t('标签，如 project=search')}
+                    showClear
+                    pure
+                  />
+
                   {isAdminUser && (
                     <>
                       <Form.Input
@@ -1370,6 +1612,9 @@ const LogsTable = () => {
                       <Form.Select.Option value='5'>
                         {t('错误')}
                       </Form.Select.Option>
+                      <Form.Select.Option value='6'>
+                        {t('调整')}
+                      </Form.Select.Option>
                     </Form.Select>
                   </div>
 
@@ -1402,6 +1647,22 @@ const LogsTable = () => {
                     >
                       {t('列设置')}
                     </Button>
+                    <Dropdown
+                      render={
+                        <Dropdown.Menu>
+                          <Dropdown.Item onClick={() => exportLogs('csv')}>
+                            CSV
+                          </Dropdown.Item>
+                          <Dropdown.Item onClick={() => exportLogs('jsonl')}>
+                            JSONL
+                          </Dropdown.Item>
+                        </Dropdown.Menu>
+                      }
+                    >
+                      <Button theme='light' type='tertiary' loading={exporting}>
+                        {t('导出')}
+                      </Button>
+                    </Dropdown>
                   </div>
                 </div>
               </div>
@@ -1,5 +1,5 @@
 import React, { useContext, useEffect, useRef, useMemo, useState } from 'react';
-import { API, copy, showError, showInfo, showSuccess, getModelCategories, renderModelTag, stringToColor } from '../../helpers';
+import { API, copy, showError, showInfo, showSuccess, getModelCategories, renderModelTag, stringToColor, timestamp2string } from '../../helpers';
 import { useTranslation } from 'react-i18next';
 
 import {
@@ -267,6 +267,29 @@ const ModelPricing = () => {
               <div className="text-gray-700">
                 {t('补全')} ${completionRatioPrice.toFixed(3)} / 1M tokens
               </div>
+              {(record.price_tiers || []).map((tier) => {
+                let tierCompletionRatio =
+                  tier.completion_ratio || record.completion_ratio;
+                let tierInputPrice =
+                  tier.model_ratio * 2 * groupRatio[selectedGroup];
+                let tierCompletionPrice =
+                  tier.model_ratio *
+                  tierCompletionRatio *
+                  2 *
+                  groupRatio[selectedGroup];
+                return (
+                  <div
+                    key={tier.min_prompt_tokens}
+                    className="text-gray-500 text-xs"
+                  >
+                    {t('提示超过 {{tokens}} tokens', {
+                      tokens: tier.min_prompt_tokens,
+                    })}
+                    ：{t('提示')} ${tierInputPrice.toFixed(3)}，{t('补全')} $
+                    {tierCompletionPrice.toFixed(3)} / 1M tokens
+                  </div>
+                );
+              })}
             </div>
           );
         } else {
@@ -287,6 +310,9 @@ const ModelPricing = () => {
   const [userState] = useContext(UserContext);
   const [groupRatio, setGroupRatio] = useState({});
   const [usableGroup, setUsableGroup] = useState({});
+  const [priceSchedules, setPriceSchedules] = useState([]);
+  const [priceOverrides, setPriceOverrides] = useState([]);
+  const [now, setNow] = useState(Math.floor(Date.now() / 1000));
 
   const setModelsFormat = (models, groupRatio) => {
     for (let i = 0; i < models.length; i++) {
@@ -317,10 +343,11 @@ const ModelPricing = () => {
     setLoading(true);
     let url = '/api/pricing';
     const res = await API.get(url);
-    const { success, message, data, group_ratio, usable_group } = res.data;
+    const { success, message, data, group_ratio, usable_group, price_schedules } = res.data;
     if (success) {
       setGroupRatio(group_ratio);
       setUsableGroup(usable_group);
+      setPriceSchedules(price_schedules || []);
       setSelectedGroup(userState.user ? userState.user.group : 'default');
       setModelsFormat(data, group_ratio);
     } else {
@@ -329,8 +356,106 @@ const ModelPricing = () => {
     setLoading(false);
   };
 
+  // 登录用户的协议价
+  const loadPriceOverrides = async () => {
+    if (!userState.user) {
+      return;
+    }
+    const res = await API.get('/api/user/self/price_overrides?p=1&page_size=100');
+    const { success, data } = res.data;
+    if (success) {
+      setPriceOverrides(data.items || []);
+    }
+  };
+
   const refresh = async () => {
     await loadPricing();
+    await loadPriceOverrides();
+  };
+
+  // 价格时段倒计时，每秒刷新一次
+  useEffect(() => {
+    if (priceSchedules.length === 0) {
+      return;
+    }
+    const timer = setInterval(() => {
+      setNow(Math.floor(Date.now() / 1000));
+    }, 1000);
+    return () => clearInterval(timer);
+  }, [priceSchedules]);
+
+  const formatCountdown = (seconds) => {
+    if (seconds <= 0) {
+      return '00:00:00';
+    }
+    const days = Math.floor(seconds / 86400);
+    const pad = (n) => String(n).padStart(2, '0');
+    const clock = `${pad(Math.floor((seconds % 86400) / 3600))}:${pad(Math.floor((seconds % 3600) / 60))}:${pad(seconds % 60)}`;
+    return days > 0 ? `${days}${t('天')} ${clock}` : clock;
+  };
+
+  const renderPriceOverrides = () => {
+    if (priceOverrides.length === 0) {
+      return null;
+    }
+    return (
+      <Card className="!rounded-xl mb-4" bordered={false} title={t('我的协议价')}>
+        <div className="flex flex-col gap-2">
+          {priceOverrides.map((override) => (
+            <div key={override.id} className="flex flex-wrap items-center gap-2 text-sm">
+              <span className="font-semibold">{override.model_name}</span>
+              <Tag shape="circle">
+                {override.token_id === 0 ? t('全部令牌') : `${t('令牌')} #${override.token_id}`}
+              </Tag>
+              {override.model_price > 0 && <span>{t('按次价格')}: ${override.model_price}</span>}
+              {override.ratio_multiplier > 0 && <span>{t('倍率乘数')}: {override.ratio_multiplier}x</span>}
+              {override.completion_ratio > 0 && <span>{t('补全倍率')}: {override.completion_ratio}</span>}
+              <span className="text-gray-500">
+                {override.expired_time === -1 ? t('永不过期') : `${t('过期时间')}: ${timestamp2string(override.expired_time)}`}
+              </span>
+            </div>
+          ))}
+        </div>
+      </Card>
+    );
+  };
+
+  const renderPriceSchedules = () => {
+    if (priceSchedules.length === 0) {
+      return null;
+    }
+    return (
+      <Card className="!rounded-xl mb-4" bordered={false} title={t('限时价格')}>
+        <div className="flex flex-col gap-2">
+          {priceSchedules.map((schedule, index) => {
+            const active = now >= schedule.start_timestamp && now < schedule.end_timestamp;
+            const target = active ? schedule.end_timestamp : schedule.start_timestamp;
+            const scope = [
+              (schedule.models || []).length > 0 ? schedule.models.join(', ') : t('全部模型'),
+              (schedule.groups || []).length > 0 ? schedule.groups.join(', ') : t('全部分组'),
+            ].join(' / ');
+            return (
+              <div key={index} className="flex flex-wrap items-center gap-2 text-sm">
+                <Tag color={active ? 'green' : 'grey'} shape="circle">
+                  {active ? t('生效中') : t('未开始')}
+                </Tag>
+                <Tag color={schedule.type === 'promotion' ? 'red' : 'blue'} shape="circle">
+                  {schedule.type === 'promotion' ? t('促销') : t('时段')}
+                </Tag>
+                <span className="font-semibold">{schedule.name}</span>
+                <span>{t('价格倍率')}: {schedule.multiplier}x</span>
+                <span className="text-gray-500">{scope}</span>
+                <Tooltip content={`${timestamp2string(schedule.start_timestamp)} ~ ${timestamp2string(schedule.end_timestamp)}`}>
+                  <span className="text-gray-500">
+                    {active ? t('距离结束') : t('距离开始')}: {formatCountdown(target - now)}
+                  </span>
+                </Tooltip>
+              </div>
+            );
+          })}
+        </div>
+      </Card>
+    );
   };
 
   const copyText = async (text) => {
@@ -588,6 +713,10 @@ const ModelPricing = () => {
                 <div className="mb-6">
                   {renderTabs()}
 
+                  {/* 限时价格与协议价 */}
+                  {renderPriceSchedules()}
+                  {renderPriceOverrides()}
+
                   {/* 搜索和表格区域 */}
                   {SearchAndActions}
                   {ModelTable}
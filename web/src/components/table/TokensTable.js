@@ -1,7 +1,6 @@
 import React, { useEffect, useState } from 'react';
 import {
   API,
-  copy,
   showError,
   showSuccess,
   timestamp2string,
@@ -38,6 +37,9 @@ import EditToken from '../../pages/Token/EditToken';
 import { useTranslation } from 'react-i18next';
 import { useTableCompactMode } from '../../hooks/useTableCompactMode';
 
+// 令牌仅保存哈希，列表中只能展示前缀，需要明文密钥时只能重置
+const maskedKey = (record) => 'sk-' + (record.key_prefix || '') + '******';
+
 const { Text } = Typography;
 
 function renderTimestamp(timestamp) {
@@ -219,7 +221,7 @@ const TokensTable = () => {
             onClick: () => {
               Modal.info({
                 title: t('令牌详情'),
-                content: 'sk-' + record.key,
+                content: maskedKey(record),
                 size: 'large',
               });
             },
@@ -234,7 +236,7 @@ const TokensTable = () => {
                 content: t('此修改将不可逆'),
                 onOk: () => {
                   manageToken(record.id, 'delete', record).then(() => {
-                    removeRecord(record.key);
+                    removeRecord(record.id);
                   });
                 },
               });
@@ -308,11 +310,28 @@ const TokensTable = () => {
               theme='light'
               type='secondary'
               size="small"
-              onClick={async (text) => {
-                await copyText('sk-' + record.key);
+              onClick={() => {
+                Modal.confirm({
+                  title: t('重置令牌密钥'),
+                  content: t(
+                    '令牌只保存哈希，无法再次查看原密钥。重置后原密钥立即失效，是否继续？',
+                  ),
+                  onOk: async () => {
+                    const key = await regenerateKey(record);
+                    if (key) {
+                      Modal.info({
+                        title: t('请立即保存令牌，关闭后将无法再次查看'),
+                        content: (
+                          <Typography.Paragraph copyable>{key}</Typography.Paragraph>
+                        ),
+                        size: 'large',
+                      });
+                    }
+                  },
+                });
               }}
             >
-              {t('复制')}
+              {t('重置密钥')}
             </Button>
 
             <Button
@@ -410,19 +429,39 @@ const TokensTable = () => {
     setSelectedKeys([]);
   };
 
-  const copyText = async (text) => {
-    if (await copy(text)) {
-      showSuccess(t('已复制到剪贴板！'));
-    } else {
-      Modal.error({
-        title: t('无法复制到剪贴板，请手动复制'),
-        content: text,
-        size: 'large',
-      });
+  const regenerateKey = async (record) => {
+    const res = await API.post(`/api/token/${record.id}/regenerate`);
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(t(message));
+      return '';
     }
+    return data.key;
+  };
+
+  // 聊天链接需要明文密钥，打开前先重置令牌密钥
+  const onOpenLink = (type, url, record) => {
+    Modal.confirm({
+      title: t('重置令牌密钥'),
+      content: t('打开聊天链接需要重置此令牌的密钥，原密钥将立即失效，是否继续？'),
+      onOk: async () => {
+        const newWindow = window.open('', '_blank');
+        const key = await regenerateKey(record);
+        if (!key) {
+          newWindow && newWindow.close();
+          return;
+        }
+        const link = buildChatLink(url, key);
+        if (newWindow) {
+          newWindow.location.href = link;
+        } else {
+          window.open(link, '_blank');
+        }
+      },
+    });
   };
 
-  const onOpenLink = async (type, url, record) => {
+  const buildChatLink = (url, key) => {
     let status = localStorage.getItem('status');
     let serverAddress = '';
     if (status) {
@@ -436,7 +475,7 @@ const TokensTable = () => {
       let cherryConfig = {
         id: 'new-api',
         baseUrl: serverAddress,
-        apiKey: 'sk-' + record.key,
+        apiKey: key,
       }
       // 替换 {cherryConfig} 为base64编码的JSON字符串
       let encodedConfig = encodeURIComponent(
@@ -446,10 +485,9 @@ const TokensTable = () => {
     } else {
       let encodedServerAddress = encodeURIComponent(serverAddress);
       url = url.replaceAll('{address}', encodedServerAddress);
-      url = url.replaceAll('{key}', 'sk-' + record.key);
+      url = url.replaceAll('{key}', key);
     }
-
-    window.open(url, '_blank');
+    return url;
   };
 
   useEffect(() => {
@@ -460,10 +498,10 @@ const TokensTable = () => {
       });
   }, [pageSize]);
 
-  const removeRecord = (key) => {
+  const removeRecord = (id) => {
     let newDataSource = [...tokens];
-    if (key != null) {
-      let idx = newDataSource.findIndex((data) => data.key === key);
+    if (id != null) {
+      let idx = newDataSource.findIndex((data) => data.id === id);
 
       if (idx > -1) {
         newDataSource.splice(idx, 1);
@@ -628,56 +666,6 @@ const TokensTable = () => {
           >
             {t('添加令牌')}
           </Button>
-          <Button
-            theme="light"
-            type="warning"
-            className="flex-1 md:flex-initial"
-            onClick={() => {
-              if (selectedKeys.length === 0) {
-                showError(t('请至少选择一个令牌！'));
-                return;
-              }
-              Modal.info({
-                title: t('复制令牌'),
-                icon: null,
-                content: t('请选择你的复制方式'),
-                footer: (
-                  <Space>
-                    <Button
-                      type="primary"
-                      theme="solid"
-                      onClick={async () => {
-                        let content = '';
-                        for (let i = 0; i < selectedKeys.length; i++) {
-                          content +=
-                            selectedKeys[i].name + '    sk-' + selectedKeys[i].key + '\n';
-                        }
-                        await copyText(content);
-                        Modal.destroyAll();
-                      }}
-                    >
-                      {t('名称+密钥')}
-                    </Button>
-                    <Button
-                      theme="light"
-                      onClick={async () => {
-                        let content = '';
-                        for (let i = 0; i < selectedKeys.length; i++) {
-                          content += 'sk-' + selectedKeys[i].key + '\n';
-                        }
-                        await copyText(content);
-                        Modal.destroyAll();
-                      }}
-                    >
-                      {t('仅密钥')}
-                    </Button>
-                  </Space>
-                ),
-              });
-            }}
-          >
-            {t('复制所选令牌')}
-          </Button>
           <Button
             theme="light"
             type="danger"
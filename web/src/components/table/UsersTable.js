@@ -41,6 +41,9 @@ import {
 import { ITEMS_PER_PAGE } from '../../constants';
 import AddUser from '../../pages/User/AddUser';
 import EditUser from '../../pages/User/EditUser';
+import PriceOverrides from '../../pages/User/PriceOverrides';
+import UserSubscriptions from '../../pages/User/UserSubscriptions';
+import QuotaAdjustments from '../../pages/User/QuotaAdjustments';
 import { useTranslation } from 'react-i18next';
 import { useTableCompactMode } from '../../hooks/useTableCompactMode';
 
@@ -238,6 +241,44 @@ const UsersTable = () => {
               });
             },
           },
+          {
+            node: 'item',
+            name: t('价格覆盖'),
+            type: 'secondary',
+            onClick: () => {
+              setPriceOverrideUser(record);
+            },
+          },
+          {
+            node: 'item',
+            name: t('调整额度'),
+            type: 'secondary',
+            onClick: () => {
+              setAdjustmentUser(record);
+            },
+          },
+          {
+            node: 'item',
+            name: t('订阅套餐'),
+            type: 'secondary',
+            onClick: () => {
+              setSubscriptionUser(record);
+            },
+          },
+          {
+            node: 'item',
+            name: t('重置两步验证'),
+            type: 'secondary',
+            onClick: () => {
+              Modal.confirm({
+                title: t('确定要重置此用户的两步验证吗？'),
+                content: t('将同时删除该用户绑定的身份验证器与通行密钥'),
+                onOk: () => {
+                  manageUser(record.id, 'disable_2fa', record);
+                },
+              });
+            },
+          },
           {
             node: 'item',
             name: t('注销'),
@@ -318,6 +359,9 @@ const UsersTable = () => {
   const [userCount, setUserCount] = useState(ITEMS_PER_PAGE);
   const [showAddUser, setShowAddUser] = useState(false);
   const [showEditUser, setShowEditUser] = useState(false);
+  const [priceOverrideUser, setPriceOverrideUser] = useState(null);
+  const [subscriptionUser, setSubscriptionUser] = useState(null);
+  const [adjustmentUser, setAdjustmentUser] = useState(null);
   const [editingUser, setEditingUser] = useState({
     id: undefined,
   });
@@ -632,6 +676,22 @@ const UsersTable = () => {
         handleClose={closeEditUser}
         editingUser={editingUser}
       ></EditUser>
+      <PriceOverrides
+        visible={priceOverrideUser !== null}
+        user={priceOverrideUser}
+        handleClose={() => setPriceOverrideUser(null)}
+      />
+      <UserSubscriptions
+        visible={subscriptionUser !== null}
+        user={subscriptionUser}
+        handleClose={() => setSubscriptionUser(null)}
+      />
+      <QuotaAdjustments
+        visible={adjustmentUser !== null}
+        user={adjustmentUser}
+        handleClose={() => setAdjustmentUser(null)}
+        refresh={refresh}
+      />
 
       <Card
         className="!rounded-2xl"
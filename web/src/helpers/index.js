@@ -6,3 +6,4 @@ export * from './render';
 export * from './log';
 export * from './data';
 export * from './token';
+export * from './passkey';
@@ -0,0 +1,91 @@
+// WebAuthn 的二进制字段在接口中使用 base64url 编码，调用浏览器 API 前后需要转换
+
+function base64UrlToBuffer(value) {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4);
+  const binary = atob(padded);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+}
+
+function bufferToBase64Url(buffer) {
+  const bytes = new Uint8Array(buffer);
+  let binary = '';
+  for (let i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+export function isPasskeySupported() {
+  return (
+    typeof window !== 'undefined' &&
+    window.PublicKeyCredential !== undefined &&
+    navigator.credentials !== undefined
+  );
+}
+
+// createPasskey 使用 /api/user/passkey/register/begin 返回的参数创建凭据，返回可直接提交的 JSON
+export async function createPasskey(options) {
+  const publicKey = { ...options.publicKey };
+  publicKey.challenge = base64UrlToBuffer(publicKey.challenge);
+  publicKey.user = {
+    ...publicKey.user,
+    id: base64UrlToBuffer(publicKey.user.id),
+  };
+  if (publicKey.excludeCredentials) {
+    publicKey.excludeCredentials = publicKey.excludeCredentials.map((c) => ({
+      ...c,
+      id: base64UrlToBuffer(c.id),
+    }));
+  }
+  const credential = await navigator.credentials.create({ publicKey });
+  return {
+    id: credential.id,
+    rawId: bufferToBase64Url(credential.rawId),
+    type: credential.type,
+    authenticatorAttachment: credential.authenticatorAttachment,
+    clientExtensionResults: credential.getClientExtensionResults(),
+    response: {
+      clientDataJSON: bufferToBase64Url(credential.response.clientDataJSON),
+      attestationObject: bufferToBase64Url(
+        credential.response.attestationObject,
+      ),
+      transports: credential.response.getTransports
+        ? credential.response.getTransports()
+        : [],
+    },
+  };
+}
+
+// getPasskeyAssertion 使用登录接口返回的参数进行验证，返回可直接提交的 JSON
+export async function getPasskeyAssertion(options) {
+  const publicKey = { ...options.publicKey };
+  publicKey.challenge = base64UrlToBuffer(publicKey.challenge);
+  if (publicKey.allowCredentials) {
+    publicKey.allowCredentials = publicKey.allowCredentials.map((c) => ({
+      ...c,
+      id: base64UrlToBuffer(c.id),
+    }));
+  }
+  const credential = await navigator.credentials.get({ publicKey });
+  const response = credential.response;
+  return {
+    id: credential.id,
+    rawId: bufferToBase64Url(credential.rawId),
+    type: credential.type,
+    authenticatorAttachment: credential.authenticatorAttachment,
+    clientExtensionResults: credential.getClientExtensionResults(),
+    response: {
+      clientDataJSON: bufferToBase64Url(response.clientDataJSON),
+      authenticatorData: bufferToBase64Url(response.authenticatorData),
+      signature: bufferToBase64Url(response.signature),
+      userHandle: response.userHandle
+        ? bufferToBase64Url(response.userHandle)
+        : undefined,
+    },
+  };
+}
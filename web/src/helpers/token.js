@@ -1,18 +1,17 @@
 import { API } from './api';
 
 /**
- * 获取可用的token keys
- * @returns {Promise<string[]>} 返回active状态的token key数组
+ * 获取聊天链接使用的token key
+ * 令牌只保存哈希，每次调用都会重置专用聊天令牌的密钥并返回新的明文密钥（不含 sk- 前缀）
+ * @returns {Promise<string[]>} 返回token key数组
  */
 export async function fetchTokenKeys() {
   try {
-    const response = await API.get('/api/token/?p=1&size=10');
+    const response = await API.post('/api/token/chat_key');
     const { success, data } = response.data;
     if (!success) throw new Error('Failed to fetch token keys');
 
-    const tokenItems = Array.isArray(data) ? data : data.items || [];
-    const activeTokens = tokenItems.filter((token) => token.status === 1);
-    return activeTokens.map((token) => token.key);
+    return [data.key.replace(/^sk-/, '')];
   } catch (error) {
     console.error('Error fetching token keys:', error);
     return [];
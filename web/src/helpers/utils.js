@@ -23,6 +23,15 @@ export function isRoot() {
   return user.role >= 100;
 }
 
+export function hasPermission(permission) {
+  let user = localStorage.getItem('user');
+  if (!user) return false;
+  user = JSON.parse(user);
+  if (user.role >= 100) return true;
+  if (user.role < 10) return false;
+  return Array.isArray(user.permissions) && user.permissions.includes(permission);
+}
+
 export function getSystemName() {
   let system_name = localStorage.getItem('system_name');
   if (!system_name) return 'New API';
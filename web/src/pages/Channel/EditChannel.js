@@ -3,6 +3,7 @@ import { useNavigate } from 'react-router-dom';
 import { useTranslation } from 'react-i18next';
 import {
   API,
+  hasPermission,
   isMobile,
   showError,
   showInfo,
@@ -79,6 +80,24 @@ const EditChannel = (props) => {
   const navigate = useNavigate();
   const channelId = props.editingChannel.id;
   const isEdit = channelId !== undefined;
+
+  const viewChannelKey = async () => {
+    const res = await API.get(`/api/channel/${channelId}/key`);
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    Modal.info({
+      title: t('渠道密钥'),
+      content: (
+        <Typography.Paragraph copyable style={{ wordBreak: 'break-all' }}>
+          {data.key}
+        </Typography.Paragraph>
+      ),
+      centered: true,
+    });
+  };
   const [loading, setLoading] = useState(isEdit);
   const handleCancel = () => {
     props.handleClose();
@@ -603,6 +622,11 @@ const EditChannel = (props) => {
                       )}
                     </>
                   )}
+                  {isEdit && hasPermission('channel.key.view') && (
+                    <Button size='small' type='tertiary' onClick={viewChannelKey}>
+                      {t('查看当前密钥')}
+                    </Button>
+                  )}
                 </Card>
 
                 {/* API Configuration Card */}
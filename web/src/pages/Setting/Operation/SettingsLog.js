@@ -14,9 +14,27 @@ export default function SettingsLog(props) {
   const { t } = useTranslation();
   const [loading, setLoading] = useState(false);
   const [loadingCleanHistoryLog, setLoadingCleanHistoryLog] = useState(false);
+  const [loadingRetention, setLoadingRetention] = useState(false);
   const [inputs, setInputs] = useState({
     LogConsumeEnabled: false,
     historyTimestamp: dayjs().subtract(1, 'month').toDate(),
+    'log_retention.enabled': false,
+    'log_retention.run_hour': '',
+    'log_retention.consume_days': '',
+    'log_retention.error_days': '',
+    'log_retention.topup_days': '',
+    'log_retention.manage_days': '',
+    'log_retention.system_days': '',
+    'log_retention.archive_enabled': false,
+    'log_retention.archive_target': 'local',
+    'log_retention.archive_dir': '',
+    'log_retention.s3_endpoint': '',
+    'log_retention.s3_region': '',
+    'log_retention.s3_bucket': '',
+    'log_retention.s3_prefix': '',
+    'log_retention.s3_access_key': '',
+    'log_retention.s3_secret_key': '',
+    'log_retention.s3_path_style': false,
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -79,11 +97,37 @@ export default function SettingsLog(props) {
     }
   }
 
+  async function onRunRetention() {
+    try {
+      setLoadingRetention(true);
+      const res = await API.post('/api/log/retention/run');
+      const { success, message, data } = res.data;
+      if (success) {
+        showSuccess(data);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoadingRetention(false);
+    }
+  }
+
+  const setField = (key) => (value) => {
+    setInputs({
+      ...inputs,
+      [key]: value,
+    });
+  };
+
   useEffect(() => {
     const currentInputs = {};
     for (let key in props.options) {
       if (Object.keys(inputs).includes(key)) {
         currentInputs[key] = props.options[key];
+        if (typeof inputs[key] === 'boolean') {
+          currentInputs[key] =
+            props.options[key] === true || props.options[key] === 'true';
+        }
       }
     }
     currentInputs['historyTimestamp'] = inputs.historyTimestamp;
@@ -137,6 +181,120 @@ export default function SettingsLog(props) {
               </Col>
             </Row>
 
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={"['log_retention.enabled']"}
+                  label={t('自动清理过期日志')}
+                  extraText={t('仅在主节点运行')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={setField('log_retention.enabled')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={"['log_retention.run_hour']"}
+                  label={t('每天执行时间（时）')}
+                  placeholder='0-23'
+                  onChange={setField('log_retention.run_hour')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Spin spinning={loadingRetention}>
+                  <Form.Slot label={t('手动执行')}>
+                    <Button size='default' onClick={onRunRetention}>
+                      {t('立即执行清理')}
+                    </Button>
+                  </Form.Slot>
+                </Spin>
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              {[
+                ['log_retention.consume_days', t('消费日志保存天数')],
+                ['log_retention.error_days', t('错误日志保存天数')],
+                ['log_retention.topup_days', t('充值日志保存天数')],
+                ['log_retention.manage_days', t('管理日志保存天数')],
+                ['log_retention.system_days', t('系统日志保存天数')],
+              ].map(([key, label]) => (
+                <Col xs={24} sm={12} md={8} lg={8} xl={8} key={key}>
+                  <Form.Input
+                    field={`['${key}']`}
+                    label={label}
+                    placeholder={t('0 表示永久保存')}
+                    onChange={setField(key)}
+                  />
+                </Col>
+              ))}
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={"['log_retention.archive_enabled']"}
+                  label={t('删除前归档')}
+                  extraText={t('导出为 gzip 压缩的 JSONL 文件')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={setField('log_retention.archive_enabled')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Select
+                  field={"['log_retention.archive_target']"}
+                  label={t('归档位置')}
+                  optionList={[
+                    { label: t('本地目录'), value: 'local' },
+                    { label: 'S3', value: 's3' },
+                  ]}
+                  onChange={setField('log_retention.archive_target')}
+                />
+              </Col>
+              {inputs['log_retention.archive_target'] !== 's3' ? (
+                <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                  <Form.Input
+                    field={"['log_retention.archive_dir']"}
+                    label={t('归档目录')}
+                    placeholder='./logs/archive'
+                    onChange={setField('log_retention.archive_dir')}
+                  />
+                </Col>
+              ) : (
+                <>
+                  {[
+                    ['log_retention.s3_endpoint', 'Endpoint', 'https://s3.amazonaws.com'],
+                    ['log_retention.s3_region', 'Region', 'us-east-1'],
+                    ['log_retention.s3_bucket', 'Bucket', ''],
+                    ['log_retention.s3_prefix', t('路径前缀'), 'newapi/logs'],
+                    ['log_retention.s3_access_key', 'Access Key', ''],
+                    ['log_retention.s3_secret_key', 'Secret Key', t('敏感信息不会发送到前端显示')],
+                  ].map(([key, label, placeholder]) => (
+                    <Col xs={24} sm={12} md={8} lg={8} xl={8} key={key}>
+                      <Form.Input
+                        field={`['${key}']`}
+                        label={label}
+                        placeholder={placeholder}
+                        type={key.endsWith('secret_key') ? 'password' : 'text'}
+                        onChange={setField(key)}
+                      />
+                    </Col>
+                  ))}
+                  <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                    <Form.Switch
+                      field={"['log_retention.s3_path_style']"}
+                      label={t('使用路径风格访问')}
+                      size='default'
+                      checkedText='｜'
+                      uncheckedText='〇'
+                      onChange={setField('log_retention.s3_path_style')}
+                    />
+                  </Col>
+                </>
+              )}
+            </Row>
+
             <Row>
               <Button size='default' onClick={onSubmit}>
                 {t('保存日志设置')}
@@ -0,0 +1,288 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  InputNumber,
+  Input,
+  Modal,
+  Space,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  renderQuota,
+  renderQuotaWithPrompt,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../helpers';
+
+// 账期额度审批与结算单管理
+export default function SettingsCreditAccounts() {
+  const { t } = useTranslation();
+  const [accounts, setAccounts] = useState([]);
+  const [statements, setStatements] = useState([]);
+  const [reviewing, setReviewing] = useState(null);
+  const [loading, setLoading] = useState(false);
+
+  const loadAccounts = async () => {
+    const res = await API.get('/api/credit/?p=1');
+    const { success, message, data } = res.data;
+    if (success) {
+      setAccounts(data.items || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadStatements = async () => {
+    const res = await API.get('/api/credit/statement?p=1&status=1');
+    const { success, message, data } = res.data;
+    if (success) {
+      setStatements(data.items || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadAccounts().then();
+    loadStatements().then();
+  }, []);
+
+  const review = async (account, action, body = {}) => {
+    setLoading(true);
+    try {
+      const res = await API.post(`/api/credit/${account.id}/${action}`, body);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('操作成功'));
+        setReviewing(null);
+        await loadAccounts();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const confirmReview = (account, action, title) => {
+    Modal.confirm({
+      title,
+      content: account.username,
+      centered: true,
+      onOk: () => review(account, action),
+    });
+  };
+
+  const settle = (statement) => {
+    Modal.confirm({
+      title: t('确认该结算单已线下付款？'),
+      content: t('将为用户补足未付欠款并标记为已付款'),
+      centered: true,
+      onOk: async () => {
+        const res = await API.post(
+          `/api/credit/statement/${statement.id}/settle`,
+        );
+        if (res.data.success) {
+          showSuccess(t('操作成功'));
+          await loadStatements();
+        } else {
+          showError(res.data.message);
+        }
+      },
+    });
+  };
+
+  const renderAccountStatus = (status) => {
+    switch (status) {
+      case 1:
+        return <Tag color='orange'>{t('待审批')}</Tag>;
+      case 2:
+        return <Tag color='green'>{t('已开通')}</Tag>;
+      case 3:
+        return <Tag color='red'>{t('已拒绝')}</Tag>;
+      case 4:
+        return <Tag color='grey'>{t('已关闭')}</Tag>;
+      default:
+        return <Tag>{t('未知状态')}</Tag>;
+    }
+  };
+
+  const accountColumns = [
+    { title: t('用户'), dataIndex: 'username' },
+    {
+      title: t('申请额度'),
+      dataIndex: 'requested_limit',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('账期额度'),
+      dataIndex: 'credit_limit',
+      render: (text, record) =>
+        record.status === 2
+          ? `${renderQuota(text)} / ${record.terms_days} ${t('天')}`
+          : '-',
+    },
+    { title: t('申请说明'), dataIndex: 'reason' },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (text) => renderAccountStatus(text),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Space>
+          {(record.status === 1 || record.status === 2) && (
+            <Button
+              size='small'
+              onClick={() =>
+                setReviewing({
+                  account: record,
+                  credit_limit: record.credit_limit || record.requested_limit,
+                  terms_days: record.terms_days || 30,
+                  remark: record.remark || '',
+                })
+              }
+            >
+              {record.status === 1 ? t('通过') : t('调整')}
+            </Button>
+          )}
+          {record.status === 1 && (
+            <Button
+              size='small'
+              type='danger'
+              onClick={() =>
+                confirmReview(record, 'reject', t('确定要拒绝此申请吗？'))
+              }
+            >
+              {t('拒绝')}
+            </Button>
+          )}
+          {record.status === 2 && (
+            <Button
+              size='small'
+              type='danger'
+              onClick={() =>
+                confirmReview(record, 'close', t('确定要关闭此账期额度吗？'))
+              }
+            >
+              {t('关闭')}
+            </Button>
+          )}
+        </Space>
+      ),
+    },
+  ];
+
+  const statementColumns = [
+    { title: t('用户ID'), dataIndex: 'user_id' },
+    {
+      title: t('账单期'),
+      dataIndex: 'period_start',
+      render: (text) => timestamp2string(text).slice(0, 7),
+    },
+    {
+      title: t('应付额度'),
+      dataIndex: 'amount_due',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('已付额度'),
+      dataIndex: 'paid_quota',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('到期时间'),
+      dataIndex: 'due_time',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Button size='small' onClick={() => settle(record)}>
+          {t('确认付款')}
+        </Button>
+      ),
+    },
+  ];
+
+  return (
+    <>
+      <Typography.Title heading={5}>{t('账期额度')}</Typography.Title>
+      <Typography.Text type='tertiary'>
+        {t(
+          '开通账期后用户余额可透支到账期额度，每月初生成上月结算单，结算单到期未付清将按系统设置限制或暂停账户；用户充值或兑换会自动抵扣欠款',
+        )}
+      </Typography.Text>
+      <Table
+        className='mt-4'
+        columns={accountColumns}
+        dataSource={accounts}
+        rowKey='id'
+        pagination={false}
+      />
+      <Typography.Title heading={6} className='mt-4'>
+        {t('未付结算单')}
+      </Typography.Title>
+      <Table
+        className='mt-2'
+        columns={statementColumns}
+        dataSource={statements}
+        rowKey='id'
+        pagination={false}
+      />
+      <Modal
+        title={t('开通账期额度')}
+        visible={reviewing !== null}
+        onOk={() =>
+          review(reviewing.account, 'approve', {
+            credit_limit: reviewing.credit_limit,
+            terms_days: reviewing.terms_days,
+            remark: reviewing.remark,
+          })
+        }
+        onCancel={() => setReviewing(null)}
+        confirmLoading={loading}
+        centered
+      >
+        {reviewing && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <InputNumber
+              prefix={t('账期额度')}
+              value={reviewing.credit_limit}
+              min={1}
+              onChange={(value) =>
+                setReviewing({ ...reviewing, credit_limit: value })
+              }
+              suffix={renderQuotaWithPrompt(reviewing.credit_limit)}
+              style={{ width: '100%' }}
+            />
+            <InputNumber
+              prefix={t('账期（天）')}
+              value={reviewing.terms_days}
+              min={1}
+              onChange={(value) =>
+                setReviewing({ ...reviewing, terms_days: value })
+              }
+              style={{ width: '100%' }}
+            />
+            <Input
+              prefix={t('备注')}
+              value={reviewing.remark}
+              onChange={(value) =>
+                setReviewing({ ...reviewing, remark: value })
+              }
+            />
+          </Space>
+        )}
+      </Modal>
+    </>
+  );
+}
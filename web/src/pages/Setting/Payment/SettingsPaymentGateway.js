@@ -29,6 +29,11 @@ export default function SettingsPaymentGateway(props) {
     TopupGroupRatio: '',
     CustomCallbackAddress: '',
     PayMethods: '',
+    StripeApiAddress: '',
+    StripeSecretKey: '',
+    StripeWebhookSecret: '',
+    StripeCurrency: 'usd',
+    StripeUnitPrice: 1,
   });
   const [originInputs, setOriginInputs] = useState({});
   const formApiRef = useRef(null);
@@ -44,6 +49,14 @@ export default function SettingsPaymentGateway(props) {
         TopupGroupRatio: props.options.TopupGroupRatio || '',
         CustomCallbackAddress: props.options.CustomCallbackAddress || '',
         PayMethods: props.options.PayMethods || '',
+        StripeApiAddress: props.options.StripeApiAddress || '',
+        StripeSecretKey: props.options.StripeSecretKey || '',
+        StripeWebhookSecret: props.options.StripeWebhookSecret || '',
+        StripeCurrency: props.options.StripeCurrency || 'usd',
+        StripeUnitPrice:
+          props.options.StripeUnitPrice !== undefined
+            ? parseFloat(props.options.StripeUnitPrice)
+            : 1,
       };
       setInputs(currentInputs);
       setOriginInputs({ ...currentInputs });
@@ -134,6 +147,53 @@ export default function SettingsPaymentGateway(props) {
     setLoading(false);
   };
 
+  const submitStripe = async () => {
+    setLoading(true);
+    try {
+      const options = [
+        {
+          key: 'StripeApiAddress',
+          value: removeTrailingSlash(inputs.StripeApiAddress),
+        },
+        { key: 'StripeCurrency', value: inputs.StripeCurrency },
+        { key: 'StripeUnitPrice', value: inputs.StripeUnitPrice.toString() },
+      ];
+      if (inputs.StripeSecretKey !== undefined && inputs.StripeSecretKey !== '') {
+        options.push({ key: 'StripeSecretKey', value: inputs.StripeSecretKey });
+      }
+      if (
+        inputs.StripeWebhookSecret !== undefined &&
+        inputs.StripeWebhookSecret !== ''
+      ) {
+        options.push({
+          key: 'StripeWebhookSecret',
+          value: inputs.StripeWebhookSecret,
+        });
+      }
+      const results = await Promise.all(
+        options.map((opt) =>
+          API.put('/api/option/', {
+            key: opt.key,
+            value: opt.value,
+          }),
+        ),
+      );
+      const errorResults = results.filter((res) => !res.data.success);
+      if (errorResults.length > 0) {
+        errorResults.forEach((res) => {
+          showError(res.data.message);
+        });
+      } else {
+        showSuccess(t('更新成功'));
+        setOriginInputs({ ...inputs });
+        props.refresh && props.refresh();
+      }
+    } catch (error) {
+      showError(t('更新失败'));
+    }
+    setLoading(false);
+  };
+
   return (
     <Spin spinning={loading}>
       <Form
@@ -212,6 +272,61 @@ export default function SettingsPaymentGateway(props) {
           />
           <Button onClick={submitPayAddress}>{t('更新支付设置')}</Button>
         </Form.Section>
+        <Form.Section text={t('Stripe 设置')}>
+          <Text>
+            {t('Webhook 地址为')}{' '}
+            {`${props.options.CustomCallbackAddress || props.options.ServerAddress || ''}/api/user/stripe/webhook`}
+            {t('，需要订阅 checkout.session.completed、checkout.session.async_payment_succeeded、charge.succeeded 与 charge.refunded 事件')}
+          </Text>
+          <Row
+            gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+          >
+            <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+              <Form.Input
+                field='StripeSecretKey'
+                label={t('Stripe 密钥')}
+                placeholder={t('敏感信息不会发送到前端显示')}
+                type='password'
+              />
+            </Col>
+            <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+              <Form.Input
+                field='StripeWebhookSecret'
+                label={t('Webhook 签名密钥')}
+                placeholder={t('敏感信息不会发送到前端显示')}
+                type='password'
+              />
+            </Col>
+            <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+              <Form.Input
+                field='StripeApiAddress'
+                label={t('Stripe API 地址')}
+                placeholder='https://api.stripe.com'
+              />
+            </Col>
+          </Row>
+          <Row
+            gutter={{ xs: 8, sm: 16, md: 24, lg: 24, xl: 24, xxl: 24 }}
+            style={{ marginTop: 16 }}
+          >
+            <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+              <Form.Input
+                field='StripeCurrency'
+                label={t('收款货币')}
+                placeholder={t('例如：usd')}
+              />
+            </Col>
+            <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+              <Form.InputNumber
+                field='StripeUnitPrice'
+                precision={2}
+                label={t('Stripe 充值价格（每美金额度）')}
+                placeholder={t('例如：1，就是 1 美元/美金额度')}
+              />
+            </Col>
+          </Row>
+          <Button onClick={submitStripe}>{t('更新 Stripe 设置')}</Button>
+        </Form.Section>
       </Form>
     </Spin>
   );
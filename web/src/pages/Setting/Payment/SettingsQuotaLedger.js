@@ -0,0 +1,164 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Input,
+  Space,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  renderQuota,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../helpers';
+
+// 额度账本分录与余额核对
+export default function SettingsQuotaLedger() {
+  const { t } = useTranslation();
+  const [entries, setEntries] = useState([]);
+  const [userId, setUserId] = useState('');
+  const [report, setReport] = useState(null);
+  const [reconciling, setReconciling] = useState(false);
+
+  const loadEntries = async () => {
+    const res = await API.get(`/api/quota_ledger/?p=1&user_id=${userId}`);
+    const { success, message, data } = res.data;
+    if (success) {
+      setEntries(data.items || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadReport = async () => {
+    const res = await API.get('/api/quota_ledger/reconcile');
+    const { success, message, data } = res.data;
+    if (success) {
+      setReport(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadEntries().then();
+    loadReport().then();
+  }, []);
+
+  const reconcile = async () => {
+    setReconciling(true);
+    try {
+      const res = await API.post('/api/quota_ledger/reconcile');
+      const { success, message, data } = res.data;
+      if (success) {
+        setReport(data);
+        showSuccess(t('核对完成'));
+      } else {
+        showError(message);
+      }
+    } finally {
+      setReconciling(false);
+    }
+  };
+
+  const columns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_time',
+      render: (text) => timestamp2string(text),
+    },
+    { title: t('用户ID'), dataIndex: 'user_id' },
+    { title: t('账户'), dataIndex: 'account' },
+    {
+      title: t('类型'),
+      dataIndex: 'type',
+      render: (text) => <Tag>{text}</Tag>,
+    },
+    {
+      title: t('金额'),
+      dataIndex: 'amount',
+      render: (text) =>
+        text < 0 ? `-${renderQuota(-text)}` : `+${renderQuota(text)}`,
+    },
+    { title: t('关联单号'), dataIndex: 'ref_id' },
+  ];
+
+  const driftColumns = [
+    { title: t('用户ID'), dataIndex: 'user_id' },
+    {
+      title: t('余额'),
+      dataIndex: 'quota',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('账本余额'),
+      dataIndex: 'ledger',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('偏差'),
+      dataIndex: 'drift',
+      render: (text) => renderQuota(text),
+    },
+  ];
+
+  return (
+    <>
+      <Typography.Title heading={5}>{t('额度账本')}</Typography.Title>
+      <Typography.Text type='tertiary'>
+        {t(
+          '每笔额度变动都会在账本中记录金额相反的两条分录（批量更新模式下每次批量写入按类型合并），系统每天记录余额检查点，并定期核对用户余额与账本，发现不一致时通知超级管理员',
+        )}
+      </Typography.Text>
+      <div className='mt-4'>
+        <Space>
+          <Button loading={reconciling} onClick={reconcile}>
+            {t('立即核对')}
+          </Button>
+          {report ? (
+            <Typography.Text>
+              {t('最近核对')}: {timestamp2string(report.finished_at)}，
+              {t('核对用户')} {report.checked_users}，{t('不一致用户')}{' '}
+              {report.drifts.length}
+            </Typography.Text>
+          ) : (
+            <Typography.Text type='tertiary'>{t('尚未核对')}</Typography.Text>
+          )}
+        </Space>
+      </div>
+      {report && report.drifts.length > 0 && (
+        <Table
+          className='mt-4'
+          columns={driftColumns}
+          dataSource={report.drifts}
+          rowKey='user_id'
+          pagination={false}
+          size='small'
+        />
+      )}
+      <div className='mt-4'>
+        <Space>
+          <Input
+            placeholder={t('用户ID')}
+            value={userId}
+            onChange={setUserId}
+            style={{ width: 160 }}
+          />
+          <Button onClick={loadEntries}>{t('查询')}</Button>
+        </Space>
+      </div>
+      <Table
+        className='mt-2'
+        columns={columns}
+        dataSource={entries}
+        rowKey='id'
+        pagination={false}
+        size='small'
+      />
+    </>
+  );
+}
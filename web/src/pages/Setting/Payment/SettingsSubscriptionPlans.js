@@ -0,0 +1,261 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  InputNumber,
+  Input,
+  Modal,
+  Space,
+  Switch,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  renderQuota,
+  renderQuotaWithPrompt,
+  showError,
+  showSuccess,
+} from '../../../helpers';
+
+const emptyPlan = {
+  name: '',
+  description: '',
+  price: 0,
+  quota: 0,
+  duration_days: 30,
+  models: '',
+  groups: '',
+  rollover_percent: 0,
+  rollover_cap: 0,
+  status: 1,
+};
+
+// 订阅套餐管理，价格与额度均为额度单位
+export default function SettingsSubscriptionPlans() {
+  const { t } = useTranslation();
+  const [plans, setPlans] = useState([]);
+  const [editingPlan, setEditingPlan] = useState(null);
+  const [loading, setLoading] = useState(false);
+
+  const loadPlans = async () => {
+    const res = await API.get('/api/subscription_plan/');
+    const { success, message, data } = res.data;
+    if (success) {
+      setPlans(data || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  useEffect(() => {
+    loadPlans().then();
+  }, []);
+
+  const savePlan = async () => {
+    setLoading(true);
+    try {
+      const res = editingPlan.id
+        ? await API.put('/api/subscription_plan/', editingPlan)
+        : await API.post('/api/subscription_plan/', editingPlan);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('保存成功'));
+        setEditingPlan(null);
+        await loadPlans();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const deletePlan = (plan) => {
+    Modal.confirm({
+      title: t('确定要删除此套餐吗？'),
+      content: plan.name,
+      centered: true,
+      onOk: async () => {
+        const res = await API.delete(`/api/subscription_plan/${plan.id}`);
+        if (res.data.success) {
+          showSuccess(t('操作成功'));
+          await loadPlans();
+        } else {
+          showError(res.data.message);
+        }
+      },
+    });
+  };
+
+  const columns = [
+    { title: t('名称'), dataIndex: 'name' },
+    {
+      title: t('价格'),
+      dataIndex: 'price',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('套餐额度'),
+      dataIndex: 'quota',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('周期'),
+      dataIndex: 'duration_days',
+      render: (text) => `${text} ${t('天')}`,
+    },
+    {
+      title: t('适用范围'),
+      dataIndex: 'models',
+      render: (text, record) => (
+        <div className='flex flex-col'>
+          <span>{record.models || t('全部模型')}</span>
+          <span>{record.groups || t('全部分组')}</span>
+        </div>
+      ),
+    },
+    {
+      title: t('结转'),
+      dataIndex: 'rollover_percent',
+      render: (text, record) =>
+        text > 0
+          ? `${text}%${record.rollover_cap > 0 ? `，${t('上限')} ${renderQuota(record.rollover_cap)}` : ''}`
+          : t('不结转'),
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (text) =>
+        text === 1 ? (
+          <Tag color='green'>{t('已启用')}</Tag>
+        ) : (
+          <Tag color='grey'>{t('已禁用')}</Tag>
+        ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Space>
+          <Button size='small' onClick={() => setEditingPlan({ ...record })}>
+            {t('编辑')}
+          </Button>
+          <Button size='small' type='danger' onClick={() => deletePlan(record)}>
+            {t('删除')}
+          </Button>
+        </Space>
+      ),
+    },
+  ];
+
+  const updateField = (field) => (value) =>
+    setEditingPlan({ ...editingPlan, [field]: value });
+
+  return (
+    <>
+      <Typography.Title heading={5}>{t('订阅套餐')}</Typography.Title>
+      <Typography.Text type='tertiary'>
+        {t(
+          '用户订阅后每个周期从余额中扣除价格并获得套餐额度，请求优先使用套餐额度；到期时开启自动续费且余额足够的订阅会自动续费，剩余额度按结转比例带入下个周期',
+        )}
+      </Typography.Text>
+      <div className='mt-4'>
+        <Button onClick={() => setEditingPlan({ ...emptyPlan })}>
+          {t('添加套餐')}
+        </Button>
+      </div>
+      <Table
+        className='mt-4'
+        columns={columns}
+        dataSource={plans}
+        rowKey='id'
+        pagination={false}
+      />
+      <Modal
+        title={editingPlan?.id ? t('编辑套餐') : t('添加套餐')}
+        visible={editingPlan !== null}
+        onOk={savePlan}
+        onCancel={() => setEditingPlan(null)}
+        confirmLoading={loading}
+        centered
+      >
+        {editingPlan && (
+          <Space vertical align='start' style={{ width: '100%' }}>
+            <Input
+              prefix={t('名称')}
+              value={editingPlan.name}
+              onChange={updateField('name')}
+            />
+            <Input
+              prefix={t('描述')}
+              value={editingPlan.description}
+              onChange={updateField('description')}
+            />
+            <InputNumber
+              prefix={t('价格')}
+              value={editingPlan.price}
+              min={0}
+              onChange={updateField('price')}
+              suffix={renderQuotaWithPrompt(editingPlan.price)}
+              style={{ width: '100%' }}
+            />
+            <InputNumber
+              prefix={t('套餐额度')}
+              value={editingPlan.quota}
+              min={0}
+              onChange={updateField('quota')}
+              suffix={renderQuotaWithPrompt(editingPlan.quota)}
+              style={{ width: '100%' }}
+            />
+            <InputNumber
+              prefix={t('周期（天）')}
+              value={editingPlan.duration_days}
+              min={1}
+              onChange={updateField('duration_days')}
+              style={{ width: '100%' }}
+            />
+            <Input
+              prefix={t('模型')}
+              placeholder={t('逗号分隔，支持 gpt-4o* 前缀匹配，留空为全部模型')}
+              value={editingPlan.models}
+              onChange={updateField('models')}
+            />
+            <Input
+              prefix={t('分组')}
+              placeholder={t('逗号分隔，留空为全部分组')}
+              value={editingPlan.groups}
+              onChange={updateField('groups')}
+            />
+            <InputNumber
+              prefix={t('结转比例（%）')}
+              value={editingPlan.rollover_percent}
+              min={0}
+              max={100}
+              onChange={updateField('rollover_percent')}
+              style={{ width: '100%' }}
+            />
+            <InputNumber
+              prefix={t('结转上限')}
+              value={editingPlan.rollover_cap}
+              min={0}
+              onChange={updateField('rollover_cap')}
+              suffix={renderQuotaWithPrompt(editingPlan.rollover_cap)}
+              style={{ width: '100%' }}
+            />
+            <Space>
+              <Switch
+                checked={editingPlan.status === 1}
+                onChange={(checked) =>
+                  setEditingPlan({ ...editingPlan, status: checked ? 1 : 2 })
+                }
+              />
+              <Typography.Text>{t('启用')}</Typography.Text>
+            </Space>
+          </Space>
+        )}
+      </Modal>
+    </>
+  );
+}
@@ -24,6 +24,7 @@ export default function ModelRatioSettings(props) {
     ModelPrice: '',
     ModelRatio: '',
     CacheRatio: '',
+    ModelTierRatio: '',
     CompletionRatio: '',
     ExposeRatioEnabled: false,
   });
@@ -206,6 +207,32 @@ export default function ModelRatioSettings(props) {
             />
           </Col>
         </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('阶梯倍率')}
+              extraText={t(
+                '提示词超过 min_prompt_tokens 时整个请求改用该阶梯的倍率，completion_ratio 与 cache_ratio 留空则沿用模型原本的倍率',
+              )}
+              placeholder={t(
+                '为一个 JSON 文本，键为模型名称，值为阶梯列表，例如：{"gemini-2.5-pro": [{"min_prompt_tokens": 200000, "model_ratio": 1.25, "completion_ratio": 6}]}',
+              )}
+              field={'ModelTierRatio'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) =>
+                setInputs({ ...inputs, ModelTierRatio: value })
+              }
+            />
+          </Col>
+        </Row>
         <Row gutter={16}>
           <Col span={16}>
             <Form.Switch
@@ -12,7 +12,8 @@ import {
   LayoutDashboard,
   MessageSquare,
   Palette,
-  CreditCard
+  CreditCard,
+  ShieldCheck
 } from 'lucide-react';
 
 import SystemSetting from '../../components/settings/SystemSetting.js';
@@ -26,6 +27,7 @@ import RatioSetting from '../../components/settings/RatioSetting.js';
 import ChatsSetting from '../../components/settings/ChatsSetting.js';
 import DrawingSetting from '../../components/settings/DrawingSetting.js';
 import PaymentSetting from '../../components/settings/PaymentSetting.js';
+import AdminRoleSetting from '../../components/settings/AdminRoleSetting.js';
 
 const Setting = () => {
   const { t } = useTranslation();
@@ -125,6 +127,16 @@ const Setting = () => {
       content: <SystemSetting />,
       itemKey: 'system',
     });
+    panes.push({
+      tab: (
+        <span style={{ display: 'flex', alignItems: 'center', gap: '5px' }}>
+          <ShieldCheck size={18} />
+          {t('管理员角色')}
+        </span>
+      ),
+      content: <AdminRoleSetting />,
+      itemKey: 'admin_role',
+    });
     panes.push({
       tab: (
         <span style={{ display: 'flex', alignItems: 'center', gap: '5px' }}>
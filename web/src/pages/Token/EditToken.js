@@ -10,6 +10,7 @@ import {
 } from '../../helpers';
 import {
   Button,
+  Modal,
   SideSheet,
   Space,
   Spin,
@@ -200,6 +201,7 @@ const EditToken = (props) => {
     } else {
       const count = parseInt(values.tokenCount, 10) || 1;
       let successCount = 0;
+      const createdKeys = [];
       for (let i = 0; i < count; i++) {
         let { tokenCount: _tc, ...localInputs } = values;
         const baseName = values.name.trim() === '' ? 'default' : values.name.trim();
@@ -222,16 +224,26 @@ const EditToken = (props) => {
         localInputs.model_limits = localInputs.model_limits.join(',');
         localInputs.model_limits_enabled = localInputs.model_limits.length > 0;
         let res = await API.post(`/api/token/`, localInputs);
-        const { success, message } = res.data;
+        const { success, message, data } = res.data;
         if (success) {
           successCount++;
+          createdKeys.push(localInputs.name + '    ' + data.key);
         } else {
           showError(t(message));
           break;
         }
       }
       if (successCount > 0) {
-        showSuccess(t('令牌创建成功，请在列表页面点击复制获取令牌！'));
+        showSuccess(t('令牌创建成功！'));
+        Modal.info({
+          title: t('请立即保存令牌，关闭后将无法再次查看'),
+          content: (
+            <Typography.Paragraph copyable style={{ whiteSpace: 'pre-wrap' }}>
+              {createdKeys.join('\n')}
+            </Typography.Paragraph>
+          ),
+          size: 'large',
+        });
         props.refresh();
         props.handleClose();
       }
@@ -6,8 +6,10 @@ import {
   showSuccess,
   renderQuota,
   renderQuotaWithAmount,
+  renderQuotaWithPrompt,
   copy,
   getQuotaPerUnit,
+  timestamp2string,
 } from '../../helpers';
 import {
   Avatar,
@@ -21,6 +23,10 @@ import {
   Banner,
   Skeleton,
   Divider,
+  Switch,
+  Tag,
+  Empty,
+  Space,
 } from '@douyinfe/semi-ui';
 import { SiAlipay, SiWechat } from 'react-icons/si';
 import { useTranslation } from 'react-i18next';
@@ -35,6 +41,9 @@ import {
   Users,
   User,
   Coins,
+  CalendarClock,
+  FileText,
+  Landmark,
 } from 'lucide-react';
 
 const { Text, Title } = Typography;
@@ -59,6 +68,14 @@ const TopUp = () => {
     statusState?.status?.enable_online_topup || false,
   );
   const [priceRatio, setPriceRatio] = useState(statusState?.status?.price || 1);
+  const [enableStripeTopUp, setEnableStripeTopUp] = useState(
+    statusState?.status?.enable_stripe_topup || false,
+  );
+  const [stripeTopUpCount, setStripeTopUpCount] = useState(
+    statusState?.status?.min_topup || 1,
+  );
+  const [stripeAmount, setStripeAmount] = useState(0);
+  const [stripeLoading, setStripeLoading] = useState(false);
   const [userQuota, setUserQuota] = useState(0);
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [open, setOpen] = useState(false);
@@ -74,6 +91,19 @@ const TopUp = () => {
   const [openTransfer, setOpenTransfer] = useState(false);
   const [transferAmount, setTransferAmount] = useState(0);
 
+  // 订阅套餐相关状态
+  const [subscriptionPlans, setSubscriptionPlans] = useState([]);
+  const [subscriptions, setSubscriptions] = useState([]);
+  const [subscribingPlanId, setSubscribingPlanId] = useState(0);
+  const [invoices, setInvoices] = useState([]);
+
+  // 账期额度相关状态
+  const [credit, setCredit] = useState(null);
+  const [creditStatements, setCreditStatements] = useState([]);
+  const [creditLimit, setCreditLimit] = useState(0);
+  const [creditReason, setCreditReason] = useState('');
+  const [creditApplying, setCreditApplying] = useState(false);
+
   // 预设充值额度选项
   const [presetAmounts, setPresetAmounts] = useState([
     { value: 5 },
@@ -275,6 +305,174 @@ const TopUp = () => {
     }
   };
 
+  // 获取订阅套餐与当前订阅
+  const getSubscriptions = async () => {
+    const res = await API.get('/api/user/self/subscriptions');
+    const { success, message, data } = res.data;
+    if (success) {
+      setSubscriptionPlans(data.plans || []);
+      setSubscriptions(data.subscriptions || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const subscribe = (plan) => {
+    Modal.confirm({
+      title: t('确认订阅'),
+      content: `${plan.name}：${t('将从余额中扣除')} ${renderQuota(plan.price)}`,
+      centered: true,
+      onOk: async () => {
+        setSubscribingPlanId(plan.id);
+        try {
+          const res = await API.post('/api/user/self/subscription', {
+            plan_id: plan.id,
+            auto_renew: true,
+          });
+          const { success, message } = res.data;
+          if (success) {
+            showSuccess(t('订阅成功'));
+            await getSubscriptions();
+            await getUserQuota();
+          } else {
+            showError(message);
+          }
+        } finally {
+          setSubscribingPlanId(0);
+        }
+      },
+    });
+  };
+
+  const updateAutoRenew = async (subscription, autoRenew) => {
+    const res = await API.put('/api/user/self/subscription/auto_renew', {
+      id: subscription.id,
+      auto_renew: autoRenew,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      setSubscriptions((subs) =>
+        subs.map((sub) =>
+          sub.id === subscription.id ? { ...sub, auto_renew: autoRenew } : sub,
+        ),
+      );
+    } else {
+      showError(message);
+    }
+  };
+
+  const renderSubscriptionStatus = (status) => {
+    switch (status) {
+      case 1:
+        return <Tag color='green'>{t('生效中')}</Tag>;
+      case 2:
+        return <Tag color='grey'>{t('已过期')}</Tag>;
+      case 3:
+        return <Tag color='red'>{t('已取消')}</Tag>;
+      default:
+        return <Tag>{t('未知状态')}</Tag>;
+    }
+  };
+
+  // 获取最近的月度账单
+  const getInvoices = async () => {
+    const res = await API.get('/api/user/self/invoices?p=1');
+    const { success, message, data } = res.data;
+    if (success) {
+      setInvoices(data.items || []);
+    } else {
+      showError(message);
+    }
+  };
+
+  const downloadInvoice = async (invoice, format) => {
+    try {
+      let blob;
+      if (format === 'pdf') {
+        const res = await API.get(
+          `/api/user/self/invoices/${invoice.id}?format=pdf`,
+          { responseType: 'blob' },
+        );
+        if (res.data.type === 'application/json') {
+          const { message } = JSON.parse(await res.data.text());
+          showError(message);
+          return;
+        }
+        blob = res.data;
+      } else {
+        const res = await API.get(`/api/user/self/invoices/${invoice.id}`);
+        const { success, message, data } = res.data;
+        if (!success) {
+          showError(message);
+          return;
+        }
+        blob = new Blob([JSON.stringify(data, null, 2)], {
+          type: 'application/json',
+        });
+      }
+      const link = document.createElement('a');
+      link.href = window.URL.createObjectURL(blob);
+      link.download = `${invoice.number}.${format}`;
+      link.click();
+      window.URL.revokeObjectURL(link.href);
+    } catch (error) {
+      showError(t('下载失败'));
+    }
+  };
+
+  // 获取账期额度与未付结算单
+  const getCredit = async () => {
+    const res = await API.get('/api/user/self/credit');
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    setCredit(data);
+    if (data.account) {
+      const statementRes = await API.get(
+        '/api/user/self/credit/statements?p=1',
+      );
+      if (statementRes.data.success) {
+        setCreditStatements(statementRes.data.data.items || []);
+      }
+    }
+  };
+
+  const applyCredit = async () => {
+    setCreditApplying(true);
+    try {
+      const res = await API.post('/api/user/self/credit', {
+        requested_limit: creditLimit,
+        reason: creditReason,
+      });
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('申请已提交，请等待管理员审批'));
+        await getCredit();
+      } else {
+        showError(message);
+      }
+    } finally {
+      setCreditApplying(false);
+    }
+  };
+
+  const renderCreditStatus = (status) => {
+    switch (status) {
+      case 1:
+        return <Tag color='orange'>{t('待审批')}</Tag>;
+      case 2:
+        return <Tag color='green'>{t('已开通')}</Tag>;
+      case 3:
+        return <Tag color='red'>{t('已拒绝')}</Tag>;
+      case 4:
+        return <Tag color='grey'>{t('已关闭')}</Tag>;
+      default:
+        return <Tag>{t('未知状态')}</Tag>;
+    }
+  };
+
   // 复制邀请链接
   const handleAffLinkClick = async () => {
     await copy(affLink);
@@ -289,6 +487,9 @@ const TopUp = () => {
       getUserQuota().then();
     }
     getAffLink().then();
+    getSubscriptions().then();
+    getInvoices().then();
+    getCredit().then();
     setTransferAmount(getQuotaPerUnit());
 
     let payMethods = localStorage.getItem('pay_methods');
@@ -327,6 +528,11 @@ const TopUp = () => {
       setTopUpLink(statusState.status.top_up_link || '');
       setEnableOnlineTopUp(statusState.status.enable_online_topup || false);
       setPriceRatio(statusState.status.price || 1);
+      setEnableStripeTopUp(statusState.status.enable_stripe_topup || false);
+      setStripeTopUpCount(statusState.status.min_topup || 1);
+      if (statusState.status.enable_stripe_topup) {
+        getStripeAmount(statusState.status.min_topup || 1);
+      }
     }
   }, [statusState?.status]);
 
@@ -361,6 +567,44 @@ const TopUp = () => {
     setAmountLoading(false);
   };
 
+  const getStripeAmount = async (value) => {
+    const res = await API.post('/api/user/amount', {
+      amount: parseFloat(value),
+      payment_method: 'stripe',
+    });
+    const { message, data } = res.data;
+    if (message === 'success') {
+      setStripeAmount(parseFloat(data));
+    } else {
+      setStripeAmount(0);
+      Toast.error({ content: '错误：' + data, id: 'getStripeAmount' });
+    }
+  };
+
+  // Stripe 充值跳转到 Stripe Checkout 页面，支付结果以 webhook 回调为准
+  const stripeTopUp = async () => {
+    if (stripeTopUpCount < minTopUp) {
+      showError(t('充值数量不能小于') + minTopUp);
+      return;
+    }
+    setStripeLoading(true);
+    try {
+      const res = await API.post('/api/user/stripe/pay', {
+        amount: parseInt(stripeTopUpCount),
+      });
+      const { message, data } = res.data;
+      if (message === 'success') {
+        window.location.href = data.url;
+      } else {
+        showError(data);
+      }
+    } catch (err) {
+      showError(t('支付请求失败'));
+    } finally {
+      setStripeLoading(false);
+    }
+  };
+
   const handleCancel = () => {
     setOpen(false);
   };
@@ -798,7 +1042,51 @@ const TopUp = () => {
                 </>
               )}
 
-              {!enableOnlineTopUp && (
+              {enableStripeTopUp && (
+                <Card className='!rounded-2xl'>
+                  <div className='flex items-start mb-4'>
+                    <CreditCard size={16} className='mr-2 mt-0.5' />
+                    <Text strong>{t('使用 Stripe 银行卡支付')}</Text>
+                  </div>
+                  <div className='flex justify-between mb-2'>
+                    <Text strong>{t('充值数量')}</Text>
+                    <Text type='tertiary'>
+                      {t('实付金额：')}
+                      {stripeAmount}{' '}
+                      {(statusState?.status?.stripe_currency || 'usd').toUpperCase()}
+                    </Text>
+                  </div>
+                  <div className='flex flex-col sm:flex-row gap-3'>
+                    <InputNumber
+                      value={stripeTopUpCount}
+                      min={minTopUp}
+                      max={999999999}
+                      step={1}
+                      precision={0}
+                      onChange={(value) => {
+                        if (value && value >= 1) {
+                          setStripeTopUpCount(value);
+                          getStripeAmount(value);
+                        }
+                      }}
+                      size='large'
+                      className='flex-1'
+                    />
+                    <Button
+                      type='primary'
+                      theme='solid'
+                      onClick={stripeTopUp}
+                      loading={stripeLoading}
+                      size='large'
+                      style={{ height: '40px' }}
+                    >
+                      {t('前往 Stripe 支付')}
+                    </Button>
+                  </div>
+                </Card>
+              )}
+
+              {!enableOnlineTopUp && !enableStripeTopUp && (
                 <Banner
                   type='warning'
                   description={t(
@@ -856,6 +1144,295 @@ const TopUp = () => {
               </Card>
             </div>
           </Card>
+
+          {/* 订阅套餐卡片 */}
+          {(subscriptionPlans.length > 0 || subscriptions.length > 0) && (
+            <Card
+              className='!rounded-2xl'
+              shadows='always'
+              bordered={false}
+              header={
+                <div className='px-5 py-4 pb-0'>
+                  <div className='flex items-center'>
+                    <Avatar
+                      className='mr-3 shadow-md flex-shrink-0'
+                      color='orange'
+                    >
+                      <CalendarClock size={24} />
+                    </Avatar>
+                    <div>
+                      <Title heading={5} style={{ margin: 0 }}>
+                        {t('订阅套餐')}
+                      </Title>
+                      <Text type='tertiary' className='text-sm'>
+                        {t('请求优先使用套餐额度，用完后再扣除余额')}
+                      </Text>
+                    </div>
+                  </div>
+                </div>
+              }
+            >
+              <div className='space-y-4'>
+                {subscriptions.length > 0 && (
+                  <div className='space-y-3'>
+                    {subscriptions.map((sub) => (
+                      <Card key={sub.id} className='!rounded-2xl'>
+                        <div className='flex items-center justify-between mb-2'>
+                          <Text strong>{sub.plan_name}</Text>
+                          {renderSubscriptionStatus(sub.status)}
+                        </div>
+                        <div className='grid grid-cols-1 md:grid-cols-2 gap-2'>
+                          <Text type='tertiary'>
+                            {t('剩余额度')}: {renderQuota(sub.remaining)} /{' '}
+                            {renderQuota(sub.allowance)}
+                          </Text>
+                          <Text type='tertiary'>
+                            {t('到期时间')}: {timestamp2string(sub.period_end)}
+                          </Text>
+                        </div>
+                        {sub.status === 1 && (
+                          <div className='flex items-center mt-2'>
+                            <Switch
+                              size='small'
+                              checked={sub.auto_renew}
+                              onChange={(checked) =>
+                                updateAutoRenew(sub, checked)
+                              }
+                            />
+                            <Text className='ml-2'>{t('自动续费')}</Text>
+                            <Text type='tertiary' size='small' className='ml-2'>
+                              {t('续费从余额扣除，请保持余额充足')}
+                            </Text>
+                          </div>
+                        )}
+                      </Card>
+                    ))}
+                  </div>
+                )}
+
+                {subscriptionPlans.length > 0 ? (
+                  <div className='grid grid-cols-1 md:grid-cols-2 gap-4'>
+                    {subscriptionPlans.map((plan) => (
+                      <Card key={plan.id} className='!rounded-2xl'>
+                        <Text strong className='block mb-1'>
+                          {plan.name}
+                        </Text>
+                        {plan.description && (
+                          <Text type='tertiary' className='block text-sm mb-2'>
+                            {plan.description}
+                          </Text>
+                        )}
+                        <div className='flex flex-col text-sm mb-3'>
+                          <Text>
+                            {t('价格')}: {renderQuota(plan.price)} /{' '}
+                            {plan.duration_days} {t('天')}
+                          </Text>
+                          <Text>
+                            {t('套餐额度')}: {renderQuota(plan.quota)}
+                          </Text>
+                          <Text type='tertiary'>
+                            {t('适用模型')}: {plan.models || t('全部模型')}
+                          </Text>
+                          {plan.rollover_percent > 0 && (
+                            <Text type='tertiary'>
+                              {t('剩余额度结转')}: {plan.rollover_percent}%
+                            </Text>
+                          )}
+                        </div>
+                        <Button
+                          type='primary'
+                          theme='solid'
+                          block
+                          loading={subscribingPlanId === plan.id}
+                          onClick={() => subscribe(plan)}
+                        >
+                          {t('订阅')}
+                        </Button>
+                      </Card>
+                    ))}
+                  </div>
+                ) : (
+                  <Empty description={t('暂无可订阅的套餐')} />
+                )}
+              </div>
+            </Card>
+          )}
+
+          {/* 月度账单卡片 */}
+          {invoices.length > 0 && (
+            <Card
+              className='!rounded-2xl'
+              shadows='always'
+              bordered={false}
+              header={
+                <div className='px-5 py-4 pb-0'>
+                  <div className='flex items-center'>
+                    <Avatar
+                      className='mr-3 shadow-md flex-shrink-0'
+                      color='cyan'
+                    >
+                      <FileText size={24} />
+                    </Avatar>
+                    <div>
+                      <Title heading={5} style={{ margin: 0 }}>
+                        {t('月度账单')}
+                      </Title>
+                      <Text type='tertiary' className='text-sm'>
+                        {t('每月初生成上月账单，可下载 PDF 或 JSON 用于报销')}
+                      </Text>
+                    </div>
+                  </div>
+                </div>
+              }
+            >
+              <div className='space-y-3'>
+                {invoices.map((invoice) => (
+                  <Card key={invoice.id} className='!rounded-2xl'>
+                    <div className='flex items-center justify-between mb-2'>
+                      <Text strong>{invoice.number}</Text>
+                      <Text type='tertiary'>
+                        {timestamp2string(invoice.period_start).slice(0, 7)}
+                      </Text>
+                    </div>
+                    <div className='grid grid-cols-1 md:grid-cols-3 gap-2'>
+                      <Text type='tertiary'>
+                        {t('期初余额')}: {renderQuota(invoice.opening_balance)}
+                      </Text>
+                      <Text type='tertiary'>
+                        {t('本期消费')}: {renderQuota(invoice.usage_quota)}
+                      </Text>
+                      <Text type='tertiary'>
+                        {t('期末余额')}: {renderQuota(invoice.closing_balance)}
+                      </Text>
+                    </div>
+                    <div className='flex gap-2 mt-2'>
+                      <Button
+                        size='small'
+                        onClick={() => downloadInvoice(invoice, 'pdf')}
+                      >
+                        {t('下载 PDF')}
+                      </Button>
+                      <Button
+                        size='small'
+                        type='tertiary'
+                        onClick={() => downloadInvoice(invoice, 'json')}
+                      >
+                        {t('下载 JSON')}
+                      </Button>
+                    </div>
+                  </Card>
+                ))}
+              </div>
+            </Card>
+          )}
+
+          {/* 账期额度卡片 */}
+          {credit && (credit.enabled || credit.account) && (
+            <Card
+              className='!rounded-2xl'
+              shadows='always'
+              bordered={false}
+              header={
+                <div className='px-5 py-4 pb-0'>
+                  <div className='flex items-center'>
+                    <Avatar
+                      className='mr-3 shadow-md flex-shrink-0'
+                      color='indigo'
+                    >
+                      <Landmark size={24} />
+                    </Avatar>
+                    <div>
+                      <Title heading={5} style={{ margin: 0 }}>
+                        {t('账期额度')}
+                      </Title>
+                      <Text type='tertiary' className='text-sm'>
+                        {t(
+                          '开通后余额可透支到账期额度，每月初生成结算单，请在到期前充值付清',
+                        )}
+                      </Text>
+                    </div>
+                  </div>
+                </div>
+              }
+            >
+              <div className='space-y-3'>
+                {credit.account && (
+                  <div className='flex items-center justify-between'>
+                    <Space>
+                      {renderCreditStatus(credit.account.status)}
+                      {credit.account.status === 2 && (
+                        <Text>
+                          {renderQuota(credit.account.credit_limit)} /{' '}
+                          {credit.account.terms_days} {t('天')}
+                        </Text>
+                      )}
+                    </Space>
+                    <Text type='tertiary'>
+                      {t('未付欠款')}: {renderQuota(credit.unpaid_quota)}
+                    </Text>
+                  </div>
+                )}
+                {credit.account?.remark && (
+                  <Text type='tertiary'>
+                    {t('审批备注')}: {credit.account.remark}
+                  </Text>
+                )}
+                {credit.enabled && credit.account?.status !== 2 && (
+                  <div className='space-y-2'>
+                    <InputNumber
+                      prefix={t('申请额度')}
+                      value={creditLimit}
+                      min={0}
+                      onChange={(value) => setCreditLimit(value)}
+                      suffix={renderQuotaWithPrompt(creditLimit)}
+                      style={{ width: '100%' }}
+                    />
+                    <Input
+                      placeholder={t('申请说明，例如公司名称与用途')}
+                      value={creditReason}
+                      onChange={(value) => setCreditReason(value)}
+                    />
+                    <Button
+                      type='primary'
+                      theme='solid'
+                      loading={creditApplying}
+                      onClick={applyCredit}
+                    >
+                      {credit.account?.status === 1
+                        ? t('更新申请')
+                        : t('申请账期额度')}
+                    </Button>
+                  </div>
+                )}
+                {creditStatements.map((statement) => (
+                  <Card key={statement.id} className='!rounded-2xl'>
+                    <div className='flex items-center justify-between mb-2'>
+                      <Text strong>
+                        {timestamp2string(statement.period_start).slice(0, 7)}
+                      </Text>
+                      {statement.status === 2 ? (
+                        <Tag color='green'>{t('已付款')}</Tag>
+                      ) : (
+                        <Tag color='orange'>{t('未付款')}</Tag>
+                      )}
+                    </div>
+                    <div className='grid grid-cols-1 md:grid-cols-3 gap-2'>
+                      <Text type='tertiary'>
+                        {t('应付额度')}: {renderQuota(statement.amount_due)}
+                      </Text>
+                      <Text type='tertiary'>
+                        {t('已付额度')}: {renderQuota(statement.paid_quota)}
+                      </Text>
+                      <Text type='tertiary'>
+                        {t('到期时间')}:{' '}
+                        {timestamp2string(statement.due_time).slice(0, 10)}
+                      </Text>
+                    </div>
+                  </Card>
+                ))}
+              </div>
+            </Card>
+          )}
         </div>
 
         {/* 右侧邀请信息卡片 */}
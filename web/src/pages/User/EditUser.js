@@ -3,6 +3,7 @@ import { useTranslation } from 'react-i18next';
 import {
   API,
   isMobile,
+  isRoot,
   showError,
   showSuccess,
   renderQuota,
@@ -23,6 +24,7 @@ import {
   Col,
   Input,
   InputNumber,
+  Select,
 } from '@douyinfe/semi-ui';
 import {
   IconUser,
@@ -42,6 +44,10 @@ const EditUser = (props) => {
   const [addQuotaModalOpen, setIsModalOpen] = useState(false);
   const [addQuotaLocal, setAddQuotaLocal] = useState('');
   const [groupOptions, setGroupOptions] = useState([]);
+  const [editingRole, setEditingRole] = useState(0);
+  const [adminRoleId, setAdminRoleId] = useState(0);
+  const [adminRoleOptions, setAdminRoleOptions] = useState([]);
+  const [originQuota, setOriginQuota] = useState(0);
   const formApiRef = useRef(null);
 
   const isEdit = Boolean(userId);
@@ -56,6 +62,7 @@ const EditUser = (props) => {
     telegram_id: '',
     email: '',
     quota: 0,
+    quota_reason: '',
     group: 'default',
     remark: '',
   });
@@ -71,6 +78,37 @@ const EditUser = (props) => {
     }
   };
 
+  const fetchAdminRoles = async () => {
+    try {
+      let res = await API.get(`/api/admin_role/`);
+      const { success, message, data } = res.data;
+      if (success) {
+        setAdminRoleOptions([
+          { label: t('默认权限'), value: 0 },
+          ...data.roles.map((r) => ({ label: r.name, value: r.id })),
+        ]);
+      } else {
+        showError(message);
+      }
+    } catch (e) {
+      showError(e.message);
+    }
+  };
+
+  const assignAdminRole = async (roleId) => {
+    const res = await API.post(`/api/admin_role/assign`, {
+      user_id: parseInt(userId),
+      role_id: roleId,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      setAdminRoleId(roleId);
+      showSuccess(t('管理员角色已更新'));
+    } else {
+      showError(message);
+    }
+  };
+
   const handleCancel = () => props.handleClose();
 
   const loadUser = async () => {
@@ -80,6 +118,9 @@ const EditUser = (props) => {
     const { success, message, data } = res.data;
     if (success) {
       data.password = '';
+      setEditingRole(data.role);
+      setAdminRoleId(data.admin_role_id || 0);
+      setOriginQuota(data.quota || 0);
       formApiRef.current?.setValues({ ...getInitValues(), ...data });
     } else {
       showError(message);
@@ -90,6 +131,7 @@ const EditUser = (props) => {
   useEffect(() => {
     loadUser();
     if (userId) fetchGroups();
+    if (userId && isRoot()) fetchAdminRoles();
   }, [props.editingUser.id]);
 
   /* ----------------------- submit ----------------------- */
@@ -270,6 +312,32 @@ const EditUser = (props) => {
                           />
                         </Form.Slot>
                       </Col>
+
+                      {parseInt(values.quota || 0) !== originQuota && (
+                        <Col span={24}>
+                          <Form.Input
+                            field='quota_reason'
+                            label={t('额度调整原因')}
+                            placeholder={t('修改额度时必须填写，会记录在额度调整记录中')}
+                            rules={[{ required: true, message: t('请填写调整原因') }]}
+                            maxLength={255}
+                            showClear
+                          />
+                        </Col>
+                      )}
+
+                      {isRoot() && editingRole === 10 && (
+                        <Col span={24}>
+                          <Form.Slot label={t('管理员角色')}>
+                            <Select
+                              value={adminRoleId}
+                              optionList={adminRoleOptions}
+                              onChange={assignAdminRole}
+                              style={{ width: '100%' }}
+                            />
+                          </Form.Slot>
+                        </Col>
+                      )}
                     </Row>
                   </Card>
                 )}
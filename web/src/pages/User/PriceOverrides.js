@@ -0,0 +1,233 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+import {
+  Button,
+  Col,
+  Form,
+  Modal,
+  Popconfirm,
+  Row,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+
+const { Text } = Typography;
+
+const PriceOverrides = ({ visible, user, handleClose }) => {
+  const { t } = useTranslation();
+  const [overrides, setOverrides] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [submitting, setSubmitting] = useState(false);
+  const formApiRef = useRef(null);
+
+  const loadOverrides = async () => {
+    if (!user?.id) {
+      return;
+    }
+    setLoading(true);
+    const res = await API.get(
+      `/api/price_override/?user_id=${user.id}&p=1&page_size=100`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setOverrides(data.items || []);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  useEffect(() => {
+    if (visible) {
+      loadOverrides();
+    }
+  }, [visible, user?.id]);
+
+  const addOverride = async (values) => {
+    setSubmitting(true);
+    const payload = {
+      user_id: user.id,
+      token_id: parseInt(values.token_id) || 0,
+      model_name: values.model_name,
+      ratio_multiplier: parseFloat(values.ratio_multiplier) || 0,
+      model_price: parseFloat(values.model_price) || 0,
+      completion_ratio: parseFloat(values.completion_ratio) || 0,
+      expired_time: values.expired_time
+        ? Math.floor(new Date(values.expired_time).getTime() / 1000)
+        : -1,
+      remark: values.remark || '',
+    };
+    const res = await API.post('/api/price_override/', payload);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('添加成功'));
+      formApiRef.current?.reset();
+      await loadOverrides();
+    } else {
+      showError(message);
+    }
+    setSubmitting(false);
+  };
+
+  const deleteOverride = async (id) => {
+    const res = await API.delete(`/api/price_override/${id}`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('删除成功'));
+      await loadOverrides();
+    } else {
+      showError(message);
+    }
+  };
+
+  const columns = [
+    {
+      title: 'ID',
+      dataIndex: 'id',
+    },
+    {
+      title: t('令牌'),
+      dataIndex: 'token_id',
+      render: (text) =>
+        text === 0 ? <Tag shape='circle'>{t('全部令牌')}</Tag> : `#${text}`,
+    },
+    {
+      title: t('模型'),
+      dataIndex: 'model_name',
+    },
+    {
+      title: t('价格'),
+      dataIndex: 'ratio_multiplier',
+      render: (text, record) => (
+        <div className='flex flex-col'>
+          {record.model_price > 0 && (
+            <Text>{t('按次价格')}: ${record.model_price}</Text>
+          )}
+          {record.ratio_multiplier > 0 && (
+            <Text>{t('倍率乘数')}: {record.ratio_multiplier}x</Text>
+          )}
+          {record.completion_ratio > 0 && (
+            <Text>{t('补全倍率')}: {record.completion_ratio}</Text>
+          )}
+        </div>
+      ),
+    },
+    {
+      title: t('过期时间'),
+      dataIndex: 'expired_time',
+      render: (text) =>
+        text === -1 ? t('永不过期') : timestamp2string(text),
+    },
+    {
+      title: t('备注'),
+      dataIndex: 'remark',
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Popconfirm
+          title={t('确定是否要删除此价格覆盖？')}
+          onConfirm={() => deleteOverride(record.id)}
+        >
+          <Button type='danger' size='small' theme='light'>
+            {t('删除')}
+          </Button>
+        </Popconfirm>
+      ),
+    },
+  ];
+
+  return (
+    <Modal
+      title={`${t('价格覆盖')} - ${user?.username || ''}`}
+      visible={visible}
+      onCancel={handleClose}
+      footer={null}
+      width={900}
+    >
+      <Text type='tertiary'>
+        {t(
+          '令牌级覆盖优先于用户级覆盖，同一级别内精确模型名优先于前缀匹配（如 gpt-4o*），* 匹配全部模型；设置按次价格后改为按次计费，倍率乘数与分组倍率、时段倍率相乘',
+        )}
+      </Text>
+      <Form
+        className='mt-4'
+        getFormApi={(api) => (formApiRef.current = api)}
+        onSubmit={addOverride}
+      >
+        <Row gutter={12}>
+          <Col span={8}>
+            <Form.Input
+              field='model_name'
+              label={t('模型')}
+              placeholder='gpt-4o, gpt-4o*, *'
+              rules={[{ required: true, message: t('请输入模型名称') }]}
+            />
+          </Col>
+          <Col span={8}>
+            <Form.Input
+              field='token_id'
+              label={t('令牌 ID')}
+              placeholder={t('留空对全部令牌生效')}
+            />
+          </Col>
+          <Col span={8}>
+            <Form.DatePicker
+              field='expired_time'
+              label={t('过期时间')}
+              type='dateTime'
+              placeholder={t('留空永不过期')}
+              style={{ width: '100%' }}
+            />
+          </Col>
+          <Col span={8}>
+            <Form.Input
+              field='ratio_multiplier'
+              label={t('倍率乘数')}
+              placeholder={t('例如 0.8 表示八折')}
+            />
+          </Col>
+          <Col span={8}>
+            <Form.Input
+              field='model_price'
+              label={t('按次价格')}
+              placeholder={t('单位美元，留空按量计费')}
+            />
+          </Col>
+          <Col span={8}>
+            <Form.Input
+              field='completion_ratio'
+              label={t('补全倍率')}
+              placeholder={t('留空沿用模型补全倍率')}
+            />
+          </Col>
+          <Col span={24}>
+            <Form.Input field='remark' label={t('备注')} />
+          </Col>
+        </Row>
+        <Button htmlType='submit' type='primary' loading={submitting}>
+          {t('添加价格覆盖')}
+        </Button>
+      </Form>
+      <Table
+        className='mt-4'
+        columns={columns}
+        dataSource={overrides}
+        loading={loading}
+        rowKey='id'
+        pagination={false}
+        size='small'
+      />
+    </Modal>
+  );
+};
+
+export default PriceOverrides;
@@ -0,0 +1,170 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  API,
+  renderQuota,
+  renderQuotaWithPrompt,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+import {
+  Button,
+  Input,
+  InputNumber,
+  Modal,
+  Select,
+  Space,
+  Table,
+  Tag,
+} from '@douyinfe/semi-ui';
+
+const QuotaAdjustments = ({ visible, user, handleClose, refresh }) => {
+  const { t } = useTranslation();
+  const [adjustments, setAdjustments] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [type, setType] = useState('credit');
+  const [quota, setQuota] = useState(0);
+  const [reason, setReason] = useState('');
+  const [submitting, setSubmitting] = useState(false);
+
+  const loadAdjustments = async () => {
+    if (!user?.id) {
+      return;
+    }
+    setLoading(true);
+    const res = await API.get(
+      `/api/user/quota_adjustment?user_id=${user.id}&p=1`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setAdjustments(data.items || []);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  useEffect(() => {
+    if (visible) {
+      setQuota(0);
+      setReason('');
+      loadAdjustments();
+    }
+  }, [visible, user?.id]);
+
+  const submit = async () => {
+    setSubmitting(true);
+    const res = await API.post('/api/user/quota_adjustment', {
+      user_id: user.id,
+      type,
+      quota,
+      reason,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('调整成功'));
+      setQuota(0);
+      setReason('');
+      await loadAdjustments();
+      refresh && refresh();
+    } else {
+      showError(message);
+    }
+    setSubmitting(false);
+  };
+
+  const renderAdjustmentType = (text) => {
+    switch (text) {
+      case 'credit':
+        return <Tag color='green'>{t('增加')}</Tag>;
+      case 'debit':
+        return <Tag color='red'>{t('扣除')}</Tag>;
+      case 'refund':
+        return <Tag color='blue'>{t('退款')}</Tag>;
+      default:
+        return <Tag>{text}</Tag>;
+    }
+  };
+
+  const columns = [
+    {
+      title: t('时间'),
+      dataIndex: 'created_time',
+      render: (text) => timestamp2string(text),
+    },
+    {
+      title: t('类型'),
+      dataIndex: 'type',
+      render: (text) => renderAdjustmentType(text),
+    },
+    {
+      title: t('额度'),
+      dataIndex: 'quota',
+      render: (text) => renderQuota(text),
+    },
+    {
+      title: t('消费日志'),
+      dataIndex: 'log_id',
+      render: (text) => (text ? `#${text}` : '-'),
+    },
+    {
+      title: t('原因'),
+      dataIndex: 'reason',
+    },
+    {
+      title: t('操作人'),
+      dataIndex: 'admin_username',
+    },
+  ];
+
+  return (
+    <Modal
+      title={`${t('调整额度')} - ${user?.username || ''}`}
+      visible={visible}
+      onCancel={handleClose}
+      footer={null}
+      width={900}
+    >
+      <Space vertical align='start' style={{ width: '100%' }}>
+        <Space>
+          <Select
+            value={type}
+            onChange={setType}
+            optionList={[
+              { label: t('增加'), value: 'credit' },
+              { label: t('扣除'), value: 'debit' },
+            ]}
+            style={{ width: 120 }}
+          />
+          <InputNumber
+            value={quota}
+            min={0}
+            onChange={setQuota}
+            suffix={renderQuotaWithPrompt(quota)}
+            style={{ width: 320 }}
+          />
+        </Space>
+        <Input
+          placeholder={t('调整原因，将显示在用户的日志中')}
+          value={reason}
+          onChange={setReason}
+        />
+        <Button type='primary' loading={submitting} onClick={submit}>
+          {t('确认调整')}
+        </Button>
+      </Space>
+      <Table
+        className='mt-4'
+        columns={columns}
+        dataSource={adjustments}
+        loading={loading}
+        rowKey='id'
+        pagination={false}
+        size='small'
+      />
+    </Modal>
+  );
+};
+
+export default QuotaAdjustments;
@@ -0,0 +1,200 @@
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  API,
+  renderQuota,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+import {
+  Button,
+  Modal,
+  Popconfirm,
+  Select,
+  Space,
+  Switch,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+
+const { Text } = Typography;
+
+const UserSubscriptions = ({ visible, user, handleClose }) => {
+  const { t } = useTranslation();
+  const [plans, setPlans] = useState([]);
+  const [subscriptions, setSubscriptions] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [planId, setPlanId] = useState(undefined);
+  const [autoRenew, setAutoRenew] = useState(false);
+  const [submitting, setSubmitting] = useState(false);
+
+  const loadPlans = async () => {
+    const res = await API.get('/api/subscription/plans');
+    const { success, message, data } = res.data;
+    if (success) {
+      setPlans((data || []).filter((plan) => plan.status === 1));
+    } else {
+      showError(message);
+    }
+  };
+
+  const loadSubscriptions = async () => {
+    if (!user?.id) {
+      return;
+    }
+    setLoading(true);
+    const res = await API.get(
+      `/api/subscription/?user_id=${user.id}&p=1&page_size=100`,
+    );
+    const { success, message, data } = res.data;
+    if (success) {
+      setSubscriptions(data.items || []);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  useEffect(() => {
+    if (visible) {
+      loadPlans();
+      loadSubscriptions();
+    }
+  }, [visible, user?.id]);
+
+  // 管理员开通套餐不扣除用户余额
+  const grant = async () => {
+    if (!planId) {
+      showError(t('请选择套餐'));
+      return;
+    }
+    setSubmitting(true);
+    const res = await API.post('/api/subscription/grant', {
+      user_id: user.id,
+      plan_id: planId,
+      auto_renew: autoRenew,
+    });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('开通成功'));
+      setPlanId(undefined);
+      await loadSubscriptions();
+    } else {
+      showError(message);
+    }
+    setSubmitting(false);
+  };
+
+  const cancel = async (id) => {
+    const res = await API.post(`/api/subscription/${id}/cancel`);
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(t('操作成功'));
+      await loadSubscriptions();
+    } else {
+      showError(message);
+    }
+  };
+
+  const columns = [
+    {
+      title: 'ID',
+      dataIndex: 'id',
+    },
+    {
+      title: t('套餐'),
+      dataIndex: 'plan_name',
+    },
+    {
+      title: t('剩余额度'),
+      dataIndex: 'remaining',
+      render: (text, record) =>
+        `${renderQuota(text)} / ${renderQuota(record.allowance)}`,
+    },
+    {
+      title: t('周期'),
+      dataIndex: 'period_end',
+      render: (text, record) => (
+        <div className='flex flex-col'>
+          <Text>{timestamp2string(record.period_start)}</Text>
+          <Text>{timestamp2string(text)}</Text>
+        </div>
+      ),
+    },
+    {
+      title: t('自动续费'),
+      dataIndex: 'auto_renew',
+      render: (text) => (text ? t('是') : t('否')),
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'status',
+      render: (text) => {
+        switch (text) {
+          case 1:
+            return <Tag color='green'>{t('生效中')}</Tag>;
+          case 2:
+            return <Tag color='grey'>{t('已过期')}</Tag>;
+          default:
+            return <Tag color='red'>{t('已取消')}</Tag>;
+        }
+      },
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) =>
+        record.status === 1 && (
+          <Popconfirm
+            title={t('确定要终止此订阅吗？')}
+            onConfirm={() => cancel(record.id)}
+          >
+            <Button type='danger' size='small' theme='light'>
+              {t('终止')}
+            </Button>
+          </Popconfirm>
+        ),
+    },
+  ];
+
+  return (
+    <Modal
+      title={`${t('订阅套餐')} - ${user?.username || ''}`}
+      visible={visible}
+      onCancel={handleClose}
+      footer={null}
+      width={900}
+    >
+      <Space>
+        <Select
+          placeholder={t('请选择套餐')}
+          value={planId}
+          onChange={setPlanId}
+          optionList={plans.map((plan) => ({
+            label: `${plan.name} (${renderQuota(plan.quota)} / ${plan.duration_days} ${t('天')})`,
+            value: plan.id,
+          }))}
+          style={{ width: 320 }}
+        />
+        <Switch checked={autoRenew} onChange={setAutoRenew} />
+        <Text>{t('自动续费')}</Text>
+        <Button type='primary' loading={submitting} onClick={grant}>
+          {t('开通套餐')}
+        </Button>
+      </Space>
+      <Table
+        className='mt-4'
+        columns={columns}
+        dataSource={subscriptions}
+        loading={loading}
+        rowKey='id'
+        pagination={false}
+        size='small'
+      />
+    </Modal>
+  );
+};
+
+export default UserSubscriptions;